@@ -0,0 +1,47 @@
+package config
+
+/*
+	Here we validate the environment configuration at startup.
+	Missing required variables stop the server before it tries to connect,
+	missing optional ones only degrade the features that need them.
+*/
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RequiredEnvVars must be set for the server to start: database settings and the stock API token.
+var RequiredEnvVars = []string{
+	"DB_HOST",
+	"DB_PORT",
+	"DB_USER",
+	"DB_PASSWORD",
+	"DB_NAME",
+	"DB_SSLMODE",
+	"API_TOKEN",
+}
+
+// OptionalEnvVars enable extra features; each maps to what stops working without it.
+var OptionalEnvVars = map[string]string{
+	"OPENAI_API_KEY":        "AI summary, chat and RAG endpoints will fail",
+	"API_KEY":               "fetch, bulk fetch, delete and metrics refresh endpoints are not authenticated",
+	"SECURE_LOGIN_PASSWORD": "the secure login demo answers 503",
+}
+
+// Validate checks the environment through getenv (usually os.Getenv).
+// It returns the required variables that are missing or blank, in declaration order,
+// and one warning per missing optional variable.
+func Validate(getenv func(string) string) (missing []string, warnings []string) {
+	for _, name := range RequiredEnvVars {
+		if strings.TrimSpace(getenv(name)) == "" {
+			missing = append(missing, name)
+		}
+	}
+	for name, impact := range OptionalEnvVars {
+		if strings.TrimSpace(getenv(name)) == "" {
+			warnings = append(warnings, fmt.Sprintf("%s is not set: %s", name, impact))
+		}
+	}
+	return missing, warnings
+}
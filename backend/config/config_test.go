@@ -0,0 +1,80 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// envFrom builds a getenv function backed by a map
+func envFrom(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func completeEnv() map[string]string {
+	return map[string]string{
+		"DB_HOST":               "localhost",
+		"DB_PORT":               "26257",
+		"DB_USER":               "root",
+		"DB_PASSWORD":           "secret",
+		"DB_NAME":               "stock-market-db",
+		"DB_SSLMODE":            "require",
+		"API_TOKEN":             "token",
+		"OPENAI_API_KEY":        "sk-test",
+		"API_KEY":               "client-key",
+		"SECURE_LOGIN_PASSWORD": "my-demo-secret",
+	}
+}
+
+// TestValidate_AllPresent validates that a complete environment passes without warnings
+func TestValidate_AllPresent(t *testing.T) {
+	missing, warnings := Validate(envFrom(completeEnv()))
+	assert.Empty(t, missing)
+	assert.Empty(t, warnings)
+}
+
+// TestValidate_MissingRequired validates that every missing or blank required variable is reported
+func TestValidate_MissingRequired(t *testing.T) {
+	env := completeEnv()
+	delete(env, "DB_HOST")
+	env["API_TOKEN"] = "   "
+
+	missing, warnings := Validate(envFrom(env))
+	assert.Equal(t, []string{"DB_HOST", "API_TOKEN"}, missing)
+	assert.Empty(t, warnings)
+
+	missing, _ = Validate(envFrom(map[string]string{}))
+	assert.Equal(t, RequiredEnvVars, missing)
+}
+
+// TestValidate_MissingOptional validates that a missing OpenAI key is only a warning
+func TestValidate_MissingOptional(t *testing.T) {
+	env := completeEnv()
+	delete(env, "OPENAI_API_KEY")
+
+	missing, warnings := Validate(envFrom(env))
+	assert.Empty(t, missing)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "OPENAI_API_KEY")
+}
+
+// TestValidate_MissingAPIKey validates that running without API_KEY only warns that mutating routes are open
+func TestValidate_MissingAPIKey(t *testing.T) {
+	env := completeEnv()
+	delete(env, "API_KEY")
+
+	missing, warnings := Validate(envFrom(env))
+	assert.Empty(t, missing)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "API_KEY is not set")
+}
+
+// TestValidate_MissingSecureLoginPassword validates that running without SECURE_LOGIN_PASSWORD warns that the secure login is off
+func TestValidate_MissingSecureLoginPassword(t *testing.T) {
+	env := completeEnv()
+	delete(env, "SECURE_LOGIN_PASSWORD")
+
+	missing, warnings := Validate(envFrom(env))
+	assert.Empty(t, missing)
+	assert.Equal(t, []string{"SECURE_LOGIN_PASSWORD is not set: the secure login demo answers 503"}, warnings)
+}
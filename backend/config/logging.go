@@ -0,0 +1,19 @@
+package config
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// ParseLogLevel reads a LOG_LEVEL value (debug, info, warn or error, case-insensitive).
+// Blank values mean info; unknown values also fall back to info and report ok=false.
+func ParseLogLevel(value string) (level slog.Level, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return slog.LevelInfo, true
+	}
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return slog.LevelInfo, false
+	}
+	return level, true
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseLogLevel validates known levels, the blank default and the fallback for unknown values
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		value string
+		level slog.Level
+		ok    bool
+	}{
+		{"", slog.LevelInfo, true},
+		{"debug", slog.LevelDebug, true},
+		{"WARN", slog.LevelWarn, true},
+		{" error ", slog.LevelError, true},
+		{"verbose", slog.LevelInfo, false},
+	}
+
+	for _, test := range tests {
+		level, ok := ParseLogLevel(test.value)
+		assert.Equal(t, test.level, level, "LOG_LEVEL=%q", test.value)
+		assert.Equal(t, test.ok, ok, "LOG_LEVEL=%q", test.value)
+	}
+}
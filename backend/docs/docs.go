@@ -15,9 +15,27 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/stocks": {
-            "post": {
-                "description": "Retrieves stock data from external API for a specific page and stores in database. Returns the raw API response with stock items and next page token.",
+        "/admin/scoring-weights": {
+            "get": {
+                "description": "Returns the scoring weights recommendations, the summary and comparisons use when no custom weights are given. They start at the built-in defaults (0.4, 0.3, 0.2, 0.1) and can be changed with PUT /admin/scoring-weights.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get the default scoring weights",
+                "responses": {
+                    "200": {
+                        "description": "Current default scoring weights",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ScoringWeights"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replaces the default scoring weights for every later recommendation, summary and comparison request until the server restarts. Each weight must be between 0 and 1, omitted weights count as 0, and all must sum to 1. The cached AI summary and recommendations are cleared. Requires the X-API-Key header when API_KEY is set.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,71 +43,91 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "stocks"
+                    "admin"
                 ],
-                "summary": "Fetch stocks by page number",
+                "summary": "Set the default scoring weights",
                 "parameters": [
                     {
-                        "description": "Request body with page number (integer, required)",
+                        "type": "string",
+                        "description": "API key, required when the server has API_KEY set",
+                        "name": "X-API-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "New default weights",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.PageRequest"
+                            "$ref": "#/definitions/handlers.ScoringWeights"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully fetched stock data from external API",
+                        "description": "Weights stored",
                         "schema": {
-                            "$ref": "#/definitions/models.ApiResponse"
+                            "$ref": "#/definitions/handlers.ScoringWeights"
                         }
                     },
                     "400": {
-                        "description": "Bad request - invalid JSON format, missing page field, or invalid page number",
+                        "description": "Bad request - invalid JSON, a weight outside 0-1 or weights not summing to 1",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error occurred",
+                    "401": {
+                        "description": "Missing or invalid API key",
                         "schema": {
-                            "$ref": "#/definitions/models.GenericErrorResponse"
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/stocks/actions": {
+        "/ai/usage": {
             "get": {
-                "description": "Retrieves a list of all unique action types found in the stock ratings database, sorted alphabetically. Used for populating filter dropdowns and ensuring UI reflects actual data.",
+                "description": "Returns the OpenAI calls and tokens used by the summary, chat (including streaming) and SQL generation features since the server started, with an estimated cost at AI_COST_PER_1K_TOKENS USD per 1,000 tokens (default 0.0004).",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "stocks"
+                    "ai-analysis"
                 ],
-                "summary": "Get all available stock actions",
+                "summary": "Get AI token usage",
                 "responses": {
                     "200": {
-                        "description": "Successfully retrieved list of unique actions",
+                        "description": "Token usage totals per feature",
                         "schema": {
-                            "$ref": "#/definitions/handlers.ActionsResponse"
+                            "$ref": "#/definitions/handlers.AIUsageResponse"
                         }
-                    },
-                    "500": {
-                        "description": "Internal server error occurred",
+                    }
+                }
+            }
+        },
+        "/debug/external-status": {
+            "get": {
+                "description": "Returns the state of the circuit breaker around the external stock API. After EXTERNAL_BREAKER_THRESHOLD consecutive failures (default 5) it opens and fetches fail fast for EXTERNAL_BREAKER_COOLDOWN (default 30s), then one probe request decides whether it closes again.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "Get the external API circuit breaker state",
+                "responses": {
+                    "200": {
+                        "description": "Current circuit breaker state",
                         "schema": {
-                            "$ref": "#/definitions/models.GenericErrorResponse"
+                            "$ref": "#/definitions/handlers.ExternalStatusResponse"
                         }
                     }
                 }
             }
         },
-        "/stocks/bulk": {
+        "/security/bulk-timing-attack": {
             "post": {
-                "description": "Clears existing database data, then fetches stock data from external API for a range of pages using parallel processing. Returns summary statistics of the operation.",
+                "description": "Exploits timing attack vulnerability by testing individual characters and combinations, measuring response times to discover password character by character. charset defaults to A-Za-z0-9 and may contain symbols or non-ASCII characters; inter_attempt_delay_ms (0-1000, default 20) sets the pause between attempts. samples (1-10, default 1) measures each candidate several times and ranks candidates by their median server duration.",
                 "consumes": [
                     "application/json"
                 ],
@@ -97,45 +135,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "stocks"
+                    "security-demo"
                 ],
-                "summary": "Fetch stocks in bulk for page range with parallel processing",
+                "summary": "Character-by-Character Timing Attack",
                 "parameters": [
                     {
-                        "description": "Request body with start_page and end_page (integers, both required, max range 1,000,000)",
+                        "description": "Base password for character-by-character timing attack",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.BulkPageRequest"
+                            "$ref": "#/definitions/handlers.BulkTimingAttackRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully processed bulk stock data fetch with parallel processing",
+                        "description": "Character-by-character timing attack results",
                         "schema": {
-                            "$ref": "#/definitions/models.BulkResponse"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Bad request - invalid JSON, negative pages, start \u003e end, or range too large",
-                        "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error occurred",
+                        "description": "Bad request",
                         "schema": {
-                            "$ref": "#/definitions/models.GenericErrorResponse"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/stocks/chat": {
+        "/security/secure-login": {
             "post": {
-                "description": "Interactive chat with gpt-4.1-nano that can query the database for specific stock information and provide personalized analysis based on actual data.",
+                "description": "Compares the submitted password against the configured secret with crypto/subtle.ConstantTimeCompare and always answers after the same fixed delay, so response times reveal nothing about how much of the password matched. Answers 503 when SECURE_LOGIN_PASSWORD is not set.",
                 "consumes": [
                     "application/json"
                 ],
@@ -143,71 +179,125 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "ai-analysis"
+                    "security-demo"
                 ],
-                "summary": "Chat with AI about stock market with database context",
+                "summary": "Constant-Time Secure Login",
                 "parameters": [
                     {
-                        "description": "Chat message from user",
+                        "description": "Password to check",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.ChatRequest"
+                            "$ref": "#/definitions/handlers.PasswordOnlyRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully generated AI chat response with database context",
+                        "description": "Password accepted",
                         "schema": {
-                            "$ref": "#/definitions/handlers.ChatResponse"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Bad request - missing message",
+                        "description": "Bad request",
                         "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
-                    "500": {
-                        "description": "Internal server error or OpenAI API error",
+                    "401": {
+                        "description": "Invalid password",
                         "schema": {
-                            "$ref": "#/definitions/models.GenericErrorResponse"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "SECURE_LOGIN_PASSWORD is not set",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/stocks/filter-options": {
+        "/security/timing-attack-info": {
             "get": {
-                "description": "Retrieves filter options including actions, ratings from database",
+                "description": "Provides educational information about timing attacks and how they work",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "stocks"
+                    "security-demo"
                 ],
-                "summary": "Get all available filter options",
+                "summary": "Timing Attack Information",
                 "responses": {
                     "200": {
-                        "description": "Successfully retrieved filter options",
+                        "description": "Timing attack information",
                         "schema": {
-                            "$ref": "#/definitions/handlers.FilterOptionsResponse"
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/security/timing-attack-login": {
+            "post": {
+                "description": "Performs a timing attack by making requests to https://api.karenai.click/swechallenge/login and measuring response times. This is for educational purposes only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "security-demo"
+                ],
+                "summary": "Timing Attack Against External API",
+                "parameters": [
+                    {
+                        "description": "Login credentials for timing attack",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TimingAttackRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Timing attack attempt completed",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TimingAttackResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error occurred",
+                    "400": {
+                        "description": "Bad request - invalid JSON or missing fields",
                         "schema": {
-                            "$ref": "#/definitions/models.GenericErrorResponse"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/stocks/list": {
+        "/stocks": {
             "post": {
-                "description": "Retrieves stored stock ratings with pagination support, ordered by creation date (newest first). Returns both data and pagination metadata.",
+                "description": "Retrieves stock data from external API for a specific page and stores in database. Returns the raw API response with stock items and next page token, plus new_count (items inserted) and duplicate_count (items already stored).",
                 "consumes": [
                     "application/json"
                 ],
@@ -217,27 +307,27 @@ const docTemplate = `{
                 "tags": [
                     "stocks"
                 ],
-                "summary": "Get paginated stock ratings from database",
+                "summary": "Fetch stocks by page number",
                 "parameters": [
                     {
-                        "description": "Request body with page_number (integer, min 1) and page_length (integer, 1-1000)",
+                        "description": "Request body with page number (integer, required)",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.PaginationRequest"
+                            "$ref": "#/definitions/models.PageRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully retrieved paginated stock ratings with metadata",
+                        "description": "Successfully fetched stock data from external API",
                         "schema": {
-                            "$ref": "#/definitions/models.PaginatedResponse"
+                            "$ref": "#/definitions/handlers.PageStoreResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad request - invalid JSON, page_number \u003c= 0, or page_length not between 1-1000",
+                        "description": "Bad request - invalid JSON format, missing page field, or invalid page number",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -247,25 +337,53 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.GenericErrorResponse"
                         }
+                    },
+                    "504": {
+                        "description": "External API did not respond within EXTERNAL_API_TIMEOUT_MS",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/stocks/metrics": {
+        "/stocks/actions": {
             "get": {
-                "description": "Analyzes all stored stock ratings using parallel processing to provide comprehensive market insights including sentiment analysis, target price changes, rating distributions, top brokerages, most active stocks, and recent activity trends.",
+                "description": "Retrieves a list of all unique action types found in the stock ratings database, sorted alphabetically. Used for populating filter dropdowns and ensuring UI reflects actual data. The whole list is returned unless limit or offset is given; total is always the number of distinct actions.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "analytics"
+                    "stocks"
+                ],
+                "summary": "Get all available stock actions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Number of actions to return (1-1000), pages the list when given",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of actions to skip (min 0), pages the list when given",
+                        "name": "offset",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get comprehensive stock market analytics and metrics",
                 "responses": {
                     "200": {
-                        "description": "Successfully calculated comprehensive market metrics and analytics",
+                        "description": "Successfully retrieved list of unique actions",
                         "schema": {
-                            "$ref": "#/definitions/models.MetricsResponse"
+                            "$ref": "#/definitions/handlers.ActionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid limit or offset parameter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
@@ -277,40 +395,40 @@ const docTemplate = `{
                 }
             }
         },
-        "/stocks/recommendations": {
+        "/stocks/brokerage/{name}": {
             "get": {
-                "description": "Analyzes all stock ratings data using configurable weighted algorithms to provide ranked investment recommendations. Considers target price changes, rating improvements, analyst sentiment, and market trends.",
+                "description": "Returns total actions, upgrades vs downgrades, distinct tickers covered, the average target price change percent (from target_from to target_to, ignoring ratings without both numeric prices) and the most recent action time for one brokerage. The name is matched case-insensitively; URL-encode spaces (e.g. Goldman%20Sachs).",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "recommendations"
+                    "analytics"
                 ],
-                "summary": "Get quantitative stock investment recommendations",
+                "summary": "Get activity statistics for a brokerage",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Number of recommendations to return (3, 5, 10, 15, 20)",
-                        "name": "limit",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Brokerage name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully generated stock recommendations with scoring and analysis",
+                        "description": "Successfully calculated brokerage statistics",
                         "schema": {
-                            "$ref": "#/definitions/handlers.RecommendationsResponse"
+                            "$ref": "#/definitions/handlers.BrokerageStats"
                         }
                     },
-                    "400": {
-                        "description": "Bad request - invalid limit parameter",
+                    "404": {
+                        "description": "No ratings found for brokerage",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error occurred during analysis",
+                        "description": "Internal server error occurred",
                         "schema": {
                             "$ref": "#/definitions/models.GenericErrorResponse"
                         }
@@ -318,9 +436,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/stocks/search": {
+        "/stocks/bulk": {
             "post": {
-                "description": "Searches through stock ratings using filters including search term, action, ratings, and target price ranges.",
+                "description": "Fetches stock data from external API for a range of pages using parallel processing and adds it to the database, skipping ratings already stored. By default the fetch runs as a background job: the response is 202 with a job_id whose progress is reported by GET /stocks/bulk/{job_id}/status. With wait=true the request blocks until the fetch is done and returns the summary below. Existing data is deleted first only when confirm_clear is true; cleared tells whether that happened. total_inserted and total_duplicates count the rows actually inserted and the rows skipped because they were already stored. inserted repeats total_inserted and duplicates_skipped adds total_duplicates and duplicates_dropped, so inserted + duplicates_skipped = total_stocks. Returns summary statistics of the operation and the deduplicated stocks fetched (capped by BULK_RESPONSE_LIMIT, default 5,000, with stocks_truncated=true when the cap is hit). If the client disconnects, fetching stops and the response reports cancelled=true with the partial count. If the deadline (BULK_DEADLINE_SECONDS or deadline_seconds) fires, fetching stops, already fetched stocks are stored and the response reports timed_out=true with pages_processed. Only one bulk operation runs at a time: a request made while another one (or a background job) is running gets 409 Conflict. An optional Idempotency-Key header de-dupes retries: a request repeating a key gets the response of the first one (409 while it still runs), server errors are not remembered.",
                 "consumes": [
                     "application/json"
                 ],
@@ -330,33 +448,58 @@ const docTemplate = `{
                 "tags": [
                     "stocks"
                 ],
-                "summary": "Search stock ratings with filters",
+                "summary": "Fetch stocks in bulk for page range with parallel processing",
                 "parameters": [
                     {
-                        "description": "Search parameters with filters",
+                        "description": "Request body with start_page and end_page (integers, both required, max range 1,000,000), optional confirm_clear, optional batch_size/max_concurrent that can only lower BULK_BATCH_SIZE/BULK_MAX_CONCURRENT, and optional deadline_seconds that can only shorten BULK_DEADLINE_SECONDS",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.AdvancedSearchRequest"
+                            "$ref": "#/definitions/models.BulkPageRequest"
                         }
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Block until the fetch is done instead of starting a background job",
+                        "name": "wait",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retries with the same key within BULK_JOB_TTL get the first response instead of starting another fetch",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully retrieved filtered stock ratings",
+                        "description": "Successfully processed bulk stock data fetch with parallel processing (wait=true)",
                         "schema": {
-                            "$ref": "#/definitions/models.PaginatedResponse"
+                            "$ref": "#/definitions/models.BulkResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Bulk fetch started as a background job",
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkJobResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Bad request - invalid JSON, invalid wait, negative pages, start \u003e end, or range too large",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Another bulk operation is already in progress",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Internal server error occurred",
                         "schema": {
                             "$ref": "#/definitions/models.GenericErrorResponse"
                         }
@@ -364,257 +507,2231 @@ const docTemplate = `{
                 }
             }
         },
-        "/stocks/summary": {
+        "/stocks/bulk/{job_id}/status": {
             "get": {
-                "description": "Uses gpt-4.1-nano to analyze current stock recommendations and generate a comprehensive natural language summary of market trends, top picks, and investment insights.",
+                "description": "Returns the progress of a bulk fetch started without wait=true: pages_processed out of pages_total, pages_with_data and status (running, done or error). Done jobs include the same result a synchronous bulk fetch returns, failed jobs include error. Finished jobs are kept for BULK_JOB_TTL (default 1h).",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "ai-analysis"
+                    "stocks"
+                ],
+                "summary": "Get bulk fetch job status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID returned by POST /stocks/bulk",
+                        "name": "job_id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get AI-generated market summary",
                 "responses": {
                     "200": {
-                        "description": "Successfully generated AI market summary",
+                        "description": "Current job status",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SummaryResponse"
+                            "$ref": "#/definitions/models.BulkJobStatusResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error or OpenAI API error",
+                    "404": {
+                        "description": "Unknown or expired job",
                         "schema": {
-                            "$ref": "#/definitions/models.GenericErrorResponse"
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.ActionsResponse": {
-            "type": "object",
-            "properties": {
-                "actions": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    },
-                    "example": [
-                        "initiated by",
-                        "target raised by",
-                        "target lowered by",
-                        "reiterated by",
-                        "upgraded"
-                    ]
-                }
-            }
         },
-        "handlers.AdvancedSearchRequest": {
-            "type": "object",
-            "properties": {
-                "action": {
-                    "type": "string"
-                },
-                "page_length": {
-                    "type": "integer"
-                },
-                "page_number": {
-                    "type": "integer"
-                },
-                "rating_from": {
-                    "type": "string"
-                },
-                "rating_to": {
-                    "type": "string"
-                },
-                "search_term": {
-                    "type": "string"
-                },
-                "target_from_max": {
-                    "type": "number"
-                },
-                "target_from_min": {
-                    "type": "number"
-                },
-                "target_to_max": {
-                    "type": "number"
-                },
+        "/stocks/chat": {
+            "post": {
+                "description": "Interactive chat with the configured OpenAI model (OPENAI_MODEL, default gpt-4.1-nano) that can query the database for specific stock information and provide personalized analysis based on actual data. The optional model field picks another allowed model for the answer: gpt-4.1-nano, gpt-4.1-mini, gpt-4.1, gpt-4o-mini or gpt-4o. With session_id the conversation memory is stored server-side (expiring after CHAT_SESSION_TTL) and any client-supplied conversation_memory is ignored. The message may be at most CHAT_MAX_MESSAGE_LENGTH characters (default 2000) and only the last 10 recent_messages are used.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ai-analysis"
+                ],
+                "summary": "Chat with AI about stock market with database context",
+                "parameters": [
+                    {
+                        "description": "Chat message from user",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChatRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include the AI-generated SQL and its row count in the response",
+                        "name": "debug",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully generated AI chat response with database context",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChatResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - missing or too long message, invalid model or invalid debug parameter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "AI rate limit exceeded (see Retry-After) or OpenAI still rate limiting after retries",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error or OpenAI API error",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "AI features unavailable: OPENAI_API_KEY not configured",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "AI-generated database query timed out (RAG_SQL_TIMEOUT_MS)",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/chat/stream": {
+            "post": {
+                "description": "Same request and RAG pipeline as /stocks/chat, but the answer is relayed as text/event-stream while OpenAI generates it. Each \"delta\" event carries {\"content\": \"...\"}; a final \"done\" event carries tokens_used, generated_at, updated_memory and session_id. Errors after streaming started arrive as an \"error\" event; earlier errors use the regular JSON error responses.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "ai-analysis"
+                ],
+                "summary": "Chat with AI about stock market, streaming the answer",
+                "parameters": [
+                    {
+                        "description": "Chat message from user",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChatRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of delta events followed by a done event",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - missing or too long message or invalid model",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "AI rate limit exceeded (see Retry-After) or OpenAI still rate limiting after retries",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error or OpenAI API error",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "AI features unavailable: OPENAI_API_KEY not configured",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "AI-generated database query timed out (RAG_SQL_TIMEOUT_MS)",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/compare": {
+            "get": {
+                "description": "For each ticker returns the latest rating, target and brokerage, the recommendation score with default weights (same scoring as /stocks/recommendations), the latest target price change percent and the number of stored ratings. Tickers are case-insensitive and duplicates are ignored. When any ticker has no ratings the request fails with 404 and unknown_tickers lists them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "Compare tickers side by side",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated tickers to compare (2-5), e.g. AAPL,MSFT",
+                        "name": "tickers",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully compared tickers",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CompareResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - missing, invalid or too many tickers",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Some tickers have no ratings, listed in unknown_tickers",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/consensus": {
+            "get": {
+                "description": "Groups every stored rating by ticker and counts bullish, neutral and bearish ratings across all brokerages (same buckets as the market sentiment metric). Also returns the average, minimum, maximum and spread of the numeric target_to prices. Tickers are ordered by coverage (number of ratings), highest first. Target price fields are null when a ticker has no numeric targets.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "Get analyst consensus per ticker",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of tickers to return (1-50)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully aggregated analyst consensus",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ConsensusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid limit parameter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/count": {
+            "post": {
+                "description": "Accepts the same filters as /stocks/search and returns only how many ratings match, without fetching them. Pagination fields are ignored. The count equals total_records of the matching search.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Count filtered stock ratings",
+                "parameters": [
+                    {
+                        "description": "Search filters, pagination fields are ignored",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AdvancedSearchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of matching ratings",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CountResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/export": {
+            "post": {
+                "description": "Accepts the same filters as /stocks/search and streams all matching ratings as a text/csv attachment, one row per rating, newest first. Pagination fields are ignored. Target prices are exported as stored (e.g. \"$150.00\").",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Export filtered stock ratings as CSV",
+                "parameters": [
+                    {
+                        "description": "Search filters, pagination fields are ignored",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AdvancedSearchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "CSV file with one row per matching rating",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/filter-options": {
+            "get": {
+                "description": "Retrieves filter options including actions, ratings and brokerages from database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get all available filter options",
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved filter options",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FilterOptionsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/import": {
+            "post": {
+                "description": "Validates and stores the given ratings through the same batch insert as bulk fetches, skipping ratings already stored. Each item needs a ticker, a company and target_from and target_to prices that are non-negative numbers. By default a single invalid item rejects the whole request with 400 and the per-item errors; with partial=true the valid items are stored and the invalid ones reported in errors.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Import stock ratings from a JSON payload",
+                "parameters": [
+                    {
+                        "description": "Ratings to store, at most 10,000",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ImportRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Store the valid items even when some are invalid",
+                        "name": "partial",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Inserted, skipped and invalid counts",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, invalid partial, no items, too many items, or invalid items without partial=true",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/list": {
+            "post": {
+                "description": "Retrieves stored stock ratings with pagination support, ordered by creation date (newest first) unless sort_by/sort_order are given. Returns both data and pagination metadata. A page_number past the last page returns empty data with has_next=false and out_of_range=true. For deep paging, pass after_id and after_created_at from the previous response's next_cursor to use keyset pagination instead of OFFSET.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get paginated stock ratings from database",
+                "parameters": [
+                    {
+                        "description": "Request body with page_number (integer, min 1), page_length (integer, 1-1000) and optional sort_by (created_at, time, ticker, company) and sort_order (asc, desc)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PaginationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved paginated stock ratings with metadata",
+                        "schema": {
+                            "$ref": "#/definitions/models.PaginatedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, page_number \u003c= 0, page_length not between 1-1000, unknown sort_by/sort_order, or incomplete cursor",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/metrics": {
+            "get": {
+                "description": "Analyzes all stored stock ratings using parallel processing to provide comprehensive market insights including sentiment analysis, target price changes, rating distributions, top brokerages, most active stocks, and recent activity trends. target_changes classifies ratings by their action text, while target_price_stats compares the numeric previous and new target prices (ratings with a non-numeric target are left out). data_freshness reports the oldest and newest report time and created_at and the hours since the last ingest, to spot a stalled ingest pipeline. rating_distribution lists the 10 most common ratings with their percentage of the total, the remaining ratings are summed into other. Results are cached in memory for METRICS_CACHE_TTL (default 30s); cached and cache_age_seconds tell whether the response came from the cache. Once the cache expires the stale metrics are served immediately with refreshing=true while a single background recompute runs; the request that starts the recompute gets 202 Accepted. Every response carries a weak ETag for the metrics version (total_records and generated_at); sending it back in If-None-Match returns 304 Not Modified without a body while the version is unchanged. The filters of /stocks/search (ticker, brokerage, time range and the others) can be passed as query params to scope every metric to the matching ratings; scoped metrics are always computed live and never cached.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "Get comprehensive stock market analytics and metrics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only ratings of this ticker",
+                        "name": "ticker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ratings of this brokerage (case-insensitive)",
+                        "name": "brokerage",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ratings of brokerages whose name contains this text",
+                        "name": "brokerage_contains",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ratings of this sector",
+                        "name": "sector",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ratings with this action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ratings with this previous rating",
+                        "name": "rating_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ratings with this new rating",
+                        "name": "rating_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ratings reported at or after this time (RFC3339)",
+                        "name": "time_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only ratings reported at or before this time (RFC3339)",
+                        "name": "time_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully calculated comprehensive market metrics and analytics",
+                        "schema": {
+                            "$ref": "#/definitions/models.MetricsResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Stale metrics served, background refresh started",
+                        "schema": {
+                            "$ref": "#/definitions/models.MetricsResponse"
+                        }
+                    },
+                    "304": {
+                        "description": "Metrics unchanged since the ETag in If-None-Match"
+                    },
+                    "400": {
+                        "description": "Invalid filter parameters",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/metrics/refresh": {
+            "post": {
+                "description": "Recomputes all market metrics synchronously, bypassing the cache, and stores the result so subsequent GET /stocks/metrics calls serve the fresh values.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "Force a refresh of the stock market metrics",
+                "responses": {
+                    "200": {
+                        "description": "Metrics recomputed and cached",
+                        "schema": {
+                            "$ref": "#/definitions/models.MetricsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/random": {
+            "get": {
+                "description": "Returns count randomly chosen stock ratings, e.g. to populate UI mockups in demos. Every call returns a new sample.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get a random sample of stock ratings",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of ratings to return (1-100)",
+                        "name": "count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Random sample of stock ratings",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RandomStocksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid count parameter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/recommendations": {
+            "get": {
+                "description": "Analyzes all stock ratings data using configurable weighted algorithms to provide ranked investment recommendations. Considers target price changes, rating improvements, analyst sentiment, and market trends. Requests without custom weights, days or only are served from the recommendations_cache table while it is fresh (cached=true, generated_at is when it was computed); it is refreshed after every bulk ingest or with POST /stocks/recommendations/refresh, cleared when ratings are fetched, imported, edited or deleted, and expires after RECOMMENDATIONS_CACHE_TTL (default 15m).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "recommendations"
+                ],
+                "summary": "Get quantitative stock investment recommendations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of recommendations to return (3, 5, 10, 15, 20)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Custom weight for target price changes (0-1). When any weight is given, omitted weights count as 0 and all must sum to 1",
+                        "name": "target_weight",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Custom weight for rating analysis (0-1)",
+                        "name": "rating_weight",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Custom weight for action analysis (0-1)",
+                        "name": "action_weight",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Custom weight for recent activity (0-1)",
+                        "name": "timing_weight",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "default": 5,
+                        "description": "Minimum score (0-10) a stock needs to be recommended",
+                        "name": "min_score",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only analyze ratings from the last N days (1-3650), all-time when omitted",
+                        "name": "days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page of recommendations (min 1). When page_number or page_length is given, limit is ignored and pagination is returned",
+                        "name": "page_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Recommendations per page (1-1000)",
+                        "name": "page_length",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated tickers to leave out of the results, e.g. tickers already held (max 100)",
+                        "name": "exclude",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated watchlist of tickers, only these are analyzed (max 100)",
+                        "name": "only",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Append the score breakdown to each reason",
+                        "name": "explain",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully generated stock recommendations with scoring and analysis",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RecommendationsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid limit, weight, min_score, days, ticker list, explain or pagination parameters",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred during analysis",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/recommendations/refresh": {
+            "post": {
+                "description": "Scores every ticker with the default scoring weights and replaces the recommendations_cache table, which GET /stocks/recommendations serves for requests without custom weights, days or only. The cache is also refreshed after every bulk ingest, cleared when ratings are fetched, imported, edited or deleted, and expires after RECOMMENDATIONS_CACHE_TTL (default 15m).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "recommendations"
+                ],
+                "summary": "Force a refresh of the cached recommendations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key, required when the server has API_KEY set",
+                        "name": "X-API-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recommendations recomputed and cached",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RecommendationsRefreshResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to recompute or store the recommendations",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/search": {
+            "post": {
+                "description": "Searches through stock ratings using filters including search term, action, brokerage (exact or brokerage_contains), sector, ratings, target price ranges, and a report time range (time_from/time_to, RFC3339). Target price filters only match rows whose target is a plain number such as \"$1,250.00\"; other values are skipped instead of failing the search.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Search stock ratings with filters",
+                "parameters": [
+                    {
+                        "description": "Search parameters with filters",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AdvancedSearchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved filtered stock ratings",
+                        "schema": {
+                            "$ref": "#/definitions/models.PaginatedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/sectors": {
+            "get": {
+                "description": "Retrieves the distinct sectors of stored stock ratings, sorted alphabetically. Ratings without a sector (older rows or API items without one) are not included.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get all available sectors",
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved list of sectors",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SectorsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/stats/daily": {
+            "get": {
+                "description": "Returns total actions, upgrades, downgrades and the average target price change percent (ignoring ratings without both numeric prices) per bucket of analyst report time, between from and to inclusive. Buckets start on the day, the Monday of the week or the first of the month, following granularity; buckets without activity are included with zeros so the series has no gaps. Defaults to the last 30 days by day.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "Get analyst activity over time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day, YYYY-MM-DD (default: 29 days before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day, YYYY-MM-DD (default: today, UTC)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "day",
+                            "week",
+                            "month"
+                        ],
+                        "type": "string",
+                        "default": "day",
+                        "description": "Bucket size",
+                        "name": "granularity",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Activity time series",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DailyStatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid date, from after to, range over 3660 days, or unknown granularity",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/summary": {
+            "get": {
+                "description": "Uses the configured OpenAI model (OPENAI_MODEL, default gpt-4.1-nano) to analyze current stock recommendations and generate a comprehensive natural language summary of market trends, top picks, and investment insights. When the AI call fails (OpenAI down or OPENAI_API_KEY missing) or ai=false is passed, a templated summary of the top picks is returned instead with source=fallback and tokens_used=0. Summaries are cached for SUMMARY_CACHE_TTL (default 5m) until new stock data is stored; cached responses have from_cache=true and cached_at, and don't count against the AI rate limit.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ai-analysis"
+                ],
+                "summary": "Get AI-generated market summary",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Set to false to skip OpenAI and get the templated summary",
+                        "name": "ai",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully generated AI market summary",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SummaryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid ai parameter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "AI rate limit exceeded (see Retry-After) or OpenAI still rate limiting after retries",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error or OpenAI API error",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/ticker/{ticker}": {
+            "get": {
+                "description": "Retrieves all stock ratings for a ticker ordered by analyst report time (newest first), with optional pagination. The ticker is matched case-insensitively. Metadata includes the number of distinct brokerages covering the ticker.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get full rating history for a ticker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ticker symbol (2-5 letters)",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number (min 1)",
+                        "name": "page_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Records per page (1-1000)",
+                        "name": "page_length",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved rating history with pagination and coverage metadata",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid ticker or pagination parameters",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No ratings found for ticker",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/trending": {
+            "get": {
+                "description": "Counts the analyst actions stored per ticker in the last days days (by created_at) and returns the most active tickers first, with their upgrade/downgrade balance and latest rating. Ties are ordered by ticker.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "Get trending tickers",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 7,
+                        "description": "Recent window in days (1-365)",
+                        "name": "days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of tickers to return (1-50)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully ranked trending tickers",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TrendingResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid days or limit parameter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/{id}": {
+            "get": {
+                "description": "Retrieves one stock rating by its numeric ID, e.g. for a detail view after selecting a row of the list.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get a stock rating by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Stock rating ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stock rating found",
+                        "schema": {
+                            "$ref": "#/definitions/models.StockRatings"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - id is not a positive integer",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No stock rating with this ID",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Updates only the fields given with a non-empty value (e.g. a wrong target price from the external feed) and returns the updated rating. Target prices are normalized like ingested ones and must be non-negative numbers; the ticker is stored uppercase.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Update a stock rating by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Stock rating ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change, empty or missing fields are left as they are",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.StockRatings"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated stock rating",
+                        "schema": {
+                            "$ref": "#/definitions/models.StockRatings"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid id, invalid JSON, invalid ticker or price, or no fields to update",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No stock rating with this ID",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The corrected rating would duplicate another stored rating",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stocks/{ticker}": {
+            "delete": {
+                "description": "Deletes every stock rating whose ticker matches the path parameter (case-insensitive). The ticker must be 2-5 letters.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Delete all ratings for a ticker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ticker symbol (2-5 letters)",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ratings deleted, returns the number of rows removed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid ticker",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No ratings found for ticker",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ws": {
+            "get": {
+                "description": "Upgrades to a WebSocket and sends every stock rating inserted afterwards (by POST /stocks or a bulk fetch) as a JSON text message shaped like models.StockRatings, without its id. Ratings skipped as duplicates are not sent. Clients that fall more than 256 ratings behind lose the oldest ones. Messages sent by the client are ignored.",
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Stream newly ingested ratings",
+                "responses": {
+                    "101": {
+                        "description": "Switching protocols, ratings follow as messages",
+                        "schema": {
+                            "$ref": "#/definitions/models.StockRatings"
+                        }
+                    },
+                    "400": {
+                        "description": "Not a WebSocket handshake",
+                        "schema": {
+                            "$ref": "#/definitions/models.GenericErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.AIEndpointUsage": {
+            "type": "object",
+            "properties": {
+                "calls": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "tokens": {
+                    "type": "integer",
+                    "example": 2940
+                }
+            }
+        },
+        "handlers.AIUsageResponse": {
+            "type": "object",
+            "properties": {
+                "cost_per_1k_tokens": {
+                    "type": "number",
+                    "example": 0.0004
+                },
+                "endpoints": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/handlers.AIEndpointUsage"
+                    }
+                },
+                "estimated_cost_usd": {
+                    "type": "number",
+                    "example": 0.00294
+                },
+                "since": {
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "total_calls": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "total_tokens": {
+                    "type": "integer",
+                    "example": 7350
+                }
+            }
+        },
+        "handlers.ActionsResponse": {
+            "type": "object",
+            "properties": {
+                "actions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "initiated by",
+                        "target raised by",
+                        "target lowered by",
+                        "reiterated by",
+                        "upgraded"
+                    ]
+                },
+                "limit": {
+                    "description": "Set when the list is paged",
+                    "type": "integer",
+                    "example": 100
+                },
+                "offset": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "total": {
+                    "description": "Number of distinct actions, regardless of limit/offset",
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        },
+        "handlers.AdvancedSearchRequest": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "brokerage": {
+                    "description": "Brokerage matches the analyst firm exactly (case-insensitive), BrokerageContains matches part of its name",
+                    "type": "string"
+                },
+                "brokerage_contains": {
+                    "type": "string"
+                },
+                "page_length": {
+                    "type": "integer"
+                },
+                "page_number": {
+                    "type": "integer"
+                },
+                "rating_from": {
+                    "type": "string"
+                },
+                "rating_to": {
+                    "type": "string"
+                },
+                "search_term": {
+                    "type": "string"
+                },
+                "sector": {
+                    "type": "string"
+                },
+                "target_from_max": {
+                    "type": "number"
+                },
+                "target_from_min": {
+                    "type": "number"
+                },
+                "target_to_max": {
+                    "type": "number"
+                },
                 "target_to_min": {
                     "type": "number"
+                },
+                "ticker": {
+                    "description": "Ticker matches the ticker exactly (case-insensitive)",
+                    "type": "string"
+                },
+                "time_from": {
+                    "description": "TimeFrom and TimeTo restrict results by analyst report time, RFC3339 (e.g. \"2025-01-31T00:00:00Z\")",
+                    "type": "string"
+                },
+                "time_to": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.BrokerageStats": {
+            "type": "object",
+            "properties": {
+                "avg_target_change_percent": {
+                    "type": "number",
+                    "example": 6.25
+                },
+                "brokerage": {
+                    "type": "string",
+                    "example": "Goldman Sachs"
+                },
+                "downgrades": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "most_recent_action_time": {
+                    "type": "string",
+                    "example": "2025-01-15T10:30:00Z"
+                },
+                "tickers_covered": {
+                    "type": "integer",
+                    "example": 25
+                },
+                "total_actions": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "upgrades": {
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "handlers.BulkTimingAttackRequest": {
+            "type": "object",
+            "required": [
+                "password"
+            ],
+            "properties": {
+                "charset": {
+                    "type": "string",
+                    "example": "abc123!@#"
+                },
+                "inter_attempt_delay_ms": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "password": {
+                    "type": "string",
+                    "example": "intento_de_contraseña"
+                },
+                "samples": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "handlers.ChatRequest": {
+            "type": "object",
+            "required": [
+                "message"
+            ],
+            "properties": {
+                "conversation_memory": {
+                    "$ref": "#/definitions/handlers.ConversationMemory"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "What are the best stocks to invest in today?"
+                },
+                "model": {
+                    "type": "string",
+                    "example": "gpt-4.1-mini"
+                },
+                "recent_messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.RecentMessage"
+                    }
+                },
+                "session_id": {
+                    "type": "string",
+                    "example": "3f2b9c1e-chat"
+                }
+            }
+        },
+        "handlers.ChatResponse": {
+            "type": "object",
+            "properties": {
+                "context_used": {
+                    "type": "string"
+                },
+                "generated_at": {
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "generated_sql": {
+                    "description": "Only set with ?debug=true: the AI-generated SQL (empty when cached context was reused) and the rows it returned",
+                    "type": "string",
+                    "example": "SELECT ticker, rating_to FROM stock_ratings WHERE ticker = 'AAPL' LIMIT 20"
+                },
+                "response": {
+                    "type": "string",
+                    "example": "Based on current market data, I recommend focusing on stocks with strong buy ratings and recent target price increases. The biotech sector shows particular promise."
+                },
+                "row_count": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "session_id": {
+                    "type": "string",
+                    "example": "3f2b9c1e-chat"
+                },
+                "tokens_used": {
+                    "type": "integer",
+                    "example": 156
+                },
+                "updated_memory": {
+                    "$ref": "#/definitions/handlers.ConversationMemory"
+                }
+            }
+        },
+        "handlers.CompareResponse": {
+            "type": "object",
+            "properties": {
+                "generated_at": {
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "stocks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.StockComparison"
+                    }
+                }
+            }
+        },
+        "handlers.ConsensusResponse": {
+            "type": "object",
+            "properties": {
+                "consensus": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.StockConsensus"
+                    }
+                },
+                "generated_at": {
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                }
+            }
+        },
+        "handlers.ConversationMemory": {
+            "type": "object",
+            "properties": {
+                "key_topics": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "last_context": {
+                    "type": "string"
+                },
+                "summary": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CountResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1250
+                }
+            }
+        },
+        "handlers.DailyStat": {
+            "type": "object",
+            "properties": {
+                "avg_target_change": {
+                    "description": "Percent, 0 when no rating had both prices",
+                    "type": "number",
+                    "example": 6.25
+                },
+                "date": {
+                    "description": "First day of the bucket",
+                    "type": "string",
+                    "example": "2025-01-15"
+                },
+                "downgrades": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "total_actions": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "upgrades": {
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "handlers.DailyStatsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.DailyStat"
+                    }
+                },
+                "from": {
+                    "type": "string",
+                    "example": "2025-01-01"
+                },
+                "granularity": {
+                    "type": "string",
+                    "example": "day"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2025-01-31"
+                }
+            }
+        },
+        "handlers.ExternalStatusResponse": {
+            "type": "object",
+            "properties": {
+                "consecutive_failures": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "cooldown": {
+                    "type": "string",
+                    "example": "30s"
+                },
+                "opened_at": {
+                    "description": "Set while open or half-open",
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "retry_in": {
+                    "description": "Time left before the next probe, set while open",
+                    "type": "string",
+                    "example": "12s"
+                },
+                "state": {
+                    "type": "string",
+                    "enum": [
+                        "closed",
+                        "open",
+                        "half_open"
+                    ],
+                    "example": "open"
+                },
+                "threshold": {
+                    "description": "Failures that open the breaker, 0 when disabled",
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        },
+        "handlers.FilterOptionsResponse": {
+            "type": "object",
+            "properties": {
+                "actions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "brokerages": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ratings_from": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ratings_to": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.ImportItemError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "target_to must be a non-negative number"
+                },
+                "index": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "ticker": {
+                    "type": "string",
+                    "example": "AAPL"
+                }
+            }
+        },
+        "handlers.ImportRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StockRatings"
+                    }
+                }
+            }
+        },
+        "handlers.ImportResponse": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.ImportItemError"
+                    }
+                },
+                "inserted": {
+                    "type": "integer",
+                    "example": 95
+                },
+                "invalid": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "skipped": {
+                    "description": "Already stored or repeated within the request",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "handlers.PageStoreResponse": {
+            "type": "object",
+            "properties": {
+                "duplicate_count": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StockRatings"
+                    }
+                },
+                "new_count": {
+                    "type": "integer",
+                    "example": 8
+                },
+                "next_page": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.PasswordOnlyRequest": {
+            "type": "object",
+            "required": [
+                "password"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "intento_de_contraseña"
+                }
+            }
+        },
+        "handlers.RandomStocksResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Number of rows returned, below the requested count for small tables",
+                    "type": "integer",
+                    "example": 10
+                },
+                "data": {
+                    "description": "Same rows as the data array of POST /stocks/list",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StockRatings"
+                    }
                 }
             }
         },
-        "handlers.ChatRequest": {
+        "handlers.RecentMessage": {
             "type": "object",
             "properties": {
-                "conversation_memory": {
-                    "$ref": "#/definitions/handlers.ConversationMemory"
+                "content": {
+                    "type": "string"
                 },
-                "message": {
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RecommendationsRefreshResponse": {
+            "type": "object",
+            "properties": {
+                "generated_at": {
                     "type": "string",
-                    "example": "What are the best stocks to invest in today?"
+                    "example": "2024-01-15T10:30:00Z"
                 },
-                "recent_messages": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/handlers.RecentMessage"
-                    }
+                "ratings_analyzed": {
+                    "type": "integer",
+                    "example": 1250
+                },
+                "tickers": {
+                    "description": "Recommendations stored, one per ticker",
+                    "type": "integer",
+                    "example": 312
                 }
             }
         },
-        "handlers.ChatResponse": {
+        "handlers.RecommendationsResponse": {
             "type": "object",
             "properties": {
-                "context_used": {
-                    "type": "string"
+                "cached": {
+                    "description": "Cached is true when the ranking came from recommendations_cache, GeneratedAt is then when it was computed",
+                    "type": "boolean",
+                    "example": false
+                },
+                "days": {
+                    "description": "Analysis window, omitted for all-time",
+                    "type": "integer",
+                    "example": 30
                 },
                 "generated_at": {
                     "type": "string",
                     "example": "2024-01-15T10:30:00Z"
                 },
-                "response": {
-                    "type": "string",
-                    "example": "Based on current market data, I recommend focusing on stocks with strong buy ratings and recent target price increases. The biotech sector shows particular promise."
+                "pagination": {
+                    "description": "Set when page_number or page_length is given",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PaginationMeta"
+                        }
+                    ]
                 },
-                "tokens_used": {
+                "recommendations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.StockRecommendation"
+                    }
+                },
+                "total_analyzed": {
                     "type": "integer",
-                    "example": 156
+                    "example": 1250
                 },
-                "updated_memory": {
-                    "$ref": "#/definitions/handlers.ConversationMemory"
+                "total_recommendations": {
+                    "description": "TotalRecommendations counts every stock scoring at least min_score, before limit or paging",
+                    "type": "integer",
+                    "example": 37
+                },
+                "weights": {
+                    "$ref": "#/definitions/handlers.ScoringWeights"
                 }
             }
         },
-        "handlers.ConversationMemory": {
+        "handlers.ScoreBreakdown": {
             "type": "object",
             "properties": {
-                "key_topics": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "action": {
+                    "type": "number",
+                    "example": 0.3
                 },
-                "last_context": {
-                    "type": "string"
+                "base": {
+                    "type": "number",
+                    "example": 5
                 },
-                "summary": {
-                    "type": "string"
+                "rating": {
+                    "type": "number",
+                    "example": 0.9
+                },
+                "target_price": {
+                    "type": "number",
+                    "example": 1.2
+                },
+                "timing": {
+                    "type": "number",
+                    "example": 0.1
+                },
+                "total": {
+                    "type": "number",
+                    "example": 7.5
                 }
             }
         },
-        "handlers.FilterOptionsResponse": {
+        "handlers.ScoringWeights": {
             "type": "object",
             "properties": {
-                "actions": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "action_weight": {
+                    "description": "Weight for action analysis (default: 0.2)",
+                    "type": "number",
+                    "example": 0.2
                 },
-                "ratings_from": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "rating_weight": {
+                    "description": "Weight for rating analysis (default: 0.3)",
+                    "type": "number",
+                    "example": 0.3
                 },
-                "ratings_to": {
+                "target_weight": {
+                    "description": "Weight for target price changes (default: 0.4)",
+                    "type": "number",
+                    "example": 0.4
+                },
+                "timing_weight": {
+                    "description": "Weight for recent activity (default: 0.1)",
+                    "type": "number",
+                    "example": 0.1
+                }
+            }
+        },
+        "handlers.SectorsResponse": {
+            "type": "object",
+            "properties": {
+                "sectors": {
                     "type": "array",
                     "items": {
                         "type": "string"
-                    }
+                    },
+                    "example": [
+                        "Healthcare",
+                        "Technology"
+                    ]
+                }
+            }
+        },
+        "handlers.StockComparison": {
+            "type": "object",
+            "properties": {
+                "brokerage": {
+                    "type": "string",
+                    "example": "Goldman Sachs"
+                },
+                "company": {
+                    "type": "string",
+                    "example": "Apple Inc."
+                },
+                "coverage": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "latest_rating": {
+                    "type": "string",
+                    "example": "Buy"
+                },
+                "latest_target": {
+                    "type": "string",
+                    "example": "$180.00"
+                },
+                "price_change": {
+                    "type": "number",
+                    "example": 20
+                },
+                "recommendation": {
+                    "type": "string",
+                    "example": "Buy"
+                },
+                "score": {
+                    "type": "number",
+                    "example": 7.2
+                },
+                "ticker": {
+                    "type": "string",
+                    "example": "AAPL"
+                }
+            }
+        },
+        "handlers.StockConsensus": {
+            "type": "object",
+            "properties": {
+                "avg_target_price": {
+                    "type": "number",
+                    "example": 185.5
+                },
+                "bearish": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "brokerages": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "bullish": {
+                    "type": "integer",
+                    "example": 8
+                },
+                "company": {
+                    "type": "string",
+                    "example": "Apple Inc."
+                },
+                "coverage": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "max_target_price": {
+                    "type": "number",
+                    "example": 210
+                },
+                "min_target_price": {
+                    "type": "number",
+                    "example": 160
+                },
+                "neutral": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "target_spread": {
+                    "type": "number",
+                    "example": 50
+                },
+                "ticker": {
+                    "type": "string",
+                    "example": "AAPL"
+                }
+            }
+        },
+        "handlers.StockRecommendation": {
+            "type": "object",
+            "properties": {
+                "brokerage": {
+                    "type": "string",
+                    "example": "Goldman Sachs"
+                },
+                "company": {
+                    "type": "string",
+                    "example": "Apple Inc."
+                },
+                "confidence": {
+                    "description": "Confidence (0-1) grows with the number of distinct brokerages covering the ticker, Score is unaffected",
+                    "type": "number",
+                    "example": 0.88
+                },
+                "current_rating": {
+                    "type": "string",
+                    "example": "Buy"
+                },
+                "price_change": {
+                    "type": "number",
+                    "example": 15.5
+                },
+                "rating_improvement": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Target raised by 15%, upgraded to Buy rating"
+                },
+                "recommendation": {
+                    "type": "string",
+                    "example": "Strong Buy"
+                },
+                "score": {
+                    "type": "number",
+                    "example": 8.5
+                },
+                "score_breakdown": {
+                    "description": "How each criterion contributed to Score",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/handlers.ScoreBreakdown"
+                        }
+                    ]
+                },
+                "sector": {
+                    "type": "string",
+                    "example": "Technology"
+                },
+                "target_price": {
+                    "type": "string",
+                    "example": "$180.00"
+                },
+                "ticker": {
+                    "type": "string",
+                    "example": "AAPL"
+                }
+            }
+        },
+        "handlers.SummaryResponse": {
+            "type": "object",
+            "properties": {
+                "cached_at": {
+                    "description": "When the cached summary was generated",
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "from_cache": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "generated_at": {
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "source": {
+                    "type": "string",
+                    "enum": [
+                        "ai",
+                        "fallback"
+                    ],
+                    "example": "ai"
+                },
+                "summary": {
+                    "type": "string",
+                    "example": "Today's market shows strong bullish sentiment with 15 stocks receiving target price increases. Apple leads recommendations with a 12% target raise to $180, while tech sector dominates with 60% of top picks."
+                },
+                "tokens_used": {
+                    "type": "integer",
+                    "example": 245
+                }
+            }
+        },
+        "handlers.TimingAttackRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "password/**/FROM/**/users--"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "davidalbertoguz@gmail.com"
                 }
             }
         },
-        "handlers.RecentMessage": {
+        "handlers.TimingAttackResponse": {
             "type": "object",
             "properties": {
-                "content": {
+                "external_response": {
                     "type": "string"
                 },
-                "role": {
-                    "type": "string"
+                "message": {
+                    "type": "string",
+                    "example": "Login attempt completed"
+                },
+                "response_time_ms": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/time.Duration"
+                        }
+                    ],
+                    "example": 150
+                },
+                "status_code": {
+                    "type": "integer",
+                    "example": 401
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": false
                 }
             }
         },
-        "handlers.RecommendationsResponse": {
+        "handlers.TrendingResponse": {
             "type": "object",
             "properties": {
+                "days": {
+                    "type": "integer",
+                    "example": 7
+                },
                 "generated_at": {
                     "type": "string",
                     "example": "2024-01-15T10:30:00Z"
                 },
-                "recommendations": {
+                "trending": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/handlers.StockRecommendation"
+                        "$ref": "#/definitions/handlers.TrendingStock"
                     }
-                },
-                "total_analyzed": {
-                    "type": "integer",
-                    "example": 1250
                 }
             }
         },
-        "handlers.StockRecommendation": {
+        "handlers.TrendingStock": {
             "type": "object",
             "properties": {
-                "brokerage": {
-                    "type": "string",
-                    "example": "Goldman Sachs"
+                "actions": {
+                    "type": "integer",
+                    "example": 9
                 },
                 "company": {
                     "type": "string",
-                    "example": "Apple Inc."
-                },
-                "current_rating": {
-                    "type": "string",
-                    "example": "Buy"
-                },
-                "price_change": {
-                    "type": "number",
-                    "example": 15.5
+                    "example": "NVIDIA Corporation"
                 },
-                "rating_improvement": {
-                    "type": "boolean",
-                    "example": true
+                "downgrades": {
+                    "type": "integer",
+                    "example": 1
                 },
-                "reason": {
+                "latest_action_time": {
                     "type": "string",
-                    "example": "Target raised by 15%, upgraded to Buy rating"
+                    "example": "2025-01-15T10:30:00Z"
                 },
-                "recommendation": {
+                "latest_rating": {
                     "type": "string",
-                    "example": "Strong Buy"
-                },
-                "score": {
-                    "type": "number",
-                    "example": 8.5
+                    "example": "Buy"
                 },
-                "target_price": {
-                    "type": "string",
-                    "example": "$180.00"
+                "net_upgrades": {
+                    "type": "integer",
+                    "example": 3
                 },
                 "ticker": {
                     "type": "string",
-                    "example": "AAPL"
-                }
-            }
-        },
-        "handlers.SummaryResponse": {
-            "type": "object",
-            "properties": {
-                "generated_at": {
-                    "type": "string",
-                    "example": "2024-01-15T10:30:00Z"
-                },
-                "summary": {
-                    "type": "string",
-                    "example": "Today's market shows strong bullish sentiment with 15 stocks receiving target price increases. Apple leads recommendations with a 12% target raise to $180, while tech sector dominates with 60% of top picks."
+                    "example": "NVDA"
                 },
-                "tokens_used": {
+                "upgrades": {
                     "type": "integer",
-                    "example": 245
+                    "example": 4
                 }
             }
         },
@@ -635,30 +2752,76 @@ const docTemplate = `{
                 }
             }
         },
-        "models.ApiResponse": {
+        "models.BrokerageActivity": {
             "type": "object",
             "properties": {
-                "items": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.StockRatings"
-                    }
+                "activity": {
+                    "type": "integer",
+                    "example": 150
                 },
-                "next_page": {
-                    "type": "string"
+                "name": {
+                    "type": "string",
+                    "example": "Goldman Sachs"
                 }
             }
         },
-        "models.BrokerageActivity": {
+        "models.BulkJobResponse": {
             "type": "object",
             "properties": {
-                "activity": {
+                "job_id": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "running"
+                },
+                "status_url": {
+                    "type": "string",
+                    "example": "/api/stocks/bulk/9f86d081884c7d659a2feaa0c55ad015/status"
+                }
+            }
+        },
+        "models.BulkJobStatusResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error is set when status is error",
+                    "type": "string",
+                    "example": "failed to fetch page 12: unexpected status 500"
+                },
+                "job_id": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015"
+                },
+                "pages_processed": {
                     "type": "integer",
-                    "example": 150
+                    "example": 420
                 },
-                "name": {
+                "pages_total": {
+                    "type": "integer",
+                    "example": 1000
+                },
+                "pages_with_data": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "result": {
+                    "description": "Result is set when status is done",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BulkResponse"
+                        }
+                    ]
+                },
+                "status": {
                     "type": "string",
-                    "example": "Goldman Sachs"
+                    "enum": [
+                        "running",
+                        "done",
+                        "error"
+                    ],
+                    "example": "running"
                 }
             }
         },
@@ -669,10 +2832,29 @@ const docTemplate = `{
                 "start_page"
             ],
             "properties": {
+                "batch_size": {
+                    "description": "Optional tuning for this request, capped at BULK_BATCH_SIZE and BULK_MAX_CONCURRENT",
+                    "type": "integer",
+                    "example": 500
+                },
+                "confirm_clear": {
+                    "description": "ConfirmClear must be true to delete every stored rating before fetching; otherwise new ratings are added on top",
+                    "type": "boolean",
+                    "example": false
+                },
+                "deadline_seconds": {
+                    "description": "Optional time limit in seconds for this request, capped at BULK_DEADLINE_SECONDS when that is set",
+                    "type": "integer",
+                    "example": 300
+                },
                 "end_page": {
                     "type": "integer",
                     "example": 100
                 },
+                "max_concurrent": {
+                    "type": "integer",
+                    "example": 10
+                },
                 "start_page": {
                     "type": "integer",
                     "example": 1
@@ -682,6 +2864,26 @@ const docTemplate = `{
         "models.BulkResponse": {
             "type": "object",
             "properties": {
+                "cancelled": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "cleared": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "duplicates_dropped": {
+                    "type": "integer",
+                    "example": 120
+                },
+                "duplicates_skipped": {
+                    "type": "integer",
+                    "example": 360
+                },
+                "inserted": {
+                    "type": "integer",
+                    "example": 7500
+                },
                 "message": {
                     "type": "string",
                     "example": "Successfully fetched and stored stock data"
@@ -690,24 +2892,88 @@ const docTemplate = `{
                     "type": "string",
                     "example": "1-1000"
                 },
+                "pages_processed": {
+                    "type": "integer",
+                    "example": 1000
+                },
                 "stocks": {
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/models.StockRatings"
                     }
                 },
+                "stocks_truncated": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "timed_out": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "total_duplicates": {
+                    "type": "integer",
+                    "example": 240
+                },
+                "total_inserted": {
+                    "type": "integer",
+                    "example": 7500
+                },
                 "total_stocks": {
                     "type": "integer",
                     "example": 7860
                 }
             }
         },
+        "models.Cursor": {
+            "type": "object",
+            "properties": {
+                "after_created_at": {
+                    "type": "string",
+                    "example": "2025-01-15T10:35:00Z"
+                },
+                "after_id": {
+                    "type": "integer",
+                    "example": 2500
+                }
+            }
+        },
+        "models.DataFreshness": {
+            "type": "object",
+            "properties": {
+                "hours_since_last_ingest": {
+                    "type": "number",
+                    "example": 2.5
+                },
+                "newest_created_at": {
+                    "description": "Last ingest",
+                    "type": "string",
+                    "example": "2025-01-15T14:00:00Z"
+                },
+                "newest_report_time": {
+                    "type": "string",
+                    "example": "2025-01-15T13:30:00Z"
+                },
+                "oldest_created_at": {
+                    "type": "string",
+                    "example": "2025-01-10T08:00:00Z"
+                },
+                "oldest_report_time": {
+                    "type": "string",
+                    "example": "2024-06-03T13:30:00Z"
+                }
+            }
+        },
         "models.ErrorResponse": {
             "type": "object",
             "properties": {
                 "error": {
                     "type": "string",
                     "example": "Invalid JSON format in request body"
+                },
+                "request_id": {
+                    "description": "Also sent as the X-Request-ID header",
+                    "type": "string",
+                    "example": "3f2a9c1e7b4d4e6f8a0b1c2d3e4f5a6b"
                 }
             }
         },
@@ -717,6 +2983,11 @@ const docTemplate = `{
                 "error": {
                     "type": "string",
                     "example": "Internal server error occurred"
+                },
+                "request_id": {
+                    "description": "Quote it when reporting the error",
+                    "type": "string",
+                    "example": "3f2a9c1e7b4d4e6f8a0b1c2d3e4f5a6b"
                 }
             }
         },
@@ -752,6 +3023,9 @@ const docTemplate = `{
         "models.MetricsData": {
             "type": "object",
             "properties": {
+                "data_freshness": {
+                    "$ref": "#/definitions/models.DataFreshness"
+                },
                 "description": {
                     "type": "string",
                     "example": "Comprehensive stock market analytics based on analyst ratings and target price changes"
@@ -770,10 +3044,7 @@ const docTemplate = `{
                     }
                 },
                 "rating_distribution": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "integer"
-                    }
+                    "$ref": "#/definitions/models.RatingDistribution"
                 },
                 "recent_activity": {
                     "type": "integer",
@@ -782,6 +3053,9 @@ const docTemplate = `{
                 "target_changes": {
                     "$ref": "#/definitions/models.TargetChanges"
                 },
+                "target_price_stats": {
+                    "$ref": "#/definitions/models.TargetPriceStats"
+                },
                 "top_brokerages": {
                     "type": "array",
                     "items": {
@@ -797,15 +3071,45 @@ const docTemplate = `{
         "models.MetricsResponse": {
             "type": "object",
             "properties": {
+                "cache_age_seconds": {
+                    "type": "number",
+                    "example": 12.4
+                },
+                "cached": {
+                    "type": "boolean",
+                    "example": true
+                },
                 "metrics": {
                     "$ref": "#/definitions/models.MetricsData"
                 },
+                "refreshing": {
+                    "type": "boolean",
+                    "example": false
+                },
                 "success": {
                     "type": "boolean",
                     "example": true
                 }
             }
         },
+        "models.OtherRatings": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 95
+                },
+                "percentage": {
+                    "type": "number",
+                    "example": 3.77
+                },
+                "ratings": {
+                    "description": "Distinct ratings in the bucket",
+                    "type": "integer",
+                    "example": 14
+                }
+            }
+        },
         "models.PageRequest": {
             "type": "object",
             "required": [
@@ -843,6 +3147,19 @@ const docTemplate = `{
                     "type": "boolean",
                     "example": false
                 },
+                "next_cursor": {
+                    "description": "NextCursor is set when more rows follow in the default created_at DESC order",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Cursor"
+                        }
+                    ]
+                },
+                "out_of_range": {
+                    "description": "OutOfRange is set when page_number is past the last page, data is then empty",
+                    "type": "boolean",
+                    "example": false
+                },
                 "page_length": {
                     "type": "integer",
                     "example": 20
@@ -864,17 +3181,81 @@ const docTemplate = `{
         "models.PaginationRequest": {
             "type": "object",
             "required": [
-                "page_length",
-                "page_number"
+                "page_length"
             ],
             "properties": {
+                "after_created_at": {
+                    "type": "string",
+                    "example": "2025-01-15T10:35:00Z"
+                },
+                "after_id": {
+                    "description": "Keyset pagination: when set, rows older than this cursor are returned instead of using page_number",
+                    "type": "integer",
+                    "example": 2500
+                },
                 "page_length": {
                     "type": "integer",
                     "example": 20
                 },
                 "page_number": {
+                    "description": "PageNumber is required unless a cursor (after_id, after_created_at) is given",
                     "type": "integer",
                     "example": 1
+                },
+                "sort_by": {
+                    "type": "string",
+                    "enum": [
+                        "created_at",
+                        "time",
+                        "ticker",
+                        "company"
+                    ],
+                    "example": "created_at"
+                },
+                "sort_order": {
+                    "type": "string",
+                    "enum": [
+                        "asc",
+                        "desc"
+                    ],
+                    "example": "desc"
+                }
+            }
+        },
+        "models.RatingDistribution": {
+            "type": "object",
+            "properties": {
+                "other": {
+                    "$ref": "#/definitions/models.OtherRatings"
+                },
+                "ratings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RatingShare"
+                    }
+                },
+                "total": {
+                    "description": "Ratings with a non-empty rating_to",
+                    "type": "integer",
+                    "example": 2520
+                }
+            }
+        },
+        "models.RatingShare": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 820
+                },
+                "percentage": {
+                    "description": "Share of RatingDistribution.Total, rounded to 2 decimals",
+                    "type": "number",
+                    "example": 32.54
+                },
+                "rating": {
+                    "type": "string",
+                    "example": "Buy"
                 }
             }
         },
@@ -909,6 +3290,11 @@ const docTemplate = `{
                     "type": "string",
                     "example": "Strong Buy"
                 },
+                "sector": {
+                    "description": "Sector is optional, it is only stored when the external API provides it",
+                    "type": "string",
+                    "example": "Technology"
+                },
                 "target_from": {
                     "type": "string",
                     "example": "$150.00"
@@ -943,6 +3329,77 @@ const docTemplate = `{
                     "example": 1200
                 }
             }
+        },
+        "models.TargetPriceStats": {
+            "type": "object",
+            "properties": {
+                "average_change_percent": {
+                    "description": "0 when nothing was compared",
+                    "type": "number",
+                    "example": 4.25
+                },
+                "compared": {
+                    "description": "Ratings with numeric target_from and target_to",
+                    "type": "integer",
+                    "example": 2410
+                },
+                "decreases": {
+                    "type": "integer",
+                    "example": 750
+                },
+                "increases": {
+                    "type": "integer",
+                    "example": 1310
+                },
+                "largest_raise_percent": {
+                    "description": "Biggest single increase, 0 when there is none",
+                    "type": "number",
+                    "example": 85.5
+                },
+                "unchanged": {
+                    "type": "integer",
+                    "example": 350
+                }
+            }
+        },
+        "time.Duration": {
+            "type": "integer",
+            "enum": [
+                -9223372036854775808,
+                9223372036854775807,
+                1,
+                1000,
+                1000000,
+                1000000000,
+                60000000000,
+                3600000000000,
+                -9223372036854775808,
+                9223372036854775807,
+                1,
+                1000,
+                1000000,
+                1000000000,
+                60000000000,
+                3600000000000
+            ],
+            "x-enum-varnames": [
+                "minDuration",
+                "maxDuration",
+                "Nanosecond",
+                "Microsecond",
+                "Millisecond",
+                "Second",
+                "Minute",
+                "Hour",
+                "minDuration",
+                "maxDuration",
+                "Nanosecond",
+                "Microsecond",
+                "Millisecond",
+                "Second",
+                "Minute",
+                "Hour"
+            ]
         }
     }
 }`
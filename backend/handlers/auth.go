@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyHeader carries the key checked by APIKeyAuth
+const apiKeyHeader = "X-API-Key"
+
+// APIKeyAuth rejects requests whose X-API-Key header does not match apiKey with 401.
+// An empty apiKey disables the check so deployments without API_KEY keep working.
+func APIKeyAuth(apiKey string) gin.HandlerFunc {
+	if apiKey == "" {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	// Hash both sides so the comparison time depends on neither the key nor its length
+	expected := sha256.Sum256([]byte(apiKey))
+	return func(c *gin.Context) {
+		provided := c.GetHeader(apiKeyHeader)
+		actual := sha256.Sum256([]byte(provided))
+		if provided == "" || subtle.ConstantTimeCompare(expected[:], actual[:]) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid API key"})
+			return
+		}
+		c.Next()
+	}
+}
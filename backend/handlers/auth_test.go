@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// performWithAPIKey calls a protected route, sending key in X-API-Key when it is not empty
+func performWithAPIKey(configuredKey, key string) (*httptest.ResponseRecorder, bool) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	reached := false
+	router.POST("/stocks/bulk", APIKeyAuth(configuredKey), func(c *gin.Context) {
+		reached = true
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	req := httptest.NewRequest("POST", "/stocks/bulk", nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w, reached
+}
+
+// TestAPIKeyAuth_MissingKey validates that requests without a key are rejected before the handler runs
+func TestAPIKeyAuth_MissingKey(t *testing.T) {
+	w, reached := performWithAPIKey("s3cret", "")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Missing or invalid API key")
+	assert.False(t, reached)
+}
+
+// TestAPIKeyAuth_WrongKey validates that a wrong key, including a prefix of the right one, is rejected
+func TestAPIKeyAuth_WrongKey(t *testing.T) {
+	for _, key := range []string{"wrong", "s3cre", "s3cret2"} {
+		w, reached := performWithAPIKey("s3cret", key)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "key %q should be rejected", key)
+		assert.False(t, reached)
+	}
+}
+
+// TestAPIKeyAuth_CorrectKey validates that the matching key reaches the handler
+func TestAPIKeyAuth_CorrectKey(t *testing.T) {
+	w, reached := performWithAPIKey("s3cret", "s3cret")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, reached)
+}
+
+// TestAPIKeyAuth_DisabledWhenUnset validates backward compatibility when API_KEY is not configured
+func TestAPIKeyAuth_DisabledWhenUnset(t *testing.T) {
+	w, reached := performWithAPIKey("", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, reached)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"smart-stock-recommender/models"
+)
+
+// defaultAutoRefreshPages is how many pages each automatic refresh fetches when AUTO_REFRESH_PAGES is not set.
+const defaultAutoRefreshPages = 10
+
+// autoRefreshConfigFromEnv reads AUTO_REFRESH_INTERVAL_MINUTES, where 0 or unset disables the scheduler,
+// and AUTO_REFRESH_PAGES; 0 pages would fetch nothing, so it falls back to the default.
+func autoRefreshConfigFromEnv() (interval time.Duration, pages int) {
+	interval = time.Duration(intFromEnv("AUTO_REFRESH_INTERVAL_MINUTES", 0)) * time.Minute
+	pages = intFromEnv("AUTO_REFRESH_PAGES", defaultAutoRefreshPages)
+	if pages == 0 {
+		pages = defaultAutoRefreshPages
+	}
+	return interval, pages
+}
+
+// StartAutoRefresh starts the scheduler configured by AUTO_REFRESH_INTERVAL_MINUTES and AUTO_REFRESH_PAGES.
+// Every interval it fetches pages 1 to AUTO_REFRESH_PAGES like POST /stocks/bulk without confirm_clear,
+// so existing ratings are kept and duplicates skipped. Cancelling ctx stops the scheduler and any
+// running fetch; the returned channel is closed once it has stopped. When disabled it is closed right away.
+func (h *StockHandler) StartAutoRefresh(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	interval, pages := autoRefreshConfigFromEnv()
+	if interval == 0 {
+		close(done)
+		return done
+	}
+
+	h.logger.Info("auto refresh scheduled", "interval", interval.String(), "pages", pages)
+	go func() {
+		defer close(done)
+		h.runAutoRefresh(ctx, interval, pages)
+	}()
+	return done
+}
+
+// runAutoRefresh fetches pages 1 to pages every interval until ctx is cancelled.
+// Runs never overlap: ticks that fire during a slow fetch are dropped by the ticker.
+func (h *StockHandler) runAutoRefresh(ctx context.Context, interval time.Duration, pages int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		h.autoRefreshOnce(ctx, pages)
+		// A run cancelled by shutdown must not race a pending tick into starting another one
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// autoRefreshOnce runs one scheduled fetch and logs its results
+func (h *StockHandler) autoRefreshOnce(ctx context.Context, pages int) {
+	if _, ok := h.bulkGuard.Start(""); !ok {
+		h.logger.Warn("auto refresh skipped, " + bulkInProgressError)
+		return
+	}
+	defer h.bulkGuard.Finish()
+
+	started := time.Now()
+	h.logger.Info("auto refresh started", "start_page", 1, "end_page", pages)
+
+	result, err := h.bulkFetch(ctx, 1, pages, h.bulkSettings)
+	if err != nil {
+		h.logger.Error("auto refresh failed", "error", err, "duration_ms", time.Since(started).Milliseconds())
+		h.discardRecommendationsCache(ctx)
+		return
+	}
+	h.logger.Info("auto refresh finished",
+		"fetched", result.TotalFetched,
+		"inserted", result.TotalInserted,
+		"duplicates", result.TotalDuplicates,
+		"pages_processed", result.PagesProcessed,
+		"cancelled", result.Cancelled,
+		"timed_out", result.TimedOut,
+		"duration_ms", time.Since(started).Milliseconds())
+
+	h.refreshRecommendationsAfterIngest(ctx, models.BulkPageRequest{StartPage: 1, EndPage: pages}, result)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRunAutoRefresh validates that the scheduler fetches the configured pages every tick until cancelled
+func TestRunAutoRefresh(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.recommendationsCacheTTL = 0
+	logs := captureLogs(handler, slog.LevelInfo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls []int
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		calls = append(calls, endPage)
+		if len(calls) == 2 {
+			return bulkFetchResult{}, errors.New("external API unavailable")
+		}
+		if len(calls) == 3 {
+			cancel() // shutdown while the third run is in flight
+		}
+		return bulkFetchResult{TotalFetched: 20, TotalInserted: 5, TotalDuplicates: 15, PagesProcessed: endPage - startPage + 1}, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		handler.runAutoRefresh(ctx, 5*time.Millisecond, 4)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("scheduler did not stop after cancellation")
+	}
+
+	assert.Equal(t, []int{4, 4, 4}, calls, "every run fetches pages 1-4, none start after cancellation")
+	var finished, failed int
+	for _, record := range logs() {
+		switch record["msg"] {
+		case "auto refresh finished":
+			finished++
+			assert.Equal(t, float64(5), record["inserted"])
+		case "auto refresh failed":
+			failed++
+		}
+	}
+	assert.Equal(t, 2, finished)
+	assert.Equal(t, 1, failed)
+	assert.NoError(t, mock.ExpectationsWereMet(), "the clear must never run")
+}
+
+// TestStartAutoRefresh_Disabled validates that the scheduler is off unless AUTO_REFRESH_INTERVAL_MINUTES is set
+func TestStartAutoRefresh_Disabled(t *testing.T) {
+	t.Setenv("AUTO_REFRESH_INTERVAL_MINUTES", "")
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		t.Error("disabled scheduler must not fetch")
+		return bulkFetchResult{}, nil
+	}
+
+	select {
+	case <-handler.StartAutoRefresh(context.Background()):
+	default:
+		t.Fatal("disabled scheduler should report stopped right away")
+	}
+}
+
+// TestAutoRefreshConfigFromEnv validates the interval and page count read from the environment
+func TestAutoRefreshConfigFromEnv(t *testing.T) {
+	t.Setenv("AUTO_REFRESH_INTERVAL_MINUTES", "1440")
+	t.Setenv("AUTO_REFRESH_PAGES", "0")
+	interval, pages := autoRefreshConfigFromEnv()
+	assert.Equal(t, 24*time.Hour, interval)
+	assert.Equal(t, defaultAutoRefreshPages, pages)
+
+	t.Setenv("AUTO_REFRESH_PAGES", "25")
+	_, pages = autoRefreshConfigFromEnv()
+	assert.Equal(t, 25, pages)
+}
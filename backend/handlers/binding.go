@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// bindJSON decodes the request body into obj with c.ShouldBindJSON, enforcing its binding tags.
+// On failure it writes a 400 with a single "error" message and returns false, so handlers only need to return.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) || len(validationErrs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format in request body"})
+		return false
+	}
+
+	// Report the first failing field by its JSON name, as clients send it
+	fieldErr := validationErrs[0]
+	field := jsonFieldName(obj, fieldErr.StructField())
+	if strings.HasPrefix(fieldErr.Tag(), "required") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Missing required field '%s' in request body", field)})
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid value for field '%s' in request body", field)})
+	return false
+}
+
+// jsonFieldName returns the JSON key of obj's struct field, or the Go name when it has no json tag
+func jsonFieldName(obj interface{}, structField string) string {
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return structField
+	}
+	field, ok := t.FieldByName(structField)
+	if !ok {
+		return structField
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return structField
+	}
+	return name
+}
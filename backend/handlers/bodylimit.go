@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxRequestBytes caps request bodies when MAX_REQUEST_BYTES is unset, far above any legitimate JSON payload
+const defaultMaxRequestBytes = 1 << 20
+
+// MaxRequestBytesFromEnv reads the request body limit from MAX_REQUEST_BYTES, 0 or invalid values keep the 1MB default.
+func MaxRequestBytesFromEnv() int64 {
+	limit := intFromEnv("MAX_REQUEST_BYTES", defaultMaxRequestBytes)
+	if limit == 0 {
+		return defaultMaxRequestBytes
+	}
+	return int64(limit)
+}
+
+// BodySizeLimit rejects requests whose body is larger than maxBytes with 413.
+// The body is read up front through http.MaxBytesReader, so an oversized upload is never buffered whole
+// and handlers keep decoding JSON from c.Request.Body as before.
+func BodySizeLimit(maxBytes int64) gin.HandlerFunc {
+	tooLarge := gin.H{"error": fmt.Sprintf("Request body too large, the limit is %d bytes", maxBytes)}
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		// A declared length is enough to refuse without reading anything
+		if c.Request.ContentLength > maxBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, tooLarge)
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newBodyLimitRouter serves /stocks/search behind BodySizeLimit(maxBytes)
+func newBodyLimitRouter(handler *StockHandler, maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(BodySizeLimit(maxBytes))
+	router.POST("/stocks/search", handler.SearchStockRatings)
+	return router
+}
+
+// TestBodySizeLimit_OversizedBody validates that a body over the limit is rejected with 413 before the handler queries anything
+func TestBodySizeLimit_OversizedBody(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	router := newBodyLimitRouter(handler, 1024)
+
+	body := `{"search_term": "` + strings.Repeat("A", 2048) + `", "page_number": 1, "page_length": 10}`
+	req := httptest.NewRequest("POST", "/stocks/search", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "Request body too large, the limit is 1024 bytes")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBodySizeLimit_UnknownLength validates that chunked bodies without a Content-Length are cut off at the limit too
+func TestBodySizeLimit_UnknownLength(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	router := newBodyLimitRouter(handler, 1024)
+
+	req := httptest.NewRequest("POST", "/stocks/search", io.NopCloser(strings.NewReader(strings.Repeat(" ", 4096)+"{}")))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBodySizeLimit_WithinLimit validates that smaller bodies still reach the handler intact
+func TestBodySizeLimit_WithinLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(BodySizeLimit(1024))
+	var received string
+	router.POST("/stocks/list", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = string(body)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("POST", "/stocks/list", bytes.NewBufferString(`{"page_number": 1, "page_length": 10}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"page_number": 1, "page_length": 10}`, received)
+}
+
+// TestMaxRequestBytesFromEnv validates the MAX_REQUEST_BYTES default and override
+func TestMaxRequestBytesFromEnv(t *testing.T) {
+	t.Setenv("MAX_REQUEST_BYTES", "")
+	assert.Equal(t, int64(1<<20), MaxRequestBytesFromEnv())
+
+	t.Setenv("MAX_REQUEST_BYTES", "0")
+	assert.Equal(t, int64(1<<20), MaxRequestBytesFromEnv())
+
+	t.Setenv("MAX_REQUEST_BYTES", "4096")
+	assert.Equal(t, int64(4096), MaxRequestBytesFromEnv())
+}
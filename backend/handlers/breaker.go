@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Defaults for the external API circuit breaker, overridden by EXTERNAL_BREAKER_THRESHOLD and EXTERNAL_BREAKER_COOLDOWN
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// errCircuitOpen is returned instead of calling the external API while the breaker is open
+var errCircuitOpen = errors.New("external API circuit breaker is open")
+
+// breakerState is the state of a circuitBreaker
+type breakerState string
+
+// Circuit breaker states
+const (
+	breakerClosed   breakerState = "closed"    // Calls go through, failures are counted
+	breakerOpen     breakerState = "open"      // Calls fail fast until the cooldown has passed
+	breakerHalfOpen breakerState = "half_open" // One probe call decides whether to close or open again
+)
+
+// circuitBreaker stops calls to a failing dependency.
+// After threshold consecutive failures it opens and Allow fails fast for cooldown, then it lets
+// a single probe through: a success closes it, a failure opens it again. A threshold of 0 disables it.
+// It is safe for concurrent use.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int       // Consecutive failures, reset by any success
+	openedAt  time.Time // When the breaker last opened
+	probeAt   time.Time // When the current half-open probe was let through
+
+	now           func() time.Time            // time.Now unless stubbed in tests
+	onStateChange func(from, to breakerState) // Called without the lock held, e.g. to log transitions
+}
+
+// newCircuitBreaker creates a closed breaker.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     breakerClosed,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a call may go ahead, returning errCircuitOpen when it must fail fast.
+// Once the cooldown has passed an open breaker turns half-open and allows one probe; another probe
+// is only allowed if the previous one has not reported back within a cooldown.
+func (b *circuitBreaker) Allow() error {
+	b.mu.Lock()
+	from := b.state
+	now := b.now()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			b.mu.Unlock()
+			return errCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		b.probeAt = now
+	case breakerHalfOpen:
+		if now.Sub(b.probeAt) < b.cooldown {
+			b.mu.Unlock()
+			return errCircuitOpen
+		}
+		b.probeAt = now
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return nil
+}
+
+// RecordSuccess resets the failure count and closes the breaker.
+func (b *circuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	from := b.state
+	b.failures = 0
+	b.state = breakerClosed
+	b.mu.Unlock()
+
+	b.notify(from, breakerClosed)
+}
+
+// RecordFailure counts a failed call, opening the breaker at the threshold or when a probe fails.
+func (b *circuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	from := b.state
+	b.failures++
+	if b.threshold > 0 && (b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold)) {
+		b.state = breakerOpen
+		b.openedAt = b.now()
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// notify reports a state transition to onStateChange
+func (b *circuitBreaker) notify(from, to breakerState) {
+	if from != to && b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
+
+// ExternalStatusResponse reports the circuit breaker state of the external stock API
+type ExternalStatusResponse struct {
+	State               string `json:"state" example:"open" enums:"closed,open,half_open"`
+	ConsecutiveFailures int    `json:"consecutive_failures" example:"5"`
+	Threshold           int    `json:"threshold" example:"5"` // Failures that open the breaker, 0 when disabled
+	Cooldown            string `json:"cooldown" example:"30s"`
+	OpenedAt            string `json:"opened_at,omitempty" example:"2024-01-15T10:30:00Z"` // Set while open or half-open
+	RetryIn             string `json:"retry_in,omitempty" example:"12s"`                   // Time left before the next probe, set while open
+}
+
+// Status snapshots the breaker for the debug endpoint.
+func (b *circuitBreaker) Status() ExternalStatusResponse {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := ExternalStatusResponse{
+		State:               string(b.state),
+		ConsecutiveFailures: b.failures,
+		Threshold:           b.threshold,
+		Cooldown:            b.cooldown.String(),
+	}
+	if b.state != breakerClosed {
+		status.OpenedAt = b.openedAt.Format(time.RFC3339)
+	}
+	if b.state == breakerOpen {
+		if left := b.cooldown - b.now().Sub(b.openedAt); left > 0 {
+			status.RetryIn = left.Round(time.Second).String()
+		}
+	}
+	return status
+}
+
+// GetExternalStatus reports whether calls to the external stock API are failing fast
+// @Summary Get the external API circuit breaker state
+// @Description Returns the state of the circuit breaker around the external stock API. After EXTERNAL_BREAKER_THRESHOLD consecutive failures (default 5) it opens and fetches fail fast for EXTERNAL_BREAKER_COOLDOWN (default 30s), then one probe request decides whether it closes again.
+// @Tags debug
+// @Produce json
+// @Success 200 {object} ExternalStatusResponse "Current circuit breaker state"
+// @Router /debug/external-status [get]
+func (h *StockHandler) GetExternalStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.externalBreaker.Status())
+}
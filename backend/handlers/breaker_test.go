@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBreakerClock is a settable clock for driving a circuitBreaker through its cooldown
+type fakeBreakerClock struct {
+	now time.Time
+}
+
+func (c *fakeBreakerClock) Now() time.Time { return c.now }
+
+// newTestBreaker creates a breaker on a fake clock that records its state transitions
+func newTestBreaker(threshold int, cooldown time.Duration) (*circuitBreaker, *fakeBreakerClock, *[]string) {
+	clock := &fakeBreakerClock{now: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)}
+	transitions := []string{}
+	breaker := newCircuitBreaker(threshold, cooldown)
+	breaker.now = clock.Now
+	breaker.onStateChange = func(from, to breakerState) {
+		transitions = append(transitions, string(from)+"->"+string(to))
+	}
+	return breaker, clock, &transitions
+}
+
+// TestCircuitBreaker_States validates closed -> open -> half-open -> open -> half-open -> closed
+func TestCircuitBreaker_States(t *testing.T) {
+	breaker, clock, transitions := newTestBreaker(3, 30*time.Second)
+
+	// Failures below the threshold keep it closed, a success resets the count
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	breaker.RecordSuccess()
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	assert.NoError(t, breaker.Allow())
+	assert.Equal(t, "closed", breaker.Status().State)
+
+	// The third consecutive failure opens it, calls fail fast during the cooldown
+	breaker.RecordFailure()
+	assert.ErrorIs(t, breaker.Allow(), errCircuitOpen)
+	clock.now = clock.now.Add(29 * time.Second)
+	assert.ErrorIs(t, breaker.Allow(), errCircuitOpen)
+	assert.Equal(t, "1s", breaker.Status().RetryIn)
+
+	// After the cooldown one probe goes through, concurrent calls still fail fast
+	clock.now = clock.now.Add(time.Second)
+	assert.NoError(t, breaker.Allow())
+	assert.Equal(t, "half_open", breaker.Status().State)
+	assert.ErrorIs(t, breaker.Allow(), errCircuitOpen)
+
+	// A failed probe opens it again for a full cooldown
+	breaker.RecordFailure()
+	assert.Equal(t, "open", breaker.Status().State)
+	assert.ErrorIs(t, breaker.Allow(), errCircuitOpen)
+
+	// A successful probe closes it
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.NoError(t, breaker.Allow())
+	breaker.RecordSuccess()
+	assert.NoError(t, breaker.Allow())
+	assert.Equal(t, ExternalStatusResponse{State: "closed", Threshold: 3, Cooldown: "30s"}, breaker.Status())
+
+	assert.Equal(t, []string{
+		"closed->open", "open->half_open", "half_open->open", "open->half_open", "half_open->closed",
+	}, *transitions)
+}
+
+// TestCircuitBreaker_StaleProbe validates that a probe that never reports back doesn't keep the breaker stuck
+func TestCircuitBreaker_StaleProbe(t *testing.T) {
+	breaker, clock, _ := newTestBreaker(1, 10*time.Second)
+
+	breaker.RecordFailure()
+	clock.now = clock.now.Add(10 * time.Second)
+	assert.NoError(t, breaker.Allow(), "First probe")
+	assert.ErrorIs(t, breaker.Allow(), errCircuitOpen)
+
+	clock.now = clock.now.Add(10 * time.Second)
+	assert.NoError(t, breaker.Allow(), "The unanswered probe is replaced after a cooldown")
+}
+
+// TestCircuitBreaker_Disabled validates that a threshold of 0 never opens the breaker
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	breaker, _, transitions := newTestBreaker(0, 30*time.Second)
+
+	for i := 0; i < 100; i++ {
+		breaker.RecordFailure()
+	}
+	assert.NoError(t, breaker.Allow())
+	assert.Empty(t, *transitions)
+}
+
+// newFailingMockAPI serves 503 until healthy is set, then an empty page, counting requests
+func newFailingMockAPI(requests *int32, healthy *atomic.Bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"ticker":"AAPL","company":"Apple Inc."}],"next_page":""}`))
+	}))
+}
+
+// TestFetchStocksFromAPI_CircuitBreaker validates that a dead external API is no longer retried once the breaker opens
+func TestFetchStocksFromAPI_CircuitBreaker(t *testing.T) {
+	t.Setenv("EXTERNAL_BREAKER_THRESHOLD", "2")
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+	clock := &fakeBreakerClock{now: time.Now()}
+	handler.externalBreaker.now = clock.Now
+
+	var requests int32
+	var healthy atomic.Bool
+	server := newFailingMockAPI(&requests, &healthy)
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	// Two failed attempts open the breaker, the remaining retries fail fast
+	stocks, err := handler.fetchStocksFromAPI(context.Background(), 1)
+	assert.ErrorIs(t, err, errCircuitOpen)
+	assert.Empty(t, stocks)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// Further pages don't reach the API at all during the cooldown
+	start := time.Now()
+	_, err = handler.fetchStocksFromAPI(context.Background(), 2)
+	assert.ErrorIs(t, err, errCircuitOpen)
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "An open breaker should not back off")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// Once the API recovers, the probe after the cooldown closes the breaker
+	healthy.Store(true)
+	clock.now = clock.now.Add(defaultBreakerCooldown)
+	stocks, err = handler.fetchStocksFromAPI(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Len(t, stocks, 1)
+	assert.Equal(t, "closed", handler.externalBreaker.Status().State)
+}
+
+// TestGetStocksByPage_CircuitOpen validates the 503 without calling the API while the breaker is open
+func TestGetStocksByPage_CircuitOpen(t *testing.T) {
+	t.Setenv("EXTERNAL_BREAKER_THRESHOLD", "1")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	var requests int32
+	var healthy atomic.Bool
+	server := newFailingMockAPI(&requests, &healthy)
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks", handler.GetStocksByPage)
+	router.GET("/debug/external-status", handler.GetExternalStatus)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/stocks", bytes.NewBufferString(`{"page": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest("POST", "/stocks", bytes.NewBufferString(`{"page": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "External API is unavailable")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "Only the first request should reach the API")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The debug endpoint shows the open breaker
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/debug/external-status", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status ExternalStatusResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "open", status.State)
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	assert.Equal(t, "30s", status.Cooldown)
+	assert.NotEmpty(t, status.OpenedAt)
+	assert.NotEmpty(t, status.RetryIn)
+}
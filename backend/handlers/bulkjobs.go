@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultBulkJobTTL is how long a finished bulk job's status stays available when BULK_JOB_TTL is not set.
+const defaultBulkJobTTL = time.Hour
+
+// Bulk job states reported by GET /stocks/bulk/:job_id/status
+const (
+	bulkJobRunning = "running"
+	bulkJobDone    = "done"
+	bulkJobError   = "error"
+)
+
+// bulkJob is the progress of one background bulk fetch.
+type bulkJob struct {
+	status        string
+	pagesTotal    int
+	pagesDone     int
+	pagesWithData int
+	err           string
+	result        gin.H     // Same body a synchronous bulk fetch returns, set once done
+	finishedAt    time.Time // Zero while running
+}
+
+// bulkJobStore tracks bulk jobs by ID in memory.
+// Finished jobs expire ttl after they finish, running jobs never expire. It is safe for concurrent use.
+type bulkJobStore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	jobs map[string]*bulkJob
+}
+
+// newBulkJobStore creates an empty store whose finished jobs expire after ttl.
+func newBulkJobStore(ttl time.Duration) *bulkJobStore {
+	return &bulkJobStore{
+		ttl:  ttl,
+		jobs: make(map[string]*bulkJob),
+	}
+}
+
+// Create registers a running job covering pagesTotal pages and returns its ID.
+// Expired jobs are purged on the way so the map doesn't grow forever.
+func (s *bulkJobStore) Create(pagesTotal int) (string, error) {
+	id, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, job := range s.jobs {
+		if job.expired(now, s.ttl) {
+			delete(s.jobs, key)
+		}
+	}
+	s.jobs[id] = &bulkJob{status: bulkJobRunning, pagesTotal: pagesTotal}
+	return id, nil
+}
+
+// Progress records how many pages of a running job were processed so far.
+func (s *bulkJobStore) Progress(id string, pagesDone, pagesWithData int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok && job.status == bulkJobRunning {
+		job.pagesDone = pagesDone
+		job.pagesWithData = pagesWithData
+	}
+}
+
+// Finish marks a job done with its response body, or failed when err is not nil.
+func (s *bulkJobStore) Finish(id string, result gin.H, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	job.finishedAt = time.Now()
+	if err != nil {
+		job.status = bulkJobError
+		job.err = err.Error()
+		return
+	}
+	job.status = bulkJobDone
+	job.result = result
+}
+
+// Status returns the status body of job id, or false when there is none or it expired.
+func (s *bulkJobStore) Status(id string) (gin.H, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	if job.expired(time.Now(), s.ttl) {
+		delete(s.jobs, id)
+		return nil, false
+	}
+
+	status := gin.H{
+		"job_id":          id,
+		"status":          job.status,
+		"pages_processed": job.pagesDone,
+		"pages_total":     job.pagesTotal,
+		"pages_with_data": job.pagesWithData,
+	}
+	if job.err != "" {
+		status["error"] = job.err
+	}
+	if job.result != nil {
+		status["result"] = job.result
+	}
+	return status, true
+}
+
+// expired reports whether a finished job is older than ttl.
+func (j *bulkJob) expired(now time.Time, ttl time.Duration) bool {
+	return !j.finishedAt.IsZero() && now.Sub(j.finishedAt) > ttl
+}
+
+// newRandomID returns a random hex ID that is hard to guess, for bulk jobs and requests.
+func newRandomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBulkJobStore_Lifecycle validates progress updates and the final state of a job
+func TestBulkJobStore_Lifecycle(t *testing.T) {
+	store := newBulkJobStore(time.Hour)
+
+	id, err := store.Create(20)
+	assert.NoError(t, err)
+	assert.Len(t, id, 32)
+
+	store.Progress(id, 5, 4)
+	status, ok := store.Status(id)
+	assert.True(t, ok)
+	assert.Equal(t, bulkJobRunning, status["status"])
+	assert.Equal(t, 5, status["pages_processed"])
+	assert.Equal(t, 4, status["pages_with_data"])
+	assert.Equal(t, 20, status["pages_total"])
+
+	store.Finish(id, gin.H{"total_stocks": 40}, nil)
+	store.Progress(id, 6, 5) // Late progress must not overwrite a finished job
+	status, _ = store.Status(id)
+	assert.Equal(t, bulkJobDone, status["status"])
+	assert.Equal(t, 5, status["pages_processed"])
+	assert.Equal(t, gin.H{"total_stocks": 40}, status["result"])
+}
+
+// TestBulkJobStore_Error validates that failed jobs keep the error message
+func TestBulkJobStore_Error(t *testing.T) {
+	store := newBulkJobStore(time.Hour)
+	id, _ := store.Create(1)
+
+	store.Finish(id, nil, errors.New("failed to insert final batch: boom"))
+
+	status, ok := store.Status(id)
+	assert.True(t, ok)
+	assert.Equal(t, bulkJobError, status["status"])
+	assert.Equal(t, "failed to insert final batch: boom", status["error"])
+	assert.NotContains(t, status, "result")
+}
+
+// TestBulkJobStore_ExpiresFinishedJobs validates TTL cleanup of finished jobs only
+func TestBulkJobStore_ExpiresFinishedJobs(t *testing.T) {
+	store := newBulkJobStore(time.Millisecond)
+	finished, _ := store.Create(1)
+	running, _ := store.Create(1)
+	store.Finish(finished, gin.H{}, nil)
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := store.Status(finished)
+	assert.False(t, ok, "Finished jobs should expire after the TTL")
+	_, ok = store.Status(running)
+	assert.True(t, ok, "Running jobs should never expire")
+
+	// Creating a job purges expired ones
+	store.Finish(running, gin.H{}, nil)
+	time.Sleep(5 * time.Millisecond)
+	store.Create(1)
+	assert.Len(t, store.jobs, 1)
+}
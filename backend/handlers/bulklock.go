@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// bulkInProgressError is returned with 409 Conflict while another bulk operation runs
+const bulkInProgressError = "bulk operation already in progress"
+
+// bulkReplay is the response remembered for an Idempotency-Key, status 0 while its bulk operation runs
+type bulkReplay struct {
+	status  int
+	body    interface{}
+	expires time.Time // Zero while running
+}
+
+// bulkGuard lets only one bulk operation (clear and fetch) run at a time in this process and
+// remembers the responses of Idempotency-Key requests so a retried request is answered with the
+// first response instead of starting another fetch. Remembered responses expire ttl after they
+// were recorded. It is safe for concurrent use.
+type bulkGuard struct {
+	mu      sync.Mutex
+	running bool
+	ttl     time.Duration
+	keys    map[string]*bulkReplay
+}
+
+// newBulkGuard creates an idle guard whose remembered responses expire after ttl
+func newBulkGuard(ttl time.Duration) *bulkGuard {
+	return &bulkGuard{ttl: ttl, keys: make(map[string]*bulkReplay)}
+}
+
+// Start claims the bulk lock for a request with the optional idempotency key.
+// When key was seen before, replay is its remembered response (status 0 while it still runs)
+// and nothing is claimed. Otherwise ok reports whether the lock was free and is now held,
+// the caller must then call Finish.
+func (g *bulkGuard) Start(key string) (replay *bulkReplay, ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for k, r := range g.keys {
+		if !r.expires.IsZero() && now.After(r.expires) {
+			delete(g.keys, k)
+		}
+	}
+
+	if r, seen := g.keys[key]; key != "" && seen {
+		copied := *r
+		return &copied, false
+	}
+	if g.running {
+		return nil, false
+	}
+	g.running = true
+	if key != "" {
+		g.keys[key] = &bulkReplay{}
+	}
+	return nil, true
+}
+
+// Remember records the response sent for key so retries get it too.
+// Server errors are forgotten instead, a retry with the same key may then run again.
+func (g *bulkGuard) Remember(key string, status int, body interface{}) {
+	if key == "" {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if status >= 500 {
+		delete(g.keys, key)
+		return
+	}
+	g.keys[key] = &bulkReplay{status: status, body: body, expires: time.Now().Add(g.ttl)}
+}
+
+// Finish releases the lock claimed by Start
+func (g *bulkGuard) Finish() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running = false
+}
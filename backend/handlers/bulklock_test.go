@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"smart-stock-recommender/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// bulkRouter routes POST /stocks/bulk, built once per test so concurrent requests share it
+func bulkRouter(handler *StockHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/bulk", handler.GetStocksBulk)
+	return router
+}
+
+// performBulkWithKey performs POST /stocks/bulk with an optional Idempotency-Key header
+func performBulkWithKey(router *gin.Engine, query, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/stocks/bulk"+query, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestGetStocksBulk_ConcurrentRequestConflicts validates that only one of two concurrent bulk requests runs
+func TestGetStocksBulk_ConcurrentRequestConflicts(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.recommendationsCacheTTL = 0
+
+	var fetches int32
+	release := make(chan struct{})
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return bulkFetchResult{TotalFetched: 1, PagesProcessed: 1}, nil
+	}
+
+	router := bulkRouter(handler)
+	codes := make(chan int, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- performBulkWithKey(router, "?wait=true", "", `{"start_page": 1, "end_page": 1}`).Code
+		}()
+	}
+
+	// The request that lost the race answers right away while the winner is still fetching
+	assert.Equal(t, http.StatusConflict, <-codes)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, http.StatusOK, <-codes)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// The lock is released once the winner finished
+	assert.Equal(t, http.StatusOK, performBulkWithKey(router, "?wait=true", "", `{"start_page": 1, "end_page": 1}`).Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStocksBulk_ConflictBody validates the 409 error message while a background job holds the lock
+func TestGetStocksBulk_ConflictBody(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+	handler.recommendationsCacheTTL = 0
+
+	release := make(chan struct{})
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		<-release
+		return bulkFetchResult{}, nil
+	}
+
+	assert.Equal(t, http.StatusAccepted, performBulkJob(handler, `{"start_page": 1, "end_page": 2}`).Code)
+	w := performBulkJob(handler, `{"start_page": 1, "end_page": 2, "confirm_clear": true}`)
+	close(release)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error": "bulk operation already in progress"}`, w.Body.String(), "the clear must not run either")
+}
+
+// TestGetStocksBulk_IdempotencyKey validates that retries with the same key get the first response
+func TestGetStocksBulk_IdempotencyKey(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+	handler.recommendationsCacheTTL = 0
+
+	var fetches int32
+	release := make(chan struct{})
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return bulkFetchResult{TotalFetched: 3, PagesProcessed: 2}, nil
+	}
+
+	router := bulkRouter(handler)
+	first := performBulkWithKey(router, "", "retry-1", `{"start_page": 1, "end_page": 2}`)
+	retry := performBulkWithKey(router, "", "retry-1", `{"start_page": 1, "end_page": 2}`)
+	close(release)
+
+	assert.Equal(t, http.StatusAccepted, first.Code)
+	assert.Equal(t, http.StatusAccepted, retry.Code)
+	var firstJob, retriedJob models.BulkJobResponse
+	assert.NoError(t, json.Unmarshal(first.Body.Bytes(), &firstJob))
+	assert.NoError(t, json.Unmarshal(retry.Body.Bytes(), &retriedJob))
+	assert.Equal(t, firstJob.JobID, retriedJob.JobID, "the retry points at the same job")
+
+	assert.Eventually(t, func() bool {
+		_, status := getBulkJobStatus(t, handler, firstJob.JobID)
+		return status.Status == "done"
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, http.StatusAccepted, performBulkWithKey(router, "", "retry-1", `{"start_page": 1, "end_page": 2}`).Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+// TestBulkGuard_KeyWhileRunning validates that a key whose operation still runs conflicts and server errors are forgotten
+func TestBulkGuard_KeyWhileRunning(t *testing.T) {
+	guard := newBulkGuard(time.Hour)
+
+	_, ok := guard.Start("key")
+	assert.True(t, ok)
+	replay, ok := guard.Start("key")
+	assert.False(t, ok)
+	if assert.NotNil(t, replay) {
+		assert.Equal(t, 0, replay.status, "still running")
+	}
+
+	guard.Remember("key", http.StatusInternalServerError, gin.H{"error": "boom"})
+	guard.Finish()
+	replay, ok = guard.Start("key")
+	assert.Nil(t, replay)
+	assert.True(t, ok, "a failed operation may be retried with its key")
+}
+
+// TestBulkGuard_ExpiresRememberedResponses validates that remembered responses are dropped after the ttl
+func TestBulkGuard_ExpiresRememberedResponses(t *testing.T) {
+	guard := newBulkGuard(time.Millisecond)
+
+	guard.Start("key")
+	guard.Remember("key", http.StatusOK, gin.H{"message": "done"})
+	guard.Finish()
+	time.Sleep(5 * time.Millisecond)
+
+	replay, ok := guard.Start("key")
+	assert.Nil(t, replay)
+	assert.True(t, ok)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache holds a single computed value that stays fresh for a fixed time-to-live.
+// Expired values are kept around so callers can serve them while a refresh runs.
+// It is safe for concurrent use.
+type ttlCache struct {
+	mu         sync.RWMutex
+	value      interface{}
+	computedAt time.Time
+	ttl        time.Duration
+	refreshing bool // A background recompute is in flight
+}
+
+// newTTLCache creates an empty cache whose values expire after ttl.
+// A ttl of 0 disables caching: every Get misses.
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{ttl: ttl}
+}
+
+// Get returns the cached value and its age when it is still fresh.
+func (c *ttlCache) Get() (interface{}, time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.value == nil {
+		return nil, 0, false
+	}
+	age := time.Since(c.computedAt)
+	if age >= c.ttl {
+		return nil, 0, false
+	}
+	return c.value, age, true
+}
+
+// GetStale returns the last stored value and its age even when it has expired.
+// Disabled caches never return a stale value.
+func (c *ttlCache) GetStale() (interface{}, time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.value == nil || c.ttl <= 0 {
+		return nil, 0, false
+	}
+	return c.value, time.Since(c.computedAt), true
+}
+
+// Set stores a freshly computed value.
+func (c *ttlCache) Set(value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.value = value
+	c.computedAt = time.Now()
+}
+
+// Invalidate drops the stored value, so the next Get and GetStale miss.
+func (c *ttlCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.value = nil
+}
+
+// StartRefresh marks a background refresh as in flight.
+// It returns false when another refresh is already running, so only one caller recomputes.
+func (c *ttlCache) StartRefresh() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.refreshing {
+		return false
+	}
+	c.refreshing = true
+	return true
+}
+
+// FinishRefresh clears the in-flight flag set by StartRefresh.
+func (c *ttlCache) FinishRefresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refreshing = false
+}
+
+// Refreshing reports whether a background refresh is in flight.
+func (c *ttlCache) Refreshing() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.refreshing
+}
@@ -0,0 +1,46 @@
+package handlers
+
+/*
+	Helpers to read optional handler settings from environment variables,
+	falling back to sane defaults when a variable is unset or invalid.
+*/
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// intFromEnv reads a non-negative integer from the environment variable name.
+func intFromEnv(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// floatFromEnv reads a non-negative number from the environment variable name.
+func floatFromEnv(name string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// durationFromEnv reads a duration from the environment variable name.
+// Accepts Go duration strings ("30s", "2m") or a plain number of seconds ("30").
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return d
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDurationFromEnv validates duration parsing for handler settings
+// Purpose: Both Go duration strings and plain seconds are accepted, invalid values fall back
+func TestDurationFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 30 * time.Second},
+		{"45s", 45 * time.Second},
+		{"2m", 2 * time.Minute},
+		{"10", 10 * time.Second},
+		{"0", 0},
+		{"soon", 30 * time.Second},
+		{"-5s", 30 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Setenv("TEST_DURATION", test.value)
+		assert.Equal(t, test.expected, durationFromEnv("TEST_DURATION", 30*time.Second), "value: %q", test.value)
+	}
+}
+
+// TestIntFromEnv validates integer parsing for handler settings
+func TestIntFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{"", 5000},
+		{"100", 100},
+		{"0", 0},
+		{"-1", 5000},
+		{"lots", 5000},
+	}
+
+	for _, test := range tests {
+		t.Setenv("TEST_INT", test.value)
+		assert.Equal(t, test.expected, intFromEnv("TEST_INT", 5000), "value: %q", test.value)
+	}
+}
+
+// TestFloatFromEnv validates number parsing for handler settings
+func TestFloatFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"", 0.5},
+		{"0.0004", 0.0004},
+		{"2", 2},
+		{"0", 0},
+		{"cheap", 0.5},
+		{"-1", 0.5},
+	}
+
+	for _, test := range tests {
+		t.Setenv("TEST_FLOAT", test.value)
+		assert.Equal(t, test.expected, floatFromEnv("TEST_FLOAT", 0.5), "value: %q", test.value)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultReadinessTimeout bounds the database ping done by Readiness
+const defaultReadinessTimeout = 2 * time.Second
+
+// HealthHandler answers liveness and readiness probes from load balancers and orchestrators
+type HealthHandler struct {
+	DB               *sql.DB
+	readinessTimeout time.Duration // Max time the readiness database ping may take
+}
+
+// NewHealthHandler creates a HealthHandler that checks the given database connection
+func NewHealthHandler(db *sql.DB) *HealthHandler {
+	return &HealthHandler{
+		DB:               db,
+		readinessTimeout: defaultReadinessTimeout,
+	}
+}
+
+// Liveness reports that the process is up, without touching dependencies.
+// Health probes live outside /api, so they are not part of the Swagger docs.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness reports whether the server can handle traffic, i.e. the database answers a ping
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.readinessTimeout)
+	defer cancel()
+
+	if err := h.DB.PingContext(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":   "unavailable",
+			"database": "unreachable",
+			"error":    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "database": "reachable"})
+}
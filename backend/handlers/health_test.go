@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func performHealthCheck(handler *HealthHandler, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/healthz", handler.Liveness)
+	router.GET("/readyz", handler.Readiness)
+
+	req := httptest.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestLiveness validates that liveness never touches the database
+func TestLiveness(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer db.Close()
+
+	w := performHealthCheck(NewHealthHandler(db), "/healthz")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestReadiness_DatabaseReachable validates the 200 response when the ping succeeds
+func TestReadiness_DatabaseReachable(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer db.Close()
+	mock.ExpectPing()
+
+	w := performHealthCheck(NewHealthHandler(db), "/readyz")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "ok", "database": "reachable"}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestReadiness_DatabaseUnreachable validates the 503 response when the ping fails
+func TestReadiness_DatabaseUnreachable(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer db.Close()
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	w := performHealthCheck(NewHealthHandler(db), "/readyz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Contains(t, w.Body.String(), `"status":"unavailable"`)
+	assert.Contains(t, w.Body.String(), "connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,48 @@
+package handlers
+
+/*
+	Shared HTTP clients for the external stock API and OpenAI. Each is built once
+	per StockHandler, so keep-alive connections are pooled across requests instead
+	of paying a new TCP/TLS handshake per call.
+*/
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// openAIMaxIdleConnsPerHost keeps a few warm connections to OpenAI, chat traffic is far lighter than bulk fetches
+const openAIMaxIdleConnsPerHost = 10
+
+// maxDrainBytes is how much of an unread response body drainAndClose discards to keep the connection reusable
+const maxDrainBytes = 64 << 10
+
+// newPooledTransport clones the default transport keeping up to maxIdlePerHost idle connections per host.
+// The default of 2 would close most connections of a 30-worker bulk fetch after every page.
+func newPooledTransport(maxIdlePerHost int) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdlePerHost
+	transport.MaxIdleConns = max(transport.MaxIdleConns, maxIdlePerHost)
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
+// newExternalAPIClient builds the client shared by every external stock API call,
+// with one idle connection kept per bulk fetch worker and every attempt counted for GET /metrics
+func newExternalAPIClient(timeout time.Duration, maxConcurrent int) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: instrumentedTransport{next: newPooledTransport(maxConcurrent)}}
+}
+
+// newOpenAIHTTPClient builds the client shared by every OpenAI call.
+// It has no overall timeout since streamed answers may run long, attempts are bounded by their context instead.
+func newOpenAIHTTPClient() *http.Client {
+	return &http.Client{Transport: newPooledTransport(openAIMaxIdleConnsPerHost)}
+}
+
+// drainAndClose reads what is left of body, up to maxDrainBytes, before closing it.
+// A body closed before EOF takes its connection down with it instead of returning it to the pool.
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
+}
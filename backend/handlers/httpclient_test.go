@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newConnCountingAPI serves one stock per page after delay and counts the TCP connections clients open to it.
+// The JSON decoder stops reading before the trailing whitespace, which must be drained for a connection to be reused.
+func newConnCountingAPI(delay time.Duration, conns *int32) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"ticker":"AAPL","company":"Apple Inc."}],"next_page":""}` + strings.Repeat(" ", 8192)))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(conns, 1)
+		}
+	}
+	server.Start()
+	return server
+}
+
+// TestExternalClient_ReusesConnection validates that sequential page fetches share one keep-alive connection
+func TestExternalClient_ReusesConnection(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var conns int32
+	server := newConnCountingAPI(0, &conns)
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	for page := 1; page <= 10; page++ {
+		stocks, err := handler.fetchStocksFromAPIWithRetry(context.Background(), page, 1)
+		assert.NoError(t, err)
+		assert.Len(t, stocks, 1)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns), "Every fetch should reuse the first connection")
+}
+
+// TestExternalClient_PoolsConnectionsPerWorker validates that parallel workers keep their connections between pages
+// Purpose: The default transport keeps only 2 idle connections per host, so most workers would reconnect after every round
+func TestExternalClient_PoolsConnectionsPerWorker(t *testing.T) {
+	t.Setenv("BULK_MAX_CONCURRENT", "8")
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var conns int32
+	server := newConnCountingAPI(5*time.Millisecond, &conns)
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	// Rounds of 8 simultaneous fetches leave all 8 connections idle at once between rounds
+	for round := 0; round < 5; round++ {
+		var wg sync.WaitGroup
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func(page int) {
+				defer wg.Done()
+				handler.fetchStocksFromAPIWithRetry(context.Background(), page, 1)
+			}(round*8 + worker + 1)
+		}
+		wg.Wait()
+	}
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&conns), int32(8), "40 fetches by 8 workers should need at most 8 connections")
+}
+
+// TestOpenAIClient_SharedPooledClient validates that OpenAI calls go through one pooled client
+func TestOpenAIClient_SharedPooledClient(t *testing.T) {
+	client := NewHTTPOpenAIClient(defaultOpenAIURL, "sk-test").(*httpOpenAIClient)
+
+	transport, ok := client.client.Transport.(*http.Transport)
+	if assert.True(t, ok, "OpenAI calls should use their own pooled transport") {
+		assert.Equal(t, openAIMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+	assert.Zero(t, client.client.Timeout, "Streams are bounded by their context, not a client timeout")
+}
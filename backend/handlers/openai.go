@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultOpenAIModel is the chat model used when OPENAI_MODEL is not set.
+const defaultOpenAIModel = "gpt-4.1-nano"
+
+// defaultOpenAIURL is the OpenAI chat-completions endpoint.
+const defaultOpenAIURL = "https://api.openai.com/v1/chat/completions"
+
+// allowedChatModels lists the models a chat request may pick with its model field.
+var allowedChatModels = []string{"gpt-4.1-nano", "gpt-4.1-mini", "gpt-4.1", "gpt-4o-mini", "gpt-4o"}
+
+// OpenAI calls are retried on 429, 5xx and network errors, each attempt with its own timeout.
+const (
+	openAIMaxAttempts    = 3
+	openAIAttemptTimeout = 30 * time.Second
+)
+
+// errNoChoices is returned when OpenAI answers without any completion choice.
+var errNoChoices = errors.New("no response from OpenAI")
+
+// errOpenAIRateLimited is returned when OpenAI still answers 429 after every retry.
+var errOpenAIRateLimited = errors.New("OpenAI rate limit exceeded, please try again later")
+
+// errAIUnavailable is returned before calling OpenAI when no API key is configured.
+var errAIUnavailable = errors.New("AI features unavailable: OPENAI_API_KEY not configured")
+
+// OpenAIMessage is a single chat message sent to OpenAI.
+type OpenAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatCompletionRequest holds the parameters of an OpenAI chat-completions call.
+type ChatCompletionRequest struct {
+	Model         string               `json:"model"`
+	Messages      []OpenAIMessage      `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   float64              `json:"temperature"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// openAIStreamOptions asks OpenAI to report token usage in the last chunk of a stream.
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAIClient sends chat-completion requests to OpenAI.
+// Both methods return the content of the first choice and the total tokens used;
+// ChatCompletionStream also hands every content delta to onDelta as it arrives.
+type OpenAIClient interface {
+	ChatCompletion(ctx context.Context, req ChatCompletionRequest) (content string, tokens int, err error)
+	ChatCompletionStream(ctx context.Context, req ChatCompletionRequest, onDelta func(delta string) error) (content string, tokens int, err error)
+}
+
+// httpOpenAIClient is the OpenAIClient that talks to the OpenAI HTTP API.
+type httpOpenAIClient struct {
+	url            string
+	apiKey         string
+	client         *http.Client
+	maxAttempts    int
+	attemptTimeout time.Duration
+	backoff        func(retry int) time.Duration // Wait before each retry, backoffDelay by default
+}
+
+// NewHTTPOpenAIClient creates an OpenAIClient posting to url with the given API key.
+func NewHTTPOpenAIClient(url, apiKey string) OpenAIClient {
+	return &httpOpenAIClient{
+		url:            url,
+		apiKey:         apiKey,
+		client:         newOpenAIHTTPClient(),
+		maxAttempts:    openAIMaxAttempts,
+		attemptTimeout: openAIAttemptTimeout,
+		backoff:        backoffDelay,
+	}
+}
+
+// ChatCompletion posts req to the chat-completions endpoint and decodes the first choice.
+// 429, 5xx and network errors are retried up to maxAttempts times; a final 429 returns errOpenAIRateLimited.
+func (c *httpOpenAIClient) ChatCompletion(ctx context.Context, req ChatCompletionRequest) (string, int, error) {
+	// Marshal request body to JSON
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Retry transient failures with exponential backoff
+	var body []byte
+	err = c.retry(ctx, func() (int, error) {
+		status, respBody, err := c.post(ctx, reqJSON)
+		body = respBody
+		return status, err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Parse response
+	var openAIResp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	// Decode response body
+	if err := json.Unmarshal(body, &openAIResp); err != nil {
+		return "", 0, err
+	}
+
+	if openAIResp.Error.Message != "" {
+		return "", 0, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
+	}
+
+	if len(openAIResp.Choices) == 0 {
+		return "", 0, errNoChoices
+	}
+
+	return openAIResp.Choices[0].Message.Content, openAIResp.Usage.TotalTokens, nil
+}
+
+// ChatCompletionStream requests a streamed completion and calls onDelta with each content chunk as it arrives.
+// It returns the full content and the total tokens reported in the final usage chunk.
+// Failures before the stream starts are retried like ChatCompletion; an error from onDelta stops the stream.
+func (c *httpOpenAIClient) ChatCompletionStream(ctx context.Context, req ChatCompletionRequest, onDelta func(delta string) error) (string, int, error) {
+	req.Stream = true
+	req.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Open the stream, retrying transient failures; no per-attempt timeout since streams may run long
+	var resp *http.Response
+	err = c.retry(ctx, func() (int, error) {
+		httpReq, err := c.newRequest(ctx, reqJSON)
+		if err != nil {
+			return 0, err
+		}
+		r, err := c.client.Do(httpReq)
+		if err != nil {
+			return 0, err
+		}
+		if r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500 {
+			drainAndClose(r.Body)
+		} else {
+			resp = r
+		}
+		return r.StatusCode, nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return "", 0, fmt.Errorf("OpenAI API error: %s", errResp.Error.Message)
+	}
+
+	// Each server-sent event is a "data: {json}" line, the stream ends with "data: [DONE]"
+	var content strings.Builder
+	tokens := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *struct {
+				TotalTokens int `json:"total_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return content.String(), tokens, fmt.Errorf("invalid stream chunk from OpenAI: %v", err)
+		}
+		if chunk.Usage != nil {
+			tokens = chunk.Usage.TotalTokens
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return content.String(), tokens, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return content.String(), tokens, err
+	}
+	if content.Len() == 0 {
+		return "", tokens, errNoChoices
+	}
+
+	return content.String(), tokens, nil
+}
+
+// retry calls attempt up to maxAttempts times with exponential backoff while it fails transiently:
+// a network error, 429 or 5xx. attempt returns the HTTP status it got, or an error when no response came back.
+// A final 429 returns errOpenAIRateLimited.
+func (c *httpOpenAIClient) retry(ctx context.Context, attempt func() (int, error)) error {
+	var lastErr error
+	rateLimited := false
+	for i := 0; i < c.maxAttempts; i++ {
+		if i > 0 {
+			if err := sleepWithContext(ctx, c.backoff(i-1)); err != nil {
+				return err
+			}
+		}
+
+		status, err := attempt()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		switch {
+		case err != nil:
+			lastErr, rateLimited = err, false
+		case status == http.StatusTooManyRequests || status >= 500:
+			lastErr, rateLimited = fmt.Errorf("status %d", status), status == http.StatusTooManyRequests
+		default:
+			return nil
+		}
+	}
+
+	if rateLimited {
+		return errOpenAIRateLimited
+	}
+	return fmt.Errorf("OpenAI API unavailable after %d attempts: %v", c.maxAttempts, lastErr)
+}
+
+// newRequest builds an authenticated chat-completions POST carrying reqJSON.
+func (c *httpOpenAIClient) newRequest(ctx context.Context, reqJSON []byte) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.url, strings.NewReader(string(reqJSON)))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	return httpReq, nil
+}
+
+// post sends one chat-completions attempt bounded by the per-attempt timeout
+// and returns the status code with the full response body.
+func (c *httpOpenAIClient) post(ctx context.Context, reqJSON []byte) (int, []byte, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.attemptTimeout)
+	defer cancel()
+
+	httpReq, err := c.newRequest(attemptCtx, reqJSON)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// make HTTP request
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// aiErrorStatus maps a failure of the AI endpoints to the HTTP status returned to the caller.
+func aiErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errOpenAIRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errRAGQueryTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errAIUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// respondAIError writes err with the status from aiErrorStatus, prefixed with what failed.
+// A missing API key is reported on its own so the message tells exactly what to fix.
+func respondAIError(c *gin.Context, failure string, err error) {
+	if errors.Is(err, errAIUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errAIUnavailable.Error()})
+		return
+	}
+	c.JSON(aiErrorStatus(err), gin.H{"error": fmt.Sprintf("%s: %v", failure, err)})
+}
+
+// checkAIConfigured returns errAIUnavailable when the default OpenAI client has no API key,
+// which OpenAI would only reject after a round trip. Injected clients bring their own credentials.
+func (h *StockHandler) checkAIConfigured() error {
+	if client, ok := h.openAI.(*httpOpenAIClient); ok && strings.TrimSpace(client.apiKey) == "" {
+		return errAIUnavailable
+	}
+	return nil
+}
+
+// openAIModelFromEnv reads OPENAI_MODEL, falling back to defaultOpenAIModel.
+func openAIModelFromEnv() string {
+	if model := strings.TrimSpace(os.Getenv("OPENAI_MODEL")); model != "" {
+		return model
+	}
+	return defaultOpenAIModel
+}
+
+// isAllowedChatModel reports whether a per-request model override may be used.
+// The configured OPENAI_MODEL is always allowed.
+func (h *StockHandler) isAllowedChatModel(model string) bool {
+	return model == h.openAIModel || contains(allowedChatModels, model)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests that matched no route, so scanners probing random paths can't grow the label set
+const unmatchedRoute = "unmatched"
+
+// metricsRegistry holds every collector served by GET /metrics, plus the Go runtime and process collectors
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency in seconds, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	externalAPIRequestsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "external_api_requests_total",
+		Help: "Requests sent to the external stock API.",
+	})
+
+	externalAPIFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "external_api_failures_total",
+		Help: "External stock API requests that failed to connect or answered 429 or 5xx.",
+	})
+
+	openAIRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "openai_requests_total",
+		Help: "Completed OpenAI calls, by feature.",
+	}, []string{"feature"})
+
+	openAITokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "openai_tokens_total",
+		Help: "OpenAI tokens used, by feature.",
+	}, []string{"feature"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		externalAPIRequestsTotal,
+		externalAPIFailuresTotal,
+		openAIRequestsTotal,
+		openAITokensTotal,
+	)
+}
+
+// PrometheusMiddleware counts every request and observes its latency, labelled by the route pattern
+// (e.g. /api/stocks/:id) rather than the raw path so IDs don't create a series each.
+func PrometheusMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		httpRequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(started).Seconds())
+	}
+}
+
+// MetricsHandler serves the collected metrics in the Prometheus text format.
+// Compression is disabled, scrapes are small and usually come from inside the cluster.
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{DisableCompression: true}))
+}
+
+// instrumentedTransport counts the requests sent through it to the external stock API,
+// failures are counted like the circuit breaker does: connection errors, 429 and 5xx
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip sends req through the wrapped transport and records the outcome
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	externalAPIRequestsTotal.Inc()
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		// A request cancelled by its caller isn't the API failing
+		if req.Context().Err() == nil {
+			externalAPIFailuresTotal.Inc()
+		}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		externalAPIFailuresTotal.Inc()
+	}
+	return resp, err
+}
+
+// recordOpenAIMetrics counts one completed OpenAI call of feature that used tokens
+func recordOpenAIMetrics(feature string, tokens int) {
+	openAIRequestsTotal.WithLabelValues(feature).Inc()
+	openAITokensTotal.WithLabelValues(feature).Add(float64(tokens))
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// metricsRouter serves GET /ping and GET /metrics behind the Prometheus middleware
+func metricsRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(PrometheusMiddleware())
+	router.GET("/metrics", MetricsHandler())
+	router.GET("/ping", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "pong"}) })
+	return router
+}
+
+// scrapeMetric scrapes /metrics and returns the value of the sample named series, 0 when it isn't exposed yet
+func scrapeMetric(t *testing.T, router *gin.Engine, series string) float64 {
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "scrapes are served uncompressed")
+
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if value, found := strings.CutPrefix(scanner.Text(), series+" "); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			assert.NoError(t, err)
+			return parsed
+		}
+	}
+	return 0
+}
+
+// TestPrometheusMiddleware_CountsRequests validates that a request increments its counter as seen by a scrape
+func TestPrometheusMiddleware_CountsRequests(t *testing.T) {
+	router := metricsRouter()
+	series := `http_requests_total{method="GET",route="/ping",status="200"}`
+	before := scrapeMetric(t, router, series)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ping", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, before+1, scrapeMetric(t, router, series))
+	assert.Positive(t, scrapeMetric(t, router, `http_request_duration_seconds_count{method="GET",route="/ping"}`))
+}
+
+// TestPrometheusMiddleware_UnmatchedRoute validates that unknown paths share one label instead of one series each
+func TestPrometheusMiddleware_UnmatchedRoute(t *testing.T) {
+	router := metricsRouter()
+	series := `http_requests_total{method="GET",route="unmatched",status="404"}`
+	before := scrapeMetric(t, router, series)
+
+	for _, path := range []string{"/wp-admin", "/.env"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.Equal(t, before+2, scrapeMetric(t, router, series))
+}
+
+// TestInstrumentedTransport_CountsFailures validates that 429 and 5xx answers count as external API failures
+func TestInstrumentedTransport_CountsFailures(t *testing.T) {
+	router := metricsRouter()
+	requestsBefore := scrapeMetric(t, router, "external_api_requests_total")
+	failuresBefore := scrapeMetric(t, router, "external_api_failures_total")
+
+	statuses := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusNotFound}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	client := newExternalAPIClient(5*time.Second, 1)
+	for _, status := range statuses {
+		resp, err := client.Get(server.URL + "?code=" + strconv.Itoa(status))
+		if assert.NoError(t, err) {
+			drainAndClose(resp.Body)
+		}
+	}
+
+	assert.Equal(t, requestsBefore+4, scrapeMetric(t, router, "external_api_requests_total"))
+	assert.Equal(t, failuresBefore+2, scrapeMetric(t, router, "external_api_failures_total"), "404 is an answer, not an outage")
+}
+
+// TestAIUsageTracker_RecordsOpenAIMetrics validates that recorded OpenAI usage is exposed per feature
+func TestAIUsageTracker_RecordsOpenAIMetrics(t *testing.T) {
+	router := metricsRouter()
+	calls := `openai_requests_total{feature="` + aiUsageSummary + `"}`
+	tokens := `openai_tokens_total{feature="` + aiUsageSummary + `"}`
+	callsBefore, tokensBefore := scrapeMetric(t, router, calls), scrapeMetric(t, router, tokens)
+
+	newAIUsageTracker().Record(aiUsageSummary, 120)
+
+	assert.Equal(t, callsBefore+1, scrapeMetric(t, router, calls))
+	assert.Equal(t, tokensBefore+120, scrapeMetric(t, router, tokens))
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Limits applied to AI-generated SQL before it is executed
+const (
+	maxRAGSelectColumns = 15 // Top-level expressions allowed in the SELECT list
+	maxRAGRows          = 50 // Rows a RAG query may return, whatever LIMIT the model wrote
+)
+
+// ragSelectColumns replaces SELECT * in AI-generated SQL: the columns the SQL prompt describes, without id
+var ragSelectColumns = []string{
+	"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to",
+	"time", "created_at", "sector", "target_from_num", "target_to_num",
+}
+
+var (
+	ragFromPattern          = regexp.MustCompile(`(?i)\bfrom\s+stock_ratings\b`)
+	ragSelectStarPattern    = regexp.MustCompile(`(?i)^select\s+(distinct\s+)?\*\s+from\b`)
+	ragTrailingLimitPattern = regexp.MustCompile(`(?i)\blimit\s+(\d+)(\s+offset\s+\d+)?$`)
+	ragLimitPattern         = regexp.MustCompile(`(?i)\blimit\b`)
+)
+
+// guardRAGSQL checks and rewrites an AI-generated SELECT before it runs.
+// The query must read from stock_ratings; SELECT * is expanded to ragSelectColumns, other
+// wildcards and more than maxRAGSelectColumns expressions are rejected. The result is capped
+// at maxRAGRows: a trailing LIMIT is lowered, a missing one is appended, and any other LIMIT
+// is kept inside a subquery that is limited instead.
+func guardRAGSQL(query string) (string, error) {
+	query = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+
+	if !ragFromPattern.MatchString(query) {
+		return "", fmt.Errorf("query must select FROM stock_ratings")
+	}
+
+	query = ragSelectStarPattern.ReplaceAllString(query, "SELECT ${1}"+strings.Join(ragSelectColumns, ", ")+" FROM")
+	items := selectListItems(query)
+	for _, item := range items {
+		if item == "*" || strings.HasSuffix(item, ".*") {
+			return "", fmt.Errorf("SELECT * is only allowed on its own")
+		}
+	}
+	if len(items) > maxRAGSelectColumns {
+		return "", fmt.Errorf("query selects %d columns, at most %d allowed", len(items), maxRAGSelectColumns)
+	}
+
+	if m := ragTrailingLimitPattern.FindStringSubmatchIndex(query); m != nil {
+		if n, err := strconv.Atoi(query[m[2]:m[3]]); err != nil || n > maxRAGRows {
+			query = query[:m[2]] + strconv.Itoa(maxRAGRows) + query[m[3]:]
+		}
+	} else if !ragLimitPattern.MatchString(query) {
+		query = fmt.Sprintf("%s LIMIT %d", query, maxRAGRows)
+	} else {
+		query = fmt.Sprintf("SELECT * FROM (%s) AS rag_query LIMIT %d", query, maxRAGRows)
+	}
+	return query, nil
+}
+
+// selectListItems splits the outer SELECT list of query into its trimmed expressions,
+// ignoring commas inside parentheses and quotes. It stops at the first top-level FROM.
+func selectListItems(query string) []string {
+	lower := strings.ToLower(query)
+	start := len("select")
+	if !strings.HasPrefix(lower, "select") {
+		return nil
+	}
+
+	var items []string
+	depth := 0
+	var quote byte
+	itemStart := start
+	for i := start; i < len(lower); i++ {
+		ch := lower[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case depth == 0 && ch == ',':
+			items = append(items, strings.TrimSpace(query[itemStart:i]))
+			itemStart = i + 1
+		case depth == 0 && strings.HasPrefix(lower[i:], "from") && isWordBoundary(lower, i-1) && isWordBoundary(lower, i+len("from")):
+			return append(items, strings.TrimSpace(query[itemStart:i]))
+		}
+	}
+	return append(items, strings.TrimSpace(query[itemStart:]))
+}
+
+// isWordBoundary reports whether position i of s is outside s or not part of an identifier
+func isWordBoundary(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return true
+	}
+	ch := s[i]
+	return !(ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9'))
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGuardRAGSQL validates the rewrites and rejections applied to AI-generated SQL
+func TestGuardRAGSQL(t *testing.T) {
+	allColumns := strings.Join(ragSelectColumns, ", ")
+	tooMany := "SELECT " + strings.Repeat("ticker, ", maxRAGSelectColumns) + "company FROM stock_ratings"
+
+	cases := []struct {
+		name  string
+		query string
+		want  string
+		err   string
+	}{
+		{"select star rewritten", "SELECT * FROM stock_ratings WHERE ticker = 'AAPL' LIMIT 10",
+			"SELECT " + allColumns + " FROM stock_ratings WHERE ticker = 'AAPL' LIMIT 10", ""},
+		{"select distinct star rewritten", "select distinct * from stock_ratings limit 5",
+			"SELECT distinct " + allColumns + " FROM stock_ratings limit 5", ""},
+		{"missing limit appended", "SELECT ticker, rating_to FROM stock_ratings ORDER BY time DESC",
+			"SELECT ticker, rating_to FROM stock_ratings ORDER BY time DESC LIMIT 50", ""},
+		{"trailing semicolon dropped", "SELECT ticker FROM stock_ratings;", "SELECT ticker FROM stock_ratings LIMIT 50", ""},
+		{"small limit kept", "SELECT ticker FROM stock_ratings LIMIT 20", "SELECT ticker FROM stock_ratings LIMIT 20", ""},
+		{"large limit lowered", "SELECT ticker FROM stock_ratings LIMIT 1000 OFFSET 10",
+			"SELECT ticker FROM stock_ratings LIMIT 50 OFFSET 10", ""},
+		{"other limit wrapped", "SELECT ticker FROM stock_ratings LIMIT (SELECT 500)",
+			"SELECT * FROM (SELECT ticker FROM stock_ratings LIMIT (SELECT 500)) AS rag_query LIMIT 50", ""},
+		{"commas in functions and strings", "SELECT COALESCE(sector, 'a,b'), COUNT(*) FROM stock_ratings GROUP BY 1",
+			"SELECT COALESCE(sector, 'a,b'), COUNT(*) FROM stock_ratings GROUP BY 1 LIMIT 50", ""},
+		{"missing from", "SELECT 1", "", "FROM stock_ratings"},
+		{"other table", "SELECT usename FROM pg_user", "", "FROM stock_ratings"},
+		{"similar table name", "SELECT ticker FROM stock_ratings_backup", "", "FROM stock_ratings"},
+		{"star with other columns", "SELECT *, ticker FROM stock_ratings", "", "SELECT * is only allowed on its own"},
+		{"qualified star", "SELECT s.* FROM stock_ratings s", "", "SELECT * is only allowed on its own"},
+		{"too many columns", tooMany, "", "at most 15 allowed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := guardRAGSQL(tc.query)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// TestExecuteSafeSQL_Guarded validates that the rewritten query is the one sent to the database
+func TestExecuteSafeSQL_Guarded(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + strings.Join(ragSelectColumns, ", ") + " FROM stock_ratings LIMIT 50")).
+		WillReturnRows(sqlmock.NewRows([]string{"ticker"}).AddRow("AAPL"))
+	mock.ExpectRollback()
+
+	results, err := handler.executeSafeSQL(context.Background(), "SELECT * FROM stock_ratings")
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	// Rejected queries never reach the database
+	_, err = handler.executeSafeSQL(context.Background(), "SELECT now()")
+	assert.ErrorContains(t, err, "FROM stock_ratings")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// defaultAIRateLimitPerMinute is how many OpenAI-backed requests are allowed per minute
+// when AI_RATE_LIMIT_PER_MINUTE is not set.
+const defaultAIRateLimitPerMinute = 30
+
+// newAIRateLimiter builds a token bucket that refills perMinute tokens per minute
+// and allows bursts of up to perMinute requests. A limit of 0 disables rate limiting.
+func newAIRateLimiter(perMinute int) *rate.Limiter {
+	if perMinute <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+}
+
+// allowAIRequest takes a token from the shared AI limiter.
+// When the bucket is empty it responds 429 with a Retry-After header and returns false.
+func (h *StockHandler) allowAIRequest(c *gin.Context) bool {
+	reservation := h.aiLimiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true
+	}
+
+	// Give the token back, this request is rejected rather than delayed
+	reservation.Cancel()
+	retryAfter := int(math.Ceil(delay.Seconds()))
+	c.Header("Retry-After", fmt.Sprintf("%d", retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": fmt.Sprintf("AI rate limit exceeded. Retry in %d seconds", retryAfter),
+	})
+	return false
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Rating sentiments used by the metrics, consensus and scoring code
+const (
+	sentimentBullish = "bullish"
+	sentimentNeutral = "neutral"
+	sentimentBearish = "bearish"
+)
+
+// ratingClass classifies one analyst rating.
+// Rank orders ratings on a 1-8 scale (higher = better) to detect upgrades, Strong marks
+// the ratings scored as a strong buy.
+type ratingClass struct {
+	Rank      int    `json:"rank"`
+	Sentiment string `json:"sentiment"`
+	Strong    bool   `json:"strong,omitempty"`
+}
+
+// ratingMap maps lowercased ratings to their class. It is the single source of truth for
+// improvement detection, recommendation scoring and the sentiment buckets of metrics and
+// consensus. RATING_MAP entries are merged over these defaults at startup.
+//
+// RATING HIERARCHY (1-8 scale, higher = better):
+// 1 = Strong Sell (worst)
+// 2 = Sell
+// 3 = Underperform/Underweight/Reduce
+// 4 = Hold
+// 5 = Neutral/Market Perform/Equal Weight
+// 6 = Outperform/Sector Outperform/Accumulate
+// 7 = Buy/Overweight
+// 8 = Strong Buy (best)
+var ratingMap = map[string]ratingClass{
+	"strong sell":         {1, sentimentBearish, false},
+	"sell":                {2, sentimentBearish, false},
+	"underperform":        {3, sentimentBearish, false},
+	"market underperform": {3, sentimentBearish, false},
+	"sector underperform": {3, sentimentBearish, false},
+	"underweight":         {3, sentimentBearish, false},
+	"reduce":              {3, sentimentBearish, false},
+	"negative":            {3, sentimentBearish, false},
+	"hold":                {4, sentimentNeutral, false},
+	"neutral":             {5, sentimentNeutral, false},
+	"market perform":      {5, sentimentNeutral, false},
+	"sector perform":      {5, sentimentNeutral, false},
+	"peer perform":        {5, sentimentNeutral, false},
+	"in-line":             {5, sentimentNeutral, false},
+	"equal weight":        {5, sentimentNeutral, false},
+	"equal-weight":        {5, sentimentNeutral, false},
+	"market weight":       {5, sentimentNeutral, false},
+	"sector weight":       {5, sentimentNeutral, false},
+	"outperform":          {6, sentimentBullish, false},
+	"market outperform":   {6, sentimentBullish, false},
+	"sector outperform":   {6, sentimentBullish, false},
+	"moderate buy":        {6, sentimentBullish, false},
+	"accumulate":          {6, sentimentBullish, false},
+	"positive":            {6, sentimentBullish, false},
+	"buy":                 {7, sentimentBullish, false},
+	"overweight":          {7, sentimentBullish, true},
+	"strong buy":          {8, sentimentBullish, true},
+	"conviction buy":      {8, sentimentBullish, true},
+	"top pick":            {8, sentimentBullish, true},
+}
+
+// ratingKeywords classifies ratings missing from ratingMap by substring, checked in this order
+// so "Sell" wins over "Buy" in a rating that mentions both. Keyword matches get rank 0.
+var ratingKeywords = []struct {
+	sentiment string
+	keywords  []string
+}{
+	{sentimentBearish, []string{"sell", "underperform", "underweight", "reduce"}},
+	{sentimentBullish, []string{"buy", "outperform", "overweight", "accumulate"}},
+	{sentimentNeutral, []string{"hold", "neutral", "perform", "weight"}},
+}
+
+// classifyRating looks rating up in ratingMap, falling back to ratingKeywords.
+// ok is false when the rating matches neither.
+func classifyRating(rating string) (class ratingClass, ok bool) {
+	lower := strings.ToLower(strings.TrimSpace(rating))
+	if class, ok := ratingMap[lower]; ok {
+		return class, true
+	}
+	for _, group := range ratingKeywords {
+		for _, keyword := range group.keywords {
+			if strings.Contains(lower, keyword) {
+				return ratingClass{Sentiment: group.sentiment, Strong: strings.Contains(lower, "strong buy")}, true
+			}
+		}
+	}
+	return ratingClass{}, false
+}
+
+// ratingSentiment returns the sentiment of rating, or "" when it can't be classified
+func ratingSentiment(rating string) string {
+	class, _ := classifyRating(rating)
+	return class.Sentiment
+}
+
+// ratingSentimentSQL builds a condition on column that matches the ratings classifyRating puts in
+// sentiment: the mapped ratings by exact (case-insensitive) value, and unmapped ones by the same
+// ordered keywords.
+func ratingSentimentSQL(column, sentiment string) string {
+	var mapped, matching []string
+	for rating, class := range ratingMap {
+		mapped = append(mapped, sqlQuote(rating))
+		if class.Sentiment == sentiment {
+			matching = append(matching, sqlQuote(rating))
+		}
+	}
+	sort.Strings(mapped)
+	sort.Strings(matching)
+
+	normalized := fmt.Sprintf("LOWER(TRIM(%s))", column)
+	var conditions []string
+	if len(matching) > 0 {
+		conditions = append(conditions, fmt.Sprintf("%s IN (%s)", normalized, strings.Join(matching, ", ")))
+	}
+
+	var earlier []string
+	for _, group := range ratingKeywords {
+		var likes []string
+		for _, keyword := range group.keywords {
+			likes = append(likes, fmt.Sprintf("%s ILIKE '%%%s%%'", column, keyword))
+		}
+		if group.sentiment == sentiment {
+			fallback := fmt.Sprintf("%s NOT IN (%s) AND (%s)", normalized, strings.Join(mapped, ", "), strings.Join(likes, " OR "))
+			if len(earlier) > 0 {
+				fallback += fmt.Sprintf(" AND NOT (%s)", strings.Join(earlier, " OR "))
+			}
+			conditions = append(conditions, "("+fallback+")")
+			break
+		}
+		earlier = append(earlier, likes...)
+	}
+	if len(conditions) == 0 {
+		return "FALSE"
+	}
+	return strings.Join(conditions, " OR ")
+}
+
+// sqlQuote quotes value as a SQL string literal
+func sqlQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// LoadRatingMapFromEnv merges the RATING_MAP environment variable over the default rating map.
+// RATING_MAP is a JSON object of rating to class, e.g. {"Buy-Rated": {"rank": 7, "sentiment": "bullish"}}.
+// It must be called before the handlers serve requests.
+func LoadRatingMapFromEnv() error {
+	raw := strings.TrimSpace(os.Getenv("RATING_MAP"))
+	if raw == "" {
+		return nil
+	}
+	return loadRatingMap(raw)
+}
+
+// loadRatingMap validates every entry of the JSON object raw before merging any into ratingMap
+func loadRatingMap(raw string) error {
+	var entries map[string]ratingClass
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return fmt.Errorf("RATING_MAP must be a JSON object of rating to {rank, sentiment, strong}: %w", err)
+	}
+
+	for rating, class := range entries {
+		if strings.TrimSpace(rating) == "" {
+			return fmt.Errorf("RATING_MAP: rating must not be empty")
+		}
+		switch class.Sentiment {
+		case sentimentBullish, sentimentNeutral, sentimentBearish:
+		default:
+			return fmt.Errorf("RATING_MAP: %q has sentiment %q, must be bullish, neutral or bearish", rating, class.Sentiment)
+		}
+		if class.Rank < 1 || class.Rank > 8 {
+			return fmt.Errorf("RATING_MAP: %q has rank %d, must be between 1 and 8", rating, class.Rank)
+		}
+	}
+	for rating, class := range entries {
+		ratingMap[strings.ToLower(strings.TrimSpace(rating))] = class
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClassifyRating_BrokerTerms validates the broker-specific ratings of the default rating map
+func TestClassifyRating_BrokerTerms(t *testing.T) {
+	tests := []struct {
+		rating    string
+		sentiment string
+		rank      int
+	}{
+		{"Market Perform", sentimentNeutral, 5},
+		{"Equal Weight", sentimentNeutral, 5},
+		{"Equal-Weight", sentimentNeutral, 5},
+		{"Sector Outperform", sentimentBullish, 6},
+		{"Sector Underperform", sentimentBearish, 3},
+		{" strong buy ", sentimentBullish, 8},
+		{"Speculative Buy", sentimentBullish, 0},
+		{"Buy/Sell", sentimentBearish, 0},
+	}
+
+	for _, test := range tests {
+		class, ok := classifyRating(test.rating)
+		assert.True(t, ok, test.rating)
+		assert.Equal(t, test.sentiment, class.Sentiment, test.rating)
+		assert.Equal(t, test.rank, class.Rank, test.rating)
+	}
+
+	_, ok := classifyRating("Not Rated")
+	assert.False(t, ok)
+	assert.False(t, isBuyRating("Market Perform"))
+	assert.False(t, isBearishRating("Equal Weight"))
+	assert.True(t, isBuyRating("Sector Outperform"))
+	assert.False(t, isStrongBuyRating("Sector Outperform"))
+	assert.True(t, isStrongBuyRating("Overweight"))
+}
+
+// TestIsRatingImprovement_BrokerTerms validates upgrades between broker-specific ratings
+func TestIsRatingImprovement_BrokerTerms(t *testing.T) {
+	assert.True(t, isRatingImprovement("Market Perform", "Sector Outperform"))
+	assert.True(t, isRatingImprovement("Underweight", "Equal Weight"))
+	assert.False(t, isRatingImprovement("Equal Weight", "Market Perform"), "same rank is not an upgrade")
+	assert.False(t, isRatingImprovement("Sector Outperform", "Market Perform"))
+}
+
+// TestRatingSentimentSQL validates that the SQL buckets match the mapped ratings and exclude the other sentiments
+func TestRatingSentimentSQL(t *testing.T) {
+	bullish := ratingSentimentSQL("rating_to", sentimentBullish)
+	neutral := ratingSentimentSQL("rating_to", sentimentNeutral)
+
+	assert.Contains(t, bullish, "LOWER(TRIM(rating_to)) IN (")
+	assert.Contains(t, bullish, "'sector outperform'")
+	assert.Contains(t, bullish, "IN ('accumulate', 'buy', 'conviction buy', 'market outperform',", "neutral ratings are not bullish")
+	assert.Contains(t, bullish, "AND NOT (rating_to ILIKE '%sell%'")
+	assert.Contains(t, neutral, "'equal weight'")
+	assert.Contains(t, neutral, "'market perform'")
+	assert.NotContains(t, ratingSentimentSQL("rating_to", sentimentBearish), "AND NOT (")
+}
+
+// TestLoadRatingMap validates that RATING_MAP entries are merged over the defaults and invalid maps rejected
+func TestLoadRatingMap(t *testing.T) {
+	original := make(map[string]ratingClass, len(ratingMap))
+	for rating, class := range ratingMap {
+		original[rating] = class
+	}
+	defer func() { ratingMap = original }()
+
+	assert.NoError(t, loadRatingMap(`{"Buy-Rated": {"rank": 7, "sentiment": "bullish"}, "Market Perform": {"rank": 4, "sentiment": "neutral"}}`))
+	assert.Equal(t, ratingClass{Rank: 7, Sentiment: sentimentBullish}, ratingMap["buy-rated"])
+	assert.Equal(t, 4, ratingMap["market perform"].Rank)
+	assert.Contains(t, ratingSentimentSQL("rating_to", sentimentBullish), "'buy-rated'")
+
+	for _, raw := range []string{
+		`["Buy"]`,
+		`{"Mixed": {"rank": 5, "sentiment": "mixed"}}`,
+		`{"Top Pick": {"rank": 9, "sentiment": "bullish"}}`,
+		`{" ": {"rank": 5, "sentiment": "neutral"}}`,
+	} {
+		assert.Error(t, loadRatingMap(raw), raw)
+	}
+	assert.NotContains(t, ratingMap, "mixed")
+}
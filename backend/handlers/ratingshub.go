@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"smart-stock-recommender/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// ratingsClientBuffer is how many unsent ratings a slow WebSocket client may have queued before the oldest are dropped.
+const ratingsClientBuffer = 256
+
+// ratingsWriteTimeout bounds each write so a stalled client can't hold its goroutine forever.
+const ratingsWriteTimeout = 10 * time.Second
+
+// ratingsHub fans newly inserted ratings out to the connected WebSocket clients.
+// Publishing never blocks ingestion: a client whose buffer is full loses its oldest queued rating.
+// It is safe for concurrent use.
+type ratingsHub struct {
+	mu      sync.Mutex
+	clients map[chan models.StockRatings]struct{}
+}
+
+// newRatingsHub creates a hub with no clients.
+func newRatingsHub() *ratingsHub {
+	return &ratingsHub{clients: make(map[chan models.StockRatings]struct{})}
+}
+
+// Subscribe registers a client and returns the channel its ratings arrive on.
+func (hub *ratingsHub) Subscribe() chan models.StockRatings {
+	ch := make(chan models.StockRatings, ratingsClientBuffer)
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.clients[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe removes a client, its channel receives nothing afterwards.
+func (hub *ratingsHub) Unsubscribe(ch chan models.StockRatings) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	delete(hub.clients, ch)
+}
+
+// Publish queues stock for every client, dropping a full client's oldest rating to make room.
+func (hub *ratingsHub) Publish(stock models.StockRatings) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for ch := range hub.clients {
+		select {
+		case ch <- stock:
+			continue
+		default:
+		}
+
+		// Full: drop the oldest queued rating. Publishers hold the lock, so the freed slot stays free.
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- stock:
+		default:
+		}
+	}
+}
+
+// ratingsUpgrader accepts connections from any origin, matching the API's CORS policy.
+var ratingsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// StreamRatings pushes newly inserted ratings to the client over a WebSocket
+// @Summary Stream newly ingested ratings
+// @Description Upgrades to a WebSocket and sends every stock rating inserted afterwards (by POST /stocks or a bulk fetch) as a JSON text message shaped like models.StockRatings, without its id. Ratings skipped as duplicates are not sent. Clients that fall more than 256 ratings behind lose the oldest ones. Messages sent by the client are ignored.
+// @Tags stocks
+// @Success 101 {object} models.StockRatings "Switching protocols, ratings follow as messages"
+// @Failure 400 {object} models.GenericErrorResponse "Not a WebSocket handshake"
+// @Router /ws [get]
+func (h *StockHandler) StreamRatings(c *gin.Context) {
+	conn, err := ratingsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade already wrote the error response
+		return
+	}
+	defer conn.Close()
+
+	ratings := h.ratingsHub.Subscribe()
+	defer h.ratingsHub.Unsubscribe(ratings)
+
+	// Reading is what notices the client going away, the messages themselves are discarded
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case stock := <-ratings:
+			conn.SetWriteDeadline(time.Now().Add(ratingsWriteTimeout))
+			if err := conn.WriteJSON(stock); err != nil {
+				h.logger.Debug("ratings stream write failed", "error", err)
+				return
+			}
+		}
+	}
+}
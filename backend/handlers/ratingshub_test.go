@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"smart-stock-recommender/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// clientCount returns how many clients are subscribed to the hub
+func (hub *ratingsHub) clientCount() int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.clients)
+}
+
+// waitForClients polls until the hub has want clients or the deadline passes
+func waitForClients(t *testing.T, hub *ratingsHub, want int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.clientCount() != want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, want, hub.clientCount())
+}
+
+// TestStreamRatings_ReceivesInsertedRating validates that a stored rating reaches a connected WebSocket client
+func TestStreamRatings_ReceivesInsertedRating(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handler.StreamRatings)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	waitForClients(t, handler.ratingsHub, 1)
+
+	mock.ExpectExec("INSERT INTO stock_ratings").WillReturnResult(sqlmock.NewResult(1, 1))
+	inserted, err := handler.storeStock(models.StockRatings{Ticker: "AAPL", Company: "Apple Inc.", TargetFrom: "150", TargetTo: "$180"})
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+
+	var received models.StockRatings
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	assert.NoError(t, conn.ReadJSON(&received))
+	assert.Equal(t, "AAPL", received.Ticker)
+	assert.Equal(t, "$150.00", received.TargetFrom, "Ratings are pushed as stored")
+	assert.Equal(t, "$180.00", received.TargetTo)
+
+	// Closing the connection unregisters the client
+	conn.Close()
+	waitForClients(t, handler.ratingsHub, 0)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestStoreStock_DuplicateNotPublished validates that ratings skipped by ON CONFLICT are not pushed
+func TestStoreStock_DuplicateNotPublished(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	ratings := handler.ratingsHub.Subscribe()
+	mock.ExpectExec("INSERT INTO stock_ratings").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err := handler.storeStock(models.StockRatings{Ticker: "AAPL"})
+	assert.NoError(t, err)
+	assert.Empty(t, ratings)
+}
+
+// TestRatingsHub_DropsOldestWhenFull validates the backpressure policy for slow clients
+func TestRatingsHub_DropsOldestWhenFull(t *testing.T) {
+	hub := newRatingsHub()
+	ratings := hub.Subscribe()
+
+	for i := 0; i < ratingsClientBuffer+2; i++ {
+		hub.Publish(models.StockRatings{ID: i})
+	}
+
+	assert.Len(t, ratings, ratingsClientBuffer)
+	assert.Equal(t, 2, (<-ratings).ID, "The two oldest ratings should have been dropped")
+
+	hub.Unsubscribe(ratings)
+	hub.Publish(models.StockRatings{ID: 999})
+	assert.Len(t, ratings, ratingsClientBuffer-1, "Unsubscribed clients receive nothing")
+}
+
+// TestBatchInsert_PublishesInsertedAfterCommit validates that bulk inserts push only the new ratings
+func TestBatchInsert_PublishesInsertedAfterCommit(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	ratings := handler.ratingsHub.Subscribe()
+	expectBulkInsertResults(mock, 1, 0)
+
+	_, _, err := handler.batchInsertStocksWithLogging([]models.StockRatings{{Ticker: "AAPL"}, {Ticker: "MSFT"}}, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, ratings, 1) {
+		assert.Equal(t, "AAPL", (<-ratings).Ticker)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
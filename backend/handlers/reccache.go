@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultRecommendationsCacheTTL is how long recommendations_cache rows are served when RECOMMENDATIONS_CACHE_TTL is not set.
+const defaultRecommendationsCacheTTL = 15 * time.Minute
+
+// recommendationsCacheBatchSize bounds the rows of one multi-row INSERT into recommendations_cache
+const recommendationsCacheBatchSize = 500
+
+// RecommendationsRefreshResponse reports a recomputed recommendations cache
+type RecommendationsRefreshResponse struct {
+	Tickers         int    `json:"tickers" example:"312"` // Recommendations stored, one per ticker
+	RatingsAnalyzed int    `json:"ratings_analyzed" example:"1250"`
+	GeneratedAt     string `json:"generated_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// RefreshRecommendationsCache recomputes the recommendations_cache table
+// @Summary Force a refresh of the cached recommendations
+// @Description Scores every ticker with the default scoring weights and replaces the recommendations_cache table, which GET /stocks/recommendations serves for requests without custom weights, days or only. The cache is also refreshed after every bulk ingest, cleared when ratings are fetched, imported, edited or deleted, and expires after RECOMMENDATIONS_CACHE_TTL (default 15m).
+// @Tags recommendations
+// @Produce json
+// @Param X-API-Key header string false "API key, required when the server has API_KEY set"
+// @Success 200 {object} RecommendationsRefreshResponse "Recommendations recomputed and cached"
+// @Failure 401 {object} models.ErrorResponse "Missing or invalid API key"
+// @Failure 500 {object} models.GenericErrorResponse "Failed to recompute or store the recommendations"
+// @Router /stocks/recommendations/refresh [post]
+func (h *StockHandler) RefreshRecommendationsCache(c *gin.Context) {
+	result, err := h.refreshRecommendationsCache(c.Request.Context())
+	if err != nil {
+		h.requestLogger(c).Error("recommendations cache refresh failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh recommendations cache"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// refreshRecommendationsCache scores every ticker with the default weights and replaces the
+// recommendations_cache rows in one transaction. Every scored ticker is stored, min_score is
+// applied when reading. Refreshes are serialized so concurrent ones can't interleave their rows.
+func (h *StockHandler) refreshRecommendationsCache(ctx context.Context) (RecommendationsRefreshResponse, error) {
+	h.recommendationsRefreshMu.Lock()
+	defer h.recommendationsRefreshMu.Unlock()
+
+	stocks, err := h.queryRecommendationStocks(ctx, 0, nil)
+	if err != nil {
+		return RecommendationsRefreshResponse{}, err
+	}
+	generatedAt := h.now()
+	recommendations := analyzeStocksForRecommendations(stocks, 0, h.scoringWeights.Get(), 0, generatedAt)
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return RecommendationsRefreshResponse{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recommendations_cache"); err != nil {
+		return RecommendationsRefreshResponse{}, err
+	}
+	for start := 0; start < len(recommendations); start += recommendationsCacheBatchSize {
+		end := min(start+recommendationsCacheBatchSize, len(recommendations))
+		if err := insertCachedRecommendations(ctx, tx, recommendations[start:end], len(stocks), generatedAt); err != nil {
+			return RecommendationsRefreshResponse{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return RecommendationsRefreshResponse{}, err
+	}
+
+	h.logger.Info("recommendations cache refreshed", "tickers", len(recommendations), "ratings", len(stocks))
+	return RecommendationsRefreshResponse{
+		Tickers:         len(recommendations),
+		RatingsAnalyzed: len(stocks),
+		GeneratedAt:     generatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+// insertCachedRecommendations stores one batch of recommendations with a multi-row INSERT
+func insertCachedRecommendations(ctx context.Context, tx *sql.Tx, recommendations []StockRecommendation, ratingsAnalyzed int, generatedAt time.Time) error {
+	placeholders := make([]string, 0, len(recommendations))
+	args := make([]interface{}, 0, len(recommendations)*8)
+	for i, rec := range recommendations {
+		details, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		n := i * 8
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8))
+		args = append(args, rec.Ticker, rec.Score, rec.Recommendation, rec.Reason, rec.TargetPrice, string(details), ratingsAnalyzed, generatedAt)
+	}
+
+	query := `INSERT INTO recommendations_cache
+		(ticker, score, recommendation, reason, target, details, ratings_analyzed, generated_at)
+		VALUES ` + strings.Join(placeholders, ", ")
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// cachedRecommendations reads the cached recommendations scoring at least minScore, ranked like
+// analyzeStocksForRecommendations. ok is false when the cache is disabled, empty, stale or unreadable,
+// and the caller should compute the recommendations live.
+func (h *StockHandler) cachedRecommendations(ctx context.Context, minScore float64) (recommendations []StockRecommendation, ratingsAnalyzed int, generatedAt time.Time, ok bool) {
+	if h.recommendationsCacheTTL == 0 {
+		return nil, 0, time.Time{}, false
+	}
+
+	// The cached generated_at comes first so an empty or stale cache is detected without reading every row
+	if err := h.DB.QueryRowContext(ctx, "SELECT generated_at, ratings_analyzed FROM recommendations_cache LIMIT 1").Scan(&generatedAt, &ratingsAnalyzed); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.logger.Warn("recommendations cache unreadable, computing live", "error", err)
+		}
+		return nil, 0, time.Time{}, false
+	}
+	if h.now().Sub(generatedAt) > h.recommendationsCacheTTL {
+		return nil, 0, time.Time{}, false
+	}
+
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT details FROM recommendations_cache
+		WHERE score >= $1
+		ORDER BY score DESC, ticker`, minScore)
+	if err != nil {
+		h.logger.Warn("recommendations cache unreadable, computing live", "error", err)
+		return nil, 0, time.Time{}, false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var details []byte
+		var rec StockRecommendation
+		if err := rows.Scan(&details); err != nil {
+			return nil, 0, time.Time{}, false
+		}
+		if err := json.Unmarshal(details, &rec); err != nil {
+			h.logger.Warn("invalid cached recommendation, computing live", "error", err)
+			return nil, 0, time.Time{}, false
+		}
+		recommendations = append(recommendations, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, time.Time{}, false
+	}
+	return recommendations, ratingsAnalyzed, generatedAt, true
+}
+
+// clearRecommendationsCache empties recommendations_cache so requests compute live until the next refresh,
+// e.g. after the default scoring weights it was ranked with changed.
+func (h *StockHandler) clearRecommendationsCache(ctx context.Context) {
+	if _, err := h.DB.ExecContext(ctx, "DELETE FROM recommendations_cache"); err != nil {
+		h.logger.Error("failed to clear recommendations cache", "error", err)
+	}
+}
+
+// invalidateDerivedCaches drops everything computed from stock_ratings once a write changed it:
+// the cached AI summary, the metrics and the recommendations_cache rows. Deleted or edited
+// ratings would otherwise keep being served until those caches expire.
+func (h *StockHandler) invalidateDerivedCaches(ctx context.Context) {
+	h.summaryCache.Invalidate()
+	h.metricsCache.Invalidate()
+	h.clearRecommendationsCache(ctx)
+}
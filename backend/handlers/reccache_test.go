@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-stock-recommender/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// cacheTestTime is the stubbed handler time used by the recommendations cache tests
+var cacheTestTime = time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)
+
+// expectCachedRecommendations mocks a recommendations_cache computed at generatedAt holding recs
+func expectCachedRecommendations(mock sqlmock.Sqlmock, generatedAt time.Time, recs ...StockRecommendation) {
+	mock.ExpectQuery("SELECT generated_at, ratings_analyzed FROM recommendations_cache").
+		WillReturnRows(sqlmock.NewRows([]string{"generated_at", "ratings_analyzed"}).AddRow(generatedAt, 1250))
+	if cacheTestTime.Sub(generatedAt) > defaultRecommendationsCacheTTL {
+		return
+	}
+	rows := sqlmock.NewRows([]string{"details"})
+	for _, rec := range recs {
+		details, _ := json.Marshal(rec)
+		rows.AddRow(details)
+	}
+	mock.ExpectQuery("SELECT details FROM recommendations_cache").WithArgs(defaultMinScore).WillReturnRows(rows)
+}
+
+// TestGetStockRecommendations_FromCache validates that default requests are served from recommendations_cache
+func TestGetStockRecommendations_FromCache(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.now = func() time.Time { return cacheTestTime }
+
+	generatedAt := cacheTestTime.Add(-time.Minute)
+	cached := []StockRecommendation{
+		{Ticker: "NVDA", Score: 9.1, Recommendation: "Strong Buy", Reason: "Upgraded to Buy", Confidence: 0.88},
+		{Ticker: "AAPL", Score: 7.5, Recommendation: "Buy", Reason: "Target raised by 20.0%"},
+		{Ticker: "MSFT", Score: 6.2, Recommendation: "Buy", Reason: "Target raised by 5.0%"},
+	}
+	expectCachedRecommendations(mock, generatedAt, cached...)
+
+	w := performGetRecommendations(handler, "?limit=1&exclude=NVDA")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Cached)
+	assert.Equal(t, generatedAt.Format(time.RFC3339), response.GeneratedAt)
+	assert.Equal(t, 1250, response.TotalAnalyzed)
+	assert.Equal(t, 2, response.TotalRecommendations, "exclude still applies to cached recommendations")
+	assert.Equal(t, []StockRecommendation{cached[1]}, response.Recommendations)
+	assert.NoError(t, mock.ExpectationsWereMet(), "stock_ratings should not be scanned")
+}
+
+// TestGetStockRecommendations_StaleCache validates that an expired or empty cache falls back to live scoring
+func TestGetStockRecommendations_StaleCache(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.now = func() time.Time { return cacheTestTime }
+
+	expectCachedRecommendations(mock, cacheTestTime.Add(-time.Hour))
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(recommendationRows())
+	mock.ExpectQuery("SELECT generated_at, ratings_analyzed FROM recommendations_cache").
+		WillReturnRows(sqlmock.NewRows([]string{"generated_at", "ratings_analyzed"}))
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(recommendationRows())
+
+	for _, label := range []string{"stale", "empty"} {
+		w := performGetRecommendations(handler, "")
+		assert.Equal(t, http.StatusOK, w.Code, label)
+		var response RecommendationsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.False(t, response.Cached, label)
+		assert.Equal(t, 1, response.TotalAnalyzed, label)
+		assert.Equal(t, "2024-01-20T12:00:00Z", response.GeneratedAt, "%s: live scoring reports the handler clock", label)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_CacheBypassed validates that custom weights, days and only are always scored live
+func TestGetStockRecommendations_CacheBypassed(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"?target_weight=1", "?days=30", "?only=AAPL"} {
+		mock.ExpectQuery("SELECT ticker, company").WillReturnRows(recommendationRows())
+		w := performGetRecommendations(handler, query)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		assert.NotContains(t, w.Body.String(), `"cached":true`, query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// performRefreshRecommendations performs POST /stocks/recommendations/refresh
+func performRefreshRecommendations(handler *StockHandler) *httptest.ResponseRecorder {
+	return serve("POST", "/stocks/recommendations/refresh", "/stocks/recommendations/refresh", handler.RefreshRecommendationsCache, nil)
+}
+
+// TestRefreshRecommendationsCache validates that every scored ticker replaces the cache in one transaction
+func TestRefreshRecommendationsCache(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.now = func() time.Time { return cacheTestTime }
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(tickerRecommendationRows("AAPL", "MSFT", "NVDA"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec("INSERT INTO recommendations_cache").
+		WithArgs(
+			"AAPL", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "$120.00", sqlmock.AnyArg(), 3, cacheTestTime,
+			"MSFT", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "$120.00", sqlmock.AnyArg(), 3, cacheTestTime,
+			"NVDA", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "$120.00", sqlmock.AnyArg(), 3, cacheTestTime,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	w := performRefreshRecommendations(handler)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsRefreshResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, RecommendationsRefreshResponse{Tickers: 3, RatingsAnalyzed: 3, GeneratedAt: "2024-01-20T12:00:00Z"}, response)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestRefreshRecommendationsCache_InsertFails validates that a failed refresh rolls back and keeps the old cache
+func TestRefreshRecommendationsCache_InsertFails(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(tickerRecommendationRows("AAPL"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO recommendations_cache").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	w := performRefreshRecommendations(handler)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to refresh recommendations cache")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStocksBulk_RefreshesRecommendationsCache validates that a bulk ingest that stored ratings rebuilds the cache
+func TestGetStocksBulk_RefreshesRecommendationsCache(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		return bulkFetchResult{TotalFetched: 1, TotalInserted: 1, PagesProcessed: 1}, nil
+	}
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(tickerRecommendationRows("AAPL"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := serve("POST", "/stocks/bulk", "/stocks/bulk?wait=true", handler.GetStocksBulk, strings.NewReader(`{"start_page": 1, "end_page": 1}`))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStocksBulk_FailedFetchAfterClearEmptiesCache validates that a confirmed clear followed by a failed fetch
+// leaves no recommendations_cache rows behind, they would list tickers that are no longer stored
+func TestGetStocksBulk_FailedFetchAfterClearEmptiesCache(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		return bulkFetchResult{}, errors.New("failed to fetch page 1: boom")
+	}
+
+	mock.ExpectExec("DELETE FROM stock_ratings").WillReturnResult(sqlmock.NewResult(0, 40))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 12))
+	// Cleared again once the fetch fails, batches committed before the failure changed the ratings too
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w := serve("POST", "/stocks/bulk", "/stocks/bulk?wait=true", handler.GetStocksBulk, strings.NewReader(`{"start_page": 1, "end_page": 1, "confirm_clear": true}`))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteStockByTicker_ClearsCachedRecommendations validates that a deleted ticker is no longer served from recommendations_cache
+func TestDeleteStockByTicker_ClearsCachedRecommendations(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.now = func() time.Time { return cacheTestTime }
+	handler.summaryCache.Set(SummaryResponse{Summary: "AAPL leads the picks"})
+	handler.metricsCache.Set(models.MetricsData{TotalRecords: 3})
+
+	mock.ExpectExec("DELETE FROM stock_ratings").WithArgs("AAPL").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.Equal(t, http.StatusOK, performDeleteByTicker(handler, "AAPL").Code)
+	_, _, summaryCached := handler.summaryCache.Get()
+	_, _, metricsCached := handler.metricsCache.Get()
+	assert.False(t, summaryCached)
+	assert.False(t, metricsCached)
+
+	// The emptied cache falls back to live scoring, which no longer sees the deleted ratings
+	mock.ExpectQuery("SELECT generated_at, ratings_analyzed FROM recommendations_cache").
+		WillReturnRows(sqlmock.NewRows([]string{"generated_at", "ratings_analyzed"}))
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(
+		sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at"}).
+			AddRow("MSFT", "Microsoft", "target raised by", "Morgan Stanley", "Hold", "Buy", "$400.00", "$460.00", cacheTestTime.Add(-time.Hour), cacheTestTime))
+
+	w := performGetRecommendations(handler, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Cached)
+	assert.NotEmpty(t, response.Recommendations)
+	for _, rec := range response.Recommendations {
+		assert.NotEqual(t, "AAPL", rec.Ticker)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in both directions, a valid incoming value is kept
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey stores the request ID in the gin context and names it in logs and error bodies
+const requestIDKey = "request_id"
+
+// validRequestID accepts IDs set by proxies and clients (UUIDs, trace IDs) but nothing that could forge log fields or headers
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID tags every request with an ID so a reported error can be found in the logs.
+// The incoming X-Request-ID is honored when valid, otherwise a random one is generated.
+// The ID is echoed in the X-Request-ID response header, added as request_id to JSON error bodies
+// and to logs written through requestLogger, and every 5xx response is logged with it.
+func RequestID(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			generated, err := newRandomID()
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate request ID"})
+				return
+			}
+			id = generated
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+
+		writer := &errorBodyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			c.Writer = writer.ResponseWriter
+			body := writer.flush(id)
+			if status := c.Writer.Status(); status >= 500 {
+				logger.Error("request failed",
+					requestIDKey, id,
+					"method", c.Request.Method,
+					"path", c.Request.URL.Path,
+					"status", status,
+					"error", errorMessage(body))
+			}
+		}()
+		c.Next()
+	}
+}
+
+// RequestIDFrom returns the ID RequestID assigned to c, empty when the middleware didn't run
+func RequestIDFrom(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// requestLogger returns the handler logger tagged with the request ID when RequestID ran
+func (h *StockHandler) requestLogger(c *gin.Context) *slog.Logger {
+	if id := RequestIDFrom(c); id != "" {
+		return h.logger.With(requestIDKey, id)
+	}
+	return h.logger
+}
+
+// errorBodyWriter holds back JSON bodies of 4xx and 5xx responses so the request ID can be added
+// before they are sent. Every other response, including streams and websockets, passes straight through.
+type errorBodyWriter struct {
+	gin.ResponseWriter
+	held *bytes.Buffer
+}
+
+// Write buffers the body of a JSON error response and writes anything else through
+func (w *errorBodyWriter) Write(data []byte) (int, error) {
+	if w.held == nil && !w.ResponseWriter.Written() && w.Status() >= 400 &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.held = &bytes.Buffer{}
+	}
+	if w.held != nil {
+		return w.held.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString is Write for strings, gin renders some bodies through it
+func (w *errorBodyWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports a held body as written so handlers don't try to answer twice
+func (w *errorBodyWriter) Written() bool {
+	return w.held != nil || w.ResponseWriter.Written()
+}
+
+// flush sends the held error body with request_id added to it and returns what was sent.
+// Bodies that aren't JSON objects or already carry request_id are sent unchanged.
+func (w *errorBodyWriter) flush(id string) []byte {
+	if w.held == nil {
+		return nil
+	}
+	body := w.held.Bytes()
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err == nil && fields != nil {
+		if _, set := fields[requestIDKey]; !set {
+			fields[requestIDKey] = id
+			if tagged, err := json.Marshal(fields); err == nil {
+				body = tagged
+			}
+		}
+	}
+	w.held = nil
+	w.ResponseWriter.Write(body)
+	return body
+}
+
+// errorMessage extracts the "error" field of a JSON error body for logging
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(body, &parsed)
+	return parsed.Error
+}
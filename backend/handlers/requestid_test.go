@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// requestIDRouter serves a few fixed responses behind the RequestID middleware
+func requestIDRouter(logger *slog.Logger) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(logger))
+	router.GET("/ok", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "ok"}) })
+	router.GET("/bad", func(c *gin.Context) { c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticker"}) })
+	router.GET("/list", func(c *gin.Context) { c.JSON(http.StatusBadRequest, []string{"not", "an", "object"}) })
+	return router
+}
+
+// getWithRequestID performs a GET with an optional X-Request-ID header
+func getWithRequestID(router *gin.Engine, path, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestRequestID_ErrorBody validates that a generated ID is sent as header and added to the error body
+func TestRequestID_ErrorBody(t *testing.T) {
+	router := requestIDRouter(slog.Default())
+
+	w := getWithRequestID(router, "/bad", "")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	id := w.Header().Get(RequestIDHeader)
+	assert.Regexp(t, `^[0-9a-f]{32}$`, id)
+	assert.JSONEq(t, `{"error": "Invalid ticker", "request_id": "`+id+`"}`, w.Body.String())
+}
+
+// TestRequestID_HonorsIncomingHeader validates that a valid incoming ID is kept and an unsafe one replaced
+func TestRequestID_HonorsIncomingHeader(t *testing.T) {
+	router := requestIDRouter(slog.Default())
+
+	w := getWithRequestID(router, "/bad", "edge-7f3c:42")
+	assert.Equal(t, "edge-7f3c:42", w.Header().Get(RequestIDHeader))
+	assert.Contains(t, w.Body.String(), `"request_id":"edge-7f3c:42"`)
+
+	w = getWithRequestID(router, "/bad", `forged" level=ERROR`)
+	assert.NotEqual(t, `forged" level=ERROR`, w.Header().Get(RequestIDHeader))
+	assert.Regexp(t, `^[0-9a-f]{32}$`, w.Header().Get(RequestIDHeader))
+}
+
+// TestRequestID_LeavesOtherBodies validates that successful and non-object bodies are sent unchanged
+func TestRequestID_LeavesOtherBodies(t *testing.T) {
+	router := requestIDRouter(slog.Default())
+
+	w := getWithRequestID(router, "/ok", "")
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	assert.JSONEq(t, `{"message": "ok"}`, w.Body.String())
+
+	w = getWithRequestID(router, "/list", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `["not", "an", "object"]`, w.Body.String())
+}
+
+// TestRequestID_ServerErrorLogged validates that a 500 can be matched to its log lines through the request ID
+func TestRequestID_ServerErrorLogged(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	logs := captureLogs(handler, slog.LevelInfo)
+	mock.ExpectQuery(`SELECT`).WillReturnError(errors.New("connection reset"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(handler.logger))
+	router.POST("/stocks/recommendations/refresh", handler.RefreshRecommendationsCache)
+	req := httptest.NewRequest("POST", "/stocks/recommendations/refresh", nil)
+	req.Header.Set(RequestIDHeader, "support-ticket-1234")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "support-ticket-1234", body["request_id"])
+
+	messages := map[string]map[string]interface{}{}
+	for _, record := range logs() {
+		assert.Equal(t, "support-ticket-1234", record["request_id"], "%v", record["msg"])
+		messages[record["msg"].(string)] = record
+	}
+	assert.Contains(t, messages, "recommendations cache refresh failed")
+	if failed, ok := messages["request failed"]; assert.True(t, ok) {
+		assert.Equal(t, float64(http.StatusInternalServerError), failed["status"])
+		assert.Equal(t, body["error"], failed["error"])
+	}
+}
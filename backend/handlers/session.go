@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultChatSessionTTL is how long an idle chat session keeps its memory when CHAT_SESSION_TTL is not set.
+const defaultChatSessionTTL = 30 * time.Minute
+
+// maxSessionIDLength bounds client-supplied session IDs so they can't be used to bloat the store.
+const maxSessionIDLength = 128
+
+// defaultChatMaxMessageLength bounds chat messages, in characters, when CHAT_MAX_MESSAGE_LENGTH is not set.
+const defaultChatMaxMessageLength = 2000
+
+// maxRecentMessages is how many of the client-supplied recent_messages are kept, the oldest are dropped.
+const maxRecentMessages = 10
+
+// sessionEntry is the server-side memory of one chat session.
+type sessionEntry struct {
+	memory    *ConversationMemory
+	expiresAt time.Time
+}
+
+// sessionStore keeps conversation memory per session ID in memory.
+// Entries expire after ttl without activity. It is safe for concurrent use.
+type sessionStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]sessionEntry
+}
+
+// newSessionStore creates an empty store whose entries expire after ttl.
+func newSessionStore(ttl time.Duration) *sessionStore {
+	return &sessionStore{
+		ttl:     ttl,
+		entries: make(map[string]sessionEntry),
+	}
+}
+
+// Get returns the memory stored for id, or false when there is none or it expired.
+func (s *sessionStore) Get(id string) (*ConversationMemory, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.entries, id)
+		return nil, false
+	}
+	return entry.memory, true
+}
+
+// Set stores memory for id and extends the session's expiry.
+// Expired sessions are purged on the way so the map doesn't grow forever.
+func (s *sessionStore) Set(id string, memory *ConversationMemory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.entries[id] = sessionEntry{memory: memory, expiresAt: now.Add(s.ttl)}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Malicious inputs fed through the filters, they must only ever reach the database as bound arguments
+const (
+	injectionOrTrue   = "' OR 1=1 --"
+	injectionDrop     = "'; DROP TABLE stock_ratings; --"
+	injectionDeleteBy = "ticker; DELETE FROM stock_ratings"
+)
+
+// destructiveSQLPattern matches SQL text that only an injected payload would produce
+var destructiveSQLPattern = regexp.MustCompile(`(?i)\b(drop|delete|truncate|alter|update|insert)\b|--|;|1\s*=\s*1`)
+
+// recordingMock returns a handler whose sqlmock records the SQL text of every executed statement
+func recordingMock(t *testing.T) (*StockHandler, sqlmock.Sqlmock, func() []string) {
+	var mu sync.Mutex
+	var executed []string
+	matcher := sqlmock.QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+		mu.Lock()
+		executed = append(executed, actualSQL)
+		mu.Unlock()
+		return sqlmock.QueryMatcherRegexp.Match(expectedSQL, actualSQL)
+	})
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewStockHandler(db), mock, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), executed...)
+	}
+}
+
+// assertNoInjectedSQL fails when an executed statement carries a payload fragment or destructive SQL
+func assertNoInjectedSQL(t *testing.T, executed []string) {
+	for _, query := range executed {
+		assert.NotRegexp(t, destructiveSQLPattern, query)
+		for _, payload := range []string{injectionOrTrue, injectionDrop, injectionDeleteBy} {
+			assert.NotContains(t, query, payload)
+		}
+	}
+}
+
+// TestSearchStockRatings_InjectionInFilters validates that malicious search_term and action values are bound, not interpolated
+func TestSearchStockRatings_InjectionInFilters(t *testing.T) {
+	handler, mock, executed := recordingMock(t)
+
+	where := regexp.QuoteMeta("WHERE (ticker ILIKE $1 OR company ILIKE $1 OR brokerage ILIKE $1 OR action ILIKE $1 OR rating_from ILIKE $1 OR rating_to ILIKE $1) AND LOWER(action) = LOWER($2)")
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM stock_ratings `+where+`$`).
+		WithArgs("%"+injectionOrTrue+"%", injectionDrop).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT id, ticker, .* FROM stock_ratings\s+`+where+`\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$3 OFFSET \$4$`).
+		WithArgs("%"+injectionOrTrue+"%", injectionDrop, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at"}))
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"page_number": 1,
+		"page_length": 20,
+		"search_term": injectionOrTrue,
+		"action":      injectionDrop,
+		"sort_by":     injectionDeleteBy, // Not a search field, must not change the fixed ORDER BY
+	})
+	w := serve("POST", "/stocks/search", "/stocks/search", handler.SearchStockRatings, bytes.NewReader(body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, executed(), 2)
+	assertNoInjectedSQL(t, executed())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBuildStockFilter_InjectionInEveryField validates that every text filter becomes a placeholder with the literal value as argument
+func TestBuildStockFilter_InjectionInEveryField(t *testing.T) {
+	req := AdvancedSearchRequest{
+		SearchTerm:        injectionOrTrue,
+		Ticker:            injectionDrop,
+		Action:            injectionDrop,
+		Brokerage:         injectionOrTrue,
+		BrokerageContains: injectionDeleteBy,
+		Sector:            injectionDrop,
+		RatingFrom:        injectionOrTrue,
+		RatingTo:          injectionDeleteBy,
+	}
+
+	whereClause, args, nextArgIndex := buildStockFilter(req)
+
+	assert.Equal(t, 9, nextArgIndex)
+	assert.NotRegexp(t, destructiveSQLPattern, whereClause)
+	assert.NotContains(t, whereClause, "'")
+	assert.Equal(t, []interface{}{
+		"%" + injectionOrTrue + "%", injectionDrop, injectionDrop, injectionOrTrue,
+		"%" + injectionDeleteBy + "%", injectionDrop, injectionOrTrue, injectionDeleteBy,
+	}, args)
+}
+
+// TestGetStockRatings_InjectionInSort validates that a malicious sort_by or sort_order is rejected before any SQL runs
+func TestGetStockRatings_InjectionInSort(t *testing.T) {
+	handler, mock, executed := recordingMock(t)
+
+	for _, body := range []map[string]interface{}{
+		{"page_number": 1, "page_length": 20, "sort_by": injectionDeleteBy},
+		{"page_number": 1, "page_length": 20, "sort_by": "ticker", "sort_order": "desc; DROP TABLE stock_ratings"},
+		{"page_number": 1, "page_length": 20, "sort_by": "ticker DESC, (SELECT 1)"},
+	} {
+		payload, _ := json.Marshal(body)
+		w := serve("POST", "/stocks/list", "/stocks/list", handler.GetStockRatings, bytes.NewReader(payload))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%v", body)
+	}
+
+	assert.Empty(t, executed(), "no statement may reach the database")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
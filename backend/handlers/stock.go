@@ -6,57 +6,274 @@ package handlers
 */
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math"
+	"math/rand"
 	"net/http"
 	"os"
+	"regexp"
 	"smart-stock-recommender/models"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"golang.org/x/time/rate"
 )
 
+// defaultExternalAPIURL is the external stock list endpoint used when EXTERNAL_API_URL is not set.
+const defaultExternalAPIURL = "https://api.karenai.click/swechallenge/list"
+
+// defaultBulkResponseLimit caps how many stocks GetStocksBulk returns when BULK_RESPONSE_LIMIT is not set.
+const defaultBulkResponseLimit = 5000
+
+// Bulk fetch tuning: BULK_BATCH_SIZE rows per insert transaction and BULK_MAX_CONCURRENT page fetch workers.
+// Values outside the min/max bounds are clamped. BULK_DEADLINE_SECONDS limits a whole bulk fetch, 0 means no limit.
+const (
+	defaultBulkBatchSize     = 1000
+	minBulkBatchSize         = 1
+	maxBulkBatchSize         = 10000
+	defaultBulkMaxConcurrent = 30
+	minBulkMaxConcurrent     = 1
+	maxBulkMaxConcurrent     = 200
+)
+
+// bulkFetchSettings tunes a single fetchStocksBulkParallel run
+type bulkFetchSettings struct {
+	BatchSize     int           // Buffered stocks that trigger a batch insert
+	MaxConcurrent int           // Page fetches running at the same time
+	Deadline      time.Duration // Max duration of the whole fetch, 0 means no limit
+	// OnProgress, when set, is called after every processed page, e.g. to update a bulk job's status
+	OnProgress func(pagesProcessed, pagesWithData int)
+}
+
+// clampInt limits value to the [min, max] range
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// bulkSettingsFromEnv reads BULK_BATCH_SIZE and BULK_MAX_CONCURRENT, clamped to their bounds, and BULK_DEADLINE_SECONDS
+func bulkSettingsFromEnv() bulkFetchSettings {
+	return bulkFetchSettings{
+		BatchSize:     clampInt(intFromEnv("BULK_BATCH_SIZE", defaultBulkBatchSize), minBulkBatchSize, maxBulkBatchSize),
+		MaxConcurrent: clampInt(intFromEnv("BULK_MAX_CONCURRENT", defaultBulkMaxConcurrent), minBulkMaxConcurrent, maxBulkMaxConcurrent),
+		Deadline:      time.Duration(intFromEnv("BULK_DEADLINE_SECONDS", 0)) * time.Second,
+	}
+}
+
+// withOverrides applies per-request batch size and concurrency, which may only lower the configured values
+func (s bulkFetchSettings) withOverrides(batchSize, maxConcurrent int) bulkFetchSettings {
+	if batchSize > 0 && batchSize < s.BatchSize {
+		s.BatchSize = batchSize
+	}
+	if maxConcurrent > 0 && maxConcurrent < s.MaxConcurrent {
+		s.MaxConcurrent = maxConcurrent
+	}
+	return s
+}
+
+// withDeadline applies a per-request deadline in seconds, which may only shorten a configured deadline
+func (s bulkFetchSettings) withDeadline(seconds int) bulkFetchSettings {
+	requested := time.Duration(seconds) * time.Second
+	if seconds > 0 && (s.Deadline == 0 || requested < s.Deadline) {
+		s.Deadline = requested
+	}
+	return s
+}
+
+// defaultMetricsCacheTTL is how long GetStockMetrics results are reused when METRICS_CACHE_TTL is not set.
+const defaultMetricsCacheTTL = 30 * time.Second
+
+// defaultSummaryCacheTTL is how long a GetStockSummary AI summary is reused when SUMMARY_CACHE_TTL is not set.
+const defaultSummaryCacheTTL = 5 * time.Minute
+
+// defaultRAGSQLTimeoutMs bounds AI-generated SQL when RAG_SQL_TIMEOUT_MS is not set.
+const defaultRAGSQLTimeoutMs = 5000
+
+// ragSQLTimeoutFromEnv reads RAG_SQL_TIMEOUT_MS; 0 would cancel every query, so it falls back to the default.
+func ragSQLTimeoutFromEnv() time.Duration {
+	ms := intFromEnv("RAG_SQL_TIMEOUT_MS", defaultRAGSQLTimeoutMs)
+	if ms == 0 {
+		ms = defaultRAGSQLTimeoutMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// chatMaxMessageFromEnv reads CHAT_MAX_MESSAGE_LENGTH; 0 would reject every message, so it falls back to the default.
+func chatMaxMessageFromEnv() int {
+	length := intFromEnv("CHAT_MAX_MESSAGE_LENGTH", defaultChatMaxMessageLength)
+	if length == 0 {
+		length = defaultChatMaxMessageLength
+	}
+	return length
+}
+
+// defaultExternalAPITimeoutMs bounds each external stock API request when EXTERNAL_API_TIMEOUT_MS is not set.
+const defaultExternalAPITimeoutMs = 20000
+
+// externalAPITimeoutFromEnv reads EXTERNAL_API_TIMEOUT_MS; 0 would fail every request, so it falls back to the default.
+func externalAPITimeoutFromEnv() time.Duration {
+	ms := intFromEnv("EXTERNAL_API_TIMEOUT_MS", defaultExternalAPITimeoutMs)
+	if ms == 0 {
+		ms = defaultExternalAPITimeoutMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// errRAGQueryTimeout is returned when AI-generated SQL runs longer than the RAG timeout.
+var errRAGQueryTimeout = errors.New("query timed out")
+
+// exportFlushEvery is how many CSV rows ExportStockRatings writes between flushes to the client.
+const exportFlushEvery = 500
+
 // StockHandler handles stock-related requests.
 type StockHandler struct {
-	DB *sql.DB
+	DB                *sql.DB
+	baseURL           string               // External stock list endpoint, read from EXTERNAL_API_URL
+	bulkResponseLimit int                  // Max stocks returned by GetStocksBulk, read from BULK_RESPONSE_LIMIT
+	metricsCache      *ttlCache            // Last computed metrics, expires after METRICS_CACHE_TTL
+	summaryCache      *ttlCache            // Last AI summary, expires after SUMMARY_CACHE_TTL or when new data is stored
+	aiLimiter         *rate.Limiter        // Shared by the OpenAI-backed endpoints, sized by AI_RATE_LIMIT_PER_MINUTE
+	openAIModel       string               // Chat model for all OpenAI calls, read from OPENAI_MODEL
+	openAI            OpenAIClient         // Sends chat completions, swappable with WithOpenAIClient
+	aiUsage           *aiUsageTracker      // OpenAI tokens used per AI feature since startup
+	aiCostPer1K       float64              // USD per 1,000 OpenAI tokens for usage estimates, read from AI_COST_PER_1K_TOKENS
+	ragSQLTimeout     time.Duration        // Limit for AI-generated SQL, read from RAG_SQL_TIMEOUT_MS
+	chatSessions      *sessionStore        // Server-side conversation memory, expires after CHAT_SESSION_TTL
+	chatMaxMessage    int                  // Longest accepted chat message in characters, read from CHAT_MAX_MESSAGE_LENGTH
+	logger            *slog.Logger         // Structured logs for bulk fetches and RAG, swappable with WithLogger
+	bulkSettings      bulkFetchSettings    // Bulk fetch batch size and workers, read from BULK_BATCH_SIZE and BULK_MAX_CONCURRENT
+	bulkJobs          *bulkJobStore        // Background bulk fetch progress, finished jobs expire after BULK_JOB_TTL
+	bulkGuard         *bulkGuard           // One bulk operation at a time, Idempotency-Key responses expire after BULK_JOB_TTL
+	ratingsHub        *ratingsHub          // Pushes newly inserted ratings to /ws clients
+	externalClient    *http.Client         // Shared by every external stock API call, its timeout comes from EXTERNAL_API_TIMEOUT_MS
+	perRowInserts     bool                 // Bulk batches insert row by row instead of multi-row, set by BULK_INSERT_MODE=per_row
+	scoringWeights    *scoringWeightsStore // Default scoring weights, replaceable at runtime with PUT /admin/scoring-weights
+	externalBreaker   *circuitBreaker      // Fails external API calls fast while it is down, see EXTERNAL_BREAKER_THRESHOLD
+	// recommendationsCacheTTL is how long recommendations_cache rows are served, RECOMMENDATIONS_CACHE_TTL=0 disables the cache
+	recommendationsCacheTTL  time.Duration
+	recommendationsRefreshMu sync.Mutex // Serializes recommendations_cache refreshes
+	// bulkFetch runs a bulk fetch, fetchStocksBulkParallel unless stubbed in tests
+	bulkFetch func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error)
+	now       func() time.Time // Reference time for recommendation freshness, time.Now unless stubbed in tests
+}
+// StockHandlerOption customizes a StockHandler built by NewStockHandler.
+type StockHandlerOption func(*StockHandler)
+
+// WithOpenAIClient replaces the default HTTP OpenAI client, e.g. with a fake in tests.
+func WithOpenAIClient(client OpenAIClient) StockHandlerOption {
+	return func(h *StockHandler) {
+		h.openAI = client
+	}
+}
+
+// WithLogger replaces the default slog logger, e.g. with the LOG_LEVEL-aware logger built in main.
+func WithLogger(logger *slog.Logger) StockHandlerOption {
+	return func(h *StockHandler) {
+		h.logger = logger
+	}
 }
 
 // NewStockHandler creates a new instance of StockHandler with the given database connection.
+// The external API URL is read once from EXTERNAL_API_URL, falling back to the default endpoint.
+// Options are applied last and override the environment-based defaults.
 // It returns a pointer to the StockHandler.
-func NewStockHandler(db *sql.DB) *StockHandler {
-	return &StockHandler{DB: db}
+func NewStockHandler(db *sql.DB, opts ...StockHandlerOption) *StockHandler {
+	baseURL := os.Getenv("EXTERNAL_API_URL")
+	if baseURL == "" {
+		baseURL = defaultExternalAPIURL
+	}
+
+	h := &StockHandler{
+		DB:                db,
+		baseURL:           baseURL,
+		bulkResponseLimit: intFromEnv("BULK_RESPONSE_LIMIT", defaultBulkResponseLimit),
+		metricsCache:      newTTLCache(durationFromEnv("METRICS_CACHE_TTL", defaultMetricsCacheTTL)),
+		summaryCache:      newTTLCache(durationFromEnv("SUMMARY_CACHE_TTL", defaultSummaryCacheTTL)),
+		aiLimiter:         newAIRateLimiter(intFromEnv("AI_RATE_LIMIT_PER_MINUTE", defaultAIRateLimitPerMinute)),
+		openAIModel:       openAIModelFromEnv(),
+		openAI:            NewHTTPOpenAIClient(defaultOpenAIURL, os.Getenv("OPENAI_API_KEY")),
+		aiUsage:           newAIUsageTracker(),
+		aiCostPer1K:       floatFromEnv("AI_COST_PER_1K_TOKENS", defaultAICostPer1KTokens),
+		ragSQLTimeout:     ragSQLTimeoutFromEnv(),
+		chatSessions:      newSessionStore(durationFromEnv("CHAT_SESSION_TTL", defaultChatSessionTTL)),
+		chatMaxMessage:    chatMaxMessageFromEnv(),
+		logger:            slog.Default(),
+		bulkSettings:      bulkSettingsFromEnv(),
+		bulkJobs:          newBulkJobStore(durationFromEnv("BULK_JOB_TTL", defaultBulkJobTTL)),
+		bulkGuard:         newBulkGuard(durationFromEnv("BULK_JOB_TTL", defaultBulkJobTTL)),
+		ratingsHub:        newRatingsHub(),
+		perRowInserts:     os.Getenv("BULK_INSERT_MODE") == "per_row",
+		scoringWeights:    newScoringWeightsStore(),
+		now:               time.Now,
+	}
+	h.recommendationsCacheTTL = durationFromEnv("RECOMMENDATIONS_CACHE_TTL", defaultRecommendationsCacheTTL)
+	h.externalClient = newExternalAPIClient(externalAPITimeoutFromEnv(), h.bulkSettings.MaxConcurrent)
+	h.externalBreaker = newCircuitBreaker(
+		intFromEnv("EXTERNAL_BREAKER_THRESHOLD", defaultBreakerThreshold),
+		durationFromEnv("EXTERNAL_BREAKER_COOLDOWN", defaultBreakerCooldown))
+	h.externalBreaker.onStateChange = func(from, to breakerState) {
+		h.logger.Warn("external API circuit breaker changed state", "from", from, "to", to)
+	}
+	h.bulkFetch = h.fetchStocksBulkParallel
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// SetBaseURL overrides the external stock list endpoint, e.g. to point at a mock server in tests.
+func (h *StockHandler) SetBaseURL(baseURL string) {
+	h.baseURL = baseURL
+}
+
+// stockListURL builds the external API URL for the given page.
+func (h *StockHandler) stockListURL(page int) string {
+	return fmt.Sprintf("%s?next_page=%d", h.baseURL, page)
+}
+
+// PageStoreResponse is the external API page plus how many of its items were new to the database
+type PageStoreResponse struct {
+	models.ApiResponse
+	NewCount       int `json:"new_count" example:"8"`
+	DuplicateCount int `json:"duplicate_count" example:"2"`
 }
 
 // GetStocksByPage fetches stock data from external API for a single page
 // @Summary Fetch stocks by page number
-// @Description Retrieves stock data from external API for a specific page and stores in database. Returns the raw API response with stock items and next page token.
+// @Description Retrieves stock data from external API for a specific page and stores in database. Returns the raw API response with stock items and next page token, plus new_count (items inserted) and duplicate_count (items already stored).
 // @Tags stocks
 // @Accept json
 // @Produce json
 // @Param request body models.PageRequest true "Request body with page number (integer, required)"
-// @Success 200 {object} models.ApiResponse "Successfully fetched stock data from external API"
+// @Success 200 {object} PageStoreResponse "Successfully fetched stock data from external API"
 // @Failure 400 {object} models.ErrorResponse "Bad request - invalid JSON format, missing page field, or invalid page number"
 // @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Failure 504 {object} models.ErrorResponse "External API did not respond within EXTERNAL_API_TIMEOUT_MS"
 // @Router /stocks [post]
 func (h *StockHandler) GetStocksByPage(c *gin.Context) {
 	// Parse JSON from request body
 	var req models.PageRequest
 
-	// Decode the JSON request body
-	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format in request body"})
-		return
-	}
-
-	// Check if 'page' field is provided
-	if req.Page == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required field 'page' in request body"})
+	// Decode the JSON request body, binding rejects a missing or zero 'page'
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -72,7 +289,7 @@ func (h *StockHandler) GetStocksByPage(c *gin.Context) {
 	}
 
 	// Fetch from external API
-	apiURL := fmt.Sprintf("https://api.karenai.click/swechallenge/list?next_page=%d", req.Page)
+	apiURL := h.stockListURL(req.Page)
 	httpReq, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
@@ -82,18 +299,31 @@ func (h *StockHandler) GetStocksByPage(c *gin.Context) {
 	// Set Authorization Header with the API token from environment variable
 	httpReq.Header.Set("Authorization", "Token "+os.Getenv("API_TOKEN"))
 
-	// Make the request
-	client := &http.Client{Timeout: 30 * time.Second}
+	// Don't wait on an external API that keeps failing
+	if err := h.externalBreaker.Allow(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "External API is unavailable after repeated failures, try again later"})
+		return
+	}
 
 	// Get the response
-	resp, err := client.Do(httpReq)
+	resp, err := h.externalClient.Do(httpReq)
 	if err != nil {
+		h.externalBreaker.RecordFailure()
+		if os.IsTimeout(err) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": fmt.Sprintf("External API did not respond within %s", h.externalClient.Timeout)})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data"})
 		return
 	}
 
-	// Close the response body
-	defer resp.Body.Close()
+	// Close the response body, draining it so the connection goes back to the pool
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		h.externalBreaker.RecordFailure()
+	} else {
+		h.externalBreaker.RecordSuccess()
+	}
 
 	// Decode response
 	var apiResp models.ApiResponse
@@ -101,40 +331,172 @@ func (h *StockHandler) GetStocksByPage(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode response"})
 		return
 	}
-	println("Fetched", len(apiResp.Items), "items from API page:", req.Page)
+	h.logger.Debug("fetched api page", "page", req.Page, "count", len(apiResp.Items))
 
-	// Store in database
+	// Store in database, counting items the ON CONFLICT clause skipped as duplicates
+	response := PageStoreResponse{ApiResponse: apiResp}
 	for _, stock := range apiResp.Items {
-		println("Storing stock:", stock.Ticker, "at time:", stock.Time.String())
-		h.storeStock(stock)
+		h.logger.Debug("storing stock", "ticker", stock.Ticker, "time", stock.Time)
+		inserted, err := h.storeStock(stock)
+		if err != nil {
+			h.requestLogger(c).Error("store stock failed", "ticker", stock.Ticker, "error", err)
+			continue
+		}
+		if inserted {
+			response.NewCount++
+		} else {
+			response.DuplicateCount++
+		}
+	}
+	if response.NewCount > 0 {
+		h.invalidateDerivedCaches(c.Request.Context())
 	}
 
 	// Return the fetched data
-	c.JSON(http.StatusOK, apiResp)
+	c.JSON(http.StatusOK, response)
+}
+
+// maxImportItems caps the ratings accepted by one POST /stocks/import request
+const maxImportItems = 10000
+
+// ImportRequest is a batch of ratings from the client's own data source
+type ImportRequest struct {
+	Items []models.StockRatings `json:"items" binding:"required"`
+}
+
+// ImportItemError explains why the item at Index of an import was rejected
+type ImportItemError struct {
+	Index  int    `json:"index" example:"3"`
+	Ticker string `json:"ticker,omitempty" example:"AAPL"`
+	Error  string `json:"error" example:"target_to must be a non-negative number"`
+}
+
+// ImportResponse reports what happened to each item of an import
+type ImportResponse struct {
+	Inserted int               `json:"inserted" example:"95"`
+	Skipped  int               `json:"skipped" example:"3"` // Already stored or repeated within the request
+	Invalid  int               `json:"invalid" example:"2"`
+	Errors   []ImportItemError `json:"errors,omitempty"`
+}
+
+// validateImportItem checks one imported rating, returning a message for the first problem found
+func validateImportItem(stock models.StockRatings) string {
+	if strings.TrimSpace(stock.Ticker) == "" {
+		return "ticker is required"
+	}
+	if strings.TrimSpace(stock.Company) == "" {
+		return "company is required"
+	}
+	// Missing prices are rejected too, normalizePrice returns "" for them just like for unparseable ones
+	if normalizePrice(stock.TargetFrom) == "" {
+		return "target_from must be a non-negative number"
+	}
+	if normalizePrice(stock.TargetTo) == "" {
+		return "target_to must be a non-negative number"
+	}
+	return ""
+}
+
+// ImportStocks stores ratings sent in the request body instead of fetched from the external API
+// @Summary Import stock ratings from a JSON payload
+// @Description Validates and stores the given ratings through the same batch insert as bulk fetches, skipping ratings already stored. Each item needs a ticker, a company and target_from and target_to prices that are non-negative numbers. By default a single invalid item rejects the whole request with 400 and the per-item errors; with partial=true the valid items are stored and the invalid ones reported in errors.
+// @Tags stocks
+// @Accept json
+// @Produce json
+// @Param request body ImportRequest true "Ratings to store, at most 10,000"
+// @Param partial query bool false "Store the valid items even when some are invalid" default(false)
+// @Success 200 {object} ImportResponse "Inserted, skipped and invalid counts"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid JSON, invalid partial, no items, too many items, or invalid items without partial=true"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/import [post]
+func (h *StockHandler) ImportStocks(c *gin.Context) {
+	partial, err := strconv.ParseBool(c.DefaultQuery("partial", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "partial must be true or false"})
+		return
+	}
+
+	var req ImportRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if len(req.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "items must contain at least one rating"})
+		return
+	}
+	if len(req.Items) > maxImportItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many items (max %d per request)", maxImportItems)})
+		return
+	}
+
+	var response ImportResponse
+	valid := make([]models.StockRatings, 0, len(req.Items))
+	for i, stock := range req.Items {
+		if message := validateImportItem(stock); message != "" {
+			response.Errors = append(response.Errors, ImportItemError{Index: i, Ticker: stock.Ticker, Error: message})
+			continue
+		}
+		stock.Ticker = strings.TrimSpace(stock.Ticker)
+		valid = append(valid, stock)
+	}
+	response.Invalid = len(response.Errors)
+
+	if response.Invalid > 0 && !partial {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("%d of %d items are invalid, nothing was imported", response.Invalid, len(req.Items)),
+			"invalid": response.Invalid,
+			"errors":  response.Errors,
+		})
+		return
+	}
+
+	unique, dropped := dedupeStocks(valid)
+	inserted, skipped, err := h.insertStockBatch(unique, 1)
+	if err != nil {
+		h.requestLogger(c).Error("stock import failed", "count", len(unique), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import stock ratings"})
+		return
+	}
+	if inserted > 0 {
+		h.invalidateDerivedCaches(c.Request.Context())
+	}
+
+	response.Inserted = inserted
+	response.Skipped = skipped + dropped
+	c.JSON(http.StatusOK, response)
 }
 
 // GetStocksBulk fetches stock data from external API for multiple pages
 // @Summary Fetch stocks in bulk for page range with parallel processing
-// @Description Clears existing database data, then fetches stock data from external API for a range of pages using parallel processing. Returns summary statistics of the operation.
+// @Description Fetches stock data from external API for a range of pages using parallel processing and adds it to the database, skipping ratings already stored. By default the fetch runs as a background job: the response is 202 with a job_id whose progress is reported by GET /stocks/bulk/{job_id}/status. With wait=true the request blocks until the fetch is done and returns the summary below. Existing data is deleted first only when confirm_clear is true; cleared tells whether that happened. total_inserted and total_duplicates count the rows actually inserted and the rows skipped because they were already stored. inserted repeats total_inserted and duplicates_skipped adds total_duplicates and duplicates_dropped, so inserted + duplicates_skipped = total_stocks. Returns summary statistics of the operation and the deduplicated stocks fetched (capped by BULK_RESPONSE_LIMIT, default 5,000, with stocks_truncated=true when the cap is hit). If the client disconnects, fetching stops and the response reports cancelled=true with the partial count. If the deadline (BULK_DEADLINE_SECONDS or deadline_seconds) fires, fetching stops, already fetched stocks are stored and the response reports timed_out=true with pages_processed. Only one bulk operation runs at a time: a request made while another one (or a background job) is running gets 409 Conflict. An optional Idempotency-Key header de-dupes retries: a request repeating a key gets the response of the first one (409 while it still runs), server errors are not remembered.
 // @Tags stocks
 // @Accept json
 // @Produce json
-// @Param request body models.BulkPageRequest true "Request body with start_page and end_page (integers, both required, max range 1,000,000)"
-// @Success 200 {object} models.BulkResponse "Successfully processed bulk stock data fetch with parallel processing"
-// @Failure 400 {object} models.ErrorResponse "Bad request - invalid JSON, negative pages, start > end, or range too large"
+// @Param request body models.BulkPageRequest true "Request body with start_page and end_page (integers, both required, max range 1,000,000), optional confirm_clear, optional batch_size/max_concurrent that can only lower BULK_BATCH_SIZE/BULK_MAX_CONCURRENT, and optional deadline_seconds that can only shorten BULK_DEADLINE_SECONDS"
+// @Param wait query bool false "Block until the fetch is done instead of starting a background job" default(false)
+// @Param Idempotency-Key header string false "Retries with the same key within BULK_JOB_TTL get the first response instead of starting another fetch"
+// @Success 200 {object} models.BulkResponse "Successfully processed bulk stock data fetch with parallel processing (wait=true)"
+// @Success 202 {object} models.BulkJobResponse "Bulk fetch started as a background job"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid JSON, invalid wait, negative pages, start > end, or range too large"
+// @Failure 409 {object} models.ErrorResponse "Another bulk operation is already in progress"
 // @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
 // @Router /stocks/bulk [post]
 func (h *StockHandler) GetStocksBulk(c *gin.Context) {
 	var req models.BulkPageRequest
 
-	// Decode the JSON request body
-	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format in request body"})
+	wait, err := strconv.ParseBool(c.DefaultQuery("wait", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wait must be true or false"})
+		return
+	}
+
+	// Decode the JSON request body, binding rejects a missing or zero start_page or end_page
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Validate start_page and end_page
-	if req.StartPage <= 0 || req.EndPage <= 0 {
+	if req.StartPage < 0 || req.EndPage < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "start_page and end_page must be positive"})
 		return
 	}
@@ -155,25 +517,200 @@ func (h *StockHandler) GetStocksBulk(c *gin.Context) {
 		return
 	}
 
-	// Clear existing data
-	if err := h.clearStockRatings(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear existing data"})
+	// One bulk operation at a time, concurrent ones would clear and refill the table under each other.
+	// A retry with a known Idempotency-Key gets the first response instead of starting another fetch.
+	idempotencyKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	replay, ok := h.bulkGuard.Start(idempotencyKey)
+	if replay != nil && replay.status != 0 {
+		c.JSON(replay.status, replay.body)
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": bulkInProgressError})
+		return
+	}
+	respond := func(status int, body interface{}) {
+		h.bulkGuard.Remember(idempotencyKey, status, body)
+		c.JSON(status, body)
+	}
+
+	// Clear existing data only when explicitly confirmed, a mistyped range must not wipe the table.
+	// Without it the inserts' ON CONFLICT DO NOTHING keeps existing ratings and skips duplicates.
+	if req.ConfirmClear {
+		if err := h.clearStockRatings(); err != nil {
+			h.bulkGuard.Finish()
+			respond(http.StatusInternalServerError, gin.H{"error": "Failed to clear existing data"})
+			return
+		}
+		h.discardRecommendationsCache(c.Request.Context())
+	}
+
+	settings := h.bulkSettings.withOverrides(req.BatchSize, req.MaxConcurrent).withDeadline(req.DeadlineSeconds)
+
+	if wait {
+		defer h.bulkGuard.Finish()
+		// Fetch and store in bulk with parallelism.
+		// The request context is cancelled when the client disconnects, which stops the workers.
+		result, err := h.bulkFetch(c.Request.Context(), req.StartPage, req.EndPage, settings)
+		if err != nil {
+			h.discardRecommendationsCache(c.Request.Context())
+			respond(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		h.refreshRecommendationsAfterIngest(c.Request.Context(), req, result)
+		respond(http.StatusOK, bulkResponseBody(req, result))
 		return
 	}
 
-	// Fetch and store in bulk with parallelism.
-	allStocks, totalFetched, err := h.fetchStocksBulkParallel(req.StartPage, req.EndPage)
+	jobID, err := h.bulkJobs.Create(req.EndPage - req.StartPage + 1)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.bulkGuard.Finish()
+		respond(http.StatusInternalServerError, gin.H{"error": "Failed to create bulk job"})
+		return
+	}
+	settings.OnProgress = func(pagesProcessed, pagesWithData int) {
+		h.bulkJobs.Progress(jobID, pagesProcessed, pagesWithData)
+	}
+
+	// The job outlives the request, so it doesn't use the request context; the deadline still applies.
+	// Its logger is taken now, the gin context is recycled once the handler returns.
+	logger := h.requestLogger(c)
+	go func() {
+		defer h.bulkGuard.Finish()
+		logger.Info("bulk job started", "job_id", jobID)
+		result, err := h.bulkFetch(context.Background(), req.StartPage, req.EndPage, settings)
+		if err != nil {
+			logger.Error("bulk job failed", "job_id", jobID, "error", err)
+			h.discardRecommendationsCache(context.Background())
+			h.bulkJobs.Finish(jobID, nil, err)
+			return
+		}
+		logger.Info("bulk job finished", "job_id", jobID)
+		h.refreshRecommendationsAfterIngest(context.Background(), req, result)
+		h.bulkJobs.Finish(jobID, bulkResponseBody(req, result), nil)
+	}()
+
+	respond(http.StatusAccepted, gin.H{
+		"job_id":     jobID,
+		"status":     bulkJobRunning,
+		"status_url": fmt.Sprintf("/api/stocks/bulk/%s/status", jobID),
+	})
+}
+
+// refreshRecommendationsAfterIngest recomputes the recommendations cache once a bulk fetch changed the stored ratings.
+// A failed refresh only logs, the ingest itself succeeded and stale cache rows expire after RECOMMENDATIONS_CACHE_TTL.
+func (h *StockHandler) refreshRecommendationsAfterIngest(ctx context.Context, req models.BulkPageRequest, result bulkFetchResult) {
+	if h.recommendationsCacheTTL == 0 || (result.TotalInserted == 0 && !req.ConfirmClear) {
+		return
+	}
+	if _, err := h.refreshRecommendationsCache(ctx); err != nil {
+		h.logger.Warn("recommendations cache refresh after bulk ingest failed", "error", err)
+	}
+}
+
+// discardRecommendationsCache empties the recommendations cache after a bulk operation changed the stored ratings
+// without rebuilding it: a confirmed clear, or a fetch that failed after some batches were committed.
+// It also runs when ctx was cancelled, a client that went away must not leave deleted tickers cached.
+func (h *StockHandler) discardRecommendationsCache(ctx context.Context) {
+	if h.recommendationsCacheTTL == 0 {
+		return
+	}
+	h.clearRecommendationsCache(context.WithoutCancel(ctx))
+}
+
+// bulkResponseBody builds the JSON summary of a finished bulk fetch
+func bulkResponseBody(req models.BulkPageRequest, result bulkFetchResult) gin.H {
+	message := "Successfully fetched and stored stock data"
+	if result.Cancelled {
+		message = "Bulk fetch cancelled by client, partial data stored"
+	}
+	if result.TimedOut {
+		message = "Bulk fetch deadline reached, partial data stored"
+	}
+
+	return gin.H{
+		"message":            message,
+		"pages_fetched":      fmt.Sprintf("%d-%d", req.StartPage, req.EndPage),
+		"total_stocks":       result.TotalFetched,
+		"stocks":             result.Stocks,
+		"stocks_truncated":   result.Truncated,
+		"cancelled":          result.Cancelled,
+		"timed_out":          result.TimedOut,
+		"pages_processed":    result.PagesProcessed,
+		"duplicates_dropped": result.DuplicatesDropped,
+		"total_inserted":     result.TotalInserted,
+		"total_duplicates":   result.TotalDuplicates,
+		"inserted":           result.TotalInserted,
+		"duplicates_skipped": result.TotalDuplicates + result.DuplicatesDropped,
+		"cleared":            req.ConfirmClear,
+	}
+}
+
+// GetBulkJobStatus reports the progress of a background bulk fetch
+// @Summary Get bulk fetch job status
+// @Description Returns the progress of a bulk fetch started without wait=true: pages_processed out of pages_total, pages_with_data and status (running, done or error). Done jobs include the same result a synchronous bulk fetch returns, failed jobs include error. Finished jobs are kept for BULK_JOB_TTL (default 1h).
+// @Tags stocks
+// @Produce json
+// @Param job_id path string true "Job ID returned by POST /stocks/bulk"
+// @Success 200 {object} models.BulkJobStatusResponse "Current job status"
+// @Failure 404 {object} models.ErrorResponse "Unknown or expired job"
+// @Router /stocks/bulk/{job_id}/status [get]
+func (h *StockHandler) GetBulkJobStatus(c *gin.Context) {
+	jobID := c.Param("job_id")
+	status, ok := h.bulkJobs.Status(jobID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Bulk job %s not found", jobID)})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// tickerPattern matches ticker symbols: 2-5 uppercase letters, same rule as extractTickers
+var tickerPattern = regexp.MustCompile(`^[A-Z]{2,5}$`)
+
+// DeleteStockByTicker removes all ratings stored for a ticker
+// @Summary Delete all ratings for a ticker
+// @Description Deletes every stock rating whose ticker matches the path parameter (case-insensitive). The ticker must be 2-5 letters.
+// @Tags stocks
+// @Produce json
+// @Param ticker path string true "Ticker symbol (2-5 letters)"
+// @Success 200 {object} map[string]interface{} "Ratings deleted, returns the number of rows removed"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid ticker"
+// @Failure 404 {object} models.ErrorResponse "No ratings found for ticker"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/{ticker} [delete]
+func (h *StockHandler) DeleteStockByTicker(c *gin.Context) {
+	ticker := strings.ToUpper(c.Param("ticker"))
+
+	// Validate ticker format before touching the database
+	if !tickerPattern.MatchString(ticker) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticker: must be 2-5 letters"})
 		return
 	}
 
-	// Return success response
+	// Imported tickers are stored as received, UPPER(ticker) also matches ratings saved in lowercase
+	result, err := h.DB.Exec("DELETE FROM stock_ratings WHERE UPPER(ticker) = $1", ticker)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stock ratings"})
+		return
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count deleted stock ratings"})
+		return
+	}
+
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No ratings found for ticker %s", ticker)})
+		return
+	}
+	h.invalidateDerivedCaches(c.Request.Context())
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":       "Successfully fetched and stored stock data",
-		"pages_fetched": fmt.Sprintf("%d-%d", req.StartPage, req.EndPage),
-		"total_stocks":  totalFetched,
-		"stocks":        allStocks,
+		"message":      fmt.Sprintf("Deleted all ratings for ticker %s", ticker),
+		"ticker":       ticker,
+		"deleted_rows": deleted,
 	})
 }
 
@@ -185,58 +722,160 @@ func (h *StockHandler) clearStockRatings() error {
 
 // fetchStocksFromAPI attempts to fetch stock data for a specific page
 // Uses retry logic to find data by trying alternative page numbers
-func (h *StockHandler) fetchStocksFromAPI(page int) ([]models.StockRatings, error) {
-	return h.fetchStocksFromAPIWithRetry(page, 5)
+func (h *StockHandler) fetchStocksFromAPI(ctx context.Context, page int) ([]models.StockRatings, error) {
+	return h.fetchStocksFromAPIWithRetry(ctx, page, 5)
 }
 
+// Backoff settings for transient external API failures (429, 5xx, network errors)
+const (
+	retryBaseDelay = 200 * time.Millisecond // Delay before the first retry, doubled on each one
+	retryMaxDelay  = 5 * time.Second        // Upper bound for a single backoff delay
+)
+
 // fetchStocksFromAPIWithRetry attempts to fetch stock data with retry logic
-// Tries different page numbers using a mathematical pattern to find data
-func (h *StockHandler) fetchStocksFromAPIWithRetry(originalPage, maxRetries int) ([]models.StockRatings, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+// Transient failures (429, 5xx, network errors) retry the same page with exponential backoff
+// Pages without data fall back to trying different page numbers using a mathematical pattern
+// Stops early and returns the context error once ctx is cancelled
+// When the last attempt failed transiently its error is returned, not an empty page
+func (h *StockHandler) fetchStocksFromAPIWithRetry(ctx context.Context, originalPage, maxRetries int) ([]models.StockRatings, error) {
+	pageWalks := 0    // Number of "no data found" fallbacks taken so far
+	backoffs := 0     // Number of transient failures retried so far
+	var lastErr error // Transient failure of the latest attempt, nil once the API answered
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		// Calculate page to try: original page first, then use prime number pattern
-		tryPage := originalPage
-		if attempt > 0 {
-			tryPage = originalPage + attempt*13 // Prime number for better distribution
+		// Don't start another attempt if the caller has given up
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
 
+		// Calculate page to try: original page first, then use prime number pattern
+		tryPage := originalPage + pageWalks*13 // Prime number for better distribution
+
 		// Make API request
-		apiURL := fmt.Sprintf("https://api.karenai.click/swechallenge/list?next_page=%d", tryPage)
-		httpReq, err := http.NewRequest("GET", apiURL, nil)
+		apiURL := h.stockListURL(tryPage)
+		httpReq, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 		if err != nil {
 			continue
 		}
 
 		httpReq.Header.Set("Authorization", "Token "+os.Getenv("API_TOKEN"))
-		resp, err := client.Do(httpReq)
+
+		// Fail fast instead of retrying against an API the breaker considers down
+		if err := h.externalBreaker.Allow(); err != nil {
+			return nil, err
+		}
+		resp, err := h.externalClient.Do(httpReq)
 		if err != nil {
+			// A cancelled request says nothing about the API's health
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			h.externalBreaker.RecordFailure()
+			// Network errors are usually transient, wait before hitting the API again
+			lastErr = err
+			if err := backoffBeforeRetry(ctx, attempt, maxRetries, backoffs); err != nil {
+				return nil, err
+			}
+			backoffs++
+			continue
+		}
+
+		// Rate limited or server error: retry the same page after backing off
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			drainAndClose(resp.Body)
+			h.externalBreaker.RecordFailure()
+			lastErr = fmt.Errorf("external API returned status %d", resp.StatusCode)
+			if err := backoffBeforeRetry(ctx, attempt, maxRetries, backoffs); err != nil {
+				return nil, err
+			}
+			backoffs++
 			continue
 		}
 
+		h.externalBreaker.RecordSuccess()
+		lastErr = nil
+
 		// Parse response
 		var apiResp models.ApiResponse
 		err = json.NewDecoder(resp.Body).Decode(&apiResp)
-		resp.Body.Close()
-		if err != nil {
-			continue
-		}
+		drainAndClose(resp.Body)
 
 		// Return data if found (no logging here to avoid confusion)
-		if len(apiResp.Items) > 0 {
+		if err == nil && len(apiResp.Items) > 0 {
 			return apiResp.Items, nil
 		}
+
+		// No data on this page, walk to the next candidate page
+		pageWalks++
+	}
+
+	// Retries exhausted by failures must not look like a page without data
+	if lastErr != nil {
+		return nil, fmt.Errorf("external API failed after %d attempts: %w", maxRetries, lastErr)
 	}
 
 	// Return empty if no data found after all attempts
 	return []models.StockRatings{}, nil
 }
 
+// backoffBeforeRetry waits out the backoff after a transient failure of attempt,
+// unless it was the last one and no retry follows
+func backoffBeforeRetry(ctx context.Context, attempt, maxRetries, backoffs int) error {
+	if attempt == maxRetries-1 {
+		return nil
+	}
+	return sleepWithContext(ctx, backoffDelay(backoffs))
+}
+
+// backoffDelay returns the exponential backoff delay for the given retry number
+// Starts at retryBaseDelay, doubles on each retry and adds up to 50% random jitter
+// so parallel workers don't retry in lockstep; the result never exceeds retryMaxDelay
+func backoffDelay(retry int) time.Duration {
+	delay := retryBaseDelay
+	for i := 0; i < retry && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// sleepWithContext waits for the given duration or until ctx is cancelled
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bulkFetchResult summarizes a bulk fetch run
+type bulkFetchResult struct {
+	Stocks            []models.StockRatings // Deduplicated stocks fetched, capped at bulkResponseLimit
+	TotalFetched      int                   // Total stocks received from the API, duplicates included
+	Truncated         bool                  // True when more unique stocks were fetched than returned
+	Cancelled         bool                  // True when ctx was cancelled before every page was processed
+	TimedOut          bool                  // True when the bulk deadline fired before every page was processed
+	PagesProcessed    int                   // Pages whose fetch completed, with or without data
+	DuplicatesDropped int                   // Buffered stocks dropped as in-memory duplicates before inserting
+	TotalInserted     int                   // Rows inserted by the batch transactions
+	TotalDuplicates   int                   // Rows skipped by the batch transactions because they were already stored
+}
+
 /*
 fetchStocksBulkParallel fetches stock data for a range of pages in parallel
 and stores them in the database.
 
-It returns the combined list of stocks fetched and the total count.
+It returns the deduplicated list of stocks fetched (up to h.bulkResponseLimit),
+the total count and whether the fetch was cancelled through ctx before every
+page was processed.
+When ctx is cancelled no new page fetches are launched, in-flight workers
+drop their results, and the stocks fetched so far are still stored.
 
 Expected Body format:
 
@@ -245,13 +884,27 @@ Expected Body format:
 		"end_page": 22
 	}
 */
-func (h *StockHandler) fetchStocksBulkParallel(startPage, endPage int) ([]models.StockRatings, int, error) {
-	const BATCH_SIZE = 1000 // Configurable batch size
-	const MAX_CONCURRENT = 30
+func (h *StockHandler) fetchStocksBulkParallel(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+	batchSize := settings.BatchSize
+	maxConcurrent := settings.MaxConcurrent
+
+	// The deadline stops workers like a client disconnect does, whatever was fetched is still stored
+	if settings.Deadline > 0 {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithTimeout(ctx, settings.Deadline)
+		defer cancelDeadline()
+	}
+
+	// Whatever got stored, even by a run that fails halfway, makes the cached AI summary and metrics outdated.
+	// recommendations_cache is refreshed by the caller once the ingest is done.
+	defer func() {
+		h.summaryCache.Invalidate()
+		h.metricsCache.Invalidate()
+	}()
 
 	pageCount := endPage - startPage + 1
-	println("🚀 Starting bulk fetch for", pageCount, "pages (from", startPage, "to", endPage, ")")
-	println("📊 Configuration: Batch size =", BATCH_SIZE, ", Max concurrent =", MAX_CONCURRENT)
+	h.logger.Info("bulk fetch started", "pages", pageCount, "start_page", startPage, "end_page", endPage)
+	h.logger.Debug("bulk fetch configuration", "batch_size", batchSize, "max_concurrent", maxConcurrent)
 
 	type result struct {
 		stocks []models.StockRatings
@@ -259,189 +912,449 @@ func (h *StockHandler) fetchStocksBulkParallel(startPage, endPage int) ([]models
 		err    error
 	}
 
+	// workerCtx is also cancelled when we return early on an error,
+	// so workers never block forever on a results channel nobody reads
+	workerCtx, cancelWorkers := context.WithCancel(ctx)
+	defer cancelWorkers()
+
 	results := make(chan result, 100) // Smaller buffer to prevent memory issues
 	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, MAX_CONCURRENT)
+	semaphore := make(chan struct{}, maxConcurrent)
 
-	// Start goroutines for fetching
-	println("🔄 Launching", MAX_CONCURRENT, "concurrent workers...")
-	for page := startPage; page <= endPage; page++ {
-		wg.Add(1)
-		go func(p int) {
-			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+	// Launch goroutines for fetching, stopping as soon as the context is cancelled
+	h.logger.Debug("launching fetch workers", "workers", maxConcurrent)
+	go func() {
+		defer func() {
+			wg.Wait()
+			close(results)
+			h.logger.Debug("fetch workers finished")
+		}()
+
+		for page := startPage; page <= endPage; page++ {
+			if workerCtx.Err() != nil {
+				return
+			}
+			select {
+			case semaphore <- struct{}{}:
+			case <-workerCtx.Done():
+				return
+			}
 
-			stocks, err := h.fetchStocksFromAPI(p)
-			results <- result{stocks: stocks, page: p, err: err}
-		}(page)
-	}
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
 
-	go func() {
-		wg.Wait()
-		close(results)
-		println("✅ All workers finished fetching")
+				stocks, err := h.fetchStocksFromAPI(workerCtx, p)
+				select {
+				case results <- result{stocks: stocks, page: p, err: err}:
+				case <-workerCtx.Done():
+				}
+			}(page)
+		}
 	}()
 
 	// Process results with detailed logging
 	var stockBuffer []models.StockRatings
+	allStocks := []models.StockRatings{}
+	seenStocks := make(map[string]bool) // Unique keys of the stocks in allStocks
+	truncated := false
 	totalFetched := 0
 	pagesWithData := 0
 	batchCount := 0
 	processedPages := 0
+	duplicatesDropped := 0
+	totalInserted := 0
+	totalDuplicates := 0
+
+	// insertBuffer drops in-memory duplicates (e.g. from overlapping fallback pages) before inserting,
+	// so the database doesn't spend transaction time rejecting them through ON CONFLICT
+	insertBuffer := func() error {
+		unique, dropped := dedupeStocks(stockBuffer)
+		duplicatesDropped += dropped
+		if dropped > 0 {
+			h.logger.Debug("dropped duplicate stocks from batch", "batch", batchCount, "count", dropped)
+		}
+		inserted, skipped, err := h.insertStockBatch(unique, batchCount)
+		if err != nil {
+			return err
+		}
+		totalInserted += inserted
+		totalDuplicates += skipped
+		return nil
+	}
 
 	for res := range results {
-		processedPages++
-
 		if res.err != nil {
-			println("❌ Error on page", res.page, ":", res.err.Error())
-			return nil, 0, fmt.Errorf("failed to fetch page %d: %v", res.page, res.err)
+			// Workers racing the cancellation report the context error, which is not a failure
+			if ctx.Err() != nil {
+				continue
+			}
+			h.logger.Error("page fetch failed", "page", res.page, "error", res.err)
+			return bulkFetchResult{}, fmt.Errorf("failed to fetch page %d: %v", res.page, res.err)
 		}
+		processedPages++
 
 		// Process pages with data
 		if len(res.stocks) > 0 {
 			stockBuffer = append(stockBuffer, res.stocks...)
 			totalFetched += len(res.stocks)
+
+			// Keep unique stocks for the response until the cap is reached
+			for _, stock := range res.stocks {
+				key := stockUniqueKey(stock)
+				if seenStocks[key] {
+					continue
+				}
+				if len(allStocks) >= h.bulkResponseLimit {
+					truncated = true
+					continue
+				}
+				seenStocks[key] = true
+				allStocks = append(allStocks, stock)
+			}
 			pagesWithData++
 
 			// Trigger batch insert when buffer reaches limit
-			if len(stockBuffer) >= BATCH_SIZE {
+			if len(stockBuffer) >= batchSize {
 				batchCount++
-				println("💾 BATCH", batchCount, ": Processing", len(stockBuffer), "stocks...")
+				h.logger.Debug("processing batch", "batch", batchCount, "count", len(stockBuffer))
 
-				if err := h.batchInsertStocksWithLogging(stockBuffer, batchCount); err != nil {
-					return nil, 0, fmt.Errorf("failed to insert batch %d: %v", batchCount, err)
+				if err := insertBuffer(); err != nil {
+					return bulkFetchResult{}, fmt.Errorf("failed to insert batch %d: %v", batchCount, err)
 				}
 
 				stockBuffer = stockBuffer[:0] // Clear buffer
 			}
 		}
 
+		if settings.OnProgress != nil {
+			settings.OnProgress(processedPages, pagesWithData)
+		}
+
 		// Progress update every 1000 pages
 		if processedPages%1000 == 0 {
-			println("📈 Progress:", processedPages, "/", pageCount, "pages processed (", fmt.Sprintf("%.1f%%", float64(processedPages)/float64(pageCount)*100), ")")
+			h.logger.Debug("bulk fetch progress", "processed_pages", processedPages, "pages", pageCount)
 		}
 	}
 
 	// Insert remaining stocks
 	if len(stockBuffer) > 0 {
 		batchCount++
-		println("💾 FINAL BATCH", batchCount, ": Inserting remaining", len(stockBuffer), "stocks...")
-		if err := h.batchInsertStocksWithLogging(stockBuffer, batchCount); err != nil {
-			return nil, 0, fmt.Errorf("failed to insert final batch: %v", err)
+		h.logger.Debug("processing final batch", "batch", batchCount, "count", len(stockBuffer))
+		if err := insertBuffer(); err != nil {
+			return bulkFetchResult{}, fmt.Errorf("failed to insert final batch: %v", err)
 		}
-		println("✅ FINAL BATCH", batchCount, "successfully inserted")
+	}
+
+	summary := bulkFetchResult{
+		Stocks:            allStocks,
+		TotalFetched:      totalFetched,
+		Truncated:         truncated,
+		PagesProcessed:    processedPages,
+		DuplicatesDropped: duplicatesDropped,
+		TotalInserted:     totalInserted,
+		TotalDuplicates:   totalDuplicates,
+	}
+
+	// Deadline hit or client went away: report what we managed to store and skip the verification query
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		h.logger.Warn("bulk fetch deadline reached", "processed_pages", processedPages, "pages", pageCount, "fetched", totalFetched)
+		summary.TimedOut = true
+		return summary, nil
+	}
+	if ctx.Err() != nil {
+		h.logger.Warn("bulk fetch cancelled", "processed_pages", processedPages, "pages", pageCount, "fetched", totalFetched)
+		summary.Cancelled = true
+		return summary, nil
 	}
 
 	// Get actual database count for verification
 	var actualCount int
 	h.DB.QueryRow("SELECT COUNT(*) FROM stock_ratings").Scan(&actualCount)
 
-	println("🎉 SUMMARY: Processed", processedPages, "pages, found data in", pagesWithData, "pages")
-	println("📊 Total stocks fetched:", totalFetched, "| Total batches processed:", batchCount)
-	println("💾 Database verification: Actual records in DB =", actualCount)
-	if actualCount < totalFetched {
-		println("⚠️  Note:", totalFetched-actualCount, "duplicates were skipped due to UNIQUE constraint")
+	h.logger.Info("bulk fetch finished", "processed_pages", processedPages, "pages_with_data", pagesWithData,
+		"fetched", totalFetched, "inserted", totalInserted, "duplicates", totalDuplicates,
+		"duplicates_dropped", duplicatesDropped, "batches", batchCount, "rows_in_db", actualCount)
+	return summary, nil
+}
+
+// stockUniqueKey builds a key from the columns of the stock_ratings UNIQUE constraint
+func stockUniqueKey(stock models.StockRatings) string {
+	return strings.Join([]string{
+		stock.Ticker, stock.Brokerage, stock.Action, stock.RatingFrom, stock.RatingTo,
+		stock.Time.UTC().Format(time.RFC3339Nano),
+	}, "|")
+}
+
+// dedupeStocks drops stocks sharing a stockUniqueKey with an earlier one, keeping the first occurrence.
+// It returns the unique stocks in their original order and how many duplicates were dropped.
+func dedupeStocks(stocks []models.StockRatings) ([]models.StockRatings, int) {
+	seen := make(map[string]bool, len(stocks))
+	unique := make([]models.StockRatings, 0, len(stocks))
+	for _, stock := range stocks {
+		key := stockUniqueKey(stock)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, stock)
 	}
-	return []models.StockRatings{}, totalFetched, nil
+	return unique, len(stocks) - len(unique)
 }
 
 // batchInsertStocksWithLogging inserts stock records in a single database transaction
-// Provides progress updates for large batches and detailed error reporting
-func (h *StockHandler) batchInsertStocksWithLogging(stocks []models.StockRatings, batchNum int) error {
+// Provides progress updates for large batches and detailed error reporting.
+// Returns how many rows were inserted and how many were skipped as already stored, both 0 when the transaction fails.
+func (h *StockHandler) batchInsertStocksWithLogging(stocks []models.StockRatings, batchNum int) (inserted, skipped int, err error) {
 	if len(stocks) == 0 {
-		return nil
+		return 0, 0, nil
 	}
 
 	// Begin database transaction
 	tx, err := h.DB.Begin()
 	if err != nil {
-		println("❌ BATCH", batchNum, ": Transaction failed:", err.Error())
-		return err
+		h.logger.Error("batch transaction failed", "batch", batchNum, "error", err)
+		return 0, 0, err
 	}
 	defer tx.Rollback()
 
 	// Prepare insert statement
 	stmt, err := tx.Prepare(`
-		INSERT INTO stock_ratings (ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO stock_ratings (ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at, sector, target_from_num, target_to_num)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13)
 		ON CONFLICT (ticker, brokerage, action, rating_from, rating_to, time) DO NOTHING`)
 	if err != nil {
-		println("❌ BATCH", batchNum, ": Statement preparation failed:", err.Error())
-		return err
+		h.logger.Error("batch statement preparation failed", "batch", batchNum, "error", err)
+		return 0, 0, err
 	}
 	defer stmt.Close()
 
 	// Execute inserts with progress tracking
 	insertedCount := 0
 	skippedCount := 0
+	var insertedStocks []models.StockRatings // Published to /ws clients once the transaction commits
 	for i, stock := range stocks {
+		stored := storedStock(stock)
 		result, err := stmt.Exec(
-			stock.Ticker, stock.TargetFrom, stock.TargetTo, stock.Company,
-			stock.Action, stock.Brokerage, stock.RatingFrom, stock.RatingTo,
-			stock.Time, time.Now())
+			stored.Ticker, stored.TargetFrom, stored.TargetTo, stored.Company,
+			stored.Action, stored.Brokerage, stored.RatingFrom, stored.RatingTo,
+			stored.Time, stored.CreatedAt, stored.Sector, priceNumeric(stored.TargetFrom), priceNumeric(stored.TargetTo))
 		if err != nil {
-			println("❌ BATCH", batchNum, ": Insert failed for", stock.Ticker, ":", err.Error())
-			return err
+			h.logger.Error("batch insert failed", "batch", batchNum, "ticker", stock.Ticker, "error", err)
+			return 0, 0, err
 		}
 
 		// Check if row was actually inserted (not a duplicate)
 		rowsAffected, _ := result.RowsAffected()
 		if rowsAffected > 0 {
 			insertedCount++
+			insertedStocks = append(insertedStocks, stored)
 		} else {
 			skippedCount++
 		}
 
 		// Show progress every 200 attempts
 		if (i+1)%200 == 0 {
-			println("📈 BATCH", batchNum, ":", i+1, "/", len(stocks), "processed (", insertedCount, "new,", skippedCount, "duplicates)")
+			h.logger.Debug("batch progress", "batch", batchNum, "processed", i+1, "count", len(stocks), "inserted", insertedCount, "skipped", skippedCount)
 		}
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		println("❌ BATCH", batchNum, ": Commit failed:", err.Error())
-		return err
+		h.logger.Error("batch commit failed", "batch", batchNum, "error", err)
+		return 0, 0, err
+	}
+	for _, stock := range insertedStocks {
+		h.ratingsHub.Publish(stock)
 	}
 
-	println("✅ BATCH", batchNum, ": Committed", insertedCount, "new stocks (", skippedCount, "duplicates skipped)")
-	return nil
+	h.logger.Debug("batch committed", "batch", batchNum, "count", insertedCount, "skipped", skippedCount)
+	return insertedCount, skippedCount, nil
 }
 
-// storeStock inserts a single stock record into the database
-// Used by single-page endpoint, bulk operations use batchInsertStocks instead
-func (h *StockHandler) storeStock(stock models.StockRatings) error {
-	query := `
-		INSERT INTO stock_ratings (ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		ON CONFLICT (ticker, brokerage, action, rating_from, rating_to, time) DO NOTHING`
+// multiRowInsertMaxRows caps the rows of one multi-row INSERT. At 13 parameters per row this stays
+// far below Postgres's 65535 bind parameter limit while keeping each statement a reasonable size.
+const multiRowInsertMaxRows = 600
 
-	_, err := h.DB.Exec(query,
-		stock.Ticker, stock.TargetFrom, stock.TargetTo, stock.Company,
-		stock.Action, stock.Brokerage, stock.RatingFrom, stock.RatingTo,
-		stock.Time, time.Now())
+// insertStockBatch stores one bulk batch, as multi-row INSERTs unless BULK_INSERT_MODE=per_row.
+// When the multi-row path fails, its transaction is rolled back and the batch is retried row by row.
+func (h *StockHandler) insertStockBatch(stocks []models.StockRatings, batchNum int) (inserted, skipped int, err error) {
+	if h.perRowInserts {
+		return h.batchInsertStocksWithLogging(stocks, batchNum)
+	}
+	inserted, skipped, err = h.batchInsertStocksMultiRow(stocks, batchNum)
+	if err != nil {
+		h.logger.Warn("multi-row batch insert failed, retrying row by row", "batch", batchNum, "error", err)
+		return h.batchInsertStocksWithLogging(stocks, batchNum)
+	}
+	return inserted, skipped, nil
+}
 
-	return err
+// batchInsertStocksMultiRow inserts stock records in a single transaction with one INSERT per
+// multiRowInsertMaxRows stocks, instead of one round trip per stock.
+// The rows actually inserted come back through RETURNING, so the counts and the ratings published to /ws
+// match batchInsertStocksWithLogging. Returns 0, 0 when the transaction fails.
+func (h *StockHandler) batchInsertStocksMultiRow(stocks []models.StockRatings, batchNum int) (inserted, skipped int, err error) {
+	if len(stocks) == 0 {
+		return 0, 0, nil
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		h.logger.Error("batch transaction failed", "batch", batchNum, "error", err)
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var insertedStocks []models.StockRatings // Published to /ws clients once the transaction commits
+	for start := 0; start < len(stocks); start += multiRowInsertMaxRows {
+		chunk := stocks[start:min(start+multiRowInsertMaxRows, len(stocks))]
+		chunkInserted, err := insertStocksMultiRow(tx, chunk)
+		if err != nil {
+			h.logger.Error("batch insert failed", "batch", batchNum, "offset", start, "error", err)
+			return 0, 0, err
+		}
+		insertedStocks = append(insertedStocks, chunkInserted...)
+		h.logger.Debug("batch progress", "batch", batchNum, "processed", start+len(chunk), "count", len(stocks), "inserted", len(insertedStocks))
+	}
+
+	if err := tx.Commit(); err != nil {
+		h.logger.Error("batch commit failed", "batch", batchNum, "error", err)
+		return 0, 0, err
+	}
+	for _, stock := range insertedStocks {
+		h.ratingsHub.Publish(stock)
+	}
+
+	inserted = len(insertedStocks)
+	skipped = len(stocks) - inserted
+	h.logger.Debug("batch committed", "batch", batchNum, "count", inserted, "skipped", skipped)
+	return inserted, skipped, nil
+}
+
+// insertStocksMultiRow runs one INSERT with a VALUES row per stock and returns the stocks, as stored,
+// that did not conflict with an existing rating
+func insertStocksMultiRow(tx *sql.Tx, stocks []models.StockRatings) ([]models.StockRatings, error) {
+	const columns = 13
+	var query strings.Builder
+	query.WriteString(`INSERT INTO stock_ratings (ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at, sector, target_from_num, target_to_num) VALUES `)
+
+	args := make([]interface{}, 0, len(stocks)*columns)
+	stored := make([]models.StockRatings, len(stocks))
+	for i, stock := range stocks {
+		stored[i] = storedStock(stock)
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := i * columns
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13)
+		args = append(args,
+			stored[i].Ticker, stored[i].TargetFrom, stored[i].TargetTo, stored[i].Company,
+			stored[i].Action, stored[i].Brokerage, stored[i].RatingFrom, stored[i].RatingTo,
+			stored[i].Time, stored[i].CreatedAt, stored[i].Sector, priceNumeric(stored[i].TargetFrom), priceNumeric(stored[i].TargetTo))
+	}
+	query.WriteString(` ON CONFLICT (ticker, brokerage, action, rating_from, rating_to, time) DO NOTHING
+		RETURNING ticker, brokerage, action, rating_from, rating_to, time`)
+
+	rows, err := tx.Query(query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Count the returned keys, a key can repeat when two stocks only differ by time zone
+	returned := make(map[string]int)
+	for rows.Next() {
+		var row models.StockRatings
+		if err := rows.Scan(&row.Ticker, &row.Brokerage, &row.Action, &row.RatingFrom, &row.RatingTo, &row.Time); err != nil {
+			return nil, err
+		}
+		returned[storedRowKey(row)]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var inserted []models.StockRatings
+	for _, stock := range stored {
+		key := storedRowKey(stock)
+		if returned[key] > 0 {
+			returned[key]--
+			inserted = append(inserted, stock)
+		}
+	}
+	return inserted, nil
+}
+
+// storedRowKey identifies a rating by its unique constraint columns as the time column stores them:
+// the wall clock time, without zone, at microsecond precision
+func storedRowKey(stock models.StockRatings) string {
+	return strings.Join([]string{
+		stock.Ticker, stock.Brokerage, stock.Action, stock.RatingFrom, stock.RatingTo,
+		stock.Time.Round(time.Microsecond).Format("2006-01-02T15:04:05.999999"),
+	}, "|")
+}
+
+// storeStock inserts a single stock record into the database
+// Used by single-page endpoint, bulk operations use batchInsertStocks instead.
+// inserted is false when the rating was already stored and the insert was skipped.
+func (h *StockHandler) storeStock(stock models.StockRatings) (inserted bool, err error) {
+	query := `
+		INSERT INTO stock_ratings (ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at, sector, target_from_num, target_to_num)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13)
+		ON CONFLICT (ticker, brokerage, action, rating_from, rating_to, time) DO NOTHING`
+
+	stored := storedStock(stock)
+	result, err := h.DB.Exec(query,
+		stored.Ticker, stored.TargetFrom, stored.TargetTo, stored.Company,
+		stored.Action, stored.Brokerage, stored.RatingFrom, stored.RatingTo,
+		stored.Time, stored.CreatedAt, stored.Sector, priceNumeric(stored.TargetFrom), priceNumeric(stored.TargetTo))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected > 0 {
+		h.ratingsHub.Publish(stored)
+	}
+	return rowsAffected > 0, nil
+}
+
+// storedStock returns stock as it is inserted: normalized target prices and created_at set to now
+func storedStock(stock models.StockRatings) models.StockRatings {
+	stock.TargetFrom = normalizePrice(stock.TargetFrom)
+	stock.TargetTo = normalizePrice(stock.TargetTo)
+	stock.CreatedAt = time.Now()
+	return stock
 }
 
 // GetStockRatings retrieves paginated stock ratings from database
 // @Summary Get paginated stock ratings from database
-// @Description Retrieves stored stock ratings with pagination support, ordered by creation date (newest first). Returns both data and pagination metadata.
+// @Description Retrieves stored stock ratings with pagination support, ordered by creation date (newest first) unless sort_by/sort_order are given. Returns both data and pagination metadata. A page_number past the last page returns empty data with has_next=false and out_of_range=true. For deep paging, pass after_id and after_created_at from the previous response's next_cursor to use keyset pagination instead of OFFSET.
 // @Tags stocks
 // @Accept json
 // @Produce json
-// @Param request body models.PaginationRequest true "Request body with page_number (integer, min 1) and page_length (integer, 1-1000)"
+// @Param request body models.PaginationRequest true "Request body with page_number (integer, min 1), page_length (integer, 1-1000) and optional sort_by (created_at, time, ticker, company) and sort_order (asc, desc)"
 // @Success 200 {object} models.PaginatedResponse "Successfully retrieved paginated stock ratings with metadata"
-// @Failure 400 {object} models.ErrorResponse "Bad request - invalid JSON, page_number <= 0, or page_length not between 1-1000"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid JSON, page_number <= 0, page_length not between 1-1000, unknown sort_by/sort_order, or incomplete cursor"
 // @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
 // @Router /stocks/list [post]
 func (h *StockHandler) GetStockRatings(c *gin.Context) {
 	var req models.PaginationRequest
 
 	// Parse request body
-	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format in request body"})
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	// Keyset pagination mode, avoids OFFSET scans for deep pages
+	if req.AfterID != 0 || req.AfterCreatedAt != nil {
+		h.getStockRatingsByCursor(c, req)
 		return
 	}
 
@@ -456,27 +1369,604 @@ func (h *StockHandler) GetStockRatings(c *gin.Context) {
 		return
 	}
 
+	// Build ORDER BY from allowlisted values only
+	orderBy, err := buildOrderByClause(req.SortBy, req.SortOrder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Calculate offset for pagination
 	offset := (req.PageNumber - 1) * req.PageLength
 
 	// Get total count
 	var totalCount int
-	err := h.DB.QueryRow("SELECT COUNT(*) FROM stock_ratings").Scan(&totalCount)
+	err = h.DB.QueryRow("SELECT COUNT(*) FROM stock_ratings").Scan(&totalCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get total count"})
+		return
+	}
+
+	// A page past the last one has no rows, answer without querying and say so
+	totalPages := (totalCount + req.PageLength - 1) / req.PageLength
+	if totalCount > 0 && req.PageNumber > totalPages {
+		c.JSON(http.StatusOK, gin.H{
+			"data": []models.StockRatings{},
+			"pagination": gin.H{
+				"page_number":   req.PageNumber,
+				"page_length":   req.PageLength,
+				"total_records": totalCount,
+				"total_pages":   totalPages,
+				"has_next":      false,
+				"has_previous":  true,
+				"out_of_range":  true,
+			},
+		})
+		return
+	}
+
+	// Query paginated data
+	query := fmt.Sprintf(`
+		SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at
+		FROM stock_ratings
+		ORDER BY %s
+		LIMIT $1 OFFSET $2`, orderBy)
+
+	rows, err := h.DB.Query(query, req.PageLength, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stock ratings"})
+		return
+	}
+	defer rows.Close()
+
+	// Parse results
+	var stocks []models.StockRatings
+	for rows.Next() {
+		var stock models.StockRatings
+		err := rows.Scan(
+			&stock.ID, &stock.Ticker, &stock.TargetFrom, &stock.TargetTo,
+			&stock.Company, &stock.Action, &stock.Brokerage,
+			&stock.RatingFrom, &stock.RatingTo, &stock.Time, &stock.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan stock data"})
+			return
+		}
+		stocks = append(stocks, stock)
+	}
+
+	// Calculate pagination metadata
+	hasNext := req.PageNumber < totalPages
+	hasPrev := req.PageNumber > 1
+
+	pagination := gin.H{
+		"page_number":   req.PageNumber,
+		"page_length":   req.PageLength,
+		"total_records": totalCount,
+		"total_pages":   totalPages,
+		"has_next":      hasNext,
+		"has_previous":  hasPrev,
+	}
+
+	// A cursor only makes sense for the default order, which keyset pagination follows
+	if hasNext && len(stocks) > 0 && req.SortBy == "" && req.SortOrder == "" {
+		pagination["next_cursor"] = cursorFor(stocks[len(stocks)-1])
+	}
+
+	// Return paginated response
+	c.JSON(http.StatusOK, gin.H{
+		"data":       stocks,
+		"pagination": pagination,
+	})
+}
+
+// getStockRatingsByCursor serves GetStockRatings in keyset pagination mode.
+// Returns the page_length rows that come after the cursor in created_at DESC, id DESC order,
+// so Postgres can seek straight to the cursor instead of scanning and discarding OFFSET rows.
+func (h *StockHandler) getStockRatingsByCursor(c *gin.Context, req models.PaginationRequest) {
+	if req.AfterID <= 0 || req.AfterCreatedAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "after_id and after_created_at must both be provided for cursor pagination"})
+		return
+	}
+
+	if req.PageLength <= 0 || req.PageLength > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_length must be between 1 and 1000"})
+		return
+	}
+
+	if (req.SortBy != "" && req.SortBy != "created_at") || (req.SortOrder != "" && strings.ToLower(req.SortOrder) != "desc") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor pagination only supports the default created_at desc order"})
+		return
+	}
+
+	// Fetch one extra row to know whether another page follows
+	query := `
+		SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at
+		FROM stock_ratings
+		WHERE (created_at, id) < ($1, $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`
+
+	rows, err := h.DB.Query(query, *req.AfterCreatedAt, req.AfterID, req.PageLength+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stock ratings"})
+		return
+	}
+	defer rows.Close()
+
+	// Parse results
+	var stocks []models.StockRatings
+	for rows.Next() {
+		var stock models.StockRatings
+		err := rows.Scan(
+			&stock.ID, &stock.Ticker, &stock.TargetFrom, &stock.TargetTo,
+			&stock.Company, &stock.Action, &stock.Brokerage,
+			&stock.RatingFrom, &stock.RatingTo, &stock.Time, &stock.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan stock data"})
+			return
+		}
+		stocks = append(stocks, stock)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read stock ratings"})
+		return
+	}
+
+	hasNext := len(stocks) > req.PageLength
+	if hasNext {
+		stocks = stocks[:req.PageLength]
+	}
+
+	// A supplied cursor points at a row already served on an earlier page
+	hasPrev := req.AfterID > 0 && req.AfterCreatedAt != nil
+
+	pagination := gin.H{
+		"page_length":  req.PageLength,
+		"has_next":     hasNext,
+		"has_previous": hasPrev,
+	}
+	if hasNext {
+		pagination["next_cursor"] = cursorFor(stocks[len(stocks)-1])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       stocks,
+		"pagination": pagination,
+	})
+}
+
+// cursorFor builds the keyset pagination cursor pointing at the given row
+func cursorFor(stock models.StockRatings) models.Cursor {
+	return models.Cursor{AfterID: stock.ID, AfterCreatedAt: stock.CreatedAt}
+}
+
+// maxRandomStocks bounds the sample size of GetRandomStocks
+const maxRandomStocks = 100
+
+// RandomStocksResponse holds a random sample of stock ratings
+type RandomStocksResponse struct {
+	Data  []models.StockRatings `json:"data"`               // Same rows as the data array of POST /stocks/list
+	Count int                   `json:"count" example:"10"` // Number of rows returned, below the requested count for small tables
+}
+
+// GetRandomStocks returns a random sample of stock ratings
+// @Summary Get a random sample of stock ratings
+// @Description Returns count randomly chosen stock ratings, e.g. to populate UI mockups in demos. Every call returns a new sample.
+// @Tags stocks
+// @Produce json
+// @Param count query int false "Number of ratings to return (1-100)" default(10)
+// @Success 200 {object} RandomStocksResponse "Random sample of stock ratings"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid count parameter"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/random [get]
+func (h *StockHandler) GetRandomStocks(c *gin.Context) {
+	count, err := strconv.Atoi(c.DefaultQuery("count", "10"))
+	if err != nil || count < 1 || count > maxRandomStocks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid count parameter. Must be between 1 and %d", maxRandomStocks)})
+		return
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at
+		FROM stock_ratings
+		ORDER BY RANDOM()
+		LIMIT $1`, count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stock ratings"})
+		return
+	}
+	defer rows.Close()
+
+	stocks := []models.StockRatings{}
+	for rows.Next() {
+		var stock models.StockRatings
+		err := rows.Scan(
+			&stock.ID, &stock.Ticker, &stock.TargetFrom, &stock.TargetTo,
+			&stock.Company, &stock.Action, &stock.Brokerage,
+			&stock.RatingFrom, &stock.RatingTo, &stock.Time, &stock.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan stock data"})
+			return
+		}
+		stocks = append(stocks, stock)
+	}
+
+	c.JSON(http.StatusOK, RandomStocksResponse{Data: stocks, Count: len(stocks)})
+}
+
+// GetStockByID retrieves a single stock rating by its primary key
+// @Summary Get a stock rating by ID
+// @Description Retrieves one stock rating by its numeric ID, e.g. for a detail view after selecting a row of the list.
+// @Tags stocks
+// @Produce json
+// @Param id path int true "Stock rating ID"
+// @Success 200 {object} models.StockRatings "Stock rating found"
+// @Failure 400 {object} models.ErrorResponse "Bad request - id is not a positive integer"
+// @Failure 404 {object} models.ErrorResponse "No stock rating with this ID"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/{id} [get]
+func (h *StockHandler) GetStockByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a positive integer"})
+		return
+	}
+
+	query := `
+		SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at, COALESCE(sector, '')
+		FROM stock_ratings
+		WHERE id = $1`
+
+	var stock models.StockRatings
+	err = h.DB.QueryRow(query, id).Scan(
+		&stock.ID, &stock.Ticker, &stock.TargetFrom, &stock.TargetTo,
+		&stock.Company, &stock.Action, &stock.Brokerage,
+		&stock.RatingFrom, &stock.RatingTo, &stock.Time, &stock.CreatedAt, &stock.Sector)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No stock rating found with id %d", id)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stock rating"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stock)
+}
+
+// UpdateStockByID corrects fields of a single stock rating
+// @Summary Update a stock rating by ID
+// @Description Updates only the fields given with a non-empty value (e.g. a wrong target price from the external feed) and returns the updated rating. Target prices are normalized like ingested ones and must be non-negative numbers; the ticker is stored uppercase.
+// @Tags stocks
+// @Accept json
+// @Produce json
+// @Param id path int true "Stock rating ID"
+// @Param request body models.StockRatings true "Fields to change, empty or missing fields are left as they are"
+// @Success 200 {object} models.StockRatings "Updated stock rating"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid id, invalid JSON, invalid ticker or price, or no fields to update"
+// @Failure 404 {object} models.ErrorResponse "No stock rating with this ID"
+// @Failure 409 {object} models.ErrorResponse "The corrected rating would duplicate another stored rating"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/{id} [put]
+func (h *StockHandler) UpdateStockByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a positive integer"})
+		return
+	}
+
+	var update models.StockRatings
+	if !bindJSON(c, &update) {
+		return
+	}
+
+	var setClauses []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if update.Ticker != "" {
+		ticker := strings.ToUpper(update.Ticker)
+		if !tickerPattern.MatchString(ticker) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticker: must be 2-5 letters"})
+			return
+		}
+		set("ticker", ticker)
+	}
+	// Corrected prices get the same normalization as ingested ones, numeric copies included
+	if update.TargetFrom != "" {
+		targetFrom := normalizePrice(update.TargetFrom)
+		if targetFrom == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_from must be a non-negative number"})
+			return
+		}
+		set("target_from", targetFrom)
+		set("target_from_num", priceNumeric(targetFrom))
+	}
+	if update.TargetTo != "" {
+		targetTo := normalizePrice(update.TargetTo)
+		if targetTo == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_to must be a non-negative number"})
+			return
+		}
+		set("target_to", targetTo)
+		set("target_to_num", priceNumeric(targetTo))
+	}
+	if update.Company != "" {
+		set("company", update.Company)
+	}
+	if update.Action != "" {
+		set("action", update.Action)
+	}
+	if update.Brokerage != "" {
+		set("brokerage", update.Brokerage)
+	}
+	if update.RatingFrom != "" {
+		set("rating_from", update.RatingFrom)
+	}
+	if update.RatingTo != "" {
+		set("rating_to", update.RatingTo)
+	}
+	if !update.Time.IsZero() {
+		set("time", update.Time)
+	}
+	if update.Sector != "" {
+		set("sector", update.Sector)
+	}
+
+	if len(setClauses) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf(`
+		UPDATE stock_ratings SET %s
+		WHERE id = $%d
+		RETURNING id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at, COALESCE(sector, '')`,
+		strings.Join(setClauses, ", "), len(args))
+
+	var stock models.StockRatings
+	err = h.DB.QueryRow(query, args...).Scan(
+		&stock.ID, &stock.Ticker, &stock.TargetFrom, &stock.TargetTo,
+		&stock.Company, &stock.Action, &stock.Brokerage,
+		&stock.RatingFrom, &stock.RatingTo, &stock.Time, &stock.CreatedAt, &stock.Sector)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No stock rating found with id %d", id)})
+		return
+	}
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another stock rating with the same ticker, brokerage, action, ratings and time already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock rating"})
+		return
+	}
+	h.invalidateDerivedCaches(c.Request.Context())
+
+	c.JSON(http.StatusOK, stock)
+}
+
+// GetStockHistoryByTicker retrieves every analyst action stored for a ticker
+// @Summary Get full rating history for a ticker
+// @Description Retrieves all stock ratings for a ticker ordered by analyst report time (newest first), with optional pagination. The ticker is matched case-insensitively. Metadata includes the number of distinct brokerages covering the ticker.
+// @Tags stocks
+// @Produce json
+// @Param ticker path string true "Ticker symbol (2-5 letters)"
+// @Param page_number query int false "Page number (min 1)" default(1)
+// @Param page_length query int false "Records per page (1-1000)" default(20)
+// @Success 200 {object} map[string]interface{} "Successfully retrieved rating history with pagination and coverage metadata"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid ticker or pagination parameters"
+// @Failure 404 {object} models.ErrorResponse "No ratings found for ticker"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/ticker/{ticker} [get]
+func (h *StockHandler) GetStockHistoryByTicker(c *gin.Context) {
+	ticker := strings.ToUpper(c.Param("ticker"))
+	if !tickerPattern.MatchString(ticker) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticker: must be 2-5 letters"})
+		return
+	}
+
+	// Parse pagination parameters
+	pageNumber, err := strconv.Atoi(c.DefaultQuery("page_number", "1"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_number must be greater than 0"})
+		return
+	}
+	pageLength, err := strconv.Atoi(c.DefaultQuery("page_length", "20"))
+	if err != nil || pageLength <= 0 || pageLength > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_length must be between 1 and 1000"})
+		return
+	}
+
+	// Get total count and brokerage coverage
+	var totalCount, brokerageCount int
+	err = h.DB.QueryRow("SELECT COUNT(*), COUNT(DISTINCT brokerage) FROM stock_ratings WHERE UPPER(ticker) = $1", ticker).
+		Scan(&totalCount, &brokerageCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get ticker history count"})
+		return
+	}
+
+	if totalCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No ratings found for ticker %s", ticker)})
+		return
+	}
+
+	// Query paginated history
+	query := `
+		SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at
+		FROM stock_ratings
+		WHERE UPPER(ticker) = $1
+		ORDER BY time DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := h.DB.Query(query, ticker, pageLength, (pageNumber-1)*pageLength)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query ticker history"})
+		return
+	}
+	defer rows.Close()
+
+	// Parse results
+	var stocks []models.StockRatings
+	for rows.Next() {
+		var stock models.StockRatings
+		err := rows.Scan(
+			&stock.ID, &stock.Ticker, &stock.TargetFrom, &stock.TargetTo,
+			&stock.Company, &stock.Action, &stock.Brokerage,
+			&stock.RatingFrom, &stock.RatingTo, &stock.Time, &stock.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan ticker history"})
+			return
+		}
+		stocks = append(stocks, stock)
+	}
+
+	// Calculate pagination metadata
+	totalPages := (totalCount + pageLength - 1) / pageLength
+
+	c.JSON(http.StatusOK, gin.H{
+		"ticker":          ticker,
+		"data":            stocks,
+		"brokerage_count": brokerageCount,
+		"pagination": gin.H{
+			"page_number":   pageNumber,
+			"page_length":   pageLength,
+			"total_records": totalCount,
+			"total_pages":   totalPages,
+			"has_next":      pageNumber < totalPages,
+			"has_previous":  pageNumber > 1,
+		},
+	})
+}
+
+// sortableColumns maps the accepted sort_by values to their columns.
+// User input never reaches ORDER BY directly, only these constants do.
+var sortableColumns = map[string]string{
+	"created_at": "created_at",
+	"time":       "time",
+	"ticker":     "ticker",
+	"company":    "company",
+}
+
+// buildOrderByClause validates sort_by and sort_order against the allowlist
+// and returns the ORDER BY expression, defaulting to created_at DESC.
+// id is appended as a tie-breaker so pages are stable.
+func buildOrderByClause(sortBy, sortOrder string) (string, error) {
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	column, ok := sortableColumns[strings.ToLower(sortBy)]
+	if !ok {
+		return "", fmt.Errorf("Invalid sort_by. Must be one of: created_at, time, ticker, company")
+	}
+
+	direction := "DESC"
+	switch strings.ToLower(sortOrder) {
+	case "", "desc":
+	case "asc":
+		direction = "ASC"
+	default:
+		return "", fmt.Errorf("Invalid sort_order. Must be 'asc' or 'desc'")
+	}
+
+	return fmt.Sprintf("%s %s, id %s", column, direction, direction), nil
+}
+
+// AdvancedSearchRequest represents search parameters with filters
+type AdvancedSearchRequest struct {
+	PageNumber int    `json:"page_number" form:"page_number"`
+	PageLength int    `json:"page_length" form:"page_length"`
+	SearchTerm string `json:"search_term,omitempty" form:"search_term"`
+	// Ticker matches the ticker exactly (case-insensitive)
+	Ticker string `json:"ticker,omitempty" form:"ticker"`
+	Action string `json:"action,omitempty" form:"action"`
+	// Brokerage matches the analyst firm exactly (case-insensitive), BrokerageContains matches part of its name
+	Brokerage         string  `json:"brokerage,omitempty" form:"brokerage"`
+	BrokerageContains string  `json:"brokerage_contains,omitempty" form:"brokerage_contains"`
+	Sector            string  `json:"sector,omitempty" form:"sector"`
+	RatingFrom        string  `json:"rating_from,omitempty" form:"rating_from"`
+	RatingTo          string  `json:"rating_to,omitempty" form:"rating_to"`
+	TargetFromMin     float64 `json:"target_from_min,omitempty" form:"target_from_min"`
+	TargetFromMax     float64 `json:"target_from_max,omitempty" form:"target_from_max"`
+	TargetToMin       float64 `json:"target_to_min,omitempty" form:"target_to_min"`
+	TargetToMax       float64 `json:"target_to_max,omitempty" form:"target_to_max"`
+	// TimeFrom and TimeTo restrict results by analyst report time, RFC3339 (e.g. "2025-01-31T00:00:00Z")
+	TimeFrom *time.Time `json:"time_from,omitempty" form:"time_from"`
+	TimeTo   *time.Time `json:"time_to,omitempty" form:"time_to"`
+}
+
+// validateSearchFilters checks filter combinations that the WHERE clause cannot express
+func validateSearchFilters(req AdvancedSearchRequest) error {
+	if req.TimeFrom != nil && req.TimeTo != nil && req.TimeFrom.After(*req.TimeTo) {
+		return errors.New("time_from must be before or equal to time_to")
+	}
+	return nil
+}
+
+// SearchStockRatings searches stock ratings with filters
+// @Summary Search stock ratings with filters
+// @Description Searches through stock ratings using filters including search term, action, brokerage (exact or brokerage_contains), sector, ratings, target price ranges, and a report time range (time_from/time_to, RFC3339). Target price filters only match rows whose target is a plain number such as "$1,250.00"; other values are skipped instead of failing the search.
+// @Tags stocks
+// @Accept json
+// @Produce json
+// @Param request body AdvancedSearchRequest true "Search parameters with filters"
+// @Success 200 {object} models.PaginatedResponse "Successfully retrieved filtered stock ratings"
+// @Failure 400 {object} models.ErrorResponse "Bad request"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error"
+// @Router /stocks/search [post]
+func (h *StockHandler) SearchStockRatings(c *gin.Context) {
+	var req AdvancedSearchRequest
+
+	// Parse request body
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	// Validate parameters
+	if req.PageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_number must be greater than 0"})
+		return
+	}
+	if req.PageLength <= 0 || req.PageLength > 1000 {
+		req.PageLength = 20
+	}
+	if err := validateSearchFilters(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Build dynamic WHERE clause
+	whereClause, args, argIndex := buildStockFilter(req)
+
+	// Calculate offset
+	offset := (req.PageNumber - 1) * req.PageLength
+
+	// Get total count
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM stock_ratings %s", whereClause)
+	var totalCount int
+	err := h.DB.QueryRow(countQuery, args...).Scan(&totalCount)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get total count"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get search count"})
 		return
 	}
 
-	// Query paginated data
-	query := `
+	// Query data
+	dataQuery := fmt.Sprintf(`
 		SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at
 		FROM stock_ratings
+		%s
 		ORDER BY created_at DESC, id DESC
-		LIMIT $1 OFFSET $2`
+		LIMIT $%d OFFSET $%d`, whereClause, argIndex, argIndex+1)
 
-	rows, err := h.DB.Query(query, req.PageLength, offset)
+	args = append(args, req.PageLength, offset)
+	rows, err := h.DB.Query(dataQuery, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stock ratings"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search stock ratings"})
 		return
 	}
 	defer rows.Close()
@@ -490,7 +1980,7 @@ func (h *StockHandler) GetStockRatings(c *gin.Context) {
 			&stock.Company, &stock.Action, &stock.Brokerage,
 			&stock.RatingFrom, &stock.RatingTo, &stock.Time, &stock.CreatedAt)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan stock data"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan search results"})
 			return
 		}
 		stocks = append(stocks, stock)
@@ -501,7 +1991,7 @@ func (h *StockHandler) GetStockRatings(c *gin.Context) {
 	hasNext := req.PageNumber < totalPages
 	hasPrev := req.PageNumber > 1
 
-	// Return paginated response
+	// Return search results with pagination
 	c.JSON(http.StatusOK, gin.H{
 		"data": stocks,
 		"pagination": gin.H{
@@ -512,67 +2002,52 @@ func (h *StockHandler) GetStockRatings(c *gin.Context) {
 			"has_next":      hasNext,
 			"has_previous":  hasPrev,
 		},
+		"applied_filters": gin.H{
+			"search_term":        req.SearchTerm,
+			"ticker":             req.Ticker,
+			"action":             req.Action,
+			"brokerage":          req.Brokerage,
+			"brokerage_contains": req.BrokerageContains,
+			"sector":             req.Sector,
+			"rating_from":        req.RatingFrom,
+			"rating_to":          req.RatingTo,
+			"target_from_min":    req.TargetFromMin,
+			"target_from_max":    req.TargetFromMax,
+			"target_to_min":      req.TargetToMin,
+			"target_to_max":      req.TargetToMax,
+			"time_from":          req.TimeFrom,
+			"time_to":            req.TimeTo,
+		},
 	})
 }
 
-// AdvancedSearchRequest represents search parameters with filters
-type AdvancedSearchRequest struct {
-	PageNumber    int     `json:"page_number"`
-	PageLength    int     `json:"page_length"`
-	SearchTerm    string  `json:"search_term,omitempty"`
-	Action        string  `json:"action,omitempty"`
-	RatingFrom    string  `json:"rating_from,omitempty"`
-	RatingTo      string  `json:"rating_to,omitempty"`
-	TargetFromMin float64 `json:"target_from_min,omitempty"`
-	TargetFromMax float64 `json:"target_from_max,omitempty"`
-	TargetToMin   float64 `json:"target_to_min,omitempty"`
-	TargetToMax   float64 `json:"target_to_max,omitempty"`
-}
-
-// SearchStockRatings searches stock ratings with filters
-// @Summary Search stock ratings with filters
-// @Description Searches through stock ratings using filters including search term, action, ratings, and target price ranges.
-// @Tags stocks
-// @Accept json
-// @Produce json
-// @Param request body AdvancedSearchRequest true "Search parameters with filters"
-// @Success 200 {object} models.PaginatedResponse "Successfully retrieved filtered stock ratings"
-// @Failure 400 {object} models.ErrorResponse "Bad request"
-// @Failure 500 {object} models.GenericErrorResponse "Internal server error"
-// @Router /stocks/search [post]
-func (h *StockHandler) SearchStockRatings(c *gin.Context) {
-	var req AdvancedSearchRequest
-
-	// Parse request body
-	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format in request body"})
-		return
-	}
-
-	// Validate parameters
-	if req.PageNumber <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "page_number must be greater than 0"})
-		return
-	}
-	if req.PageLength <= 0 || req.PageLength > 1000 {
-		req.PageLength = 20
-	}
-
-	// Build dynamic WHERE clause
+// buildStockFilter turns the search filters into a WHERE clause with positional arguments.
+// It returns the clause (empty when no filter applies), its arguments and the next free placeholder index,
+// where callers append their own arguments such as LIMIT and OFFSET.
+// Shared by the search, count and export endpoints so they always match the same rows.
+func buildStockFilter(req AdvancedSearchRequest) (whereClause string, args []interface{}, nextArgIndex int) {
 	whereConditions := []string{}
-	args := []interface{}{}
+	args = []interface{}{}
 	argIndex := 1
 
-	// Search term filter
+	// Search term filter, ILIKE on the raw columns so the pg_trgm indexes on ticker, company and brokerage apply
+	// (terms shorter than 3 characters can't use a trigram index and fall back to a scan, matching the same rows)
 	if req.SearchTerm != "" {
 		searchPattern := "%" + req.SearchTerm + "%"
 		whereConditions = append(whereConditions, fmt.Sprintf(
-			"(LOWER(ticker) LIKE LOWER($%d) OR LOWER(company) LIKE LOWER($%d) OR LOWER(brokerage) LIKE LOWER($%d) OR LOWER(action) LIKE LOWER($%d) OR LOWER(rating_from) LIKE LOWER($%d) OR LOWER(rating_to) LIKE LOWER($%d))",
+			"(ticker ILIKE $%d OR company ILIKE $%d OR brokerage ILIKE $%d OR action ILIKE $%d OR rating_from ILIKE $%d OR rating_to ILIKE $%d)",
 			argIndex, argIndex, argIndex, argIndex, argIndex, argIndex))
 		args = append(args, searchPattern)
 		argIndex++
 	}
 
+	// Ticker filter
+	if req.Ticker != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("UPPER(ticker) = UPPER($%d)", argIndex))
+		args = append(args, req.Ticker)
+		argIndex++
+	}
+
 	// Action filter
 	if req.Action != "" && req.Action != "all" {
 		whereConditions = append(whereConditions, fmt.Sprintf("LOWER(action) = LOWER($%d)", argIndex))
@@ -580,6 +2055,25 @@ func (h *StockHandler) SearchStockRatings(c *gin.Context) {
 		argIndex++
 	}
 
+	// Brokerage filters
+	if req.Brokerage != "" && req.Brokerage != "all" {
+		whereConditions = append(whereConditions, fmt.Sprintf("LOWER(brokerage) = LOWER($%d)", argIndex))
+		args = append(args, req.Brokerage)
+		argIndex++
+	}
+	if req.BrokerageContains != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("LOWER(brokerage) LIKE LOWER($%d)", argIndex))
+		args = append(args, "%"+req.BrokerageContains+"%")
+		argIndex++
+	}
+
+	// Sector filter, ratings without a known sector never match
+	if req.Sector != "" && req.Sector != "all" {
+		whereConditions = append(whereConditions, fmt.Sprintf("LOWER(sector) = LOWER($%d)", argIndex))
+		args = append(args, req.Sector)
+		argIndex++
+	}
+
 	// Rating from filter
 	if req.RatingFrom != "" && req.RatingFrom != "all" {
 		whereConditions = append(whereConditions, fmt.Sprintf("LOWER(rating_from) = LOWER($%d)", argIndex))
@@ -594,64 +2088,98 @@ func (h *StockHandler) SearchStockRatings(c *gin.Context) {
 		argIndex++
 	}
 
-	// Target price range filters
+	// Target price range filters on the numeric columns filled at insert, rows whose target isn't a number never match
 	if req.TargetFromMin > 0 {
-		whereConditions = append(whereConditions, fmt.Sprintf("CAST(REPLACE(REPLACE(target_from, '$', ''), ',', '') AS NUMERIC) >= $%d", argIndex))
+		whereConditions = append(whereConditions, fmt.Sprintf("target_from_num >= $%d", argIndex))
 		args = append(args, req.TargetFromMin)
 		argIndex++
 	}
 	if req.TargetFromMax > 0 {
-		whereConditions = append(whereConditions, fmt.Sprintf("CAST(REPLACE(REPLACE(target_from, '$', ''), ',', '') AS NUMERIC) <= $%d", argIndex))
+		whereConditions = append(whereConditions, fmt.Sprintf("target_from_num <= $%d", argIndex))
 		args = append(args, req.TargetFromMax)
 		argIndex++
 	}
 	if req.TargetToMin > 0 {
-		whereConditions = append(whereConditions, fmt.Sprintf("CAST(REPLACE(REPLACE(target_to, '$', ''), ',', '') AS NUMERIC) >= $%d", argIndex))
+		whereConditions = append(whereConditions, fmt.Sprintf("target_to_num >= $%d", argIndex))
 		args = append(args, req.TargetToMin)
 		argIndex++
 	}
 	if req.TargetToMax > 0 {
-		whereConditions = append(whereConditions, fmt.Sprintf("CAST(REPLACE(REPLACE(target_to, '$', ''), ',', '') AS NUMERIC) <= $%d", argIndex))
+		whereConditions = append(whereConditions, fmt.Sprintf("target_to_num <= $%d", argIndex))
 		args = append(args, req.TargetToMax)
 		argIndex++
 	}
 
+	// Report time range filters
+	if req.TimeFrom != nil {
+		whereConditions = append(whereConditions, fmt.Sprintf("time >= $%d", argIndex))
+		args = append(args, *req.TimeFrom)
+		argIndex++
+	}
+	if req.TimeTo != nil {
+		whereConditions = append(whereConditions, fmt.Sprintf("time <= $%d", argIndex))
+		args = append(args, *req.TimeTo)
+		argIndex++
+	}
+
 	// Build WHERE clause
-	whereClause := ""
 	if len(whereConditions) > 0 {
 		whereClause = "WHERE " + strings.Join(whereConditions, " AND ")
 	}
 
-	// Calculate offset
-	offset := (req.PageNumber - 1) * req.PageLength
+	return whereClause, args, argIndex
+}
 
-	// Get total count
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM stock_ratings %s", whereClause)
-	var totalCount int
-	err := h.DB.QueryRow(countQuery, args...).Scan(&totalCount)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get search count"})
+// ExportStockRatings streams every stock rating matching the search filters as CSV
+// @Summary Export filtered stock ratings as CSV
+// @Description Accepts the same filters as /stocks/search and streams all matching ratings as a text/csv attachment, one row per rating, newest first. Pagination fields are ignored. Target prices are exported as stored (e.g. "$150.00").
+// @Tags stocks
+// @Accept json
+// @Produce text/csv
+// @Param request body AdvancedSearchRequest true "Search filters, pagination fields are ignored"
+// @Success 200 {string} string "CSV file with one row per matching rating"
+// @Failure 400 {object} models.ErrorResponse "Bad request"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error"
+// @Router /stocks/export [post]
+func (h *StockHandler) ExportStockRatings(c *gin.Context) {
+	var req AdvancedSearchRequest
+
+	// Parse request body
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := validateSearchFilters(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Query data
-	dataQuery := fmt.Sprintf(`
+	whereClause, args, _ := buildStockFilter(req)
+	query := fmt.Sprintf(`
 		SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at
 		FROM stock_ratings
 		%s
-		ORDER BY created_at DESC, id DESC
-		LIMIT $%d OFFSET $%d`, whereClause, argIndex, argIndex+1)
+		ORDER BY created_at DESC, id DESC`, whereClause)
 
-	args = append(args, req.PageLength, offset)
-	rows, err := h.DB.Query(dataQuery, args...)
+	rows, err := h.DB.QueryContext(c.Request.Context(), query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search stock ratings"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export stock ratings"})
 		return
 	}
 	defer rows.Close()
 
-	// Parse results
-	var stocks []models.StockRatings
+	filename := fmt.Sprintf("stock_ratings_%s.csv", time.Now().UTC().Format("20060102_150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// Stream rows as they are read so large exports are never held in memory
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{
+		"id", "ticker", "target_from", "target_to", "company", "action",
+		"brokerage", "rating_from", "rating_to", "time", "created_at",
+	})
+
+	written := 0
 	for rows.Next() {
 		var stock models.StockRatings
 		err := rows.Scan(
@@ -659,44 +2187,78 @@ func (h *StockHandler) SearchStockRatings(c *gin.Context) {
 			&stock.Company, &stock.Action, &stock.Brokerage,
 			&stock.RatingFrom, &stock.RatingTo, &stock.Time, &stock.CreatedAt)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan search results"})
-			return
+			// Headers are already sent, the truncated file is all we can do
+			h.requestLogger(c).Error("csv export aborted", "rows_written", written, "error", err)
+			break
 		}
-		stocks = append(stocks, stock)
+
+		writer.Write([]string{
+			strconv.Itoa(stock.ID), stock.Ticker, stock.TargetFrom, stock.TargetTo,
+			stock.Company, stock.Action, stock.Brokerage, stock.RatingFrom, stock.RatingTo,
+			stock.Time.Format(time.RFC3339), stock.CreatedAt.Format(time.RFC3339),
+		})
+		written++
+
+		// Push data to the client periodically instead of buffering the whole export
+		if written%exportFlushEvery == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		h.requestLogger(c).Error("csv export aborted", "rows_written", written, "error", err)
 	}
 
-	// Calculate pagination metadata
-	totalPages := (totalCount + req.PageLength - 1) / req.PageLength
-	hasNext := req.PageNumber < totalPages
-	hasPrev := req.PageNumber > 1
+	writer.Flush()
+	c.Writer.Flush()
+}
 
-	// Return search results with pagination
-	c.JSON(http.StatusOK, gin.H{
-		"data": stocks,
-		"pagination": gin.H{
-			"page_number":   req.PageNumber,
-			"page_length":   req.PageLength,
-			"total_records": totalCount,
-			"total_pages":   totalPages,
-			"has_next":      hasNext,
-			"has_previous":  hasPrev,
-		},
-		"applied_filters": gin.H{
-			"search_term":     req.SearchTerm,
-			"action":          req.Action,
-			"rating_from":     req.RatingFrom,
-			"rating_to":       req.RatingTo,
-			"target_from_min": req.TargetFromMin,
-			"target_from_max": req.TargetFromMax,
-			"target_to_min":   req.TargetToMin,
-			"target_to_max":   req.TargetToMax,
-		},
-	})
+// CountResponse holds the number of stock ratings matching a filter
+type CountResponse struct {
+	Count int `json:"count" example:"1250"`
+}
+
+// GetStockCount counts the stock ratings matching the search filters
+// @Summary Count filtered stock ratings
+// @Description Accepts the same filters as /stocks/search and returns only how many ratings match, without fetching them. Pagination fields are ignored. The count equals total_records of the matching search.
+// @Tags stocks
+// @Accept json
+// @Produce json
+// @Param request body AdvancedSearchRequest true "Search filters, pagination fields are ignored"
+// @Success 200 {object} CountResponse "Number of matching ratings"
+// @Failure 400 {object} models.ErrorResponse "Bad request"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error"
+// @Router /stocks/count [post]
+func (h *StockHandler) GetStockCount(c *gin.Context) {
+	var req AdvancedSearchRequest
+
+	// Parse request body
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := validateSearchFilters(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same WHERE clause as the search, so the count always matches its total_records
+	whereClause, args, _ := buildStockFilter(req)
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM stock_ratings %s", whereClause)
+	var count int
+	if err := h.DB.QueryRowContext(c.Request.Context(), countQuery, args...).Scan(&count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count stock ratings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, CountResponse{Count: count})
 }
 
 // ActionsResponse represents the response structure for stock actions
 type ActionsResponse struct {
 	Actions []string `json:"actions" example:"initiated by,target raised by,target lowered by,reiterated by,upgraded"`
+	Total   int      `json:"total" example:"5"`             // Number of distinct actions, regardless of limit/offset
+	Limit   int      `json:"limit,omitempty" example:"100"` // Set when the list is paged
+	Offset  int      `json:"offset,omitempty" example:"0"`
 }
 
 // FilterOptionsResponse represents available filter options
@@ -704,25 +2266,59 @@ type FilterOptionsResponse struct {
 	Actions     []string `json:"actions"`
 	RatingsFrom []string `json:"ratings_from"`
 	RatingsTo   []string `json:"ratings_to"`
+	Brokerages  []string `json:"brokerages"`
 }
 
 // GetStockActions retrieves all unique action types from the database
 // @Summary Get all available stock actions
-// @Description Retrieves a list of all unique action types found in the stock ratings database, sorted alphabetically. Used for populating filter dropdowns and ensuring UI reflects actual data.
+// @Description Retrieves a list of all unique action types found in the stock ratings database, sorted alphabetically. Used for populating filter dropdowns and ensuring UI reflects actual data. The whole list is returned unless limit or offset is given; total is always the number of distinct actions.
 // @Tags stocks
 // @Produce json
+// @Param limit query int false "Number of actions to return (1-1000), pages the list when given" default(100)
+// @Param offset query int false "Number of actions to skip (min 0), pages the list when given" default(0)
 // @Success 200 {object} ActionsResponse "Successfully retrieved list of unique actions"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid limit or offset parameter"
 // @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
 // @Router /stocks/actions [get]
 func (h *StockHandler) GetStockActions(c *gin.Context) {
+	_, hasLimit := c.GetQuery("limit")
+	_, hasOffset := c.GetQuery("offset")
+	paged := hasLimit || hasOffset
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter. Must be between 1 and 1000"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter. Must be 0 or greater"})
+		return
+	}
+
 	// Query to get all unique actions from the database
 	query := `
 		SELECT DISTINCT action 
 		FROM stock_ratings 
 		WHERE action IS NOT NULL AND action != '' 
 		ORDER BY action ASC`
+	var args []interface{}
+	total := 0
+	if paged {
+		err := h.DB.QueryRow(`
+		SELECT COUNT(DISTINCT action)
+		FROM stock_ratings
+		WHERE action IS NOT NULL AND action != ''`).Scan(&total)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count stock actions"})
+			return
+		}
+		query += `
+		LIMIT $1 OFFSET $2`
+		args = append(args, limit, offset)
+	}
 
-	rows, err := h.DB.Query(query)
+	rows, err := h.DB.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stock actions"})
 		return
@@ -740,14 +2336,18 @@ func (h *StockHandler) GetStockActions(c *gin.Context) {
 	}
 
 	// Return the list of actions
-	c.JSON(http.StatusOK, ActionsResponse{
-		Actions: actions,
-	})
+	response := ActionsResponse{Actions: actions, Total: len(actions)}
+	if paged {
+		response.Total = total
+		response.Limit = limit
+		response.Offset = offset
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // GetFilterOptions retrieves all available filter options
 // @Summary Get all available filter options
-// @Description Retrieves filter options including actions, ratings from database
+// @Description Retrieves filter options including actions, ratings and brokerages from database
 // @Tags stocks
 // @Produce json
 // @Success 200 {object} FilterOptionsResponse "Successfully retrieved filter options"
@@ -756,46 +2356,69 @@ func (h *StockHandler) GetStockActions(c *gin.Context) {
 func (h *StockHandler) GetFilterOptions(c *gin.Context) {
 	var response FilterOptionsResponse
 
-	// Get unique actions
-	actionsQuery := `SELECT DISTINCT action FROM stock_ratings WHERE action IS NOT NULL AND action != '' ORDER BY action ASC`
-	rows, err := h.DB.Query(actionsQuery)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var action string
-			if err := rows.Scan(&action); err == nil {
-				response.Actions = append(response.Actions, action)
-			}
-		}
+	// Each list is best effort: a failed query leaves that option list empty
+	if actions, err := h.queryDistinct("action"); err == nil {
+		response.Actions = actions
+	}
+	if ratingsFrom, err := h.queryDistinct("rating_from"); err == nil {
+		response.RatingsFrom = ratingsFrom
+	}
+	if ratingsTo, err := h.queryDistinct("rating_to"); err == nil {
+		response.RatingsTo = ratingsTo
+	}
+	if brokerages, err := h.queryDistinct("brokerage"); err == nil {
+		response.Brokerages = brokerages
 	}
 
-	// Get unique ratings from
-	ratingsFromQuery := `SELECT DISTINCT rating_from FROM stock_ratings WHERE rating_from IS NOT NULL AND rating_from != '' ORDER BY rating_from ASC`
-	rows, err = h.DB.Query(ratingsFromQuery)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var rating string
-			if err := rows.Scan(&rating); err == nil {
-				response.RatingsFrom = append(response.RatingsFrom, rating)
-			}
-		}
+	c.JSON(http.StatusOK, response)
+}
+
+// SectorsResponse represents the response structure for the sectors endpoint
+type SectorsResponse struct {
+	Sectors []string `json:"sectors" example:"Healthcare,Technology"`
+}
+
+// GetStockSectors retrieves all distinct sectors from the database
+// @Summary Get all available sectors
+// @Description Retrieves the distinct sectors of stored stock ratings, sorted alphabetically. Ratings without a sector (older rows or API items without one) are not included.
+// @Tags stocks
+// @Produce json
+// @Success 200 {object} SectorsResponse "Successfully retrieved list of sectors"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/sectors [get]
+func (h *StockHandler) GetStockSectors(c *gin.Context) {
+	sectors, err := h.queryDistinct("sector")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stock sectors"})
+		return
+	}
+	if sectors == nil {
+		sectors = []string{}
 	}
 
-	// Get unique ratings to
-	ratingsToQuery := `SELECT DISTINCT rating_to FROM stock_ratings WHERE rating_to IS NOT NULL AND rating_to != '' ORDER BY rating_to ASC`
-	rows, err = h.DB.Query(ratingsToQuery)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var rating string
-			if err := rows.Scan(&rating); err == nil {
-				response.RatingsTo = append(response.RatingsTo, rating)
-			}
-		}
+	c.JSON(http.StatusOK, SectorsResponse{Sectors: sectors})
+}
+
+// queryDistinct returns the sorted non-empty distinct values of a stock_ratings column.
+// The column name is interpolated into the query, so it must never come from user input.
+// The result set is closed before returning so callers can run queries back to back.
+func (h *StockHandler) queryDistinct(column string) ([]string, error) {
+	query := fmt.Sprintf(`SELECT DISTINCT %[1]s FROM stock_ratings WHERE %[1]s IS NOT NULL AND %[1]s != '' ORDER BY %[1]s ASC`, column)
+	rows, err := h.DB.Query(query)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	c.JSON(http.StatusOK, response)
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
 }
 
 // stockData represents internal stock data structure for analysis
@@ -808,7 +2431,8 @@ type stockData struct {
 	RatingTo   string
 	TargetFrom string
 	TargetTo   string
-	Time       string // Actual analyst report time (the important one for analysis)
+	Time       time.Time // Actual analyst report time (the important one for analysis), zero when unknown
+	Sector     string    // Only selected by the summary query, empty when unknown
 	// Note: CreatedAt removed - we don't need database insertion time for analysis
 }
 
@@ -824,44 +2448,223 @@ type StockRecommendation struct {
 	Brokerage         string  `json:"brokerage" example:"Goldman Sachs"`
 	PriceChange       float64 `json:"price_change" example:"15.5"`
 	RatingImprovement bool    `json:"rating_improvement" example:"true"`
+	Sector            string  `json:"sector,omitempty" example:"Technology"`
+	// Confidence (0-1) grows with the number of distinct brokerages covering the ticker, Score is unaffected
+	Confidence     float64        `json:"confidence" example:"0.88"`
+	ScoreBreakdown ScoreBreakdown `json:"score_breakdown"` // How each criterion contributed to Score
+}
+
+// ScoreBreakdown holds the weighted contribution of each scoring criterion.
+// Total is Base plus the four contributions, capped to the 0-10 score range.
+type ScoreBreakdown struct {
+	Base        float64 `json:"base" example:"5.0"`
+	TargetPrice float64 `json:"target_price" example:"1.2"`
+	Rating      float64 `json:"rating" example:"0.9"`
+	Action      float64 `json:"action" example:"0.3"`
+	Timing      float64 `json:"timing" example:"0.1"`
+	Total       float64 `json:"total" example:"7.5"`
+}
+
+// explanation describes how the criteria add up to the total, e.g.
+// "score 7.50 = base 5.00 +1.20 target price +0.90 rating +0.30 action +0.10 timing"
+func (b ScoreBreakdown) explanation() string {
+	return fmt.Sprintf("score %.2f = base %.2f %+.2f target price %+.2f rating %+.2f action %+.2f timing",
+		b.Total, b.Base, b.TargetPrice, b.Rating, b.Action, b.Timing)
 }
 
-type RecommendationsResponse struct {
-	Recommendations []StockRecommendation `json:"recommendations"`
-	GeneratedAt     string                `json:"generated_at" example:"2024-01-15T10:30:00Z"`
-	TotalAnalyzed   int                   `json:"total_analyzed" example:"1250"`
+type RecommendationsResponse struct {
+	Recommendations []StockRecommendation `json:"recommendations"`
+	GeneratedAt     string                `json:"generated_at" example:"2024-01-15T10:30:00Z"`
+	TotalAnalyzed   int                   `json:"total_analyzed" example:"1250"`
+	Weights         ScoringWeights        `json:"weights"`
+	Days            int                   `json:"days,omitempty" example:"30"` // Analysis window, omitted for all-time
+	// TotalRecommendations counts every stock scoring at least min_score, before limit or paging
+	TotalRecommendations int                    `json:"total_recommendations" example:"37"`
+	Pagination           *models.PaginationMeta `json:"pagination,omitempty"` // Set when page_number or page_length is given
+	// Cached is true when the ranking came from recommendations_cache, GeneratedAt is then when it was computed
+	Cached bool `json:"cached" example:"false"`
+}
+
+// GetStockRecommendations analyzes stock data and provides investment recommendations
+// @Summary Get quantitative stock investment recommendations
+// @Description Analyzes all stock ratings data using configurable weighted algorithms to provide ranked investment recommendations. Considers target price changes, rating improvements, analyst sentiment, and market trends. Requests without custom weights, days or only are served from the recommendations_cache table while it is fresh (cached=true, generated_at is when it was computed); it is refreshed after every bulk ingest or with POST /stocks/recommendations/refresh, cleared when ratings are fetched, imported, edited or deleted, and expires after RECOMMENDATIONS_CACHE_TTL (default 15m).
+// @Tags recommendations
+// @Produce json
+// @Param limit query int false "Number of recommendations to return (3, 5, 10, 15, 20)" default(10)
+// @Param target_weight query number false "Custom weight for target price changes (0-1). When any weight is given, omitted weights count as 0 and all must sum to 1"
+// @Param rating_weight query number false "Custom weight for rating analysis (0-1)"
+// @Param action_weight query number false "Custom weight for action analysis (0-1)"
+// @Param timing_weight query number false "Custom weight for recent activity (0-1)"
+// @Param min_score query number false "Minimum score (0-10) a stock needs to be recommended" default(5.0)
+// @Param days query int false "Only analyze ratings from the last N days (1-3650), all-time when omitted"
+// @Param page_number query int false "Page of recommendations (min 1). When page_number or page_length is given, limit is ignored and pagination is returned"
+// @Param page_length query int false "Recommendations per page (1-1000)" default(20)
+// @Param exclude query string false "Comma-separated tickers to leave out of the results, e.g. tickers already held (max 100)"
+// @Param only query string false "Comma-separated watchlist of tickers, only these are analyzed (max 100)"
+// @Param explain query bool false "Append the score breakdown to each reason" default(false)
+// @Success 200 {object} RecommendationsResponse "Successfully generated stock recommendations with scoring and analysis"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid limit, weight, min_score, days, ticker list, explain or pagination parameters"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred during analysis"
+// @Router /stocks/recommendations [get]
+func (h *StockHandler) GetStockRecommendations(c *gin.Context) {
+	// Parse limit parameter
+	limitStr := c.DefaultQuery("limit", "10")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter. Must be between 1 and 50"})
+		return
+	}
+
+	// Optional paging past the 50 recommendation limit
+	_, hasPageNumber := c.GetQuery("page_number")
+	_, hasPageLength := c.GetQuery("page_length")
+	paged := hasPageNumber || hasPageLength
+	pageNumber, err := strconv.Atoi(c.DefaultQuery("page_number", "1"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_number must be greater than 0"})
+		return
+	}
+	pageLength, err := strconv.Atoi(c.DefaultQuery("page_length", "20"))
+	if err != nil || pageLength <= 0 || pageLength > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_length must be between 1 and 1000"})
+		return
+	}
+
+	// Resolve scoring weights, custom weights must sum to 100%
+	weights, err := parseScoringWeights(c, h.scoringWeights.Get())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Parse minimum score threshold
+	minScore, err := strconv.ParseFloat(c.DefaultQuery("min_score", strconv.FormatFloat(defaultMinScore, 'f', -1, 64)), 64)
+	if err != nil || minScore < 0 || minScore > 10 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_score parameter. Must be between 0 and 10"})
+		return
+	}
+
+	// Parse optional analysis window, all-time when absent
+	days := 0
+	if daysStr, ok := c.GetQuery("days"); ok {
+		days, err = strconv.Atoi(daysStr)
+		if err != nil || days < 1 || days > maxRecommendationDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid days parameter. Must be between 1 and %d", maxRecommendationDays)})
+			return
+		}
+	}
+
+	explain, err := strconv.ParseBool(c.DefaultQuery("explain", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "explain must be true or false"})
+		return
+	}
+
+	// Optional ticker lists, exclude drops tickers after scoring and only restricts the analysis
+	exclude, err := parseTickerList("exclude", c.Query("exclude"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	only, err := parseTickerList("only", c.Query("only"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Requests the cache was computed for are served from recommendations_cache, anything else is scored live
+	var recommendations []StockRecommendation
+	var totalAnalyzed int
+	var generatedAt time.Time
+	cached := false
+	if days == 0 && len(only) == 0 && weights == h.scoringWeights.Get() {
+		recommendations, totalAnalyzed, generatedAt, cached = h.cachedRecommendations(c.Request.Context(), minScore)
+	}
+	if !cached {
+		stocks, err := h.queryRecommendationStocks(c.Request.Context(), days, only)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stock data for recommendations"})
+			return
+		}
+
+		// Rank every qualifying stock, then cut the requested limit or page
+		now := h.now()
+		recommendations = analyzeStocksForRecommendations(stocks, 0, weights, minScore, now)
+		totalAnalyzed = len(stocks)
+		generatedAt = now
+	}
+	if len(exclude) > 0 {
+		// Filter in place so the ranking order is kept and limit still fills up from the remaining stocks
+		kept := recommendations[:0]
+		for _, rec := range recommendations {
+			if !contains(exclude, strings.ToUpper(rec.Ticker)) {
+				kept = append(kept, rec)
+			}
+		}
+		recommendations = kept
+	}
+	if explain {
+		for i := range recommendations {
+			recommendations[i].Reason += "; " + recommendations[i].ScoreBreakdown.explanation()
+		}
+	}
+	total := len(recommendations)
+
+	response := RecommendationsResponse{
+		GeneratedAt:          generatedAt.Format(time.RFC3339),
+		TotalAnalyzed:        totalAnalyzed,
+		Weights:              weights,
+		Days:                 days,
+		TotalRecommendations: total,
+		Cached:               cached,
+	}
+	if paged {
+		start := min((pageNumber-1)*pageLength, total)
+		end := min(start+pageLength, total)
+		recommendations = recommendations[start:end]
+
+		totalPages := (total + pageLength - 1) / pageLength
+		response.Pagination = &models.PaginationMeta{
+			PageNumber:   pageNumber,
+			PageLength:   pageLength,
+			TotalRecords: total,
+			TotalPages:   totalPages,
+			HasNext:      pageNumber < totalPages,
+			HasPrevious:  pageNumber > 1,
+		}
+	} else if total > limit {
+		recommendations = recommendations[:limit]
+	}
+	response.Recommendations = recommendations
+
+	// Return top recommendations
+	c.JSON(http.StatusOK, response)
 }
 
-// GetStockRecommendations analyzes stock data and provides investment recommendations
-// @Summary Get quantitative stock investment recommendations
-// @Description Analyzes all stock ratings data using configurable weighted algorithms to provide ranked investment recommendations. Considers target price changes, rating improvements, analyst sentiment, and market trends.
-// @Tags recommendations
-// @Produce json
-// @Param limit query int false "Number of recommendations to return (3, 5, 10, 15, 20)" default(10)
-// @Success 200 {object} RecommendationsResponse "Successfully generated stock recommendations with scoring and analysis"
-// @Failure 400 {object} models.ErrorResponse "Bad request - invalid limit parameter"
-// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred during analysis"
-// @Router /stocks/recommendations [get]
-func (h *StockHandler) GetStockRecommendations(c *gin.Context) {
-	// Parse limit parameter
-	limitStr := c.DefaultQuery("limit", "10")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 || limit > 50 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter. Must be between 1 and 50"})
-		return
+// queryRecommendationStocks loads the ratings analyzed for recommendations, newest first.
+// days > 0 limits them to the last days days and only to the listed tickers. The window is applied
+// before grouping, so the latest entry per ticker is the latest one inside the window.
+func (h *StockHandler) queryRecommendationStocks(ctx context.Context, days int, only []string) ([]stockData, error) {
+	var args []interface{}
+	windowClause := ""
+	if days > 0 {
+		args = append(args, days)
+		windowClause = fmt.Sprintf(" AND time >= NOW() - ($%d * INTERVAL '1 day')", len(args))
+	}
+	if len(only) > 0 {
+		args = append(args, pq.Array(only))
+		windowClause += fmt.Sprintf(" AND UPPER(ticker) = ANY($%d)", len(args))
 	}
-	// Query to get all stock data for analysis
 	query := `
 		SELECT ticker, company, action, brokerage, rating_from, rating_to, 
 		       target_from, target_to, time, created_at
 		FROM stock_ratings 
-		WHERE ticker IS NOT NULL AND company IS NOT NULL
+		WHERE ticker IS NOT NULL AND company IS NOT NULL` + windowClause + `
 		ORDER BY time DESC`
 
-	rows, err := h.DB.Query(query)
+	rows, err := h.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stock data for recommendations"})
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -869,33 +2672,94 @@ func (h *StockHandler) GetStockRecommendations(c *gin.Context) {
 	var stocks []stockData
 	for rows.Next() {
 		var stock stockData
+		var reportTime sql.NullTime
 		var createdAt time.Time // Scan but don't use for analysis
 		err := rows.Scan(&stock.Ticker, &stock.Company, &stock.Action, &stock.Brokerage,
 			&stock.RatingFrom, &stock.RatingTo, &stock.TargetFrom, &stock.TargetTo,
-			&stock.Time, &createdAt)
+			&reportTime, &createdAt)
 		if err != nil {
 			continue
 		}
+		stock.Time = reportTime.Time
 		stocks = append(stocks, stock)
 	}
+	return stocks, nil
+}
 
-	// Analyze and generate recommendations with specified limit
-	recommendations := analyzeStocksForRecommendations(stocks, limit)
+// parseTickerList parses a comma-separated list of tickers from the named query parameter.
+// Tickers are trimmed, uppercased and deduplicated; an empty value yields a nil list.
+func parseTickerList(param, raw string) ([]string, error) {
+	var tickers []string
+	for _, part := range strings.Split(raw, ",") {
+		ticker := strings.ToUpper(strings.TrimSpace(part))
+		if ticker == "" || contains(tickers, ticker) {
+			continue
+		}
+		if !tickerPattern.MatchString(ticker) {
+			return nil, fmt.Errorf("Invalid ticker %s in %s: must be 2-5 letters", ticker, param)
+		}
+		tickers = append(tickers, ticker)
+	}
+	if len(tickers) > maxTickerListLength {
+		return nil, fmt.Errorf("%s must list at most %d tickers", param, maxTickerListLength)
+	}
+	return tickers, nil
+}
 
-	// Return top recommendations
-	c.JSON(http.StatusOK, RecommendationsResponse{
-		Recommendations: recommendations,
-		GeneratedAt:     time.Now().Format(time.RFC3339),
-		TotalAnalyzed:   len(stocks),
-	})
+// parseScoringWeights builds scoring weights from the target_weight, rating_weight,
+// action_weight and timing_weight query parameters.
+// Returns defaults when none are present. Otherwise omitted weights count as 0
+// and the resulting set must pass validateWeights.
+func parseScoringWeights(c *gin.Context, defaults ScoringWeights) (ScoringWeights, error) {
+	var weights ScoringWeights
+	params := []struct {
+		name  string
+		value *float64
+	}{
+		{"target_weight", &weights.TargetPriceWeight},
+		{"rating_weight", &weights.RatingWeight},
+		{"action_weight", &weights.ActionWeight},
+		{"timing_weight", &weights.TimingWeight},
+	}
+
+	custom := false
+	for _, param := range params {
+		raw, ok := c.GetQuery(param.name)
+		if !ok {
+			continue
+		}
+		custom = true
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value < 0 || value > 1 {
+			return ScoringWeights{}, fmt.Errorf("Invalid %s parameter. Must be a number between 0 and 1", param.name)
+		}
+		*param.value = value
+	}
+
+	if !custom {
+		return defaults, nil
+	}
+	if err := weights.validateWeights(); err != nil {
+		return ScoringWeights{}, err
+	}
+	return weights, nil
 }
 
+// defaultMinScore is the minimum score a stock needs to be recommended
+const defaultMinScore = 5.0
+
+// maxRecommendationDays bounds the days window of GetStockRecommendations to ten years
+const maxRecommendationDays = 3650
+
+// maxTickerListLength bounds the exclude and only ticker lists of GetStockRecommendations
+const maxTickerListLength = 100
+
 // analyzeStocksForRecommendations implements the quantitative recommendation algorithm
 // 
 // ALGORITHM OVERVIEW:
 // 1. Groups all stocks by ticker symbol to get latest data per company
 // 2. Calculates weighted score (0-10) for each stock using multiple criteria
-// 3. Filters stocks with score >= 5.0 (minimum recommendation threshold)
+// 3. Filters stocks with score >= minScore (default 5.0, minimum recommendation threshold)
 // 4. Sorts by score (highest first) and returns top 10 recommendations
 // 
 // WHY TOP 3 IS VARIABLE:
@@ -904,7 +2768,9 @@ func (h *StockHandler) GetStockRecommendations(c *gin.Context) {
 // - Updated target prices and ratings
 // - Time decay (recent activity gets bonus points)
 // - Competitive ranking (a stock with 8.5 score today might drop to 7.8 tomorrow)
-func analyzeStocksForRecommendations(stocks []stockData, limit int) []StockRecommendation {
+//
+// now is the reference time for the freshness bonus, passed in so scoring is deterministic in tests
+func analyzeStocksForRecommendations(stocks []stockData, limit int, weights ScoringWeights, minScore float64, now time.Time) []StockRecommendation {
 	// STEP 1: Group stocks by ticker to get latest data per company
 	// This ensures we analyze the most recent analyst opinion for each stock
 	stockMap := make(map[string][]stockData)
@@ -921,35 +2787,22 @@ func analyzeStocksForRecommendations(stocks []stockData, limit int) []StockRecom
 		}
 
 		// Get the most recent entry for this stock (based on actual analyst report time)
-		latestStock := stockList[0]
-		for _, s := range stockList {
-			// Parse time strings to compare actual report dates
-			sTime, sErr := time.Parse("2006-01-02 15:04:05", s.Time)
-			latestTime, latestErr := time.Parse("2006-01-02 15:04:05", latestStock.Time)
-			if sErr == nil && latestErr == nil && sTime.After(latestTime) {
-				latestStock = s
-			}
-		}
+		latestStock := latestStockData(stockList)
 
 		// STEP 3: Calculate quantitative recommendation score (0-10 scale)
 		// Uses configurable weighted algorithm considering multiple factors
-		score := calculateStockScore(latestStock, stockList)
-		if score < 5.0 { // QUALITY FILTER: Only recommend stocks with score >= 5.0
+		breakdown := calculateStockScore(latestStock, stockList, weights, now)
+		score := breakdown.Total
+		if score < minScore { // QUALITY FILTER: Only recommend stocks with score >= minScore
 			continue // Skip low-quality recommendations
 		}
 
 		// Parse target prices for analysis
-		// Parse "$150.00" -> 150.0
-		targetFrom := parsePrice(latestStock.TargetFrom)
-		targetTo := parsePrice(latestStock.TargetTo)
-		priceChange := 0.0
-		if targetFrom > 0 {
-			priceChange = ((targetTo - targetFrom) / targetFrom) * 100
-		}
+		priceChange := targetPriceChange(latestStock)
 
 		// Determine recommendation level
 		recommendationLevel := getRecommendationLevel(score)
-		reason := generateRecommendationReason(latestStock, priceChange, score)
+		reason := generateRecommendationReason(latestStock, stockList, priceChange, score)
 
 		recommendations = append(recommendations, StockRecommendation{
 			Ticker:            ticker,
@@ -962,31 +2815,60 @@ func analyzeStocksForRecommendations(stocks []stockData, limit int) []StockRecom
 			Brokerage:         latestStock.Brokerage,
 			PriceChange:       priceChange,
 			RatingImprovement: isRatingImprovement(latestStock.RatingFrom, latestStock.RatingTo),
+			Sector:            latestStock.Sector,
+			Confidence:        coverageConfidence(stockList),
+			ScoreBreakdown:    breakdown,
 		})
 	}
 
 	// STEP 4: SORTING - This is where the magic happens!
 	// Sort by score in DESCENDING order (highest scores first)
 	// This determines the final ranking: #1, #2, #3, etc.
+	// Equal scores are ordered by ticker, so the ranking doesn't depend on map iteration order
 	sort.Slice(recommendations, func(i, j int) bool {
-		return recommendations[i].Score > recommendations[j].Score // Higher score = better rank
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score // Higher score = better rank
+		}
+		return recommendations[i].Ticker < recommendations[j].Ticker
 	})
 
-	// STEP 5: Return top N recommendations based on user selection
-	if len(recommendations) > limit {
+	// STEP 5: Return top N recommendations based on user selection (limit 0 returns them all)
+	if limit > 0 && len(recommendations) > limit {
 		recommendations = recommendations[:limit] // Slice to get requested number
 	}
 
 	return recommendations // Sorted list: [highest_score, second_highest, third_highest, ...]
 }
 
+// latestStockData returns the entry of a ticker's history with the most recent report time.
+// Unknown (zero) times never win, so the first entry is kept when no time is known.
+func latestStockData(history []stockData) stockData {
+	latest := history[0]
+	for _, s := range history {
+		if s.Time.After(latest.Time) {
+			latest = s
+		}
+	}
+	return latest
+}
+
+// targetPriceChange returns the percent change from target_from to target_to, 0 when target_from is unknown
+func targetPriceChange(stock stockData) float64 {
+	targetFrom := parsePrice(stock.TargetFrom) // Parse "$150.00" -> 150.0
+	targetTo := parsePrice(stock.TargetTo)
+	if targetFrom <= 0 {
+		return 0
+	}
+	return ((targetTo - targetFrom) / targetFrom) * 100
+}
+
 // ScoringWeights defines configurable weights for stock scoring algorithm
 // Allows easy modification of scoring criteria for market adaptability
 type ScoringWeights struct {
-	TargetPriceWeight float64 // Weight for target price changes (default: 0.4)
-	RatingWeight      float64 // Weight for rating analysis (default: 0.3)
-	ActionWeight      float64 // Weight for action analysis (default: 0.2)
-	TimingWeight      float64 // Weight for recent activity (default: 0.1)
+	TargetPriceWeight float64 `json:"target_weight" example:"0.4"` // Weight for target price changes (default: 0.4)
+	RatingWeight      float64 `json:"rating_weight" example:"0.3"` // Weight for rating analysis (default: 0.3)
+	ActionWeight      float64 `json:"action_weight" example:"0.2"` // Weight for action analysis (default: 0.2)
+	TimingWeight      float64 `json:"timing_weight" example:"0.1"` // Weight for recent activity (default: 0.1)
 }
 
 // validateWeights ensures weights sum to 100% (1.0)
@@ -1023,7 +2905,7 @@ func getDefaultWeights() ScoringWeights {
 // 🎯 Target Price Changes: Configurable % (default 40%)
 // ⭐ Rating Analysis: Configurable % (default 30%)
 // 📊 Action Analysis: Configurable % (default 20%)
-// ⏰ Recent Activity: Configurable % (default 10%)
+// ⏰ Recent Activity: Configurable % (default 10%), includes upgrade/downgrade momentum
 // 
 // SCORE RANGES:
 // 8.5-10.0 = Strong Buy (top tier recommendations)
@@ -1031,9 +2913,11 @@ func getDefaultWeights() ScoringWeights {
 // 6.0-6.9  = Moderate Buy (decent opportunities)
 // 5.0-5.9  = Hold (minimum threshold)
 // 0.0-4.9  = Not recommended (filtered out)
-func calculateStockScore(stock stockData, history []stockData) float64 {
-	weights := getDefaultWeights() // Get configurable weights
-	score := 5.0 // NEUTRAL BASE SCORE - every stock starts here
+//
+// now is the reference time the 24-hour freshness bonus is measured from.
+// Returns the weighted contribution of each criterion along with the capped total.
+func calculateStockScore(stock stockData, history []stockData, weights ScoringWeights, now time.Time) ScoreBreakdown {
+	breakdown := ScoreBreakdown{Base: 5.0} // NEUTRAL BASE SCORE - every stock starts here
 
 	// 🎯 CRITERION 1: TARGET PRICE ANALYSIS (CONFIGURABLE WEIGHT)
 	// Price targets directly indicate expected returns - critical for speculative markets
@@ -1053,7 +2937,7 @@ func calculateStockScore(stock stockData, history []stockData) float64 {
 	} else if targetTo < targetFrom {
 		targetPriceScore = -2.0 // PENALTY: Price target was LOWERED
 	}
-	score += targetPriceScore * weights.TargetPriceWeight // Apply configurable weight
+	breakdown.TargetPrice = targetPriceScore * weights.TargetPriceWeight // Apply configurable weight
 
 	// ⭐ CRITERION 2: RATING ANALYSIS (CONFIGURABLE WEIGHT)
 	// Analyst ratings reflect professional opinion and research
@@ -1067,7 +2951,7 @@ func calculateStockScore(stock stockData, history []stockData) float64 {
 	} else if isBuyRating(stock.RatingTo) {
 		ratingScore += 1.0 // BUY: Positive rating
 	}
-	score += ratingScore * weights.RatingWeight // Apply configurable weight
+	breakdown.Rating = ratingScore * weights.RatingWeight // Apply configurable weight
 
 	// 📊 CRITERION 3: ACTION ANALYSIS (CONFIGURABLE WEIGHT)
 	// Actions indicate the direction and confidence of analyst changes
@@ -1080,26 +2964,53 @@ func calculateStockScore(stock stockData, history []stockData) float64 {
 	} else if strings.Contains(action, "lowered") || strings.Contains(action, "downgrade") {
 		actionScore = -1.5 // NEGATIVE ACTIONS: "target lowered", "rating downgraded"
 	}
-	score += actionScore * weights.ActionWeight // Apply configurable weight
+	breakdown.Action = actionScore * weights.ActionWeight // Apply configurable weight
 
 	// ⏰ CRITERION 4: RECENT ACTIVITY BONUS (CONFIGURABLE WEIGHT)
 	// Recent analyst reports indicate current market relevance
 	var timingScore float64
-	analystTime, err := time.Parse("2006-01-02 15:04:05", stock.Time)
-	if err == nil && time.Since(analystTime).Hours() < 24 {
+	if !stock.Time.IsZero() && now.Sub(stock.Time).Hours() < 24 {
 		timingScore += 0.5 // FRESHNESS BONUS: Analyst report is less than 24 hours old
 	}
 	// MULTIPLE ANALYST COVERAGE BONUS
 	if len(history) > 1 {
 		timingScore += 0.5 // CONSENSUS BONUS: 2+ analysts have opinions on this stock
 	}
-	score += timingScore * weights.TimingWeight // Apply configurable weight
+	// MOMENTUM BONUS: kept inside the timing weight so the weights still sum to 100%
+	if upgrades, downgrades := actionMomentum(history); upgrades > downgrades {
+		timingScore += 0.5 // More upgrades than downgrades across the ticker's coverage
+	}
+	breakdown.Timing = timingScore * weights.TimingWeight // Apply configurable weight
 
 	// FINAL SCORE CAPPING: Ensure score stays within valid range
-	return math.Min(10.0, math.Max(0.0, score)) // Cap between 0-10 (no negative or >10 scores)
+	score := breakdown.Base + breakdown.TargetPrice + breakdown.Rating + breakdown.Action + breakdown.Timing
+	breakdown.Total = math.Min(10.0, math.Max(0.0, score)) // Cap between 0-10 (no negative or >10 scores)
+	return breakdown
 }
 
 // Helper functions
+
+// actionMomentum counts the upgrade and downgrade actions in a ticker's rating history
+func actionMomentum(history []stockData) (upgrades, downgrades int) {
+	for _, s := range history {
+		action := strings.ToLower(s.Action)
+		if strings.Contains(action, "downgrade") {
+			downgrades++
+		} else if strings.Contains(action, "upgrade") {
+			upgrades++
+		}
+	}
+	return upgrades, downgrades
+}
+
+// pluralize formats a count with its noun, adding an "s" unless the count is 1 ("3 upgrades", "1 downgrade")
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
 func parsePrice(priceStr string) float64 {
 	cleanPrice := strings.ReplaceAll(priceStr, "$", "")
 	cleanPrice = strings.ReplaceAll(cleanPrice, ",", "")
@@ -1107,40 +3018,90 @@ func parsePrice(priceStr string) float64 {
 	return price
 }
 
-// isRatingImprovement checks if a rating was upgraded
-// 
-// RATING HIERARCHY (1-8 scale, higher = better):
-// 1 = Strong Sell (worst)
-// 2 = Sell  
-// 3 = Underperform/Underweight
-// 4 = Hold
-// 5 = Neutral
-// 6 = Outperform
-// 7 = Buy/Overweight  
-// 8 = Strong Buy (best)
+// maxPriceLength is the size of the target_from and target_to VARCHAR columns
+const maxPriceLength = 20
+
+// normalizePrice turns a target price from the external API into the canonical "$1250.50" form stored in the database.
+// Values that aren't a non-negative number once $ and commas are stripped ("N/A", "—", "€100") become "",
+// which the numeric casts turn into NULL; the columns are NOT NULL so an empty string stands in for a missing price.
+func normalizePrice(raw string) string {
+	clean := strings.TrimSpace(raw)
+	clean = strings.ReplaceAll(clean, "$", "")
+	clean = strings.ReplaceAll(clean, ",", "")
+	if clean == "" {
+		return ""
+	}
+
+	price, err := strconv.ParseFloat(clean, 64)
+	if err != nil || price < 0 || math.IsInf(price, 0) || math.IsNaN(price) {
+		return ""
+	}
+	normalized := fmt.Sprintf("$%.2f", price)
+	if len(normalized) > maxPriceLength {
+		return ""
+	}
+	return normalized
+}
+
+// priceNumeric returns a price normalized by normalizePrice as the text of a NUMERIC for the
+// target_from_num and target_to_num columns, or NULL when the price is missing.
+func priceNumeric(normalized string) sql.NullString {
+	if normalized == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: strings.TrimPrefix(normalized, "$"), Valid: true}
+}
+
+// isRatingImprovement checks if a rating was upgraded, comparing ranks from ratingMap.
+// Ratings missing from the map rank 0.
 // 
 // EXAMPLES:
 // "Hold" (4) -> "Buy" (7) = TRUE (improvement)
 // "Buy" (7) -> "Hold" (4) = FALSE (downgrade)
 // "Buy" (7) -> "Strong Buy" (8) = TRUE (improvement)
 func isRatingImprovement(from, to string) bool {
-	ratingScore := map[string]int{
-		"strong sell": 1, "sell": 2, "underperform": 3, "hold": 4, "neutral": 5,
-		"outperform": 6, "buy": 7, "strong buy": 8, "overweight": 7, "underweight": 3,
+	toClass, _ := classifyRating(to)
+	fromClass, _ := classifyRating(from)
+	return toClass.Rank > fromClass.Rank
+}
+
+// coverageConfidence rates how well a ticker is covered by analysts on a 0-1 scale.
+// It counts the distinct brokerages in the history and applies a logistic curve centered on
+// confidenceMidpoint analysts, so one analyst gives about 0.05, four give 0.5 and eight or more approach 1.0.
+func coverageConfidence(history []stockData) float64 {
+	brokerages := make(map[string]bool)
+	for _, stock := range history {
+		if name := strings.ToLower(strings.TrimSpace(stock.Brokerage)); name != "" {
+			brokerages[name] = true
+		}
 	}
-	return ratingScore[strings.ToLower(to)] > ratingScore[strings.ToLower(from)]
+	if len(brokerages) == 0 {
+		return 0
+	}
+	confidence := 1 / (1 + math.Exp(-confidenceSteepness*(float64(len(brokerages))-confidenceMidpoint)))
+	return math.Round(confidence*100) / 100
 }
 
-// isStrongBuyRating checks if a rating is a strong buy or overweight
+// Logistic curve parameters of coverageConfidence
+const (
+	confidenceMidpoint  = 4.0
+	confidenceSteepness = 1.0
+)
+
+// isStrongBuyRating checks if a rating is a strong buy, e.g. Strong Buy or Overweight
 func isStrongBuyRating(rating string) bool {
-	lower := strings.ToLower(rating)
-	return strings.Contains(lower, "strong buy") || strings.Contains(lower, "overweight")
+	class, _ := classifyRating(rating)
+	return class.Strong
 }
 
-// isBuyRating checks if a rating is a buy or outperform
+// isBuyRating checks if a rating is bullish, e.g. Buy, Outperform or Sector Outperform
 func isBuyRating(rating string) bool {
-	lower := strings.ToLower(rating)
-	return strings.Contains(lower, "buy") || strings.Contains(lower, "outperform")
+	return ratingSentiment(rating) == sentimentBullish
+}
+
+// isBearishRating checks if a rating is bearish, e.g. Sell, Underperform or Underweight
+func isBearishRating(rating string) bool {
+	return ratingSentiment(rating) == sentimentBearish
 }
 
 // getRecommendationLevel maps score to recommendation string
@@ -1157,7 +3118,8 @@ func getRecommendationLevel(score float64) string {
 }
 
 // generateRecommendationReason creates a reason string based on analysis
-func generateRecommendationReason(stock stockData, priceChange, score float64) string {
+// history is every rating of the ticker, used to mention momentum when several firms acted on it
+func generateRecommendationReason(stock stockData, history []stockData, priceChange, score float64) string {
 	reasons := []string{}
 
 	if priceChange > 10 {
@@ -1169,6 +3131,10 @@ func generateRecommendationReason(stock stockData, priceChange, score float64) s
 	if strings.Contains(strings.ToLower(stock.Action), "initiated") {
 		reasons = append(reasons, "New analyst coverage")
 	}
+	if upgrades, downgrades := actionMomentum(history); upgrades+downgrades > 1 {
+		reasons = append(reasons, fmt.Sprintf("%s vs %s in recent coverage",
+			pluralize(upgrades, "upgrade"), pluralize(downgrades, "downgrade")))
+	}
 	if score >= 8.0 {
 		reasons = append(reasons, "Strong analyst sentiment")
 	}
@@ -1184,40 +3150,110 @@ type SummaryResponse struct {
 	Summary     string `json:"summary" example:"Today's market shows strong bullish sentiment with 15 stocks receiving target price increases. Apple leads recommendations with a 12% target raise to $180, while tech sector dominates with 60% of top picks."`
 	GeneratedAt string `json:"generated_at" example:"2024-01-15T10:30:00Z"`
 	TokensUsed  int    `json:"tokens_used" example:"245"`
+	Source      string `json:"source" example:"ai" enums:"ai,fallback"`
+	FromCache   bool   `json:"from_cache" example:"false"`
+	CachedAt    string `json:"cached_at,omitempty" example:"2024-01-15T10:30:00Z"` // When the cached summary was generated
 }
 
+// Where a SummaryResponse's text comes from
+const (
+	summarySourceAI       = "ai"
+	summarySourceFallback = "fallback"
+)
+
 // GetStockSummary generates AI-powered natural language summary of stock recommendations
 // @Summary Get AI-generated market summary
-// @Description Uses gpt-4.1-nano to analyze current stock recommendations and generate a comprehensive natural language summary of market trends, top picks, and investment insights.
+// @Description Uses the configured OpenAI model (OPENAI_MODEL, default gpt-4.1-nano) to analyze current stock recommendations and generate a comprehensive natural language summary of market trends, top picks, and investment insights. When the AI call fails (OpenAI down or OPENAI_API_KEY missing) or ai=false is passed, a templated summary of the top picks is returned instead with source=fallback and tokens_used=0. Summaries are cached for SUMMARY_CACHE_TTL (default 5m) until new stock data is stored; cached responses have from_cache=true and cached_at, and don't count against the AI rate limit.
 // @Tags ai-analysis
 // @Produce json
+// @Param ai query bool false "Set to false to skip OpenAI and get the templated summary" default(true)
 // @Success 200 {object} SummaryResponse "Successfully generated AI market summary"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid ai parameter"
+// @Failure 429 {object} models.GenericErrorResponse "AI rate limit exceeded (see Retry-After) or OpenAI still rate limiting after retries"
 // @Failure 500 {object} models.GenericErrorResponse "Internal server error or OpenAI API error"
 // @Router /stocks/summary [get]
 func (h *StockHandler) GetStockSummary(c *gin.Context) {
+	useAI, err := strconv.ParseBool(c.DefaultQuery("ai", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ai must be true or false"})
+		return
+	}
+	if !useAI {
+		c.JSON(http.StatusOK, fallbackSummaryResponse(h.getRecommendationsForSummary()))
+		return
+	}
+
+	// Recommendations only change when data is ingested, so a cached summary is still accurate
+	if cached, age, ok := h.summaryCache.Get(); ok {
+		response := cached.(SummaryResponse)
+		response.FromCache = true
+		response.CachedAt = time.Now().Add(-age).Format(time.RFC3339)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Protect the OpenAI quota from bursts
+	if !h.allowAIRequest(c) {
+		return
+	}
+
 	// Get current recommendations
 	recommendations := h.getRecommendationsForSummary()
 	if len(recommendations) == 0 {
-		c.JSON(http.StatusOK, SummaryResponse{
-			Summary:     "No stock recommendations available at this time. Please ensure the database contains stock ratings data.",
-			GeneratedAt: time.Now().Format(time.RFC3339),
-			TokensUsed:  0,
-		})
+		c.JSON(http.StatusOK, fallbackSummaryResponse(recommendations))
 		return
 	}
 
-	// Generate AI summary
-	summary, tokensUsed, err := h.generateAISummary(recommendations)
+	// Generate AI summary, a templated summary beats an error when OpenAI is down or not configured
+	summary, tokensUsed, err := h.generateAISummary(c.Request.Context(), recommendations)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate AI summary: %v", err)})
+		h.requestLogger(c).Warn("ai summary failed, using fallback summary", "error", err)
+		c.JSON(http.StatusOK, fallbackSummaryResponse(recommendations))
 		return
 	}
 
-	c.JSON(http.StatusOK, SummaryResponse{
+	response := SummaryResponse{
 		Summary:     summary,
 		GeneratedAt: time.Now().Format(time.RFC3339),
 		TokensUsed:  tokensUsed,
-	})
+		Source:      summarySourceAI,
+	}
+	h.summaryCache.Set(response)
+	c.JSON(http.StatusOK, response)
+}
+
+// fallbackSummaryResponse builds a deterministic summary without AI: the top 3 picks,
+// how many are strong buys and the average target change.
+func fallbackSummaryResponse(recommendations []StockRecommendation) SummaryResponse {
+	response := SummaryResponse{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		TokensUsed:  0,
+		Source:      summarySourceFallback,
+	}
+	if len(recommendations) == 0 {
+		response.Summary = "No stock recommendations available at this time. Please ensure the database contains stock ratings data."
+		return response
+	}
+
+	var topPicks []string
+	strongBuys := 0
+	totalPriceChange := 0.0
+	for i, rec := range recommendations {
+		if i < 3 {
+			topPicks = append(topPicks, fmt.Sprintf("%s (%s, score %.1f, target %s)", rec.Ticker, rec.Recommendation, rec.Score, rec.TargetPrice))
+		}
+		if rec.Recommendation == "Strong Buy" {
+			strongBuys++
+		}
+		totalPriceChange += rec.PriceChange
+	}
+
+	var summary strings.Builder
+	summary.WriteString("Top picks: " + strings.Join(topPicks, ", ") + ". ")
+	summary.WriteString(fmt.Sprintf("%d of %d recommended stocks are rated Strong Buy. ", strongBuys, len(recommendations)))
+	summary.WriteString(fmt.Sprintf("Average target change: %+.1f%%.", totalPriceChange/float64(len(recommendations))))
+	response.Summary = summary.String()
+	return response
 }
 
 // getRecommendationsForSummary gets top recommendations for AI analysis
@@ -1225,7 +3261,7 @@ func (h *StockHandler) getRecommendationsForSummary() []StockRecommendation {
 	// Query to get recent stock data for analysis
 	query := `
 		SELECT ticker, company, action, brokerage, rating_from, rating_to, 
-		       target_from, target_to, time, created_at
+		       target_from, target_to, time, created_at, COALESCE(sector, '')
 		FROM stock_ratings 
 		WHERE ticker IS NOT NULL AND company IS NOT NULL
 		ORDER BY time DESC
@@ -1242,90 +3278,44 @@ func (h *StockHandler) getRecommendationsForSummary() []StockRecommendation {
 	var stocks []stockData
 	for rows.Next() {
 		var stock stockData
+		var reportTime sql.NullTime
 		var createdAt time.Time // Scan but don't use for analysis
 		err := rows.Scan(&stock.Ticker, &stock.Company, &stock.Action, &stock.Brokerage,
 			&stock.RatingFrom, &stock.RatingTo, &stock.TargetFrom, &stock.TargetTo,
-			&stock.Time, &createdAt)
+			&reportTime, &createdAt, &stock.Sector)
 		if err != nil {
 			continue
 		}
+		stock.Time = reportTime.Time
 		stocks = append(stocks, stock)
 	}
 
-	return analyzeStocksForRecommendations(stocks, 10) // Default limit for summary
+	return analyzeStocksForRecommendations(stocks, 10, h.scoringWeights.Get(), defaultMinScore, h.now()) // Default limit, weights and threshold for summary
 }
 
-// generateAISummary calls OpenAI gpt-4.1-nano to generate market summary
-func (h *StockHandler) generateAISummary(recommendations []StockRecommendation) (string, int, error) {
-	// Prepare data for AI analysis
-	prompt := h.buildSummaryPrompt(recommendations)
-
-	// OpenAI API request
-	reqBody := map[string]interface{}{
-		"model": "gpt-4.1-nano",
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": "You are a Wall Street equity research analyst. Analyze the stock data and provide a brief market summary focusing on: 1) Top Rating Actions - highlight stocks upgraded/initiated with Buy/Outperform ratings, 2) Target Price Increases - emphasize significant target hikes with high upside potential, 3) Reinforced Confidence - note reiterated Buy/Outperform ratings showing continued analyst confidence, 4) Negative Signals - briefly flag target cuts or underweight ratings, 5) Brokerage Reputation - mention reputable firms backing stocks. Format: Brief sentences with specific stock examples and price targets. Keep under 150 words, focus on actionable insights.",
-			},
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"max_tokens":  200,
-		"temperature": 0.7,
-	}
-
-	// Marshal request body to JSON
-	reqJSON, _ := json.Marshal(reqBody)
-
-	// Make API request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", strings.NewReader(string(reqJSON)))
-	if err != nil {
-		return "", 0, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
-
-	// make HTTP request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", 0, err
-	}
-	defer resp.Body.Close()
-
-	// Parse response
-	var openAIResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Usage struct {
-			TotalTokens int `json:"total_tokens"`
-		} `json:"usage"`
-		Error struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-
-	// Decode response body
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
+// generateAISummary calls the configured OpenAI model to generate market summary
+func (h *StockHandler) generateAISummary(ctx context.Context, recommendations []StockRecommendation) (string, int, error) {
+	if err := h.checkAIConfigured(); err != nil {
 		return "", 0, err
 	}
 
-	if openAIResp.Error.Message != "" {
-		return "", 0, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
-	}
+	// Prepare data for AI analysis
+	prompt := h.buildSummaryPrompt(recommendations)
 
-	if len(openAIResp.Choices) == 0 {
-		return "", 0, fmt.Errorf("no response from OpenAI")
+	// OpenAI API request
+	messages := []OpenAIMessage{
+		{Role: "system", Content: "You are a Wall Street equity research analyst. Analyze the stock data and provide a brief market summary focusing on: 1) Top Rating Actions - highlight stocks upgraded/initiated with Buy/Outperform ratings, 2) Target Price Increases - emphasize significant target hikes with high upside potential, 3) Reinforced Confidence - note reiterated Buy/Outperform ratings showing continued analyst confidence, 4) Negative Signals - briefly flag target cuts or underweight ratings, 5) Brokerage Reputation - mention reputable firms backing stocks. Format: Brief sentences with specific stock examples and price targets. Keep under 150 words, focus on actionable insights."},
+		{Role: "user", Content: prompt},
 	}
 
-	return openAIResp.Choices[0].Message.Content, openAIResp.Usage.TotalTokens, nil
+	summary, tokens, err := h.openAI.ChatCompletion(ctx, ChatCompletionRequest{
+		Model:       h.openAIModel,
+		Messages:    messages,
+		MaxTokens:   200,
+		Temperature: 0.7,
+	})
+	h.aiUsage.Record(aiUsageSummary, tokens)
+	return summary, tokens, err
 }
 
 // buildSummaryPrompt creates the prompt for AI analysis
@@ -1337,6 +3327,9 @@ func (h *StockHandler) buildSummaryPrompt(recommendations []StockRecommendation)
 	// Build focused prompt for key insights
 	prompt := "ANALYST ACTIONS SUMMARY - Provide brief market insights with specific examples:\n\n"
 
+	// Computed aggregates so the model doesn't have to estimate percentages or sector shares
+	prompt += summaryAggregates(recommendations) + "\n"
+
 	// Include top recommendations with key details
 	for i, rec := range recommendations {
 		if i >= 8 { // Focus on top 8 for concise analysis
@@ -1350,6 +3343,60 @@ func (h *StockHandler) buildSummaryPrompt(recommendations []StockRecommendation)
 	return prompt
 }
 
+// summaryAggregates computes the aggregate lines of the summary prompt for all recommendations:
+// bullish/bearish counts, strong buys, average score and price change, and counts per sector when known.
+func summaryAggregates(recommendations []StockRecommendation) string {
+	bullish, bearish, strongBuys := 0, 0, 0
+	totalScore, totalPriceChange := 0.0, 0.0
+	sectorCounts := make(map[string]int)
+	hasSectors := false
+	for _, rec := range recommendations {
+		if isBuyRating(rec.CurrentRating) || isStrongBuyRating(rec.CurrentRating) {
+			bullish++
+		} else if isBearishRating(rec.CurrentRating) {
+			bearish++
+		}
+		if rec.Recommendation == "Strong Buy" {
+			strongBuys++
+		}
+		totalScore += rec.Score
+		totalPriceChange += rec.PriceChange
+
+		sector := rec.Sector
+		if sector == "" {
+			sector = "Unknown"
+		} else {
+			hasSectors = true
+		}
+		sectorCounts[sector]++
+	}
+
+	count := float64(len(recommendations))
+	aggregates := fmt.Sprintf("AGGREGATES (%d recommendations, use these numbers instead of estimating):\n", len(recommendations))
+	aggregates += fmt.Sprintf("Bullish ratings: %d | Bearish ratings: %d | Strong Buys: %d | Average score: %.2f | Average price change: %.1f%%\n",
+		bullish, bearish, strongBuys, totalScore/count, totalPriceChange/count)
+
+	if hasSectors {
+		sectors := make([]string, 0, len(sectorCounts))
+		for sector := range sectorCounts {
+			sectors = append(sectors, sector)
+		}
+		// Largest sectors first, ties by name so the prompt is stable
+		sort.Slice(sectors, func(i, j int) bool {
+			if sectorCounts[sectors[i]] != sectorCounts[sectors[j]] {
+				return sectorCounts[sectors[i]] > sectorCounts[sectors[j]]
+			}
+			return sectors[i] < sectors[j]
+		})
+		parts := make([]string, len(sectors))
+		for i, sector := range sectors {
+			parts[i] = fmt.Sprintf("%s %d", sector, sectorCounts[sector])
+		}
+		aggregates += "Recommendations per sector: " + strings.Join(parts, ", ") + "\n"
+	}
+	return aggregates
+}
+
 // ChatResponse represents an AI chat response
 type ChatResponse struct {
 	Response       string               `json:"response" example:"Based on current market data, I recommend focusing on stocks with strong buy ratings and recent target price increases. The biotech sector shows particular promise."`
@@ -1357,13 +3404,20 @@ type ChatResponse struct {
 	GeneratedAt    string               `json:"generated_at" example:"2024-01-15T10:30:00Z"`
 	ContextUsed    string               `json:"context_used,omitempty"`
 	UpdatedMemory  *ConversationMemory  `json:"updated_memory,omitempty"`
+	SessionID      string               `json:"session_id,omitempty" example:"3f2b9c1e-chat"`
+	// Only set with ?debug=true: the AI-generated SQL (empty when cached context was reused) and the rows it returned
+	GeneratedSQL   *string              `json:"generated_sql,omitempty" example:"SELECT ticker, rating_to FROM stock_ratings WHERE ticker = 'AAPL' LIMIT 20"`
+	RowCount       *int                 `json:"row_count,omitempty" example:"12"`
 }
 
-// ChatRequest represents a chat request with optional conversation memory
+// ChatRequest represents a chat request with optional conversation memory.
+// When SessionID is set the memory is kept server-side and ConversationMemory is ignored.
 type ChatRequest struct {
-	Message            string                 `json:"message" example:"What are the best stocks to invest in today?"`
+	Message            string                 `json:"message" binding:"required" example:"What are the best stocks to invest in today?"`
 	ConversationMemory *ConversationMemory    `json:"conversation_memory,omitempty"`
 	RecentMessages     []RecentMessage        `json:"recent_messages,omitempty"`
+	Model              string                 `json:"model,omitempty" example:"gpt-4.1-mini"`
+	SessionID          string                 `json:"session_id,omitempty" example:"3f2b9c1e-chat"`
 }
 
 // ConversationMemory holds compressed conversation history and key topics
@@ -1381,51 +3435,211 @@ type RecentMessage struct {
 
 // GetStockChat provides AI-powered chat responses with RAG (Retrieval-Augmented Generation)
 // @Summary Chat with AI about stock market with database context
-// @Description Interactive chat with gpt-4.1-nano that can query the database for specific stock information and provide personalized analysis based on actual data.
+// @Description Interactive chat with the configured OpenAI model (OPENAI_MODEL, default gpt-4.1-nano) that can query the database for specific stock information and provide personalized analysis based on actual data. The optional model field picks another allowed model for the answer: gpt-4.1-nano, gpt-4.1-mini, gpt-4.1, gpt-4o-mini or gpt-4o. With session_id the conversation memory is stored server-side (expiring after CHAT_SESSION_TTL) and any client-supplied conversation_memory is ignored. The message may be at most CHAT_MAX_MESSAGE_LENGTH characters (default 2000) and only the last 10 recent_messages are used.
 // @Tags ai-analysis
 // @Accept json
 // @Produce json
 // @Param request body ChatRequest true "Chat message from user"
+// @Param debug query bool false "Include the AI-generated SQL and its row count in the response" default(false)
 // @Success 200 {object} ChatResponse "Successfully generated AI chat response with database context"
-// @Failure 400 {object} models.ErrorResponse "Bad request - missing message"
+// @Failure 400 {object} models.ErrorResponse "Bad request - missing or too long message, invalid model or invalid debug parameter"
+// @Failure 429 {object} models.GenericErrorResponse "AI rate limit exceeded (see Retry-After) or OpenAI still rate limiting after retries"
 // @Failure 500 {object} models.GenericErrorResponse "Internal server error or OpenAI API error"
+// @Failure 504 {object} models.GenericErrorResponse "AI-generated database query timed out (RAG_SQL_TIMEOUT_MS)"
+// @Failure 503 {object} models.GenericErrorResponse "AI features unavailable: OPENAI_API_KEY not configured"
 // @Router /stocks/chat [post]
 func (h *StockHandler) GetStockChat(c *gin.Context) {
-	// Parse request body
-	var req ChatRequest
-
-	// Validate input and decode JSON
-	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+	// Debug output exposes schema details, so it is opt-in per request
+	debug, err := strconv.ParseBool(c.DefaultQuery("debug", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "debug must be true or false"})
 		return
 	}
 
-	if req.Message == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Message is required"})
+	turn, ok := h.prepareChatTurn(c)
+	if !ok {
 		return
 	}
 
-	// Enhanced RAG with conversation memory
-	dbContext, err := h.retrieveRelevantDataWithMemory(req.Message, req.ConversationMemory)
+	// Generate AI response with conversation context
+	response, tokensUsed, updatedMemory, err := h.generateChatResponseWithMemory(c.Request.Context(), turn.model, turn.req.Message, turn.dbContext, turn.req.RecentMessages, turn.memory)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retrieve data: %v", err)})
+		respondAIError(c, "Failed to generate response", err)
 		return
 	}
 
-	// Generate AI response with conversation context
-	response, tokensUsed, updatedMemory, err := h.generateChatResponseWithMemory(req.Message, dbContext, req.RecentMessages, req.ConversationMemory)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate response: %v", err)})
-		return
+	if turn.req.SessionID != "" {
+		h.chatSessions.Set(turn.req.SessionID, updatedMemory)
 	}
 
-	c.JSON(http.StatusOK, ChatResponse{
+	chatResponse := ChatResponse{
 		Response:      response,
 		TokensUsed:    tokensUsed,
 		GeneratedAt:   time.Now().Format(time.RFC3339),
-		ContextUsed:   dbContext,
+		ContextUsed:   turn.dbContext,
 		UpdatedMemory: updatedMemory,
+		SessionID:     turn.req.SessionID,
+	}
+	if debug {
+		chatResponse.GeneratedSQL = &turn.generatedSQL
+		chatResponse.RowCount = &turn.rowCount
+	}
+	c.JSON(http.StatusOK, chatResponse)
+}
+
+// GetStockChatStream streams the AI chat answer as Server-Sent Events
+// @Summary Chat with AI about stock market, streaming the answer
+// @Description Same request and RAG pipeline as /stocks/chat, but the answer is relayed as text/event-stream while OpenAI generates it. Each "delta" event carries {"content": "..."}; a final "done" event carries tokens_used, generated_at, updated_memory and session_id. Errors after streaming started arrive as an "error" event; earlier errors use the regular JSON error responses.
+// @Tags ai-analysis
+// @Accept json
+// @Produce text/event-stream
+// @Param request body ChatRequest true "Chat message from user"
+// @Success 200 {string} string "Stream of delta events followed by a done event"
+// @Failure 400 {object} models.ErrorResponse "Bad request - missing or too long message or invalid model"
+// @Failure 429 {object} models.GenericErrorResponse "AI rate limit exceeded (see Retry-After) or OpenAI still rate limiting after retries"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error or OpenAI API error"
+// @Failure 504 {object} models.GenericErrorResponse "AI-generated database query timed out (RAG_SQL_TIMEOUT_MS)"
+// @Failure 503 {object} models.GenericErrorResponse "AI features unavailable: OPENAI_API_KEY not configured"
+// @Router /stocks/chat/stream [post]
+func (h *StockHandler) GetStockChatStream(c *gin.Context) {
+	turn, ok := h.prepareChatTurn(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.checkAIConfigured(); err != nil {
+		respondAIError(c, "Failed to generate response", err)
+		return
+	}
+	conversationContext := h.buildConversationContext(turn.req.RecentMessages, turn.memory)
+	request := chatCompletionRequest(turn.model, turn.req.Message, turn.dbContext, conversationContext)
+
+	// Headers are only sent with the first delta, so failures before that still get a JSON error
+	started := false
+	response, tokensUsed, err := h.openAI.ChatCompletionStream(ctx, request, func(delta string) error {
+		// Stop reading from OpenAI once the client has gone away
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !started {
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			started = true
+		}
+		c.SSEvent("delta", gin.H{"content": delta})
+		c.Writer.Flush()
+		return nil
+	})
+	h.aiUsage.Record(aiUsageChat, tokensUsed)
+	if ctx.Err() != nil {
+		h.requestLogger(c).Debug("chat stream client disconnected")
+		return
+	}
+	if err != nil {
+		if !started {
+			respondAIError(c, "Failed to generate response", err)
+			return
+		}
+		c.SSEvent("error", gin.H{"error": fmt.Sprintf("Failed to generate response: %v", err)})
+		c.Writer.Flush()
+		return
+	}
+
+	updatedMemory := h.updateConversationMemory(turn.req.Message, response, turn.dbContext, turn.memory)
+	if turn.req.SessionID != "" {
+		h.chatSessions.Set(turn.req.SessionID, updatedMemory)
+	}
+
+	c.SSEvent("done", gin.H{
+		"tokens_used":    tokensUsed,
+		"generated_at":   time.Now().Format(time.RFC3339),
+		"updated_memory": updatedMemory,
+		"session_id":     turn.req.SessionID,
 	})
+	c.Writer.Flush()
+}
+
+// chatTurn holds a validated chat request together with the memory and database context it will use.
+type chatTurn struct {
+	req          ChatRequest
+	model        string
+	memory       *ConversationMemory
+	dbContext    string
+	generatedSQL string // SQL the RAG step ran, empty when the cached context was reused
+	rowCount     int    // Rows returned by generatedSQL
+}
+
+// prepareChatTurn runs the steps shared by the chat endpoints: rate limiting, request validation,
+// session memory lookup and RAG retrieval. On failure it writes the error response and returns false.
+func (h *StockHandler) prepareChatTurn(c *gin.Context) (*chatTurn, bool) {
+	// Protect the OpenAI quota from bursts, this also covers the RAG SQL generation call
+	if !h.allowAIRequest(c) {
+		return nil, false
+	}
+
+	// Parse request body
+	var req ChatRequest
+
+	// Validate input and decode JSON, binding rejects an empty message
+	if !bindJSON(c, &req) {
+		return nil, false
+	}
+
+	// Long messages inflate both the SQL generation and the answer prompt
+	if utf8.RuneCountInString(req.Message) > h.chatMaxMessage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("message must be at most %d characters", h.chatMaxMessage)})
+		return nil, false
+	}
+
+	// Only the latest recent messages go into the prompt, whatever the client sends
+	if len(req.RecentMessages) > maxRecentMessages {
+		req.RecentMessages = req.RecentMessages[len(req.RecentMessages)-maxRecentMessages:]
+	}
+
+	// Optional per-request model override, restricted to known chat models
+	model := h.openAIModel
+	if req.Model != "" {
+		if !h.isAllowedChatModel(req.Model) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid model. Must be one of: %s", strings.Join(allowedChatModels, ", ")),
+			})
+			return nil, false
+		}
+		model = req.Model
+	}
+
+	// With a session ID the server-side memory is authoritative, client-supplied memory is only a fallback
+	memory := req.ConversationMemory
+	if req.SessionID != "" {
+		if len(req.SessionID) > maxSessionIDLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("session_id must be at most %d characters", maxSessionIDLength)})
+			return nil, false
+		}
+		memory, _ = h.chatSessions.Get(req.SessionID)
+	}
+
+	// Enhanced RAG with conversation memory
+	rag, err := h.retrieveRelevantDataWithMemory(c.Request.Context(), req.Message, memory)
+	if errors.Is(err, errRAGQueryTimeout) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{
+			"error": fmt.Sprintf("The database query for this question timed out after %s. Try asking something more specific.", h.ragSQLTimeout),
+		})
+		return nil, false
+	}
+	if err != nil {
+		respondAIError(c, "Failed to retrieve data", err)
+		return nil, false
+	}
+
+	return &chatTurn{
+		req:          req,
+		model:        model,
+		memory:       memory,
+		dbContext:    rag.Context,
+		generatedSQL: rag.SQL,
+		rowCount:     rag.RowCount,
+	}, true
 }
 
 // generateChatResponseWithMemory implements memory-enhanced AI response generation
@@ -1456,24 +3670,24 @@ func (h *StockHandler) GetStockChat(c *gin.Context) {
 // Traditional: Full conversation (1000+ tokens)
 // Memory approach: Summary + recent (200-300 tokens)
 // Efficiency gain: 70-80% token reduction
-func (h *StockHandler) generateChatResponseWithMemory(userMessage, context string, recentMessages []RecentMessage, memory *ConversationMemory) (string, int, *ConversationMemory, error) {
+func (h *StockHandler) generateChatResponseWithMemory(ctx context.Context, model, userMessage, context string, recentMessages []RecentMessage, memory *ConversationMemory) (string, int, *ConversationMemory, error) {
 	// STEP 1: BUILD LIGHTWEIGHT CONVERSATION CONTEXT
 	// Create compressed context from memory + recent messages (not full history)
 	conversationContext := h.buildConversationContext(recentMessages, memory)
-	println("💬 Memory: Built conversation context, length:", len(conversationContext), "chars")
+	h.logger.Debug("built conversation context", "length", len(conversationContext))
 
 	// STEP 2: GENERATE AI RESPONSE WITH ENHANCED CONTEXT
 	// Send user question + database context + conversation context to AI
-	response, tokens, err := h.generateChatResponse(userMessage, context, conversationContext)
+	response, tokens, err := h.generateChatResponse(ctx, model, userMessage, context, conversationContext)
 	if err != nil {
 		return "", 0, nil, err
 	}
-	println("✅ Memory: AI response generated, tokens used:", tokens)
+	h.logger.Debug("chat response generated", "tokens", tokens)
 
 	// STEP 3: UPDATE CONVERSATION MEMORY
 	// Extract topics, update summary, cache context for future reuse
 	updatedMemory := h.updateConversationMemory(userMessage, response, context, memory)
-	println("💾 Memory: Updated memory with topics:", updatedMemory.KeyTopics)
+	h.logger.Debug("conversation memory updated", "topics", updatedMemory.KeyTopics)
 
 	return response, tokens, updatedMemory, nil
 }
@@ -1530,7 +3744,7 @@ func (h *StockHandler) updateConversationMemory(userMessage, response, dbContext
 	// STEP 1: EXTRACT KEY TOPICS FROM USER MESSAGE
 	// Identify tickers, semantic topics, and action types for future context matching
 	topics := h.extractKeyTopics(userMessage)
-	println("🏷️ Memory: Extracted topics from message:", topics)
+	h.logger.Debug("extracted message topics", "topics", topics)
 
 	// STEP 2: BUILD UPDATED MEMORY STRUCTURE
 	// Merge topics, update summary, cache context for reuse
@@ -1540,15 +3754,36 @@ func (h *StockHandler) updateConversationMemory(userMessage, response, dbContext
 		LastContext: dbContext, // Cache for potential reuse
 	}
 
-	println("📊 Memory: Updated summary:", updatedMemory.Summary[:min(50, len(updatedMemory.Summary))])
+	h.logger.Debug("conversation summary updated", "summary", truncateRunes(updatedMemory.Summary, 50))
 	return updatedMemory
 }
 
-// extractTickers finds ticker symbols in user message using pattern matching
+// tickerStopwords are common English and finance words that match the ticker pattern but are not tickers.
+// Extend this set when new false positives show up in conversation memory.
+var tickerStopwords = map[string]bool{
+	"A": true, "AN": true, "THE": true, "AND": true, "OR": true, "BUT": true, "NOT": true, "NO": true,
+	"IS": true, "ARE": true, "WAS": true, "BE": true, "BEEN": true, "DO": true, "DOES": true, "DID": true,
+	"HAS": true, "HAVE": true, "HAD": true, "CAN": true, "WILL": true, "WOULD": true, "COULD": true, "SHOULD": true,
+	"I": true, "ME": true, "MY": true, "WE": true, "US": true, "OUR": true, "YOU": true, "YOUR": true,
+	"IT": true, "ITS": true, "THEY": true, "THEM": true, "THEIR": true, "HE": true, "SHE": true, "HIS": true, "HER": true,
+	"WHAT": true, "WHICH": true, "WHO": true, "WHY": true, "HOW": true, "WHEN": true, "WHERE": true,
+	"THIS": true, "THAT": true, "THESE": true, "THOSE": true, "THERE": true, "HERE": true,
+	"TO": true, "OF": true, "IN": true, "ON": true, "AT": true, "BY": true, "FOR": true, "FROM": true,
+	"WITH": true, "ABOUT": true, "INTO": true, "OVER": true, "AS": true, "IF": true, "SO": true, "THAN": true,
+	"ALL": true, "ANY": true, "SOME": true, "MOST": true, "MORE": true, "LESS": true, "BEST": true, "TOP": true,
+	"SHOW": true, "TELL": true, "GIVE": true, "LIST": true, "FIND": true, "GET": true, "SEE": true,
+	"VS": true, "NEW": true, "LAST": true, "NEXT": true, "WEEK": true, "MONTH": true, "YEAR": true, "TODAY": true,
+	"BUY": true, "SELL": true, "HOLD": true, "STOCK": true, "PRICE": true, "RATED": true,
+}
+
+// extractTickers finds ticker symbols in user message using pattern matching, skipping tickerStopwords
 func (h *StockHandler) extractTickers(message string) []string {
 	words := strings.Fields(strings.ToUpper(message))
 	var tickers []string
 	for _, word := range words {
+		if tickerStopwords[word] {
+			continue
+		}
 		if len(word) >= 2 && len(word) <= 5 {
 			isValidTicker := true
 			for _, char := range word {
@@ -1602,26 +3837,28 @@ func (h *StockHandler) extractKeyTopics(message string) []string {
 	tickers := h.extractTickers(message)
 	topics = append(topics, tickers...)
 	if len(tickers) > 0 {
-		println("🏷️ Topics: Found tickers:", tickers)
+		h.logger.Debug("found tickers", "tickers", tickers)
 	}
 
 	// CATEGORY 2: SEMANTIC TOPIC EXTRACTION
 	// Identify market themes and concepts for thematic context matching
+	// Collected separately so logging never depends on slice positions
+	var semanticTopics []string
 	if strings.Contains(message, "target") || strings.Contains(message, "price") {
-		topics = append(topics, "target_prices")
+		semanticTopics = append(semanticTopics, "target_prices")
 	}
 	if strings.Contains(message, "rating") || strings.Contains(message, "upgrade") || strings.Contains(message, "downgrade") {
-		topics = append(topics, "ratings")
+		semanticTopics = append(semanticTopics, "ratings")
 	}
 	if strings.Contains(message, "sector") || strings.Contains(message, "industry") {
-		topics = append(topics, "sectors")
+		semanticTopics = append(semanticTopics, "sectors")
 	}
 	if strings.Contains(message, "raised") || strings.Contains(message, "lowered") || strings.Contains(message, "initiated") {
-		topics = append(topics, "analyst_actions")
+		semanticTopics = append(semanticTopics, "analyst_actions")
 	}
 
-	println("📊 Topics: Extracted semantic topics:", topics[len(tickers):])
-	return topics
+	h.logger.Debug("extracted semantic topics", "topics", semanticTopics)
+	return append(topics, semanticTopics...)
 }
 
 // mergeTopics combines current and new topics
@@ -1651,85 +3888,50 @@ func (h *StockHandler) mergeTopics(current, new []string) []string {
 func (h *StockHandler) generateConversationSummary(userMessage, response, currentSummary string) string {
 	// Simple summary logic - in production, could use AI for this
 	if currentSummary == "" {
-		return fmt.Sprintf("User asked about: %s", userMessage[:min(50, len(userMessage))])
-	}
-	return fmt.Sprintf("%s; Latest: %s", currentSummary[:min(100, len(currentSummary))], userMessage[:min(30, len(userMessage))])
-}
-
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+		return fmt.Sprintf("User asked about: %s", truncateRunes(userMessage, 50))
 	}
-	return b
+	return fmt.Sprintf("%s; Latest: %s", truncateRunes(currentSummary, 100), truncateRunes(userMessage, 30))
 }
 
-// generateChatResponse calls OpenAI for chat responses
-func (h *StockHandler) generateChatResponse(userMessage, context, conversationContext string) (string, int, error) {
-	reqBody := map[string]interface{}{
-		"model": "gpt-4.1-nano",
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": "You are a professional financial advisor with access to real-time stock market database. Use the provided database context to answer questions accurately. When users ask about specific stocks, sectors, or market trends, reference the actual data provided. If asked about stocks not in the context, clearly state data limitations. Keep responses helpful and actionable.\n\nFORMATTING RULES:\n- Use markdown formatting for better readability\n- Use numbered lists (1. 2. 3.) for multiple items\n- Use **bold** for company names and tickers\n- Use bullet points (-) for sub-items\n- Keep responses concise but complete\n\nConversation Context:\n" + conversationContext + "\n\nDatabase Context:\n" + context,
-			},
-			{
-				"role":    "user",
-				"content": userMessage,
-			},
-		},
-		"max_tokens":   500,
-		"temperature": 0.7,
-	}
-
-	// Marshal request body to JSON
-	reqJSON, _ := json.Marshal(reqBody)
-
-	// configure API request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", strings.NewReader(string(reqJSON)))
-	if err != nil {
-		return "", 0, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
-
-	// make HTTP request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", 0, err
-	}
-	defer resp.Body.Close()
-
-	// Parse response
-	var openAIResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Usage struct {
-			TotalTokens int `json:"total_tokens"`
-		} `json:"usage"`
-		Error struct {
-			Message string `json:"message"`
-		} `json:"error"`
+// truncateRunes shortens s to at most n characters without splitting a multibyte UTF-8 rune
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
 	}
+	return string(runes[:n])
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
+// generateChatResponse calls OpenAI for chat responses using the given model
+func (h *StockHandler) generateChatResponse(ctx context.Context, model, userMessage, context, conversationContext string) (string, int, error) {
+	if err := h.checkAIConfigured(); err != nil {
 		return "", 0, err
 	}
+	response, tokens, err := h.openAI.ChatCompletion(ctx, chatCompletionRequest(model, userMessage, context, conversationContext))
+	h.aiUsage.Record(aiUsageChat, tokens)
+	return response, tokens, err
+}
 
-	if openAIResp.Error.Message != "" {
-		return "", 0, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
+// chatCompletionRequest builds the OpenAI request answering userMessage with the database and conversation context
+func chatCompletionRequest(model, userMessage, context, conversationContext string) ChatCompletionRequest {
+	messages := []OpenAIMessage{
+		{Role: "system", Content: "You are a professional financial advisor with access to real-time stock market database. Use the provided database context to answer questions accurately. When users ask about specific stocks, sectors, or market trends, reference the actual data provided. If asked about stocks not in the context, clearly state data limitations. Keep responses helpful and actionable.\n\nFORMATTING RULES:\n- Use markdown formatting for better readability\n- Use numbered lists (1. 2. 3.) for multiple items\n- Use **bold** for company names and tickers\n- Use bullet points (-) for sub-items\n- Keep responses concise but complete\n\nConversation Context:\n" + conversationContext + "\n\nDatabase Context:\n" + context},
+		{Role: "user", Content: userMessage},
 	}
 
-	if len(openAIResp.Choices) == 0 {
-		return "", 0, fmt.Errorf("no response from OpenAI")
+	return ChatCompletionRequest{
+		Model:       model,
+		Messages:    messages,
+		MaxTokens:   500,
+		Temperature: 0.7,
 	}
+}
 
-	return openAIResp.Choices[0].Message.Content, openAIResp.Usage.TotalTokens, nil
+// ragResult is the database context retrieved for a chat message and how it was obtained
+type ragResult struct {
+	Context  string // Formatted query results passed to the chat model
+	SQL      string // AI-generated SQL that produced Context, empty when cached context was reused
+	RowCount int    // Rows returned by SQL
 }
 
 // retrieveRelevantDataWithMemory implements RAG with intelligent conversation memory
@@ -1773,19 +3975,18 @@ func (h *StockHandler) generateChatResponse(userMessage, context, conversationCo
 // Traditional approach: Send full conversation (1000+ tokens per request)
 // Memory approach: Send only new question + cached context (100-200 tokens)
 // Savings: 80-90% reduction in API costs for follow-up questions
-func (h *StockHandler) retrieveRelevantDataWithMemory(userMessage string, memory *ConversationMemory) (string, error) {
+func (h *StockHandler) retrieveRelevantDataWithMemory(ctx context.Context, userMessage string, memory *ConversationMemory) (ragResult, error) {
 	// STEP 1: SMART CONTEXT REUSE CHECK
 	// Analyze if current query relates to previous topics to avoid redundant database queries
 	if memory != nil && memory.LastContext != "" && h.isSimilarQuery(userMessage, memory.KeyTopics) {
-		println("🧠 Memory: Reusing cached context for similar query")
-		println("💾 Memory: Topics matched:", memory.KeyTopics)
-		return memory.LastContext, nil // COST SAVINGS: No new SQL generation needed
+		h.logger.Debug("reusing cached context", "topics", memory.KeyTopics)
+		return ragResult{Context: memory.LastContext}, nil // COST SAVINGS: No new SQL generation needed
 	}
 
 	// STEP 2: FRESH CONTEXT GENERATION
 	// Generate new database context for different/new topics
-	println("🆕 Memory: Generating fresh context for new topic")
-	return h.retrieveRelevantData(userMessage)
+	h.logger.Debug("generating fresh context")
+	return h.retrieveRelevantData(ctx, userMessage)
 }
 
 // isSimilarQuery checks if current query is similar to previous topics
@@ -1824,34 +4025,45 @@ func (h *StockHandler) isSimilarQuery(query string, topics []string) bool {
 // ✅ Dynamic SQL generation
 // ✅ Flexible and extensible
 // ✅ Maintains SQL injection protection
-func (h *StockHandler) retrieveRelevantData(userMessage string) (string, error) {
+func (h *StockHandler) retrieveRelevantData(ctx context.Context, userMessage string) (ragResult, error) {
 	// STEP 1: Generate SQL query using AI based on user question
-	println("🤖 RAG: Generating SQL for question:", userMessage)
-	sqlQuery, err := h.generateSQLFromQuestion(userMessage)
+	h.logger.Debug("rag generating sql", "question", userMessage)
+	sqlQuery, err := h.generateSQLFromQuestion(ctx, userMessage)
 	if err != nil {
-		println("❌ RAG: Failed to generate SQL:", err.Error())
-		return "", fmt.Errorf("failed to generate SQL: %v", err)
+		h.logger.Warn("rag sql generation failed", "error", err)
+		return ragResult{}, fmt.Errorf("failed to generate SQL: %w", err)
 	}
-	println("📝 RAG: Generated SQL Query:")
-	println("   ", sqlQuery)
+	h.logger.Debug("rag generated sql", "sql", sqlQuery)
 
 	// STEP 2: Validate and execute the generated SQL safely
-	println("🔍 RAG: Validating and executing SQL...")
-	results, err := h.executeSafeSQL(sqlQuery)
+	results, err := h.executeSafeSQL(ctx, sqlQuery)
+	if err != nil && !errors.Is(err, errRAGQueryTimeout) && ctx.Err() == nil {
+		// One correction attempt: the model often fixes a wrong function or missing cast once it sees the error.
+		// Timeouts are not retried, a corrected query is unlikely to be faster and would double the wait.
+		h.logger.Warn("rag sql failed, asking for a correction", "sql", sqlQuery, "error", err)
+		corrected, correctErr := h.correctSQLQuery(ctx, userMessage, sqlQuery, err)
+		if correctErr != nil {
+			h.logger.Warn("rag sql correction failed", "error", correctErr)
+			return ragResult{}, fmt.Errorf("failed to execute query: %w", err)
+		}
+		h.logger.Debug("rag corrected sql", "sql", corrected)
+		sqlQuery = corrected
+		results, err = h.executeSafeSQL(ctx, sqlQuery)
+	}
 	if err != nil {
-		println("❌ RAG: Failed to execute SQL:", err.Error())
-		return "", fmt.Errorf("failed to execute query: %v", err)
+		h.logger.Warn("rag sql execution failed", "error", err)
+		return ragResult{}, fmt.Errorf("failed to execute query: %w", err)
 	}
-	println("✅ RAG: SQL executed successfully, found", len(results), "results")
+	h.logger.Debug("rag sql executed", "count", len(results))
 
 	// STEP 3: Format results as structured context
 	context := h.formatQueryResults(results, userMessage)
-	println("📊 RAG: Context formatted, length:", len(context), "characters")
-	return context, nil
+	h.logger.Debug("rag context formatted", "length", len(context))
+	return ragResult{Context: context, SQL: sqlQuery, RowCount: len(results)}, nil
 }
 
-// generateSQLFromQuestion uses AI to convert natural language to SQL
-func (h *StockHandler) generateSQLFromQuestion(question string) (string, error) {
+// sqlPromptMessages builds the conversation asking the model to turn question into a query on stock_ratings
+func sqlPromptMessages(question string) []OpenAIMessage {
 	schema := `
 	Database Schema:
 	Table: stock_ratings
@@ -1867,8 +4079,11 @@ func (h *StockHandler) generateSQLFromQuestion(question string) (string, error)
 	- rating_to (VARCHAR(50)) - New rating like 'Buy', 'Strong Buy'
 	- time (TIMESTAMP) - When analyst made the report
 	- created_at (TIMESTAMP) - When record was inserted
+	- sector (VARCHAR(100), nullable) - Industry sector like 'Technology', NULL when unknown
+	- target_from_num (NUMERIC, nullable) - target_from as a number like 150.00, NULL when not numeric
+	- target_to_num (NUMERIC, nullable) - target_to as a number like 180.00, NULL when not numeric
 	
-	IMPORTANT: Price fields contain dollar signs and commas. Use CAST(REPLACE(REPLACE(column, '$', ''), ',', '') AS NUMERIC) for calculations.
+	IMPORTANT: Price fields contain dollar signs and commas, and may be empty. Prefer target_from_num and target_to_num for calculations; otherwise use (CASE WHEN column ~ '^\$?[0-9][0-9,]*(\.[0-9]+)?$' THEN CAST(REPLACE(REPLACE(column, '$', ''), ',', '') AS NUMERIC) END) for calculations.
 	`
 
 	prompt := fmt.Sprintf(`%s
@@ -1881,211 +4096,931 @@ func (h *StockHandler) generateSQLFromQuestion(question string) (string, error)
 	3. Include relevant columns for the question
 	4. Use proper SQL syntax
 	5. Return only the SQL query, no explanations
-	6. For price calculations, use: CAST(REPLACE(REPLACE(column, '$', ''), ',', '') AS NUMERIC)
+	6. For price calculations, use target_from_num/target_to_num, or: (CASE WHEN column ~ '^\$?[0-9][0-9,]*(\.[0-9]+)?$' THEN CAST(REPLACE(REPLACE(column, '$', ''), ',', '') AS NUMERIC) END)
 	7. Price fields (target_from, target_to) may contain commas and dollar signs
 
 	SQL:`, schema, question)
 
-	println("🧠 AI: Sending prompt to OpenAI for SQL generation...")
-	println("📋 AI: Question:", question)
+	return []OpenAIMessage{
+		{Role: "system", Content: "You are a SQL expert. Generate safe PostgreSQL queries based on user questions. Only return the SQL query."},
+		{Role: "user", Content: prompt},
+	}
+}
+
+// generateSQLFromQuestion uses AI to convert natural language to SQL
+func (h *StockHandler) generateSQLFromQuestion(ctx context.Context, question string) (string, error) {
+	if err := h.checkAIConfigured(); err != nil {
+		return "", err
+	}
 
-	reqBody := map[string]interface{}{
-		"model": "gpt-4.1-nano",
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": "You are a SQL expert. Generate safe PostgreSQL queries based on user questions. Only return the SQL query.",
-			},
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"max_tokens":   200,
-		"temperature": 0.1,
+	h.logger.Debug("requesting sql from openai", "question", question)
+	return h.completeSQL(ctx, sqlPromptMessages(question))
+}
+
+// correctSQLQuery asks the model to fix a generated query, replaying the original prompt with the
+// failed query and the error it produced.
+func (h *StockHandler) correctSQLQuery(ctx context.Context, question, failedSQL string, queryErr error) (string, error) {
+	if err := h.checkAIConfigured(); err != nil {
+		return "", err
+	}
+
+	messages := append(sqlPromptMessages(question),
+		OpenAIMessage{Role: "assistant", Content: failedSQL},
+		OpenAIMessage{Role: "user", Content: fmt.Sprintf("That query failed with this error:\n%v\n\nReturn a corrected PostgreSQL query for the same question, following the same rules. Only return the SQL query.", queryErr)},
+	)
+	h.logger.Debug("requesting sql correction from openai", "question", question)
+	return h.completeSQL(ctx, messages)
+}
+
+// completeSQL sends a SQL generation conversation to OpenAI and returns the query it produced
+func (h *StockHandler) completeSQL(ctx context.Context, messages []OpenAIMessage) (string, error) {
+	content, tokens, err := h.openAI.ChatCompletion(ctx, ChatCompletionRequest{
+		Model:       h.openAIModel,
+		Messages:    messages,
+		MaxTokens:   200,
+		Temperature: 0.1,
+	})
+	h.aiUsage.Record(aiUsageSQLGeneration, tokens)
+	if errors.Is(err, errNoChoices) {
+		return "", fmt.Errorf("no SQL generated")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sqlQuery := strings.TrimSpace(content)
+	sqlQuery = strings.Trim(sqlQuery, "`")
+	h.logger.Debug("sql generated", "sql", sqlQuery)
+	return sqlQuery, nil
+}
+
+// executeSafeSQL validates and executes the generated SQL query
+//
+// The query is first checked and rewritten by guardRAGSQL, so it reads from stock_ratings, selects a
+// bounded set of columns and returns at most maxRAGRows rows.
+// It runs in a read-only transaction with a Postgres statement_timeout and a context deadline
+// of RAG_SQL_TIMEOUT_MS, so runaway LLM-authored SQL is cancelled and reported as errRAGQueryTimeout.
+func (h *StockHandler) executeSafeSQL(ctx context.Context, sqlQuery string) ([]map[string]interface{}, error) {
+	// Basic SQL injection protection
+	sqlLower := strings.ToLower(sqlQuery)
+	if !strings.HasPrefix(sqlLower, "select") {
+		h.logger.Warn("non-select sql blocked", "sql", sqlQuery)
+		return nil, fmt.Errorf("only SELECT queries allowed")
+	}
+	if strings.Contains(sqlLower, "drop") || strings.Contains(sqlLower, "delete") || strings.Contains(sqlLower, "update") || strings.Contains(sqlLower, "insert") {
+		h.logger.Warn("dangerous sql blocked", "sql", sqlQuery)
+		return nil, fmt.Errorf("dangerous SQL operations not allowed")
+	}
+	guarded, err := guardRAGSQL(sqlQuery)
+	if err != nil {
+		h.logger.Warn("rag sql rejected", "sql", sqlQuery, "error", err)
+		return nil, err
+	}
+	sqlQuery = guarded
+
+	h.logger.Debug("executing validated sql")
+	queryCtx, cancel := context.WithTimeout(ctx, h.ragSQLTimeout)
+	defer cancel()
+
+	// Read-only transaction so SET LOCAL only affects this query and no write can slip through
+	tx, err := h.DB.BeginTx(queryCtx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, ragQueryError(queryCtx, err, h.ragSQLTimeout)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(queryCtx, fmt.Sprintf("SET LOCAL statement_timeout = %d", h.ragSQLTimeout.Milliseconds())); err != nil {
+		return nil, ragQueryError(queryCtx, err, h.ragSQLTimeout)
+	}
+
+	rows, err := tx.QueryContext(queryCtx, sqlQuery)
+	if err != nil {
+		h.logger.Warn("rag query failed", "sql", sqlQuery, "error", err)
+		return nil, ragQueryError(queryCtx, err, h.ragSQLTimeout)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		h.logger.Warn("rag query columns failed", "error", err)
+		return nil, err
+	}
+	h.logger.Debug("rag query columns", "columns", columns)
+
+	var results []map[string]interface{}
+	rowCount := 0
+	for rows.Next() {
+		rowCount++
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range columns {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			h.logger.Warn("skipping rag row", "row", rowCount, "error", err)
+			continue
+		}
+
+		row := make(map[string]interface{})
+		for i, col := range columns {
+			if values[i] != nil {
+				row[col] = values[i]
+			}
+		}
+		results = append(results, row)
+		
+		// Log first few rows for debugging
+		if rowCount <= 3 {
+			h.logger.Debug("rag row sample", "row", rowCount, "values", row)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, ragQueryError(queryCtx, err, h.ragSQLTimeout)
+	}
+
+	h.logger.Debug("rag rows processed", "rows", rowCount, "count", len(results))
+	return results, nil
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate key (SQLSTATE 23505)
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// ragQueryError reports timeouts of AI-generated SQL as errRAGQueryTimeout,
+// whether the context deadline fired or Postgres cancelled the statement (SQLSTATE 57014).
+func ragQueryError(ctx context.Context, err error, timeout time.Duration) error {
+	var pqErr *pq.Error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || (errors.As(err, &pqErr) && pqErr.Code == "57014") {
+		return fmt.Errorf("%w after %s", errRAGQueryTimeout, timeout)
+	}
+	return err
+}
+
+// formatQueryResults formats the SQL results into readable context
+func (h *StockHandler) formatQueryResults(results []map[string]interface{}, question string) string {
+	h.logger.Debug("formatting rag results", "count", len(results))
+	if len(results) == 0 {
+		return "No data found for your query."
+	}
+
+	var context strings.Builder
+	context.WriteString(fmt.Sprintf("Query results for: %s\n\n", question))
+
+	formattedRows := 0
+	for i, row := range results {
+		if i >= 20 { // Limit context size
+			context.WriteString("... (showing first 20 results)\n")
+			h.logger.Debug("rag results truncated", "limit", 20)
+			break
+		}
+
+		// Format each row based on available columns
+		if ticker, ok := row["ticker"]; ok {
+			if company, ok := row["company"]; ok {
+				context.WriteString(fmt.Sprintf("%v (%v)", company, ticker))
+			} else {
+				context.WriteString(fmt.Sprintf("%v", ticker))
+			}
+		}
+
+		if rating, ok := row["rating_to"]; ok {
+			context.WriteString(fmt.Sprintf(" - Rating: %v", rating))
+		}
+		if target, ok := row["target_to"]; ok {
+			context.WriteString(fmt.Sprintf(" - Target: %v", target))
+		}
+		if action, ok := row["action"]; ok {
+			context.WriteString(fmt.Sprintf(" - Action: %v", action))
+		}
+		if brokerage, ok := row["brokerage"]; ok {
+			context.WriteString(fmt.Sprintf(" - Brokerage: %v", brokerage))
+		}
+
+		// Add any calculated fields
+		for key, value := range row {
+			if !contains([]string{"ticker", "company", "rating_to", "target_to", "action", "brokerage"}, key) {
+				context.WriteString(fmt.Sprintf(" - %s: %v", key, value))
+			}
+		}
+
+		context.WriteString("\n")
+		formattedRows++
+	}
+
+	h.logger.Debug("rag results formatted", "rows", formattedRows, "length", context.Len())
+	return context.String()
+}
+
+// contains checks if a slice contains a string
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+
+// numericTargetToSQL and numericTargetFromSQL parse target prices ("$1,234.50") as numbers.
+// Values that aren't numbers ("", "N/A", "—") become NULL instead of failing the whole query: the cast
+// sits inside a CASE because PostgreSQL doesn't promise to check a WHERE guard before evaluating the cast.
+const (
+	numericTargetToSQL   = `(CASE WHEN target_to ~ '^\$?[0-9][0-9,]*(\.[0-9]+)?$' THEN CAST(REPLACE(REPLACE(target_to, '$', ''), ',', '') AS NUMERIC) END)`
+	numericTargetFromSQL = `(CASE WHEN target_from ~ '^\$?[0-9][0-9,]*(\.[0-9]+)?$' THEN CAST(REPLACE(REPLACE(target_from, '$', ''), ',', '') AS NUMERIC) END)`
+)
+
+// StockConsensus aggregates every analyst rating stored for one ticker
+type StockConsensus struct {
+	Ticker         string   `json:"ticker" example:"AAPL"`
+	Company        string   `json:"company" example:"Apple Inc."`
+	Coverage       int      `json:"coverage" example:"12"`
+	Brokerages     int      `json:"brokerages" example:"7"`
+	Bullish        int      `json:"bullish" example:"8"`
+	Neutral        int      `json:"neutral" example:"3"`
+	Bearish        int      `json:"bearish" example:"1"`
+	AvgTargetPrice *float64 `json:"avg_target_price" example:"185.5"`
+	MinTargetPrice *float64 `json:"min_target_price" example:"160"`
+	MaxTargetPrice *float64 `json:"max_target_price" example:"210"`
+	TargetSpread   *float64 `json:"target_spread" example:"50"`
+}
+
+// ConsensusResponse lists per-ticker consensus, most covered tickers first
+type ConsensusResponse struct {
+	Consensus   []StockConsensus `json:"consensus"`
+	GeneratedAt string           `json:"generated_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// GetStockConsensus aggregates all analyst ratings per ticker into a consensus view
+// @Summary Get analyst consensus per ticker
+// @Description Groups every stored rating by ticker and counts bullish, neutral and bearish ratings across all brokerages (same buckets as the market sentiment metric). Also returns the average, minimum, maximum and spread of the numeric target_to prices. Tickers are ordered by coverage (number of ratings), highest first. Target price fields are null when a ticker has no numeric targets.
+// @Tags analytics
+// @Produce json
+// @Param limit query int false "Number of tickers to return (1-50)" default(10)
+// @Success 200 {object} ConsensusResponse "Successfully aggregated analyst consensus"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid limit parameter"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/consensus [get]
+func (h *StockHandler) GetStockConsensus(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter. Must be between 1 and 50"})
+		return
+	}
+
+	query := `
+		SELECT ticker, MAX(company), COUNT(*) AS coverage, COUNT(DISTINCT brokerage),
+			SUM(CASE WHEN ` + ratingSentimentSQL("rating_to", sentimentBullish) + ` THEN 1 ELSE 0 END),
+			SUM(CASE WHEN ` + ratingSentimentSQL("rating_to", sentimentNeutral) + ` THEN 1 ELSE 0 END),
+			SUM(CASE WHEN ` + ratingSentimentSQL("rating_to", sentimentBearish) + ` THEN 1 ELSE 0 END),
+			AVG(` + numericTargetToSQL + `),
+			MIN(` + numericTargetToSQL + `),
+			MAX(` + numericTargetToSQL + `)
+		FROM stock_ratings
+		WHERE ticker IS NOT NULL AND ticker != ''
+		GROUP BY ticker
+		ORDER BY coverage DESC, ticker ASC
+		LIMIT $1`
+
+	rows, err := h.DB.Query(query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query analyst consensus"})
+		return
+	}
+	defer rows.Close()
+
+	consensus := []StockConsensus{}
+	for rows.Next() {
+		var item StockConsensus
+		var avgTarget, minTarget, maxTarget sql.NullFloat64
+		if err := rows.Scan(&item.Ticker, &item.Company, &item.Coverage, &item.Brokerages,
+			&item.Bullish, &item.Neutral, &item.Bearish, &avgTarget, &minTarget, &maxTarget); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan analyst consensus"})
+			return
+		}
+		if avgTarget.Valid {
+			avg := math.Round(avgTarget.Float64*100) / 100
+			item.AvgTargetPrice = &avg
+		}
+		if minTarget.Valid && maxTarget.Valid {
+			minPrice, maxPrice := minTarget.Float64, maxTarget.Float64
+			spread := maxPrice - minPrice
+			item.MinTargetPrice = &minPrice
+			item.MaxTargetPrice = &maxPrice
+			item.TargetSpread = &spread
+		}
+		consensus = append(consensus, item)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read analyst consensus"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConsensusResponse{
+		Consensus:   consensus,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+	})
+}
+
+// maxCompareTickers caps how many tickers CompareStocks compares at once
+const maxCompareTickers = 5
+
+// StockComparison is the current analyst picture of one compared ticker
+type StockComparison struct {
+	Ticker         string  `json:"ticker" example:"AAPL"`
+	Company        string  `json:"company" example:"Apple Inc."`
+	LatestRating   string  `json:"latest_rating" example:"Buy"`
+	LatestTarget   string  `json:"latest_target" example:"$180.00"`
+	Brokerage      string  `json:"brokerage" example:"Goldman Sachs"`
+	Score          float64 `json:"score" example:"7.2"`
+	Recommendation string  `json:"recommendation" example:"Buy"`
+	PriceChange    float64 `json:"price_change" example:"20"`
+	Coverage       int     `json:"coverage" example:"12"`
+}
+
+// CompareResponse lists the compared tickers in the order they were requested
+type CompareResponse struct {
+	Stocks      []StockComparison `json:"stocks"`
+	GeneratedAt string            `json:"generated_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// CompareStocks compares the latest analyst view of a few tickers side by side
+// @Summary Compare tickers side by side
+// @Description For each ticker returns the latest rating, target and brokerage, the recommendation score with default weights (same scoring as /stocks/recommendations), the latest target price change percent and the number of stored ratings. Tickers are case-insensitive and duplicates are ignored. When any ticker has no ratings the request fails with 404 and unknown_tickers lists them.
+// @Tags analytics
+// @Produce json
+// @Param tickers query string true "Comma-separated tickers to compare (2-5), e.g. AAPL,MSFT"
+// @Success 200 {object} CompareResponse "Successfully compared tickers"
+// @Failure 400 {object} models.ErrorResponse "Bad request - missing, invalid or too many tickers"
+// @Failure 404 {object} map[string]interface{} "Some tickers have no ratings, listed in unknown_tickers"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/compare [get]
+func (h *StockHandler) CompareStocks(c *gin.Context) {
+	var tickers []string
+	seen := make(map[string]bool)
+	for _, raw := range strings.Split(c.Query("tickers"), ",") {
+		ticker := strings.ToUpper(strings.TrimSpace(raw))
+		if ticker == "" || seen[ticker] {
+			continue
+		}
+		if !tickerPattern.MatchString(ticker) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid ticker %s: must be 2-5 letters", ticker)})
+			return
+		}
+		seen[ticker] = true
+		tickers = append(tickers, ticker)
+	}
+	if len(tickers) < 2 || len(tickers) > maxCompareTickers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("tickers must list between 2 and %d different tickers", maxCompareTickers)})
+		return
+	}
+
+	query := `
+		SELECT ticker, company, action, brokerage, rating_from, rating_to,
+		       target_from, target_to, time
+		FROM stock_ratings
+		WHERE UPPER(ticker) = ANY($1)`
+
+	rows, err := h.DB.Query(query, pq.Array(tickers))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query stocks to compare"})
+		return
+	}
+	defer rows.Close()
+
+	histories := make(map[string][]stockData)
+	for rows.Next() {
+		var stock stockData
+		var reportTime sql.NullTime
+		if err := rows.Scan(&stock.Ticker, &stock.Company, &stock.Action, &stock.Brokerage,
+			&stock.RatingFrom, &stock.RatingTo, &stock.TargetFrom, &stock.TargetTo, &reportTime); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan stocks to compare"})
+			return
+		}
+		stock.Time = reportTime.Time
+		ticker := strings.ToUpper(stock.Ticker)
+		histories[ticker] = append(histories[ticker], stock)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read stocks to compare"})
+		return
+	}
+
+	unknown := []string{}
+	for _, ticker := range tickers {
+		if len(histories[ticker]) == 0 {
+			unknown = append(unknown, ticker)
+		}
+	}
+	if len(unknown) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":           fmt.Sprintf("No ratings found for tickers %s", strings.Join(unknown, ", ")),
+			"unknown_tickers": unknown,
+		})
+		return
+	}
+
+	weights := h.scoringWeights.Get()
+	now := h.now()
+	stocks := make([]StockComparison, 0, len(tickers))
+	for _, ticker := range tickers {
+		history := histories[ticker]
+		latest := latestStockData(history)
+		score := calculateStockScore(latest, history, weights, now).Total
+		stocks = append(stocks, StockComparison{
+			Ticker:         ticker,
+			Company:        latest.Company,
+			LatestRating:   latest.RatingTo,
+			LatestTarget:   latest.TargetTo,
+			Brokerage:      latest.Brokerage,
+			Score:          score,
+			Recommendation: getRecommendationLevel(score),
+			PriceChange:    targetPriceChange(latest),
+			Coverage:       len(history),
+		})
+	}
+
+	c.JSON(http.StatusOK, CompareResponse{
+		Stocks:      stocks,
+		GeneratedAt: now.Format(time.RFC3339),
+	})
+}
+
+// defaultTrendingDays is the recent window GetTrendingStocks counts actions in when days is not given
+const defaultTrendingDays = 7
+
+// TrendingStock is the recent analyst activity on one ticker
+type TrendingStock struct {
+	Ticker           string     `json:"ticker" example:"NVDA"`
+	Company          string     `json:"company" example:"NVIDIA Corporation"`
+	Actions          int        `json:"actions" example:"9"`
+	Upgrades         int        `json:"upgrades" example:"4"`
+	Downgrades       int        `json:"downgrades" example:"1"`
+	NetUpgrades      int        `json:"net_upgrades" example:"3"`
+	LatestRating     string     `json:"latest_rating" example:"Buy"`
+	LatestActionTime *time.Time `json:"latest_action_time" example:"2025-01-15T10:30:00Z"`
+}
+
+// TrendingResponse lists the tickers with the most analyst actions in the window, most active first
+type TrendingResponse struct {
+	Trending    []TrendingStock `json:"trending"`
+	Days        int             `json:"days" example:"7"`
+	GeneratedAt string          `json:"generated_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// GetTrendingStocks ranks tickers by how many analyst actions they received recently
+// @Summary Get trending tickers
+// @Description Counts the analyst actions stored per ticker in the last days days (by created_at) and returns the most active tickers first, with their upgrade/downgrade balance and latest rating. Ties are ordered by ticker.
+// @Tags analytics
+// @Produce json
+// @Param days query int false "Recent window in days (1-365)" default(7)
+// @Param limit query int false "Number of tickers to return (1-50)" default(10)
+// @Success 200 {object} TrendingResponse "Successfully ranked trending tickers"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid days or limit parameter"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/trending [get]
+func (h *StockHandler) GetTrendingStocks(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultTrendingDays)))
+	if err != nil || days < 1 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter. Must be between 1 and 365"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter. Must be between 1 and 50"})
+		return
 	}
 
-	reqJSON, _ := json.Marshal(reqBody)
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", strings.NewReader(string(reqJSON)))
+	query := `
+		SELECT ticker, MAX(company), COUNT(*) AS actions,
+			SUM(CASE WHEN action ILIKE '%upgrade%' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN action ILIKE '%downgrade%' THEN 1 ELSE 0 END),
+			(ARRAY_AGG(rating_to ORDER BY time DESC NULLS LAST, id DESC))[1],
+			MAX(time)
+		FROM stock_ratings
+		WHERE created_at >= NOW() - ($1 * INTERVAL '1 day') AND ticker IS NOT NULL AND ticker != ''
+		GROUP BY ticker
+		ORDER BY actions DESC, ticker ASC
+		LIMIT $2`
+
+	rows, err := h.DB.Query(query, days, limit)
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query trending stocks"})
+		return
+	}
+	defer rows.Close()
+
+	trending := []TrendingStock{}
+	for rows.Next() {
+		var item TrendingStock
+		var latestRating sql.NullString
+		var latestAction sql.NullTime
+		if err := rows.Scan(&item.Ticker, &item.Company, &item.Actions,
+			&item.Upgrades, &item.Downgrades, &latestRating, &latestAction); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan trending stocks"})
+			return
+		}
+		item.NetUpgrades = item.Upgrades - item.Downgrades
+		item.LatestRating = latestRating.String
+		if latestAction.Valid {
+			item.LatestActionTime = &latestAction.Time
+		}
+		trending = append(trending, item)
 	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read trending stocks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TrendingResponse{
+		Trending:    trending,
+		Days:        days,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+	})
+}
+
+// BrokerageStats summarizes the activity of one analyst firm
+type BrokerageStats struct {
+	Brokerage              string     `json:"brokerage" example:"Goldman Sachs"`
+	TotalActions           int        `json:"total_actions" example:"42"`
+	Upgrades               int        `json:"upgrades" example:"10"`
+	Downgrades             int        `json:"downgrades" example:"4"`
+	TickersCovered         int        `json:"tickers_covered" example:"25"`
+	AvgTargetChangePercent *float64   `json:"avg_target_change_percent" example:"6.25"`
+	MostRecentActionTime   *time.Time `json:"most_recent_action_time" example:"2025-01-15T10:30:00Z"`
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
+// GetBrokerageStats returns activity statistics for a single brokerage
+// @Summary Get activity statistics for a brokerage
+// @Description Returns total actions, upgrades vs downgrades, distinct tickers covered, the average target price change percent (from target_from to target_to, ignoring ratings without both numeric prices) and the most recent action time for one brokerage. The name is matched case-insensitively; URL-encode spaces (e.g. Goldman%20Sachs).
+// @Tags analytics
+// @Produce json
+// @Param name path string true "Brokerage name"
+// @Success 200 {object} BrokerageStats "Successfully calculated brokerage statistics"
+// @Failure 404 {object} models.ErrorResponse "No ratings found for brokerage"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/brokerage/{name} [get]
+func (h *StockHandler) GetBrokerageStats(c *gin.Context) {
+	name := strings.TrimSpace(c.Param("name"))
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	query := `
+		SELECT MAX(brokerage), COUNT(*),
+			SUM(CASE WHEN action ILIKE '%upgrade%' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN action ILIKE '%downgrade%' THEN 1 ELSE 0 END),
+			COUNT(DISTINCT ticker),
+			AVG((` + numericTargetToSQL + ` - ` + numericTargetFromSQL + `) / NULLIF(` + numericTargetFromSQL + `, 0) * 100),
+			MAX(time)
+		FROM stock_ratings
+		WHERE LOWER(brokerage) = LOWER($1)`
+
+	var stats BrokerageStats
+	var brokerage sql.NullString
+	var upgrades, downgrades sql.NullInt64
+	var avgChange sql.NullFloat64
+	var lastAction sql.NullTime
+	err := h.DB.QueryRow(query, name).Scan(&brokerage, &stats.TotalActions, &upgrades, &downgrades,
+		&stats.TickersCovered, &avgChange, &lastAction)
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate brokerage statistics"})
+		return
 	}
-	defer resp.Body.Close()
 
-	var openAIResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+	if stats.TotalActions == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No ratings found for brokerage %s", name)})
+		return
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
-		return "", err
+	// Report the name as stored rather than as typed
+	stats.Brokerage = brokerage.String
+	stats.Upgrades = int(upgrades.Int64)
+	stats.Downgrades = int(downgrades.Int64)
+	if avgChange.Valid {
+		avg := math.Round(avgChange.Float64*100) / 100
+		stats.AvgTargetChangePercent = &avg
 	}
-
-	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no SQL generated")
+	if lastAction.Valid {
+		stats.MostRecentActionTime = &lastAction.Time
 	}
 
-	sqlQuery := strings.TrimSpace(openAIResp.Choices[0].Message.Content)
-	sqlQuery = strings.Trim(sqlQuery, "`")
-	println("✅ AI: SQL generated successfully")
-	println("🔧 AI: Raw SQL from OpenAI:", sqlQuery)
-	return sqlQuery, nil
+	c.JSON(http.StatusOK, stats)
 }
 
-// executeSafeSQL validates and executes the generated SQL query
-func (h *StockHandler) executeSafeSQL(sqlQuery string) ([]map[string]interface{}, error) {
-	// Basic SQL injection protection
-	println("🔒 Security: Validating SQL query for safety...")
-	sqlLower := strings.ToLower(sqlQuery)
-	if !strings.HasPrefix(sqlLower, "select") {
-		println("❌ Security: Non-SELECT query blocked:", sqlQuery)
-		return nil, fmt.Errorf("only SELECT queries allowed")
+// Daily stats range: the last defaultDailyStatsDays days unless from/to are given, at most maxDailyStatsDays
+const (
+	defaultDailyStatsDays = 30
+	maxDailyStatsDays     = 3660
+)
+
+// dailyStatsGranularities are the date_trunc units GetDailyStats accepts
+var dailyStatsGranularities = []string{"day", "week", "month"}
+
+// DailyStat is the analyst activity of one day, week or month
+type DailyStat struct {
+	Date            string  `json:"date" example:"2025-01-15"` // First day of the bucket
+	TotalActions    int     `json:"total_actions" example:"42"`
+	Upgrades        int     `json:"upgrades" example:"10"`
+	Downgrades      int     `json:"downgrades" example:"4"`
+	AvgTargetChange float64 `json:"avg_target_change" example:"6.25"` // Percent, 0 when no rating had both prices
+}
+
+// DailyStatsResponse is a gap-free activity time series for charting
+type DailyStatsResponse struct {
+	From        string      `json:"from" example:"2025-01-01"`
+	To          string      `json:"to" example:"2025-01-31"`
+	Granularity string      `json:"granularity" example:"day"`
+	Data        []DailyStat `json:"data"`
+}
+
+// truncateDate returns the first day of the day, week (Monday) or month containing date, like Postgres date_trunc
+func truncateDate(date time.Time, granularity string) time.Time {
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	switch granularity {
+	case "week":
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	case "month":
+		return date.AddDate(0, 0, 1-date.Day())
 	}
-	if strings.Contains(sqlLower, "drop") || strings.Contains(sqlLower, "delete") || strings.Contains(sqlLower, "update") || strings.Contains(sqlLower, "insert") {
-		println("❌ Security: Dangerous SQL operation blocked:", sqlQuery)
-		return nil, fmt.Errorf("dangerous SQL operations not allowed")
+	return date
+}
+
+// nextBucket returns the first day of the bucket after the one starting at start
+func nextBucket(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
 	}
-	println("✅ Security: SQL query validated as safe")
+	return start.AddDate(0, 0, 1)
+}
 
-	println("💾 Database: Executing SQL query...")
-	rows, err := h.DB.Query(sqlQuery)
-	if err != nil {
-		println("❌ Database: Query execution failed:", err.Error())
-		println("🔍 Database: Failed query was:", sqlQuery)
-		return nil, err
+// GetDailyStats returns analyst activity per day, week or month for charting
+// @Summary Get analyst activity over time
+// @Description Returns total actions, upgrades, downgrades and the average target price change percent (ignoring ratings without both numeric prices) per bucket of analyst report time, between from and to inclusive. Buckets start on the day, the Monday of the week or the first of the month, following granularity; buckets without activity are included with zeros so the series has no gaps. Defaults to the last 30 days by day.
+// @Tags analytics
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default: 29 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default: today, UTC)"
+// @Param granularity query string false "Bucket size" Enums(day, week, month) default(day)
+// @Success 200 {object} DailyStatsResponse "Activity time series"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid date, from after to, range over 3660 days, or unknown granularity"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/stats/daily [get]
+func (h *StockHandler) GetDailyStats(c *gin.Context) {
+	granularity := strings.ToLower(c.DefaultQuery("granularity", "day"))
+	if !contains(dailyStatsGranularities, granularity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("granularity must be one of: %s", strings.Join(dailyStatsGranularities, ", "))})
+		return
 	}
-	defer rows.Close()
 
-	columns, err := rows.Columns()
+	to := truncateDate(h.now().UTC(), "day")
+	if toStr, ok := c.GetQuery("to"); ok {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(defaultDailyStatsDays - 1))
+	if fromStr, ok := c.GetQuery("from"); ok {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before or equal to to"})
+		return
+	}
+	if to.Sub(from).Hours()/24 >= maxDailyStatsDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Date range too large (max %d days)", maxDailyStatsDays)})
+		return
+	}
+
+	// DATE(date_trunc(...)) is DATE(time) for days and the bucket's first day otherwise
+	query := `
+		SELECT DATE(date_trunc($3, time)) AS bucket, COUNT(*),
+			SUM(CASE WHEN action ILIKE '%upgrade%' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN action ILIKE '%downgrade%' THEN 1 ELSE 0 END),
+			AVG((target_to_num - target_from_num) / NULLIF(target_from_num, 0) * 100)
+		FROM stock_ratings
+		WHERE time >= $1 AND time < $2
+		GROUP BY bucket
+		ORDER BY bucket`
+
+	rows, err := h.DB.Query(query, from, to.AddDate(0, 0, 1), granularity)
 	if err != nil {
-		println("❌ Database: Failed to get columns:", err.Error())
-		return nil, err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate daily statistics"})
+		return
 	}
-	println("📋 Database: Query columns:", columns)
+	defer rows.Close()
 
-	var results []map[string]interface{}
-	rowCount := 0
+	stats := make(map[string]DailyStat)
 	for rows.Next() {
-		rowCount++
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range columns {
-			valuePtrs[i] = &values[i]
-		}
-
-		if err := rows.Scan(valuePtrs...); err != nil {
-			println("⚠️  Database: Skipping row", rowCount, "due to scan error:", err.Error())
-			continue
+		var bucket time.Time
+		var stat DailyStat
+		var upgrades, downgrades sql.NullInt64
+		var avgChange sql.NullFloat64
+		if err := rows.Scan(&bucket, &stat.TotalActions, &upgrades, &downgrades, &avgChange); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read daily statistics"})
+			return
 		}
+		stat.Date = bucket.Format("2006-01-02")
+		stat.Upgrades = int(upgrades.Int64)
+		stat.Downgrades = int(downgrades.Int64)
+		stat.AvgTargetChange = math.Round(avgChange.Float64*100) / 100
+		stats[stat.Date] = stat
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read daily statistics"})
+		return
+	}
 
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			if values[i] != nil {
-				row[col] = values[i]
-			}
-		}
-		results = append(results, row)
-		
-		// Log first few rows for debugging
-		if rowCount <= 3 {
-			println(fmt.Sprintf("📄 Database: Row %d sample:", rowCount), fmt.Sprintf("%+v", row))
+	// Walk every bucket of the range so quiet days show up as zeros
+	response := DailyStatsResponse{
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		Granularity: granularity,
+		Data:        []DailyStat{},
+	}
+	for bucket := truncateDate(from, granularity); !bucket.After(to); bucket = nextBucket(bucket, granularity) {
+		date := bucket.Format("2006-01-02")
+		stat, ok := stats[date]
+		if !ok {
+			stat = DailyStat{Date: date}
 		}
+		response.Data = append(response.Data, stat)
 	}
 
-	println("📊 Database: Total rows processed:", rowCount, "| Results collected:", len(results))
-	return results, nil
+	c.JSON(http.StatusOK, response)
 }
 
-// formatQueryResults formats the SQL results into readable context
-func (h *StockHandler) formatQueryResults(results []map[string]interface{}, question string) string {
-	println("📝 Formatting: Starting to format", len(results), "results for question:", question)
-	if len(results) == 0 {
-		println("⚠️  Formatting: No results to format")
-		return "No data found for your query."
+// GetStockMetrics calculates and returns comprehensive market metrics from stock ratings data
+// @Summary Get comprehensive stock market analytics and metrics
+// @Description Analyzes all stored stock ratings using parallel processing to provide comprehensive market insights including sentiment analysis, target price changes, rating distributions, top brokerages, most active stocks, and recent activity trends. target_changes classifies ratings by their action text, while target_price_stats compares the numeric previous and new target prices (ratings with a non-numeric target are left out). data_freshness reports the oldest and newest report time and created_at and the hours since the last ingest, to spot a stalled ingest pipeline. rating_distribution lists the 10 most common ratings with their percentage of the total, the remaining ratings are summed into other. Results are cached in memory for METRICS_CACHE_TTL (default 30s); cached and cache_age_seconds tell whether the response came from the cache. Once the cache expires the stale metrics are served immediately with refreshing=true while a single background recompute runs; the request that starts the recompute gets 202 Accepted. Every response carries a weak ETag for the metrics version (total_records and generated_at); sending it back in If-None-Match returns 304 Not Modified without a body while the version is unchanged. The filters of /stocks/search (ticker, brokerage, time range and the others) can be passed as query params to scope every metric to the matching ratings; scoped metrics are always computed live and never cached.
+// @Tags analytics
+// @Produce json
+// @Param ticker query string false "Only ratings of this ticker"
+// @Param brokerage query string false "Only ratings of this brokerage (case-insensitive)"
+// @Param brokerage_contains query string false "Only ratings of brokerages whose name contains this text"
+// @Param sector query string false "Only ratings of this sector"
+// @Param action query string false "Only ratings with this action"
+// @Param rating_from query string false "Only ratings with this previous rating"
+// @Param rating_to query string false "Only ratings with this new rating"
+// @Param time_from query string false "Only ratings reported at or after this time (RFC3339)"
+// @Param time_to query string false "Only ratings reported at or before this time (RFC3339)"
+// @Param If-None-Match header string false "ETag of a previous response"
+// @Success 200 {object} models.MetricsResponse "Successfully calculated comprehensive market metrics and analytics"
+// @Success 202 {object} models.MetricsResponse "Stale metrics served, background refresh started"
+// @Success 304 "Metrics unchanged since the ETag in If-None-Match"
+// @Failure 400 {object} models.ErrorResponse "Invalid filter parameters"
+// @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
+// @Router /stocks/metrics [get]
+func (h *StockHandler) GetStockMetrics(c *gin.Context) {
+	var filter AdvancedSearchRequest
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter parameters: " + err.Error()})
+		return
+	}
+	if err := validateSearchFilters(filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	var context strings.Builder
-	context.WriteString(fmt.Sprintf("Query results for: %s\n\n", question))
-
-	formattedRows := 0
-	for i, row := range results {
-		if i >= 20 { // Limit context size
-			context.WriteString("... (showing first 20 results)\n")
-			println("📄 Formatting: Truncated results at 20 items")
-			break
+	// Scoped metrics are computed for every request, only the whole-table metrics are cached
+	if whereClause, _, _ := buildStockFilter(filter); whereClause != "" {
+		metrics, err := h.computeStockMetrics(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+		writeMetrics(c, http.StatusOK, metrics, false, 0, false)
+		return
+	}
 
-		// Format each row based on available columns
-		if ticker, ok := row["ticker"]; ok {
-			if company, ok := row["company"]; ok {
-				context.WriteString(fmt.Sprintf("%v (%v)", company, ticker))
-			} else {
-				context.WriteString(fmt.Sprintf("%v", ticker))
-			}
-		}
+	// Serve from cache while fresh, the dashboard polls this endpoint frequently
+	if cached, age, ok := h.metricsCache.Get(); ok {
+		writeMetrics(c, http.StatusOK, cached, true, age, h.metricsCache.Refreshing())
+		return
+	}
 
-		if rating, ok := row["rating_to"]; ok {
-			context.WriteString(fmt.Sprintf(" - Rating: %v", rating))
-		}
-		if target, ok := row["target_to"]; ok {
-			context.WriteString(fmt.Sprintf(" - Target: %v", target))
-		}
-		if action, ok := row["action"]; ok {
-			context.WriteString(fmt.Sprintf(" - Action: %v", action))
-		}
-		if brokerage, ok := row["brokerage"]; ok {
-			context.WriteString(fmt.Sprintf(" - Brokerage: %v", brokerage))
+	// Stale-while-revalidate: answer with the expired metrics and recompute in the background
+	if stale, age, ok := h.metricsCache.GetStale(); ok {
+		status := http.StatusOK
+		if h.metricsCache.StartRefresh() {
+			status = http.StatusAccepted
+			go h.refreshMetricsInBackground()
 		}
+		writeMetrics(c, status, stale, true, age, true)
+		return
+	}
 
-		// Add any calculated fields
-		for key, value := range row {
-			if !contains([]string{"ticker", "company", "rating_to", "target_to", "action", "brokerage"}, key) {
-				context.WriteString(fmt.Sprintf(" - %s: %v", key, value))
-			}
-		}
+	metrics, err := h.computeStockMetrics(AdvancedSearchRequest{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.metricsCache.Set(metrics)
 
-		context.WriteString("\n")
-		formattedRows++
+	// Return comprehensive metrics
+	writeMetrics(c, http.StatusOK, metrics, false, 0, false)
+}
+
+// writeMetrics sends metrics with their ETag, or just 304 Not Modified when If-None-Match already names that version
+func writeMetrics(c *gin.Context, status int, metrics interface{}, cached bool, age time.Duration, refreshing bool) {
+	etag := metricsETag(metrics)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache") // Browsers must revalidate, which is cheap with the ETag
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
 	}
 
-	println("✅ Formatting: Successfully formatted", formattedRows, "rows")
-	println("📏 Formatting: Final context length:", len(context.String()), "characters")
-	return context.String()
+	c.JSON(status, gin.H{
+		"success":           true,
+		"metrics":           metrics,
+		"cached":            cached,
+		"cache_age_seconds": age.Seconds(),
+		"refreshing":        refreshing,
+	})
 }
 
-// contains checks if a slice contains a string
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
+// metricsETag identifies a computed metrics version by its total_records and generated_at.
+// It is weak because cached and cache_age_seconds change between responses of the same version.
+func metricsETag(metrics interface{}) string {
+	values, _ := metrics.(map[string]interface{})
+	generatedAt := fmt.Sprint(values["generated_at"])
+	if t, ok := values["generated_at"].(time.Time); ok {
+		generatedAt = t.UTC().Format(time.RFC3339Nano)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v|%s", values["total_records"], generatedAt)))
+	return fmt.Sprintf(`W/"%x"`, sum[:8])
+}
+
+// etagMatches reports whether an If-None-Match header names etag, comparing weakly as RFC 9110 requires
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
 			return true
 		}
 	}
 	return false
 }
 
-
-
-// GetStockMetrics calculates and returns comprehensive market metrics from stock ratings data
-// @Summary Get comprehensive stock market analytics and metrics
-// @Description Analyzes all stored stock ratings using parallel processing to provide comprehensive market insights including sentiment analysis, target price changes, rating distributions, top brokerages, most active stocks, and recent activity trends.
+// RefreshStockMetrics forces a recomputation of the market metrics and updates the cache
+// @Summary Force a refresh of the stock market metrics
+// @Description Recomputes all market metrics synchronously, bypassing the cache, and stores the result so subsequent GET /stocks/metrics calls serve the fresh values.
 // @Tags analytics
 // @Produce json
-// @Success 200 {object} models.MetricsResponse "Successfully calculated comprehensive market metrics and analytics"
+// @Success 200 {object} models.MetricsResponse "Metrics recomputed and cached"
 // @Failure 500 {object} models.GenericErrorResponse "Internal server error occurred"
-// @Router /stocks/metrics [get]
-func (h *StockHandler) GetStockMetrics(c *gin.Context) {
+// @Router /stocks/metrics/refresh [post]
+func (h *StockHandler) RefreshStockMetrics(c *gin.Context) {
+	metrics, err := h.computeStockMetrics(AdvancedSearchRequest{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.metricsCache.Set(metrics)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":           true,
+		"metrics":           metrics,
+		"cached":            false,
+		"cache_age_seconds": 0,
+		"refreshing":        false,
+	})
+}
+
+// refreshMetricsInBackground recomputes the metrics after StartRefresh succeeded.
+// On failure the stale value is kept and the next request will try again.
+func (h *StockHandler) refreshMetricsInBackground() {
+	defer h.metricsCache.FinishRefresh()
+
+	metrics, err := h.computeStockMetrics(AdvancedSearchRequest{})
+	if err != nil {
+		h.logger.Error("background metrics refresh failed", "error", err)
+		return
+	}
+	h.metricsCache.Set(metrics)
+}
+
+// computeStockMetrics runs the metric aggregation queries in parallel and combines the results.
+// Every query is scoped to the ratings matching filter, built once with buildStockFilter like the search.
+func (h *StockHandler) computeStockMetrics(filter AdvancedSearchRequest) (map[string]interface{}, error) {
+	filterClause, args, _ := buildStockFilter(filter)
+	where := func(conditions ...string) string {
+		return metricsWhere(filterClause, conditions...)
+	}
+
 	// Execute multiple queries in parallel for better performance
 	type MetricResult struct {
 		Name  string
@@ -2101,7 +5036,7 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 	go func() {
 		defer wg.Done()
 		var count int
-		err := h.DB.QueryRow("SELECT COUNT(*) FROM stock_ratings").Scan(&count)
+		err := h.DB.QueryRow("SELECT COUNT(*) FROM stock_ratings"+where(), args...).Scan(&count)
 		results <- MetricResult{"total_records", count, err}
 	}()
 
@@ -2111,13 +5046,13 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 		defer wg.Done()
 		query := `
 			SELECT 
-				SUM(CASE WHEN action ILIKE '%raised%' OR action ILIKE '%increase%' OR action ILIKE '%upgrade%' THEN 1 ELSE 0 END) as targets_raised,
-				SUM(CASE WHEN action ILIKE '%lowered%' OR action ILIKE '%decrease%' OR action ILIKE '%downgrade%' THEN 1 ELSE 0 END) as targets_lowered,
-				SUM(CASE WHEN action ILIKE '%maintained%' OR action ILIKE '%reiterated%' THEN 1 ELSE 0 END) as targets_maintained
-			FROM stock_ratings`
+				COALESCE(SUM(CASE WHEN action ILIKE '%raised%' OR action ILIKE '%increase%' OR action ILIKE '%upgrade%' THEN 1 ELSE 0 END), 0) as targets_raised,
+				COALESCE(SUM(CASE WHEN action ILIKE '%lowered%' OR action ILIKE '%decrease%' OR action ILIKE '%downgrade%' THEN 1 ELSE 0 END), 0) as targets_lowered,
+				COALESCE(SUM(CASE WHEN action ILIKE '%maintained%' OR action ILIKE '%reiterated%' THEN 1 ELSE 0 END), 0) as targets_maintained
+			FROM stock_ratings` + where()
 
 		var raised, lowered, maintained int
-		err := h.DB.QueryRow(query).Scan(&raised, &lowered, &maintained)
+		err := h.DB.QueryRow(query, args...).Scan(&raised, &lowered, &maintained)
 		if err != nil {
 			results <- MetricResult{"target_changes", nil, err}
 			return
@@ -2130,36 +5065,34 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 		}, nil}
 	}()
 
-	// 3. Rating Distribution Analysis
+	// 3. Rating Distribution Analysis.
+	// Every distinct rating is counted so the grand total and the "other" bucket cover the ratings beyond the top 10.
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		query := `
 			SELECT rating_to, COUNT(*) as count
-			FROM stock_ratings 
-			WHERE rating_to IS NOT NULL AND rating_to != ''
+			FROM stock_ratings` + where("rating_to IS NOT NULL AND rating_to != ''") + `
 			GROUP BY rating_to 
-			ORDER BY count DESC
-			LIMIT 10`
+			ORDER BY count DESC, rating_to`
 
-		rows, err := h.DB.Query(query)
+		rows, err := h.DB.Query(query, args...)
 		if err != nil {
 			results <- MetricResult{"rating_distribution", nil, err}
 			return
 		}
 		defer rows.Close()
 
-		ratings := make(map[string]int)
+		var counts []models.RatingShare
 		for rows.Next() {
-			var rating string
-			var count int
-			if err := rows.Scan(&rating, &count); err != nil {
+			var share models.RatingShare
+			if err := rows.Scan(&share.Rating, &share.Count); err != nil {
 				continue
 			}
-			ratings[rating] = count
+			counts = append(counts, share)
 		}
 
-		results <- MetricResult{"rating_distribution", ratings, nil}
+		results <- MetricResult{"rating_distribution", buildRatingDistribution(counts, topRatingsInDistribution), nil}
 	}()
 
 	// 4. Top Active Brokerages
@@ -2168,13 +5101,12 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 		defer wg.Done()
 		query := `
 			SELECT brokerage, COUNT(*) as activity_count
-			FROM stock_ratings 
-			WHERE brokerage IS NOT NULL AND brokerage != ''
+			FROM stock_ratings` + where("brokerage IS NOT NULL AND brokerage != ''") + `
 			GROUP BY brokerage 
 			ORDER BY activity_count DESC
 			LIMIT 10`
 
-		rows, err := h.DB.Query(query)
+		rows, err := h.DB.Query(query, args...)
 		if err != nil {
 			results <- MetricResult{"top_brokerages", nil, err}
 			return
@@ -2203,13 +5135,12 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 		defer wg.Done()
 		query := `
 			SELECT ticker, company, COUNT(*) as rating_count
-			FROM stock_ratings 
-			WHERE ticker IS NOT NULL AND ticker != ''
+			FROM stock_ratings` + where("ticker IS NOT NULL AND ticker != ''") + `
 			GROUP BY ticker, company 
 			ORDER BY rating_count DESC
 			LIMIT 15`
 
-		rows, err := h.DB.Query(query)
+		rows, err := h.DB.Query(query, args...)
 		if err != nil {
 			results <- MetricResult{"most_active_stocks", nil, err}
 			return
@@ -2239,14 +5170,14 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 		defer wg.Done()
 		query := `
 			SELECT 
-				SUM(CASE WHEN rating_to ILIKE '%buy%' OR rating_to ILIKE '%strong%' THEN 1 ELSE 0 END) as bullish_ratings,
-				SUM(CASE WHEN rating_to ILIKE '%sell%' OR rating_to ILIKE '%underperform%' THEN 1 ELSE 0 END) as bearish_ratings,
-				SUM(CASE WHEN rating_to ILIKE '%hold%' OR rating_to ILIKE '%neutral%' THEN 1 ELSE 0 END) as neutral_ratings
-			FROM stock_ratings 
-			WHERE rating_to IS NOT NULL AND rating_to != ''`
+				COALESCE(SUM(CASE WHEN ` + ratingSentimentSQL("rating_to", sentimentBullish) + ` THEN 1 ELSE 0 END), 0) as bullish_ratings,
+				COALESCE(SUM(CASE WHEN ` + ratingSentimentSQL("rating_to", sentimentBearish) + ` THEN 1 ELSE 0 END), 0) as bearish_ratings,
+				COALESCE(SUM(CASE WHEN ` + ratingSentimentSQL("rating_to", sentimentNeutral) + ` THEN 1 ELSE 0 END), 0) as neutral_ratings
+			FROM stock_ratings` + where("rating_to IS NOT NULL AND rating_to != ''")
 
+		// SUM is NULL on an empty table, hence the COALESCE, and every percentage is 0 then
 		var bullish, bearish, neutral int
-		err := h.DB.QueryRow(query).Scan(&bullish, &bearish, &neutral)
+		err := h.DB.QueryRow(query, args...).Scan(&bullish, &bearish, &neutral)
 		if err != nil {
 			results <- MetricResult{"market_sentiment", nil, err}
 			return
@@ -2257,28 +5188,83 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 			"bullish_count":      bullish,
 			"bearish_count":      bearish,
 			"neutral_count":      neutral,
-			"bullish_percentage": float64(bullish) / float64(total) * 100,
-			"bearish_percentage": float64(bearish) / float64(total) * 100,
-			"neutral_percentage": float64(neutral) / float64(total) * 100,
+			"bullish_percentage": percentOf(bullish, total),
+			"bearish_percentage": percentOf(bearish, total),
+			"neutral_percentage": percentOf(neutral, total),
 		}
 
 		results <- MetricResult{"market_sentiment", sentiment, nil}
 	}()
 
-	// 7. Recent Activity (last 7 days)
+	// 7. Target Price Statistics from the numeric target columns.
+	// Unlike target_changes this compares the prices themselves, whatever the action text says.
+	// Non-numeric targets are NULL there and skipped, a $0 previous target has no percent change.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		query := `
+			SELECT 
+				COUNT(*) AS prices_compared,
+				COUNT(*) FILTER (WHERE target_to_num > target_from_num) AS price_increases,
+				COUNT(*) FILTER (WHERE target_to_num < target_from_num) AS price_decreases,
+				COUNT(*) FILTER (WHERE target_to_num = target_from_num) AS price_unchanged,
+				AVG((target_to_num - target_from_num) / NULLIF(target_from_num, 0) * 100) AS average_change_pct,
+				MAX((target_to_num - target_from_num) / NULLIF(target_from_num, 0) * 100) FILTER (WHERE target_to_num > target_from_num) AS largest_raise_pct
+			FROM stock_ratings` + where("target_from_num IS NOT NULL AND target_to_num IS NOT NULL")
+
+		var compared, increases, decreases, unchanged int
+		var averageChange, largestRaise sql.NullFloat64
+		err := h.DB.QueryRow(query, args...).Scan(&compared, &increases, &decreases, &unchanged, &averageChange, &largestRaise)
+		if err != nil {
+			results <- MetricResult{"target_price_stats", nil, err}
+			return
+		}
+
+		results <- MetricResult{"target_price_stats", models.TargetPriceStats{
+			Compared:             compared,
+			Increases:            increases,
+			Decreases:            decreases,
+			Unchanged:            unchanged,
+			AverageChangePercent: math.Round(averageChange.Float64*100) / 100,
+			LargestRaisePercent:  math.Round(largestRaise.Float64*100) / 100,
+		}, nil}
+	}()
+
+	// 8. Recent Activity (last 7 days)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		query := `
 			SELECT COUNT(*) as recent_count
-			FROM stock_ratings 
-			WHERE created_at >= NOW() - INTERVAL '7 days'`
+			FROM stock_ratings` + where("created_at >= NOW() - INTERVAL '7 days'")
 
 		var recentCount int
-		err := h.DB.QueryRow(query).Scan(&recentCount)
+		err := h.DB.QueryRow(query, args...).Scan(&recentCount)
 		results <- MetricResult{"recent_activity", recentCount, err}
 	}()
 
+	// 9. Data Freshness, a stalled ingest shows up as a growing hours_since_last_ingest
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		query := `
+			SELECT 
+				MIN(time) AS oldest_report_time,
+				MAX(time) AS newest_report_time,
+				MIN(created_at) AS oldest_created_at,
+				MAX(created_at) AS newest_created_at
+			FROM stock_ratings` + where()
+
+		var oldestTime, newestTime, oldestCreated, newestCreated sql.NullTime
+		err := h.DB.QueryRow(query, args...).Scan(&oldestTime, &newestTime, &oldestCreated, &newestCreated)
+		if err != nil {
+			results <- MetricResult{"data_freshness", nil, err}
+			return
+		}
+
+		results <- MetricResult{"data_freshness", buildDataFreshness(oldestTime, newestTime, oldestCreated, newestCreated, h.now()), nil}
+	}()
+
 	// Wait for all goroutines to complete
 	go func() {
 		wg.Wait()
@@ -2289,10 +5275,7 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 	metrics := make(map[string]interface{})
 	for result := range results {
 		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Failed to calculate %s: %v", result.Name, result.Error),
-			})
-			return
+			return nil, fmt.Errorf("Failed to calculate %s: %v", result.Name, result.Error)
 		}
 		metrics[result.Name] = result.Value
 	}
@@ -2301,9 +5284,77 @@ func (h *StockHandler) GetStockMetrics(c *gin.Context) {
 	metrics["generated_at"] = time.Now().UTC()
 	metrics["description"] = "Comprehensive stock market analytics based on analyst ratings and target price changes"
 
-	// Return comprehensive metrics
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"metrics": metrics,
-	})
+	return metrics, nil
+}
+
+// buildDataFreshness fills the data_freshness metric, every field stays null when no rating is stored.
+// HoursSinceLastIngest counts from the newest created_at, rounded to 2 decimals.
+func buildDataFreshness(oldestTime, newestTime, oldestCreated, newestCreated sql.NullTime, now time.Time) models.DataFreshness {
+	timeOrNil := func(value sql.NullTime) *time.Time {
+		if !value.Valid {
+			return nil
+		}
+		t := value.Time.UTC()
+		return &t
+	}
+
+	freshness := models.DataFreshness{
+		OldestReportTime: timeOrNil(oldestTime),
+		NewestReportTime: timeOrNil(newestTime),
+		OldestCreatedAt:  timeOrNil(oldestCreated),
+		NewestCreatedAt:  timeOrNil(newestCreated),
+	}
+	if newestCreated.Valid {
+		hours := math.Round(now.Sub(newestCreated.Time).Hours()*100) / 100
+		freshness.HoursSinceLastIngest = &hours
+	}
+	return freshness
+}
+
+// metricsWhere combines the WHERE clause of the metrics filter with the own conditions of one metric query.
+// The result starts with a space, or is empty when neither has a condition.
+func metricsWhere(filterClause string, conditions ...string) string {
+	if filterClause != "" {
+		conditions = append(conditions, strings.TrimPrefix(filterClause, "WHERE "))
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "\n\t\t\tWHERE " + strings.Join(conditions, " AND ")
+}
+
+// percentOf returns part as a percentage of total, 0 when total is 0.
+// Metrics must never divide by zero: encoding/json rejects the resulting NaN and the whole response fails.
+func percentOf(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// topRatingsInDistribution is how many ratings the rating_distribution metric lists on their own
+const topRatingsInDistribution = 10
+
+// buildRatingDistribution turns per-rating counts, most frequent first, into the rating_distribution metric:
+// the first top ratings with their share of all ratings, the rest summed into Other.
+func buildRatingDistribution(counts []models.RatingShare, top int) models.RatingDistribution {
+	distribution := models.RatingDistribution{Ratings: []models.RatingShare{}}
+	for _, share := range counts {
+		distribution.Total += share.Count
+	}
+
+	percentage := func(count int) float64 {
+		return math.Round(percentOf(count, distribution.Total)*100) / 100
+	}
+	for i, share := range counts {
+		if i < top {
+			share.Percentage = percentage(share.Count)
+			distribution.Ratings = append(distribution.Ratings, share)
+			continue
+		}
+		distribution.Other.Count += share.Count
+		distribution.Other.Ratings++
+	}
+	distribution.Other.Percentage = percentage(distribution.Other.Count)
+	return distribution
 }
@@ -18,16 +18,29 @@ TEST PURPOSE:
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strconv"
 	"smart-stock-recommender/models"
+	"strings"
+	"sync"
+	"unicode/utf8"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,6 +50,22 @@ func setupTestHandler() (*StockHandler, sqlmock.Sqlmock, *sql.DB) {
 	return handler, mock, db
 }
 
+// serve registers h for method and route on a fresh test router and serves one request to target,
+// a non-nil body is sent as JSON
+func serve(method, route, target string, h gin.HandlerFunc, body io.Reader) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, h)
+
+	req := httptest.NewRequest(method, target, body)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
 // TestNewStockHandler validates handler initialization
 // Purpose: Ensures StockHandler is properly created with database connection
 func TestNewStockHandler(t *testing.T) {
@@ -173,21 +202,82 @@ func TestGetStockRatings_InvalidPageNumber(t *testing.T) {
 
 	router.ServeHTTP(w, req)
 
+	// A zero page_number is rejected by the binding tags like an absent one
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Missing required field 'page_number'")
+
+	req = httptest.NewRequest("POST", "/stocks/list", bytes.NewBufferString(`{"page_number": -1, "page_length": 20}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	assert.Contains(t, w.Body.String(), "page_number must be greater than 0")
 }
 
+// TestBindJSON_MissingRequiredFields validates that binding tags reject absent fields before any query runs
+func TestBindJSON_MissingRequiredFields(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks", handler.GetStocksByPage)
+	router.POST("/stocks/bulk", handler.GetStocksBulk)
+	router.POST("/stocks/list", handler.GetStockRatings)
+	router.POST("/stocks/chat", handler.GetStockChat)
+
+	tests := []struct {
+		path     string
+		body     string
+		expected string
+	}{
+		{"/stocks", `{}`, "Missing required field 'page' in request body"},
+		{"/stocks/bulk", `{"start_page": 1}`, "Missing required field 'end_page' in request body"},
+		{"/stocks/bulk", `{"end_page": 5, "confirm_clear": true}`, "Missing required field 'start_page' in request body"},
+		{"/stocks/list", `{"page_number": 1}`, "Missing required field 'page_length' in request body"},
+		{"/stocks/list", `{"page_length": 20}`, "Missing required field 'page_number' in request body"},
+		{"/stocks/chat", `{"model": "gpt-4.1-mini"}`, "Missing required field 'message' in request body"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.path, bytes.NewBufferString(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, test.path+" "+test.body)
+		assert.JSONEq(t, `{"error": "`+test.expected+`"}`, w.Body.String())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBindJSON_WrongType validates that a mistyped field gets the same error as malformed JSON
+func TestBindJSON_WrongType(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	w := serve("POST", "/stocks/list", "/stocks/list", handler.GetStockRatings, bytes.NewBufferString(`{"page_number": "one", "page_length": 20}`))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "Invalid JSON format in request body"}`, w.Body.String())
+}
+
 func TestSearchStockRatings_Success(t *testing.T) {
 	handler, mock, db := setupTestHandler()
 	defer db.Close()
 
-	// Mock count query
-	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	// The search term is matched with ILIKE on the raw columns so the pg_trgm indexes apply
+	where := regexp.QuoteMeta("WHERE (ticker ILIKE $1 OR company ILIKE $1 OR brokerage ILIKE $1 OR action ILIKE $1 OR rating_from ILIKE $1 OR rating_to ILIKE $1)")
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM stock_ratings `+where+`$`).
+		WithArgs("%AAPL%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
 
-	// Mock search query
 	rows := sqlmock.NewRows([]string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at"}).
 		AddRow(1, "AAPL", "$150.00", "$180.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", time.Now(), time.Now())
-	mock.ExpectQuery("SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at FROM stock_ratings WHERE").WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT id, ticker, target_from, target_to, company, action, brokerage, rating_from, rating_to, time, created_at\s+FROM stock_ratings\s+`+where+`\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$2 OFFSET \$3$`).
+		WithArgs("%AAPL%", 20, 0).
+		WillReturnRows(rows)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -206,14 +296,24 @@ func TestSearchStockRatings_Success(t *testing.T) {
 	var response map[string]interface{}
 	json.Unmarshal(w.Body.Bytes(), &response)
 	assert.Contains(t, response, "data")
-	assert.Contains(t, response, "search_term")
-	assert.Equal(t, "AAPL", response["search_term"])
+	assert.Contains(t, response, "applied_filters")
+	assert.Equal(t, "AAPL", response["applied_filters"].(map[string]interface{})["search_term"])
+	assert.Equal(t, float64(5), response["pagination"].(map[string]interface{})["total_records"])
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+// TestSearchStockRatings_EmptySearchTerm validates that without a search term or filter buildStockFilter adds no WHERE clause
 func TestSearchStockRatings_EmptySearchTerm(t *testing.T) {
-	handler, _, db := setupTestHandler()
+	handler, mock, db := setupTestHandler()
 	defer db.Close()
 
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM stock_ratings\s*$`).
+		WithArgs().
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`FROM stock_ratings\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$1 OFFSET \$2$`).
+		WithArgs(20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at"}))
+
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 	router.POST("/stocks/search", handler.SearchStockRatings)
@@ -226,8 +326,177 @@ func TestSearchStockRatings_EmptySearchTerm(t *testing.T) {
 
 	router.ServeHTTP(w, req)
 
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total_records":0`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSearchStockRatings_DateRangeWithSearchTerm validates that a report time range combines with the search term
+func TestSearchStockRatings_DateRangeWithSearchTerm(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	timeFrom := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeTo := time.Date(2025, 1, 31, 23, 59, 59, 0, time.UTC)
+	where := "WHERE (ticker ILIKE $1 OR company ILIKE $1 OR brokerage ILIKE $1 OR action ILIKE $1 OR rating_from ILIKE $1 OR rating_to ILIKE $1) AND time >= $2 AND time <= $3"
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM stock_ratings " + where)).
+		WithArgs("%AAPL%", timeFrom, timeTo).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(where)).
+		WithArgs("%AAPL%", timeFrom, timeTo, 20, 0).
+		WillReturnRows(sqlmock.NewRows(stockRatingColumns).
+			AddRow(1, "AAPL", "$150.00", "$180.00", "Apple Inc.", "upgraded by", "Goldman Sachs", "Hold", "Buy", timeFrom, timeFrom))
+
+	body := `{"page_number": 1, "page_length": 20, "search_term": "AAPL", "time_from": "2025-01-01T00:00:00Z", "time_to": "2025-01-31T23:59:59Z"}`
+	w := serve("POST", "/stocks/search", "/stocks/search", handler.SearchStockRatings, bytes.NewBufferString(body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSearchStockRatings_ShortSearchTerm validates that terms too short for a trigram index still match by substring
+func TestSearchStockRatings_ShortSearchTerm(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	where := "WHERE (ticker ILIKE $1 OR company ILIKE $1 OR brokerage ILIKE $1 OR action ILIKE $1 OR rating_from ILIKE $1 OR rating_to ILIKE $1)"
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM stock_ratings " + where)).
+		WithArgs("%gs%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta(where)).
+		WithArgs("%gs%", 20, 0).
+		WillReturnRows(sqlmock.NewRows(stockRatingColumns))
+
+	body := `{"page_number": 1, "page_length": 20, "search_term": "gs"}`
+	w := serve("POST", "/stocks/search", "/stocks/search", handler.SearchStockRatings, bytes.NewBufferString(body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSearchStockRatings_InvertedDateRange validates that time_from after time_to is rejected before querying
+func TestSearchStockRatings_InvertedDateRange(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	body := `{"page_number": 1, "time_from": "2025-02-01T00:00:00Z", "time_to": "2025-01-01T00:00:00Z"}`
+	w := serve("POST", "/stocks/search", "/stocks/search", handler.SearchStockRatings, bytes.NewBufferString(body))
+
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), "search_term is required")
+	assert.Contains(t, w.Body.String(), "time_from must be before or equal to time_to")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSearchStockRatings_BrokerageFilter validates that only the brokerage condition is applied alongside pagination
+func TestSearchStockRatings_BrokerageFilter(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	where := "WHERE LOWER(brokerage) = LOWER($1)"
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM stock_ratings " + where)).
+		WithArgs("Goldman Sachs").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(where + "\n\t\tORDER BY created_at DESC, id DESC\n\t\tLIMIT $2 OFFSET $3")).
+		WithArgs("Goldman Sachs", 20, 0).
+		WillReturnRows(sqlmock.NewRows(stockRatingColumns).
+			AddRow(1, "AAPL", "$150.00", "$180.00", "Apple Inc.", "upgraded by", "Goldman Sachs", "Hold", "Buy", time.Now(), time.Now()))
+
+	body := `{"page_number": 1, "page_length": 20, "brokerage": "Goldman Sachs"}`
+	w := serve("POST", "/stocks/search", "/stocks/search", handler.SearchStockRatings, bytes.NewBufferString(body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	filters := response["applied_filters"].(map[string]interface{})
+	assert.Equal(t, "Goldman Sachs", filters["brokerage"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBuildStockFilter_BrokerageContains validates the partial brokerage match
+func TestBuildStockFilter_BrokerageContains(t *testing.T) {
+	whereClause, args, argIndex := buildStockFilter(AdvancedSearchRequest{BrokerageContains: "goldman"})
+
+	assert.Equal(t, "WHERE LOWER(brokerage) LIKE LOWER($1)", whereClause)
+	assert.Equal(t, []interface{}{"%goldman%"}, args)
+	assert.Equal(t, 2, argIndex)
+}
+
+// TestBuildStockFilter validates the clause, arguments and next placeholder for each filter alone and combined
+func TestBuildStockFilter(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	searchColumns := "(ticker ILIKE $1 OR company ILIKE $1 OR brokerage ILIKE $1 OR action ILIKE $1 OR rating_from ILIKE $1 OR rating_to ILIKE $1)"
+
+	tests := []struct {
+		name  string
+		req   AdvancedSearchRequest
+		where string
+		args  []interface{}
+	}{
+		{"no filters", AdvancedSearchRequest{}, "", []interface{}{}},
+		{"pagination only", AdvancedSearchRequest{PageNumber: 3, PageLength: 50}, "", []interface{}{}},
+		{"search term", AdvancedSearchRequest{SearchTerm: "apple"}, "WHERE " + searchColumns, []interface{}{"%apple%"}},
+		{"ticker", AdvancedSearchRequest{Ticker: "aapl"}, "WHERE UPPER(ticker) = UPPER($1)", []interface{}{"aapl"}},
+		{"action", AdvancedSearchRequest{Action: "upgraded by"}, "WHERE LOWER(action) = LOWER($1)", []interface{}{"upgraded by"}},
+		{"brokerage", AdvancedSearchRequest{Brokerage: "Goldman Sachs"}, "WHERE LOWER(brokerage) = LOWER($1)", []interface{}{"Goldman Sachs"}},
+		{"brokerage contains", AdvancedSearchRequest{BrokerageContains: "gold"}, "WHERE LOWER(brokerage) LIKE LOWER($1)", []interface{}{"%gold%"}},
+		{"sector", AdvancedSearchRequest{Sector: "Technology"}, "WHERE LOWER(sector) = LOWER($1)", []interface{}{"Technology"}},
+		{"rating from", AdvancedSearchRequest{RatingFrom: "Hold"}, "WHERE LOWER(rating_from) = LOWER($1)", []interface{}{"Hold"}},
+		{"rating to", AdvancedSearchRequest{RatingTo: "Buy"}, "WHERE LOWER(rating_to) = LOWER($1)", []interface{}{"Buy"}},
+		{"target from min", AdvancedSearchRequest{TargetFromMin: 10}, "WHERE target_from_num >= $1", []interface{}{10.0}},
+		{"target from max", AdvancedSearchRequest{TargetFromMax: 20}, "WHERE target_from_num <= $1", []interface{}{20.0}},
+		{"target to min", AdvancedSearchRequest{TargetToMin: 30}, "WHERE target_to_num >= $1", []interface{}{30.0}},
+		{"target to max", AdvancedSearchRequest{TargetToMax: 40}, "WHERE target_to_num <= $1", []interface{}{40.0}},
+		{"time from", AdvancedSearchRequest{TimeFrom: &from}, "WHERE time >= $1", []interface{}{from}},
+		{"time to", AdvancedSearchRequest{TimeTo: &to}, "WHERE time <= $1", []interface{}{to}},
+		{
+			"all is no filter",
+			AdvancedSearchRequest{Action: "all", Brokerage: "all", Sector: "all", RatingFrom: "all", RatingTo: "all"},
+			"", []interface{}{},
+		},
+		{
+			"search term with time range",
+			AdvancedSearchRequest{SearchTerm: "AAPL", TimeFrom: &from, TimeTo: &to},
+			"WHERE " + searchColumns + " AND time >= $2 AND time <= $3",
+			[]interface{}{"%AAPL%", from, to},
+		},
+		{
+			"brokerage exact and contains",
+			AdvancedSearchRequest{Brokerage: "Goldman Sachs", BrokerageContains: "gold"},
+			"WHERE LOWER(brokerage) = LOWER($1) AND LOWER(brokerage) LIKE LOWER($2)",
+			[]interface{}{"Goldman Sachs", "%gold%"},
+		},
+		{
+			"ratings and price ranges",
+			AdvancedSearchRequest{RatingFrom: "Hold", RatingTo: "Buy", TargetFromMin: 100, TargetToMax: 200},
+			"WHERE LOWER(rating_from) = LOWER($1) AND LOWER(rating_to) = LOWER($2) AND target_from_num >= $3 AND target_to_num <= $4",
+			[]interface{}{"Hold", "Buy", 100.0, 200.0},
+		},
+		{
+			"every filter",
+			AdvancedSearchRequest{
+				SearchTerm: "a", Action: "upgraded by", Brokerage: "Goldman Sachs", BrokerageContains: "gold", Sector: "Technology",
+				RatingFrom: "Hold", RatingTo: "Buy", TargetFromMin: 1, TargetFromMax: 2, TargetToMin: 3, TargetToMax: 4,
+				TimeFrom: &from, TimeTo: &to,
+			},
+			"WHERE " + searchColumns + " AND LOWER(action) = LOWER($2) AND LOWER(brokerage) = LOWER($3) AND LOWER(brokerage) LIKE LOWER($4)" +
+				" AND LOWER(sector) = LOWER($5) AND LOWER(rating_from) = LOWER($6) AND LOWER(rating_to) = LOWER($7)" +
+				" AND target_from_num >= $8 AND target_from_num <= $9 AND target_to_num >= $10 AND target_to_num <= $11" +
+				" AND time >= $12 AND time <= $13",
+			[]interface{}{"%a%", "upgraded by", "Goldman Sachs", "%gold%", "Technology", "Hold", "Buy", 1.0, 2.0, 3.0, 4.0, from, to},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, next := buildStockFilter(tt.req)
+
+			assert.Equal(t, tt.where, where)
+			assert.Equal(t, tt.args, args)
+			assert.Equal(t, len(tt.args)+1, next, "The next placeholder follows the filter arguments")
+		})
+	}
 }
 
 func TestGetStockActions_Success(t *testing.T) {
@@ -257,12 +526,51 @@ func TestGetStockActions_Success(t *testing.T) {
 	assert.Contains(t, response.Actions, "target raised by")
 }
 
+func performGetActions(handler *StockHandler, query string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/actions", "/stocks/actions"+query, handler.GetStockActions, nil)
+}
+
+// TestGetStockActions_Paged validates limit/offset paging with the total distinct count
+func TestGetStockActions_Paged(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(DISTINCT action)")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	mock.ExpectQuery(regexp.QuoteMeta("ORDER BY action ASC LIMIT $1 OFFSET $2")).
+		WithArgs(2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"action"}).AddRow("reiterated by").AddRow("target lowered by"))
+
+	w := performGetActions(handler, "?limit=2&offset=2")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ActionsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"reiterated by", "target lowered by"}, response.Actions)
+	assert.Equal(t, 5, response.Total)
+	assert.Equal(t, 2, response.Limit)
+	assert.Equal(t, 2, response.Offset)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockActions_InvalidPaging validates the limit and offset bounds
+func TestGetStockActions_InvalidPaging(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"?limit=0", "?limit=1001", "?limit=abc", "?offset=-1", "?offset=abc"} {
+		w := performGetActions(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s should be rejected", query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetStockRecommendations_Success(t *testing.T) {
 	handler, mock, db := setupTestHandler()
 	defer db.Close()
 
 	rows := sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at"}).
-		AddRow("AAPL", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", "$150.00", "$180.00", "2024-01-15 10:30:00", time.Now())
+		AddRow("AAPL", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", "$150.00", "$180.00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), time.Now())
 	mock.ExpectQuery("SELECT ticker, company, action, brokerage, rating_from, rating_to, target_from, target_to, time, created_at FROM stock_ratings").WillReturnRows(rows)
 
 	gin.SetMode(gin.TestMode)
@@ -317,11 +625,11 @@ func TestCalculateStockScore(t *testing.T) {
 		RatingTo:   "Buy", // Rating improvement
 		TargetFrom: "$150.00",
 		TargetTo:   "$180.00", // 20% price increase
-		Time:       "2024-01-15 10:30:00",
+		Time:       time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
 	}
 
 	history := []stockData{stock}
-	score := calculateStockScore(stock, history)
+	score := calculateStockScore(stock, history, getDefaultWeights(), time.Now()).Total
 
 	// Score should be above neutral (5.0) due to positive factors
 	assert.Greater(t, score, 5.0, "Score should be above neutral for positive stock data")
@@ -350,6 +658,294 @@ func TestParsePrice(t *testing.T) {
 	}
 }
 
+// TestNormalizePrice validates the canonical target price stored at ingestion
+// Purpose: Unparseable targets are stored empty so the NUMERIC casts in search and metrics never fail
+func TestNormalizePrice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		desc     string
+	}{
+		{"$1,250.50", "$1250.50", "Commas are dropped and cents kept"},
+		{"$150", "$150.00", "Cents are always written"},
+		{" 99.999 ", "$100.00", "Surrounding spaces are trimmed and cents rounded"},
+		{"N/A", "", "Placeholders are not prices"},
+		{"", "", "Empty stays empty"},
+		{"€100", "", "Other currencies can't be compared with dollar targets"},
+		{"—", "", "Dashes are not prices"},
+		{"-5", "", "Negative targets are rejected"},
+		{"NaN", "", "NaN is rejected"},
+		{"1e30", "", "Values that don't fit the column are rejected"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, normalizePrice(test.input), test.desc)
+	}
+}
+
+// TestStoreStock_NormalizesPrices validates that target prices are normalized before they are stored,
+// along with their numeric copies (NULL for a missing price)
+func TestStoreStock_NormalizesPrices(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	stock := models.StockRatings{Ticker: "AAPL", TargetFrom: "N/A", TargetTo: "$1,250.5"}
+	mock.ExpectExec("INSERT INTO stock_ratings").
+		WithArgs("AAPL", "", "$1250.50", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "1250.50").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	inserted, err := handler.storeStock(stock)
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBatchInsert_NormalizesPrices validates that bulk inserts store normalized target prices and their numeric copies
+func TestBatchInsert_NormalizesPrices(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO stock_ratings").ExpectExec().
+		WithArgs("MSFT", "$300.00", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "300.00", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	_, _, err := handler.batchInsertStocksWithLogging([]models.StockRatings{{Ticker: "MSFT", TargetFrom: "300", TargetTo: "€350"}}, 1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBatchInsertMultiRow_InsertsAndCounts validates that one statement inserts the whole batch
+// and that the rows it returns decide the inserted and skipped counts
+func TestBatchInsertMultiRow_InsertsAndCounts(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	ratings := handler.ratingsHub.Subscribe()
+
+	newYork := time.FixedZone("EST", -5*3600)
+	stocks := []models.StockRatings{
+		{Ticker: "AAPL", Brokerage: "Goldman Sachs", Action: "upgraded by", TargetFrom: "150", Time: time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{Ticker: "MSFT", Brokerage: "Morgan Stanley", Action: "reiterated by", Time: time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{Ticker: "NVDA", Brokerage: "Jefferies", Action: "upgraded by", Time: time.Date(2025, 1, 15, 9, 0, 0, 0, newYork)},
+	}
+
+	// MSFT conflicts with a stored rating. The TIMESTAMP column keeps NVDA's wall clock time without its zone
+	args := make([]driver.Value, 0, 39)
+	for range stocks {
+		for i := 0; i < 13; i++ {
+			args = append(args, sqlmock.AnyArg())
+		}
+	}
+	args[0], args[1], args[11] = "AAPL", "$150.00", "150.00"
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13), ($14,") + ".*" +
+		regexp.QuoteMeta("($27, $28, $29, $30, $31, $32, $33, $34, $35, $36, NULLIF($37, ''), $38, $39) ON CONFLICT")).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"ticker", "brokerage", "action", "rating_from", "rating_to", "time"}).
+			AddRow("AAPL", "Goldman Sachs", "upgraded by", "", "", time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)).
+			AddRow("NVDA", "Jefferies", "upgraded by", "", "", time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)))
+	mock.ExpectCommit()
+
+	inserted, skipped, err := handler.insertStockBatch(stocks, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inserted)
+	assert.Equal(t, 1, skipped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	if assert.Len(t, ratings, 2, "Only the inserted ratings are published") {
+		first, second := <-ratings, <-ratings
+		assert.Equal(t, "AAPL", first.Ticker)
+		assert.Equal(t, "$150.00", first.TargetFrom)
+		assert.Equal(t, "NVDA", second.Ticker)
+	}
+}
+
+// TestBatchInsertMultiRow_Chunked validates that large batches are split to stay under the bind parameter limit
+func TestBatchInsertMultiRow_Chunked(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	stocks := make([]models.StockRatings, multiRowInsertMaxRows+1)
+	for i := range stocks {
+		stocks[i] = models.StockRatings{Ticker: "T" + strconv.Itoa(i)}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("$7800)") + " ON CONFLICT").
+		WillReturnRows(sqlmock.NewRows([]string{"ticker", "brokerage", "action", "rating_from", "rating_to", "time"}).
+			AddRow("T0", "", "", "", "", time.Time{}))
+	mock.ExpectQuery(regexp.QuoteMeta("VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13) ON CONFLICT")).
+		WillReturnRows(sqlmock.NewRows([]string{"ticker", "brokerage", "action", "rating_from", "rating_to", "time"}).
+			AddRow("T600", "", "", "", "", time.Time{}))
+	mock.ExpectCommit()
+
+	inserted, skipped, err := handler.insertStockBatch(stocks, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inserted)
+	assert.Equal(t, multiRowInsertMaxRows-1, skipped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBatchInsertMultiRow_FallsBackToPerRow validates that a failing multi-row statement is retried row by row
+func TestBatchInsertMultiRow_FallsBackToPerRow(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO stock_ratings .* RETURNING").WillReturnError(errors.New("statement too large"))
+	mock.ExpectRollback()
+	expectBulkInsertResults(mock, 1, 0)
+
+	inserted, skipped, err := handler.insertStockBatch([]models.StockRatings{{Ticker: "AAPL"}, {Ticker: "MSFT"}}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 1, skipped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBatchInsert_PerRowMode validates that BULK_INSERT_MODE=per_row keeps one statement per stock
+func TestBatchInsert_PerRowMode(t *testing.T) {
+	t.Setenv("BULK_INSERT_MODE", "per_row")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	expectBulkInsert(mock, 2)
+
+	inserted, _, err := handler.insertStockBatch([]models.StockRatings{{Ticker: "AAPL"}, {Ticker: "MSFT"}}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// benchmarkRoundTrip is the simulated database latency of every statement in the batch insert benchmarks
+const benchmarkRoundTrip = 100 * time.Microsecond
+
+// benchmarkStocks returns count distinct stocks for the batch insert benchmarks
+func benchmarkStocks(count int) []models.StockRatings {
+	stocks := make([]models.StockRatings, count)
+	for i := range stocks {
+		stocks[i] = models.StockRatings{
+			Ticker: "T" + strconv.Itoa(i), Company: "Company", Action: "upgraded by", Brokerage: "Goldman Sachs",
+			RatingFrom: "Hold", RatingTo: "Buy", TargetFrom: "$150.00", TargetTo: "$180.00", Time: time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC),
+		}
+	}
+	return stocks
+}
+
+// BenchmarkBatchInsertPerRow measures a 1000 stock batch inserted with one statement per stock
+func BenchmarkBatchInsertPerRow(b *testing.B) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	stocks := benchmarkStocks(1000)
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare("INSERT INTO stock_ratings")
+		for range stocks {
+			prep.ExpectExec().WillDelayFor(benchmarkRoundTrip).WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+		mock.ExpectCommit()
+		b.StartTimer()
+
+		if _, _, err := handler.batchInsertStocksWithLogging(stocks, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBatchInsertMultiRow measures the same batch inserted with multi-row statements
+func BenchmarkBatchInsertMultiRow(b *testing.B) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	stocks := benchmarkStocks(1000)
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		mock.ExpectBegin()
+		for start := 0; start < len(stocks); start += multiRowInsertMaxRows {
+			rows := sqlmock.NewRows([]string{"ticker", "brokerage", "action", "rating_from", "rating_to", "time"})
+			for _, stock := range stocks[start:min(start+multiRowInsertMaxRows, len(stocks))] {
+				rows.AddRow(stock.Ticker, stock.Brokerage, stock.Action, stock.RatingFrom, stock.RatingTo, stock.Time)
+			}
+			mock.ExpectQuery("INSERT INTO stock_ratings").WillDelayFor(benchmarkRoundTrip).WillReturnRows(rows)
+		}
+		mock.ExpectCommit()
+		b.StartTimer()
+
+		if _, _, err := handler.batchInsertStocksMultiRow(stocks, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestCalculateStockScore_FreshnessBonus validates that the 24-hour freshness bonus is measured from the given time
+func TestCalculateStockScore_FreshnessBonus(t *testing.T) {
+	stock := stockData{Ticker: "AAPL", Action: "reiterated by", RatingFrom: "Hold", RatingTo: "Hold", TargetFrom: "$150.00", TargetTo: "$150.00", Time: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)}
+	history := []stockData{stock}
+	weights := getDefaultWeights()
+
+	fresh := calculateStockScore(stock, history, weights, time.Date(2024, 1, 16, 9, 30, 0, 0, time.UTC)).Total
+	stale := calculateStockScore(stock, history, weights, time.Date(2024, 1, 16, 11, 30, 0, 0, time.UTC)).Total
+
+	assert.InDelta(t, 5.0+0.5*weights.TimingWeight, fresh, 1e-9, "A report 23 hours old gets the freshness bonus")
+	assert.InDelta(t, 5.0, stale, 1e-9, "A report 25 hours old does not")
+}
+
+// TestCalculateStockScore_Breakdown validates that the weighted components add up to the reported total
+func TestCalculateStockScore_Breakdown(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	bullish := stockData{Ticker: "AAPL", Action: "upgraded by", RatingFrom: "Hold", RatingTo: "Strong Buy", TargetFrom: "$150.00", TargetTo: "$190.00", Time: now.Add(-time.Hour)}
+	bearish := stockData{Ticker: "META", Action: "target lowered by", RatingFrom: "Buy", RatingTo: "Sell", TargetFrom: "$300.00", TargetTo: "$250.00", Time: now.AddDate(0, 0, -3)}
+	custom := ScoringWeights{TargetPriceWeight: 0.1, RatingWeight: 0.2, ActionWeight: 0.3, TimingWeight: 0.4}
+
+	for _, weights := range []ScoringWeights{getDefaultWeights(), custom} {
+		for _, stock := range []stockData{bullish, bearish} {
+			b := calculateStockScore(stock, []stockData{stock, stock}, weights, now)
+			assert.Equal(t, 5.0, b.Base)
+			assert.InDelta(t, b.Total, b.Base+b.TargetPrice+b.Rating+b.Action+b.Timing, 1e-9, "%s components should sum to the total", stock.Ticker)
+		}
+	}
+
+	// Each component is its criterion score times the weight
+	b := calculateStockScore(bullish, []stockData{bullish}, custom, now)
+	assert.InDelta(t, 3.0*custom.TargetPriceWeight, b.TargetPrice, 1e-9, "A 26.7% raise scores 3.0")
+	assert.InDelta(t, 3.5*custom.RatingWeight, b.Rating, 1e-9, "Upgrade plus Strong Buy scores 3.5")
+	assert.InDelta(t, 1.5*custom.ActionWeight, b.Action, 1e-9)
+	assert.InDelta(t, 1.0*custom.TimingWeight, b.Timing, 1e-9, "Freshness and upgrade momentum bonuses, no consensus bonus for a single rating")
+	assert.Equal(t, "score 6.85 = base 5.00 +0.30 target price +0.70 rating +0.45 action +0.40 timing", b.explanation())
+
+	bear := calculateStockScore(bearish, []stockData{bearish}, custom, now)
+	assert.Less(t, bear.TargetPrice, 0.0)
+	assert.Less(t, bear.Action, 0.0)
+}
+
+// TestRecommendationMomentum validates that upgrades vs downgrades across the history shape the score and reason
+func TestRecommendationMomentum(t *testing.T) {
+	latest := stockData{Ticker: "NVDA", Action: "upgraded by", RatingFrom: "Hold", RatingTo: "Buy", TargetFrom: "$100.00", TargetTo: "$104.00", Time: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)}
+	history := []stockData{
+		latest,
+		{Ticker: "NVDA", Action: "upgraded by", Time: time.Date(2024, 1, 14, 10, 30, 0, 0, time.UTC)},
+		{Ticker: "NVDA", Action: "Upgrade", Time: time.Date(2024, 1, 13, 10, 30, 0, 0, time.UTC)},
+		{Ticker: "NVDA", Action: "downgraded by", Time: time.Date(2024, 1, 12, 10, 30, 0, 0, time.UTC)},
+		{Ticker: "NVDA", Action: "target raised by", Time: time.Date(2024, 1, 11, 10, 30, 0, 0, time.UTC)},
+	}
+
+	upgrades, downgrades := actionMomentum(history)
+	assert.Equal(t, 3, upgrades)
+	assert.Equal(t, 1, downgrades)
+
+	reason := generateRecommendationReason(latest, history, 4, 6.0)
+	assert.Contains(t, reason, "3 upgrades vs 1 downgrade in recent coverage")
+
+	// Same history with the momentum reversed scores lower by the momentum bonus
+	bearish := []stockData{latest, history[3], {Ticker: "NVDA", Action: "downgraded by"}, history[4], history[1]}
+	weights := getDefaultWeights()
+	assert.InDelta(t, 0.5*weights.TimingWeight, calculateStockScore(latest, history, weights, time.Now()).Total-calculateStockScore(latest, bearish, weights, time.Now()).Total, 1e-9)
+	assert.NotContains(t, generateRecommendationReason(latest, []stockData{latest}, 4, 6.0), "in recent coverage", "A single action is not momentum")
+}
+
 // TestIsRatingImprovement validates rating upgrade detection logic
 // Purpose: Ensures the algorithm correctly identifies when analyst ratings improve
 // Business Logic: Rating improvements are key factors in recommendation scoring
@@ -376,6 +972,65 @@ func TestIsRatingImprovement(t *testing.T) {
 	}
 }
 
+// coverageHistory returns a history of size ratings from distinct brokerages
+func coverageHistory(size int) []stockData {
+	history := make([]stockData, size)
+	for i := range history {
+		history[i] = stockData{Ticker: "AAPL", Brokerage: "Brokerage " + strconv.Itoa(i)}
+	}
+	return history
+}
+
+// TestAnalyzeStocksForRecommendations_TieOrder validates that equal scores are ranked by ticker on every run
+func TestAnalyzeStocksForRecommendations_TieOrder(t *testing.T) {
+	reportTime := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	tied := stockData{Company: "Company", Action: "target raised by", Brokerage: "Goldman Sachs", RatingFrom: "Hold", RatingTo: "Buy", TargetFrom: "$100.00", TargetTo: "$120.00", Time: reportTime}
+	var stocks []stockData
+	for _, ticker := range []string{"NVDA", "AMZN", "MSFT", "AAPL", "GOOG"} {
+		stock := tied
+		stock.Ticker = ticker
+		stocks = append(stocks, stock)
+	}
+	top := tied
+	top.Ticker, top.RatingTo = "ZM", "Strong Buy"
+	stocks = append(stocks, top)
+
+	now := reportTime.Add(48 * time.Hour)
+	for run := 0; run < 20; run++ {
+		recommendations := analyzeStocksForRecommendations(stocks, 0, getDefaultWeights(), 0, now)
+		var tickers []string
+		for _, rec := range recommendations {
+			tickers = append(tickers, rec.Ticker)
+		}
+		assert.Equal(t, []string{"ZM", "AAPL", "AMZN", "GOOG", "MSFT", "NVDA"}, tickers, "run %d", run)
+	}
+
+	// The limit cuts the same tickers every time
+	limited := analyzeStocksForRecommendations(stocks, 3, getDefaultWeights(), 0, now)
+	if assert.Len(t, limited, 3) {
+		assert.Equal(t, "AMZN", limited[2].Ticker)
+		assert.Equal(t, limited[1].Score, limited[2].Score)
+	}
+}
+
+// TestCoverageConfidence validates that confidence grows with analyst coverage and stays within 0-1
+func TestCoverageConfidence(t *testing.T) {
+	one := coverageConfidence(coverageHistory(1))
+	three := coverageConfidence(coverageHistory(3))
+	ten := coverageConfidence(coverageHistory(10))
+
+	assert.Greater(t, three, one)
+	assert.Greater(t, ten, three)
+	assert.Greater(t, one, 0.0)
+	assert.LessOrEqual(t, ten, 1.0)
+	assert.GreaterOrEqual(t, coverageConfidence(coverageHistory(8)), 0.95, "Eight analysts should be close to full confidence")
+
+	// Repeated ratings from the same brokerage count once
+	repeated := append(coverageHistory(1), stockData{Ticker: "AAPL", Brokerage: " brokerage 0"})
+	assert.Equal(t, one, coverageConfidence(repeated))
+	assert.Equal(t, 0.0, coverageConfidence(nil))
+}
+
 func TestIsBuyRating(t *testing.T) {
 	tests := []struct {
 		rating   string
@@ -478,6 +1133,17 @@ func TestExtractTickers(t *testing.T) {
 	}
 }
 
+// TestExtractTickers_Stopwords validates that common uppercase words are not treated as tickers
+func TestExtractTickers_Stopwords(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	assert.Empty(t, handler.extractTickers("WHAT ARE THE BEST"), "Common words should not be tickers")
+	assert.Empty(t, handler.extractTickers("WHAT ARE THE BEST BUY STOCKS"), "Rating words should not be tickers")
+	assert.Equal(t, []string{"AAPL", "MSFT"}, handler.extractTickers("AAPL MSFT"))
+	assert.Equal(t, []string{"AAPL"}, handler.extractTickers("Show me AAPL ratings"))
+}
+
 // TestExtractKeyTopics validates semantic topic extraction for conversation memory
 // Purpose: Tests the AI system's ability to identify themes and concepts in user queries
 // Memory System: Enables intelligent context caching and conversation continuity
@@ -511,6 +1177,49 @@ func TestExtractKeyTopics(t *testing.T) {
 	}
 }
 
+// TestExtractKeyTopics_SemanticOnly validates topic extraction when the message has no tickers
+func TestExtractKeyTopics_SemanticOnly(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var result []string
+	assert.NotPanics(t, func() {
+		result = handler.extractKeyTopics("which price targets were raised?")
+	})
+	assert.Contains(t, result, "target_prices")
+	assert.Contains(t, result, "analyst_actions")
+}
+
+// TestGenerateConversationSummary_MultibyteInput validates that truncation never splits a UTF-8 rune
+func TestGenerateConversationSummary_MultibyteInput(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	message := strings.Repeat("é", 40) + strings.Repeat("📈", 40)
+	summary := handler.generateConversationSummary(message, "", "")
+	assert.True(t, utf8.ValidString(summary), "First summary should be valid UTF-8")
+	assert.Equal(t, "User asked about: "+strings.Repeat("é", 40)+strings.Repeat("📈", 10), summary)
+
+	previous := strings.Repeat("ü", 150)
+	summary = handler.generateConversationSummary(message, "", previous)
+	assert.True(t, utf8.ValidString(summary), "Appended summary should be valid UTF-8")
+	assert.Equal(t, strings.Repeat("ü", 100)+"; Latest: "+strings.Repeat("é", 30), summary)
+}
+
+// TestUpdateConversationMemory_MultibyteInput validates the memory update path with emoji and accented text
+func TestUpdateConversationMemory_MultibyteInput(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	memory := &ConversationMemory{Summary: strings.Repeat("🚀", 60)}
+	var updated *ConversationMemory
+	assert.NotPanics(t, func() {
+		updated = handler.updateConversationMemory("¿Qué rating tiene? 📉", "", "", memory)
+	})
+	assert.True(t, utf8.ValidString(updated.Summary))
+	assert.Contains(t, updated.KeyTopics, "ratings")
+}
+
 // UTILITY FUNCTION TESTS
 // These tests validate helper functions used throughout the application
 
@@ -527,4 +1236,4056 @@ func TestContains(t *testing.T) {
 	// Test negative cases - items that should not be found
 	assert.False(t, contains(slice, "grape"), "Should not find 'grape' in slice")
 	assert.False(t, contains(slice, ""), "Should not find empty string in slice")
-}
\ No newline at end of file
+}
+
+// BULK FETCH CANCELLATION TESTS
+// These tests validate that bulk fetches stop calling the external API once the client disconnects
+
+// countingTransport stubs the external API and counts every outgoing request
+type countingTransport struct {
+	calls  int32
+	onCall func()
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.calls, 1)
+	if t.onCall != nil {
+		t.onCall()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"items":[],"next_page":""}`)),
+		Request:    req,
+	}, nil
+}
+
+// stubExternalTransport sends the handler's external API requests through transport
+func stubExternalTransport(handler *StockHandler, transport http.RoundTripper) {
+	handler.externalClient.Transport = transport
+}
+
+// TestGetStocksBulk_CancelledContext validates that a disconnected client stops the bulk fetch
+// Purpose: Ensures no external API calls are made once the request context is cancelled
+func TestGetStocksBulk_CancelledContext(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	transport := &countingTransport{}
+	stubExternalTransport(handler, transport)
+
+	mock.ExpectExec("DELETE FROM stock_ratings").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/bulk", handler.GetStocksBulk)
+
+	// Client has already gone away by the time the fetch starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	reqBody := models.BulkPageRequest{StartPage: 1, EndPage: 500, ConfirmClear: true}
+	jsonBody, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/stocks/bulk?wait=true", bytes.NewBuffer(jsonBody)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&transport.calls), "No API calls should happen after cancellation")
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, true, response["cancelled"])
+	assert.Equal(t, float64(0), response["total_stocks"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestFetchStocksBulkParallel_CancelMidway validates that cancellation stops launching new page fetches
+// Purpose: Only requests already in flight when the client disconnects may still reach the API
+func TestFetchStocksBulkParallel_CancelMidway(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel as soon as the first request reaches the external API
+	transport := &countingTransport{onCall: cancel}
+	stubExternalTransport(handler, transport)
+
+	result, err := handler.fetchStocksBulkParallel(ctx, 1, 5000, handler.bulkSettings)
+
+	assert.NoError(t, err)
+	assert.True(t, result.Cancelled, "Fetch should report cancellation")
+	assert.Equal(t, 0, result.TotalFetched)
+	assert.Empty(t, result.Stocks)
+	// Each of the concurrent workers can have at most one request in flight when cancel fires
+	assert.LessOrEqual(t, int(atomic.LoadInt32(&transport.calls)), 30, "Cancelled fetch should not keep calling the API")
+
+	// Nothing may reach the API once the fetch has returned
+	callsAfterReturn := atomic.LoadInt32(&transport.calls)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, callsAfterReturn, atomic.LoadInt32(&transport.calls))
+}
+
+// EXTERNAL API CONFIGURATION TESTS
+
+// TestNewStockHandler_BaseURL validates the external API URL configuration
+// Purpose: Ensures EXTERNAL_API_URL overrides the default endpoint and falls back when unset
+func TestNewStockHandler_BaseURL(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	t.Setenv("EXTERNAL_API_URL", "")
+	assert.Equal(t, defaultExternalAPIURL, NewStockHandler(db).baseURL, "Default URL should be used when unset")
+
+	t.Setenv("EXTERNAL_API_URL", "http://localhost:9000/list")
+	assert.Equal(t, "http://localhost:9000/list", NewStockHandler(db).baseURL, "EXTERNAL_API_URL should override the default")
+}
+
+// TestGetStocksByPage_CustomBaseURL validates that single page fetches hit the configured endpoint
+// Purpose: Allows integration suites to redirect external API calls to a mock server
+func TestGetStocksByPage_CustomBaseURL(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	var requestedPage string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPage = r.URL.Query().Get("next_page")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"ticker":"AAPL","company":"Apple Inc.","target_from":"$150.00","target_to":"$180.00","action":"target raised by","brokerage":"Goldman Sachs","rating_from":"Hold","rating_to":"Buy","time":"2025-01-15T10:30:00Z"}],"next_page":"AAPL"}`))
+	}))
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	mock.ExpectExec("INSERT INTO stock_ratings").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks", handler.GetStocksByPage)
+
+	reqBody := models.PageRequest{Page: 7}
+	jsonBody, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/stocks", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "7", requestedPage, "Mock server should receive the requested page")
+
+	var response models.ApiResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.Items, 1)
+	assert.Equal(t, "AAPL", response.Items[0].Ticker)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// newSlowMockAPI answers after delay, or gives up when the client disconnects first, counting the requests
+func newSlowMockAPI(delay time.Duration, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		select {
+		case <-time.After(delay):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"items":[],"next_page":""}`))
+		case <-r.Context().Done():
+		}
+	}))
+}
+
+// TestExternalAPITimeout_GetStocksByPage validates that EXTERNAL_API_TIMEOUT_MS cuts a slow external API off with 504
+func TestExternalAPITimeout_GetStocksByPage(t *testing.T) {
+	t.Setenv("EXTERNAL_API_TIMEOUT_MS", "50")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	var requests int32
+	server := newSlowMockAPI(2*time.Second, &requests)
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks", handler.GetStocksByPage)
+
+	req := httptest.NewRequest("POST", "/stocks", bytes.NewBufferString(`{"page": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	start := time.Now()
+	router.ServeHTTP(w, req)
+
+	assert.Less(t, time.Since(start), time.Second, "The request should give up after the configured 50ms")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "External API did not respond within 50ms")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestExternalAPITimeout_BulkFetchRetry validates that bulk page fetches share the configured timeout
+func TestExternalAPITimeout_BulkFetchRetry(t *testing.T) {
+	t.Setenv("EXTERNAL_API_TIMEOUT_MS", "50")
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var requests int32
+	server := newSlowMockAPI(2*time.Second, &requests)
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	start := time.Now()
+	stocks, err := handler.fetchStocksFromAPIWithRetry(context.Background(), 1, 1)
+
+	// Exhausted retries report the failure instead of an empty page
+	assert.ErrorContains(t, err, "external API failed after 1 attempts")
+	assert.Empty(t, stocks)
+	assert.Less(t, time.Since(start), 200*time.Millisecond, "The attempt should time out after 50ms without a backoff after it")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+// TestExternalAPITimeoutFromEnv validates the EXTERNAL_API_TIMEOUT_MS default and override
+func TestExternalAPITimeoutFromEnv(t *testing.T) {
+	t.Setenv("EXTERNAL_API_TIMEOUT_MS", "")
+	assert.Equal(t, 20*time.Second, externalAPITimeoutFromEnv())
+
+	t.Setenv("EXTERNAL_API_TIMEOUT_MS", "0")
+	assert.Equal(t, 20*time.Second, externalAPITimeoutFromEnv(), "0 would fail every request")
+
+	t.Setenv("EXTERNAL_API_TIMEOUT_MS", "45000")
+	assert.Equal(t, 45*time.Second, externalAPITimeoutFromEnv())
+	assert.Equal(t, 45*time.Second, NewStockHandler(nil).externalClient.Timeout)
+}
+
+// TestGetStocksByPage_CountsNewAndDuplicates validates that the response tells new items from already stored ones
+func TestGetStocksByPage_CountsNewAndDuplicates(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"ticker":"AAPL","company":"Apple Inc.","target_from":"$150.00","target_to":"$180.00","action":"target raised by","brokerage":"Goldman Sachs","rating_from":"Hold","rating_to":"Buy","time":"2025-01-15T10:30:00Z"},{"ticker":"MSFT","company":"Microsoft","target_from":"$300.00","target_to":"$350.00","action":"upgraded by","brokerage":"Morgan Stanley","rating_from":"Hold","rating_to":"Buy","time":"2025-01-15T11:00:00Z"}],"next_page":"MSFT"}`))
+	}))
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	mock.ExpectExec("INSERT INTO stock_ratings").WithArgs("AAPL", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO stock_ratings").WithArgs("MSFT", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w := serve("POST", "/stocks", "/stocks", handler.GetStocksByPage, bytes.NewBufferString(`{"page": 1}`))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response PageStoreResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Items, 2)
+	assert.Equal(t, "MSFT", response.NextPage)
+	assert.Equal(t, 1, response.NewCount)
+	assert.Equal(t, 1, response.DuplicateCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// RETRY AND BACKOFF TESTS
+
+// TestFetchStocksFromAPIWithRetry_Backoff validates exponential backoff on transient failures
+// Purpose: Ensures 503 responses are retried on the same page after increasing delays
+// instead of burning every attempt within a few milliseconds
+func TestFetchStocksFromAPIWithRetry_Backoff(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var calls int32
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.Query().Get("next_page"))
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"ticker":"AAPL","company":"Apple Inc."}],"next_page":""}`))
+	}))
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	start := time.Now()
+	stocks, err := handler.fetchStocksFromAPIWithRetry(context.Background(), 3, 5)
+	elapsed := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.Len(t, stocks, 1)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "Should succeed on the third attempt")
+	assert.Equal(t, []string{"3", "3", "3"}, pages, "Transient failures should retry the same page")
+	// Two backoffs: 200ms + 400ms, each with up to 50% jitter
+	assert.GreaterOrEqual(t, elapsed, 600*time.Millisecond, "Elapsed time should reflect the backoff delays")
+	assert.Less(t, elapsed, 2*time.Second, "Backoff should not exceed the expected delays plus jitter")
+}
+
+// TestFetchStocksFromAPIWithRetry_PageWalk validates the "no data found" fallback
+// Purpose: Empty pages should be retried immediately on the next page of the prime-number pattern
+func TestFetchStocksFromAPIWithRetry_PageWalk(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.Query().Get("next_page"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[],"next_page":""}`))
+	}))
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	start := time.Now()
+	stocks, err := handler.fetchStocksFromAPIWithRetry(context.Background(), 1, 3)
+
+	assert.NoError(t, err)
+	assert.Empty(t, stocks)
+	assert.Equal(t, []string{"1", "14", "27"}, pages, "Empty pages should walk by 13")
+	assert.Less(t, time.Since(start), 200*time.Millisecond, "Empty pages should not back off")
+}
+
+// TestBackoffDelay validates the exponential growth and cap of retry delays
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		retry int
+		min   time.Duration
+	}{
+		{0, 200 * time.Millisecond},
+		{1, 400 * time.Millisecond},
+		{2, 800 * time.Millisecond},
+		{10, 5 * time.Second},
+	}
+
+	for _, test := range tests {
+		delay := backoffDelay(test.retry)
+		assert.GreaterOrEqual(t, delay, test.min, "retry %d", test.retry)
+		assert.LessOrEqual(t, delay, min(test.min+test.min/2, retryMaxDelay), "retry %d should add at most 50%% jitter", test.retry)
+	}
+
+	// Jitter is applied before the cap, so no delay ever exceeds it
+	for i := 0; i < 100; i++ {
+		assert.LessOrEqual(t, backoffDelay(4), retryMaxDelay)
+	}
+}
+
+// TestFetchStocksFromAPIWithRetry_ExhaustedRetries validates that a page failing every attempt returns the last error
+// Purpose: The caller must not mistake an outage for a page without data, and no backoff follows the last attempt
+func TestFetchStocksFromAPIWithRetry_ExhaustedRetries(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	start := time.Now()
+	stocks, err := handler.fetchStocksFromAPIWithRetry(context.Background(), 1, 2)
+	elapsed := time.Since(start)
+
+	assert.EqualError(t, err, "external API failed after 2 attempts: external API returned status 429")
+	assert.Nil(t, stocks)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	// Only the backoff between the two attempts: 200ms plus up to 50% jitter
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+	assert.Less(t, elapsed, 400*time.Millisecond, "No backoff should follow the last attempt")
+}
+
+// BULK RESPONSE TESTS
+
+// newBulkMockAPI serves the same two stock ratings for every page, so pages overlap completely
+func newBulkMockAPI() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[
+			{"ticker":"AAPL","company":"Apple Inc.","target_from":"$150.00","target_to":"$180.00","action":"target raised by","brokerage":"Goldman Sachs","rating_from":"Hold","rating_to":"Buy","time":"2025-01-15T10:30:00Z"},
+			{"ticker":"MSFT","company":"Microsoft","target_from":"$300.00","target_to":"$350.00","action":"upgraded by","brokerage":"Morgan Stanley","rating_from":"Hold","rating_to":"Buy","time":"2025-01-15T11:00:00Z"}
+		],"next_page":""}`))
+	}))
+}
+
+// expectBulkInsert mocks a single batch transaction inserting the given number of rows
+func expectBulkInsert(mock sqlmock.Sqlmock, rows int) {
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare("INSERT INTO stock_ratings")
+	for i := 0; i < rows; i++ {
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+}
+
+// expectBulkInsertResults mocks a single batch transaction whose inserts affect the given number of rows each,
+// 0 meaning the row conflicted with a stored one
+func expectBulkInsertResults(mock sqlmock.Sqlmock, rowsAffected ...int64) {
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare("INSERT INTO stock_ratings")
+	for _, affected := range rowsAffected {
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, affected))
+	}
+	mock.ExpectCommit()
+}
+
+// bulkMockStocks are the unique keys of the two stocks newBulkMockAPI serves
+var bulkMockStocks = []models.StockRatings{
+	{Ticker: "AAPL", Brokerage: "Goldman Sachs", Action: "target raised by", RatingFrom: "Hold", RatingTo: "Buy", Time: time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)},
+	{Ticker: "MSFT", Brokerage: "Morgan Stanley", Action: "upgraded by", RatingFrom: "Hold", RatingTo: "Buy", Time: time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+}
+
+// expectMultiRowInsert mocks a single multi-row batch transaction whose INSERT returns the given stocks as inserted,
+// stocks that are not returned conflicted with stored ones
+func expectMultiRowInsert(mock sqlmock.Sqlmock, inserted ...models.StockRatings) {
+	rows := sqlmock.NewRows([]string{"ticker", "brokerage", "action", "rating_from", "rating_to", "time"})
+	for _, stock := range inserted {
+		rows.AddRow(stock.Ticker, stock.Brokerage, stock.Action, stock.RatingFrom, stock.RatingTo, stock.Time)
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO stock_ratings .* RETURNING").WillReturnRows(rows)
+	mock.ExpectCommit()
+}
+
+// captureLogs points the handler logger at an in-memory JSON handler and returns a function decoding the records
+func captureLogs(handler *StockHandler, level slog.Level) func() []map[string]interface{} {
+	var buf bytes.Buffer
+	handler.logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level}))
+	return func() []map[string]interface{} {
+		var records []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record map[string]interface{}
+			if json.Unmarshal([]byte(line), &record) == nil {
+				records = append(records, record)
+			}
+		}
+		return records
+	}
+}
+
+// TestBatchInsert_LogsCommittedBatch validates the structured debug record written after a batch commit
+func TestBatchInsert_LogsCommittedBatch(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	records := captureLogs(handler, slog.LevelDebug)
+
+	stocks := []models.StockRatings{{Ticker: "AAPL"}, {Ticker: "MSFT"}, {Ticker: "NVDA"}}
+	expectBulkInsert(mock, len(stocks))
+
+	inserted, skipped, err := handler.batchInsertStocksWithLogging(stocks, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, inserted)
+	assert.Equal(t, 0, skipped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var committed map[string]interface{}
+	for _, record := range records() {
+		if record["msg"] == "batch committed" {
+			committed = record
+		}
+	}
+	if assert.NotNil(t, committed, "A batch committed record should be logged") {
+		assert.Equal(t, "DEBUG", committed["level"])
+		assert.Equal(t, float64(4), committed["batch"])
+		assert.Equal(t, float64(3), committed["count"])
+	}
+}
+
+// TestBatchInsert_QuietAtInfo validates that per-batch records are not written at the info level
+func TestBatchInsert_QuietAtInfo(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	records := captureLogs(handler, slog.LevelInfo)
+
+	expectBulkInsert(mock, 1)
+
+	_, _, err := handler.batchInsertStocksWithLogging([]models.StockRatings{{Ticker: "AAPL"}}, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, records())
+}
+
+// TestGetStocksBulk_ReturnsStocks validates that bulk fetches return the deduplicated stocks
+// Purpose: Clients fetching small ranges should get the rows back, not an empty list
+func TestGetStocksBulk_ReturnsStocks(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	server := newBulkMockAPI()
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	mock.ExpectExec("DELETE FROM stock_ratings").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+	// Both pages return the same 2 stocks, so the buffer is deduplicated before inserting
+	expectMultiRowInsert(mock, bulkMockStocks...)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/bulk", handler.GetStocksBulk)
+
+	reqBody := models.BulkPageRequest{StartPage: 1, EndPage: 2, ConfirmClear: true}
+	jsonBody, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/stocks/bulk?wait=true", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.BulkResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 4, response.TotalStocks, "Total should count every fetched stock")
+	assert.Len(t, response.Stocks, 2, "Duplicate stocks across pages should be returned once")
+	assert.False(t, response.Truncated)
+	assert.True(t, response.Cleared, "Confirmed bulk fetches should report the table as cleared")
+	assert.Equal(t, 2, response.DuplicatesDropped, "In-memory duplicates should be dropped before inserting")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDedupeStocks validates that exact duplicates are dropped while order and distinct stocks are kept
+func TestDedupeStocks(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	apple := models.StockRatings{Ticker: "AAPL", Brokerage: "Goldman Sachs", Action: "upgraded by", RatingFrom: "Hold", RatingTo: "Buy", Time: now}
+	tesla := models.StockRatings{Ticker: "TSLA", Brokerage: "Morgan Stanley", Action: "downgraded by", RatingFrom: "Buy", RatingTo: "Hold", Time: now}
+	// Same key as apple but a later time, so it's a distinct rating
+	appleLater := apple
+	appleLater.Time = now.Add(time.Hour)
+
+	unique, dropped := dedupeStocks([]models.StockRatings{apple, tesla, apple, appleLater, tesla, apple})
+
+	assert.Equal(t, 3, dropped)
+	assert.Equal(t, []models.StockRatings{apple, tesla, appleLater}, unique)
+}
+
+// TestDedupeStocks_Empty validates that an empty buffer is handled
+func TestDedupeStocks_Empty(t *testing.T) {
+	unique, dropped := dedupeStocks(nil)
+
+	assert.Empty(t, unique)
+	assert.Equal(t, 0, dropped)
+}
+
+// TestFetchStocksBulkParallel_ConfiguredBatchSize validates that inserts are triggered at the configured batch size
+func TestFetchStocksBulkParallel_ConfiguredBatchSize(t *testing.T) {
+	t.Setenv("BULK_BATCH_SIZE", "2")
+	t.Setenv("BULK_MAX_CONCURRENT", "1")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	server := newBulkMockAPI()
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	// Every page returns 2 stocks, so each page fills exactly one batch
+	for i := 0; i < 3; i++ {
+		expectMultiRowInsert(mock, bulkMockStocks...)
+	}
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	assert.Equal(t, bulkFetchSettings{BatchSize: 2, MaxConcurrent: 1}, handler.bulkSettings)
+	result, err := handler.fetchStocksBulkParallel(context.Background(), 1, 3, handler.bulkSettings)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 6, result.TotalFetched)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestFetchStocksBulkParallel_AggregatesInsertCounts validates that the summary adds up the per-batch insert results
+// Purpose: Inserted and duplicate totals come from the inserts themselves, not from comparing against the table count
+func TestFetchStocksBulkParallel_AggregatesInsertCounts(t *testing.T) {
+	t.Setenv("BULK_BATCH_SIZE", "2")
+	t.Setenv("BULK_MAX_CONCURRENT", "1")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	server := newBulkMockAPI()
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	// One batch per page: new rows, already stored rows, then a mix
+	expectMultiRowInsert(mock, bulkMockStocks...)
+	expectMultiRowInsert(mock)
+	expectMultiRowInsert(mock, bulkMockStocks[0])
+	// The table count is unrelated to this run's totals, e.g. rows kept from earlier fetches
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(50))
+
+	result, err := handler.fetchStocksBulkParallel(context.Background(), 1, 3, handler.bulkSettings)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 6, result.TotalFetched)
+	assert.Equal(t, 3, result.TotalInserted)
+	assert.Equal(t, 3, result.TotalDuplicates)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStocksBulk_InsertedAndSkippedSplit validates that the response tells new rows from skipped duplicates
+// Purpose: Clients learn how many rows actually landed when part of the range was already stored
+func TestGetStocksBulk_InsertedAndSkippedSplit(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	server := newBulkMockAPI()
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	// Pages 1-2 return the same 2 stocks: 2 are dropped in memory and MSFT conflicts with a stored row
+	expectMultiRowInsert(mock, bulkMockStocks[0])
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(30))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/bulk", handler.GetStocksBulk)
+
+	req := httptest.NewRequest("POST", "/stocks/bulk?wait=true", bytes.NewBufferString(`{"start_page": 1, "end_page": 2}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.BulkResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 4, response.TotalStocks)
+	assert.Equal(t, 1, response.Inserted)
+	assert.Equal(t, 3, response.DuplicatesSkipped)
+	assert.Equal(t, response.TotalStocks, response.Inserted+response.DuplicatesSkipped)
+	assert.Equal(t, 1, response.TotalInserted)
+	assert.Equal(t, 1, response.TotalDuplicates)
+	assert.Equal(t, 2, response.DuplicatesDropped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestFetchStocksBulkParallel_DeadlineReturnsPartialResult validates that the bulk deadline stops fetching,
+// stores what was already fetched and reports a timed out result
+func TestFetchStocksBulkParallel_DeadlineReturnsPartialResult(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	// Each page takes 300ms, so only the first page finishes before the 450ms deadline
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[
+			{"ticker":"AAPL","company":"Apple Inc.","target_from":"$150.00","target_to":"$180.00","action":"target raised by","brokerage":"Goldman Sachs","rating_from":"Hold","rating_to":"Buy","time":"2025-01-15T10:30:00Z"},
+			{"ticker":"MSFT","company":"Microsoft","target_from":"$300.00","target_to":"$350.00","action":"upgraded by","brokerage":"Morgan Stanley","rating_from":"Hold","rating_to":"Buy","time":"2025-01-15T11:00:00Z"}
+		],"next_page":""}`))
+	}))
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	// The buffered stocks of the finished page are flushed, no verification count query runs
+	expectMultiRowInsert(mock, bulkMockStocks...)
+
+	settings := bulkFetchSettings{BatchSize: 1000, MaxConcurrent: 1, Deadline: 450 * time.Millisecond}
+	start := time.Now()
+	result, err := handler.fetchStocksBulkParallel(context.Background(), 1, 100, settings)
+	elapsed := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.True(t, result.TimedOut)
+	assert.False(t, result.Cancelled)
+	assert.Equal(t, 1, result.PagesProcessed)
+	assert.Equal(t, 2, result.TotalFetched)
+	assert.Less(t, elapsed, 2*time.Second, "Workers should stop promptly once the deadline fires")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBulkFetchSettings_WithDeadline validates that a request deadline can only shorten the configured one
+func TestBulkFetchSettings_WithDeadline(t *testing.T) {
+	unlimited := bulkFetchSettings{BatchSize: 1000, MaxConcurrent: 30}
+	assert.Equal(t, 60*time.Second, unlimited.withDeadline(60).Deadline)
+	assert.Equal(t, time.Duration(0), unlimited.withDeadline(0).Deadline)
+
+	configured := bulkFetchSettings{BatchSize: 1000, MaxConcurrent: 30, Deadline: 120 * time.Second}
+	assert.Equal(t, 30*time.Second, configured.withDeadline(30).Deadline)
+	assert.Equal(t, 120*time.Second, configured.withDeadline(600).Deadline)
+}
+
+// TestBulkSettingsFromEnv validates defaults and clamping of BULK_BATCH_SIZE and BULK_MAX_CONCURRENT
+func TestBulkSettingsFromEnv(t *testing.T) {
+	assert.Equal(t, bulkFetchSettings{BatchSize: 1000, MaxConcurrent: 30}, bulkSettingsFromEnv())
+
+	t.Setenv("BULK_BATCH_SIZE", "50000")
+	t.Setenv("BULK_MAX_CONCURRENT", "0")
+	assert.Equal(t, bulkFetchSettings{BatchSize: maxBulkBatchSize, MaxConcurrent: minBulkMaxConcurrent}, bulkSettingsFromEnv())
+}
+
+// TestBulkFetchSettings_WithOverrides validates that per-request values can only lower the configured maxima
+func TestBulkFetchSettings_WithOverrides(t *testing.T) {
+	configured := bulkFetchSettings{BatchSize: 1000, MaxConcurrent: 30}
+
+	assert.Equal(t, bulkFetchSettings{BatchSize: 100, MaxConcurrent: 5}, configured.withOverrides(100, 5))
+	assert.Equal(t, configured, configured.withOverrides(5000, 100), "Overrides above the configured values are clamped")
+	assert.Equal(t, configured, configured.withOverrides(0, -1), "Unset overrides keep the configured values")
+}
+
+// TestGetStocksBulk_WithoutConfirmClearKeepsData validates that no DELETE is issued unless confirm_clear is true
+// Purpose: A mistyped page range must only add ratings, never wipe the existing dataset
+func TestGetStocksBulk_WithoutConfirmClearKeepsData(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	server := newBulkMockAPI()
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	// No DELETE expectation: sqlmock fails the insert if a DELETE arrives first
+	expectMultiRowInsert(mock, bulkMockStocks...)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(120))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/bulk", handler.GetStocksBulk)
+
+	req := httptest.NewRequest("POST", "/stocks/bulk?wait=true", bytes.NewBufferString(`{"start_page": 1, "end_page": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.BulkResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.False(t, response.Cleared)
+	assert.Equal(t, 2, response.TotalStocks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// performBulkJob starts a bulk fetch without wait and returns the response
+func performBulkJob(handler *StockHandler, body string) *httptest.ResponseRecorder {
+	return serve("POST", "/stocks/bulk", "/stocks/bulk", handler.GetStocksBulk, bytes.NewBufferString(body))
+}
+
+// getBulkJobStatus performs GET /stocks/bulk/:job_id/status and decodes the response
+func getBulkJobStatus(t *testing.T, handler *StockHandler, jobID string) (int, models.BulkJobStatusResponse) {
+	w := serve("GET", "/stocks/bulk/:job_id/status", "/stocks/bulk/"+jobID+"/status", handler.GetBulkJobStatus, nil)
+
+	var status models.BulkJobStatusResponse
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	}
+	return w.Code, status
+}
+
+// TestGetStocksBulk_JobStatusTransitions validates that a background bulk job goes from running to done
+// Purpose: Clients get a job_id right away and can follow progress until the result is available
+func TestGetStocksBulk_JobStatusTransitions(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	progressed := make(chan struct{})
+	release := make(chan struct{})
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		settings.OnProgress(3, 2)
+		close(progressed)
+		<-release
+		settings.OnProgress(10, 8)
+		return bulkFetchResult{TotalFetched: 16, PagesProcessed: 10}, nil
+	}
+
+	w := performBulkJob(handler, `{"start_page": 1, "end_page": 10}`)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var started models.BulkJobResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &started))
+	assert.NotEmpty(t, started.JobID)
+	assert.Equal(t, "running", started.Status)
+	assert.Equal(t, "/api/stocks/bulk/"+started.JobID+"/status", started.StatusURL)
+
+	<-progressed
+	code, status := getBulkJobStatus(t, handler, started.JobID)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "running", status.Status)
+	assert.Equal(t, 3, status.PagesProcessed)
+	assert.Equal(t, 10, status.PagesTotal)
+	assert.Equal(t, 2, status.PagesWithData)
+	assert.Nil(t, status.Result)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		_, status = getBulkJobStatus(t, handler, started.JobID)
+		return status.Status == "done"
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 10, status.PagesProcessed)
+	assert.Equal(t, 8, status.PagesWithData)
+	if assert.NotNil(t, status.Result) {
+		assert.Equal(t, 16, status.Result.TotalStocks)
+		assert.Equal(t, "1-10", status.Result.PagesFetched)
+	}
+	assert.Empty(t, status.Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStocksBulk_JobStatusError validates that a failed background bulk job reports the error
+func TestGetStocksBulk_JobStatusError(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	handler.bulkFetch = func(ctx context.Context, startPage, endPage int, settings bulkFetchSettings) (bulkFetchResult, error) {
+		settings.OnProgress(1, 0)
+		return bulkFetchResult{}, errors.New("failed to fetch page 2: boom")
+	}
+
+	w := performBulkJob(handler, `{"start_page": 1, "end_page": 5}`)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var started models.BulkJobResponse
+	json.Unmarshal(w.Body.Bytes(), &started)
+
+	var status models.BulkJobStatusResponse
+	assert.Eventually(t, func() bool {
+		_, status = getBulkJobStatus(t, handler, started.JobID)
+		return status.Status == "error"
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "failed to fetch page 2: boom", status.Error)
+	assert.Equal(t, 1, status.PagesProcessed)
+	assert.Nil(t, status.Result)
+	assert.NoError(t, mock.ExpectationsWereMet(), "batches stored before the failure must not leave the cache stale")
+}
+
+// TestGetStocksBulk_InvalidWait validates that wait must be a boolean
+func TestGetStocksBulk_InvalidWait(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	w := serve("POST", "/stocks/bulk", "/stocks/bulk?wait=soon", handler.GetStocksBulk, bytes.NewBufferString(`{"start_page": 1, "end_page": 1}`))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "wait must be true or false")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// performImport posts body to /stocks/import with the given query string
+func performImport(handler *StockHandler, query, body string) *httptest.ResponseRecorder {
+	return serve("POST", "/stocks/import", "/stocks/import"+query, handler.ImportStocks, bytes.NewBufferString(body))
+}
+
+// TestImportStocks_ValidBatch validates that a clean payload is stored and counted, repeats included
+func TestImportStocks_ValidBatch(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.summaryCache.Set(SummaryResponse{Summary: "Outdated"})
+	handler.metricsCache.Set(models.MetricsData{TotalRecords: 1})
+
+	// AAPL is sent twice and dropped once in memory, MSFT conflicts with a stored rating
+	expectMultiRowInsert(mock, bulkMockStocks[0])
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := performImport(handler, "", `{"items": [
+		{"ticker": "AAPL", "company": "Apple Inc.", "target_from": "$150.00", "target_to": "180", "action": "target raised by", "brokerage": "Goldman Sachs", "rating_from": "Hold", "rating_to": "Buy", "time": "2025-01-15T10:30:00Z"},
+		{"ticker": "MSFT", "company": "Microsoft", "target_from": "300", "target_to": "$350.00", "action": "upgraded by", "brokerage": "Morgan Stanley", "rating_from": "Hold", "rating_to": "Buy", "time": "2025-01-15T11:00:00Z"},
+		{"ticker": "AAPL", "company": "Apple Inc.", "target_from": "$150.00", "target_to": "180", "action": "target raised by", "brokerage": "Goldman Sachs", "rating_from": "Hold", "rating_to": "Buy", "time": "2025-01-15T10:30:00Z"}
+	]}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ImportResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, ImportResponse{Inserted: 1, Skipped: 2, Invalid: 0}, response)
+	_, _, cached := handler.summaryCache.Get()
+	assert.False(t, cached, "New ratings should invalidate the cached summary")
+	_, _, cached = handler.metricsCache.Get()
+	assert.False(t, cached, "New ratings should invalidate the cached metrics")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// importWithInvalidItem has a valid AAPL item and a MSFT item with an unparseable price
+const importWithInvalidItem = `{"items": [
+	{"ticker": "AAPL", "company": "Apple Inc.", "target_from": "$150.00", "target_to": "$180.00", "action": "target raised by", "brokerage": "Goldman Sachs", "rating_from": "Hold", "rating_to": "Buy", "time": "2025-01-15T10:30:00Z"},
+	{"ticker": "MSFT", "company": "Microsoft", "target_from": "$300.00", "target_to": "N/A"}
+]}`
+
+// TestImportStocks_StrictRejectsInvalidItem validates that one bad item rejects the whole request with its details
+func TestImportStocks_StrictRejectsInvalidItem(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	w := performImport(handler, "", importWithInvalidItem)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response struct {
+		Error   string            `json:"error"`
+		Invalid int               `json:"invalid"`
+		Errors  []ImportItemError `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "1 of 2 items are invalid, nothing was imported", response.Error)
+	assert.Equal(t, 1, response.Invalid)
+	assert.Equal(t, []ImportItemError{{Index: 1, Ticker: "MSFT", Error: "target_to must be a non-negative number"}}, response.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Nothing should be inserted")
+}
+
+// TestImportStocks_PartialStoresValidItems validates that partial=true stores the valid items and reports the rest
+func TestImportStocks_PartialStoresValidItems(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	expectMultiRowInsert(mock, bulkMockStocks[0])
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := performImport(handler, "?partial=true", importWithInvalidItem)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ImportResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Inserted)
+	assert.Equal(t, 0, response.Skipped)
+	assert.Equal(t, 1, response.Invalid)
+	assert.Equal(t, []ImportItemError{{Index: 1, Ticker: "MSFT", Error: "target_to must be a non-negative number"}}, response.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestImportStocks_InvalidRequest validates the request-level checks made before any item is looked at
+func TestImportStocks_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		query    string
+		body     string
+		expected string
+	}{
+		{"?partial=maybe", `{"items": []}`, "partial must be true or false"},
+		{"", `{}`, "Missing required field 'items'"},
+		{"", `{"items": []}`, "items must contain at least one rating"},
+		{"", `{"items": [{"ticker": "AAPL"}]}`, "company is required"},
+		{"", `{"items": [{"company": "Apple Inc."}]}`, "ticker is required"},
+		{"", `{"items": [{"ticker": "AAPL", "company": "Apple Inc.", "target_to": "$180.00"}]}`, "target_from must be a non-negative number"},
+		{"", `{"items": [{"ticker": "AAPL", "company": "Apple Inc.", "target_from": "$150.00", "target_to": " "}]}`, "target_to must be a non-negative number"},
+	}
+
+	for _, test := range tests {
+		handler, mock, db := setupTestHandler()
+
+		w := performImport(handler, test.query, test.body)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, test.body)
+		assert.Contains(t, w.Body.String(), test.expected)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	}
+}
+
+// TestGetBulkJobStatus_NotFound validates the 404 for unknown job IDs
+func TestGetBulkJobStatus_NotFound(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	code, _ := getBulkJobStatus(t, handler, "does-not-exist")
+
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+// TestFetchStocksBulkParallel_Truncated validates the response cap on returned stocks
+// Purpose: Large ranges must not produce huge payloads, the cap is reported via Truncated
+func TestFetchStocksBulkParallel_Truncated(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	server := newBulkMockAPI()
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+	handler.bulkResponseLimit = 1
+
+	expectMultiRowInsert(mock, bulkMockStocks...)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	result, err := handler.fetchStocksBulkParallel(context.Background(), 1, 1, handler.bulkSettings)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TotalFetched)
+	assert.Len(t, result.Stocks, 1, "Returned stocks should be capped")
+	assert.True(t, result.Truncated, "Truncated should be set when the cap is hit")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// DELETE BY TICKER TESTS
+
+func performDeleteByTicker(handler *StockHandler, ticker string) *httptest.ResponseRecorder {
+	return serve("DELETE", "/stocks/:ticker", "/stocks/"+ticker, handler.DeleteStockByTicker, nil)
+}
+
+// TestDeleteStockByTicker_Matched validates deleting every rating for a ticker
+// Purpose: Lowercase tickers are normalized and the deleted row count is returned
+func TestDeleteStockByTicker_Matched(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM stock_ratings WHERE UPPER\\(ticker\\) = \\$1").
+		WithArgs("AAPL").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w := performDeleteByTicker(handler, "aapl")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(3), response["deleted_rows"])
+	assert.Equal(t, "AAPL", response["ticker"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteStockByTicker_LowercaseStored validates that ratings imported with a lowercase ticker are deleted too
+func TestDeleteStockByTicker_LowercaseStored(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	// Import stores "aapl" as sent, only a comparison on UPPER(ticker) reaches that row
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM stock_ratings WHERE UPPER(ticker) = $1")).
+		WithArgs("AAPL").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := performDeleteByTicker(handler, "AAPL")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"deleted_rows":1`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteStockByTicker_NotFound validates the 404 when no rows match
+func TestDeleteStockByTicker_NotFound(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM stock_ratings").
+		WithArgs("ZZZZ").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w := performDeleteByTicker(handler, "ZZZZ")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "No ratings found for ticker ZZZZ")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteStockByTicker_InvalidTicker validates ticker format checks
+// Security: Invalid tickers are rejected before any query is executed
+func TestDeleteStockByTicker_InvalidTicker(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, ticker := range []string{"A", "TOOLONG", "AB1", "A%27B"} {
+		w := performDeleteByTicker(handler, ticker)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "ticker: %s", ticker)
+		assert.Contains(t, w.Body.String(), "Invalid ticker", "ticker: %s", ticker)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "No query should run for invalid tickers")
+}
+
+// CUSTOM SCORING WEIGHT TESTS
+
+// recommendationRows returns mock rows for the recommendations query
+func recommendationRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at"}).
+		AddRow("AAPL", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", "$150.00", "$180.00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), time.Now())
+}
+
+func performGetRecommendations(handler *StockHandler, query string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/recommendations", "/stocks/recommendations"+query, handler.GetStockRecommendations, nil)
+}
+
+// TestGetStockRecommendations_DefaultWeights validates the default 40/30/20/10 weighting
+func TestGetStockRecommendations_DefaultWeights(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(recommendationRows())
+
+	w := performGetRecommendations(handler, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, getDefaultWeights(), response.Weights)
+}
+
+// TestGetStockRecommendations_CustomWeights validates scoring with custom weights
+// Purpose: A pure target-price weighting should score the 20% raise as 5.0 + 2.0
+func TestGetStockRecommendations_CustomWeights(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(recommendationRows())
+
+	w := performGetRecommendations(handler, "?target_weight=1.0")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, ScoringWeights{TargetPriceWeight: 1.0}, response.Weights)
+	assert.Len(t, response.Recommendations, 1)
+	assert.InDelta(t, 7.0, response.Recommendations[0].Score, 0.001)
+}
+
+// TestGetStockRecommendations_InvalidWeights validates weight validation errors
+func TestGetStockRecommendations_InvalidWeights(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	w := performGetRecommendations(handler, "?target_weight=0.5&rating_weight=0.3&action_weight=0.2&timing_weight=0.1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "weights must sum to 100%")
+
+	w = performGetRecommendations(handler, "?target_weight=abc")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid target_weight parameter")
+}
+
+// TestGetStockRecommendations_DaysWindow validates that days restricts the analysis query to recent rows
+func TestGetStockRecommendations_DaysWindow(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE ticker IS NOT NULL AND company IS NOT NULL AND time >= NOW() - ($1 * INTERVAL '1 day') ORDER BY time DESC")).
+		WithArgs(30).
+		WillReturnRows(recommendationRows())
+
+	w := performGetRecommendations(handler, "?days=30")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 30, response.Days)
+	assert.Equal(t, 1, response.TotalAnalyzed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_AllTimeByDefault validates that the interval clause is omitted without days
+func TestGetStockRecommendations_AllTimeByDefault(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE ticker IS NOT NULL AND company IS NOT NULL ORDER BY time DESC")).
+		WithArgs().
+		WillReturnRows(recommendationRows())
+
+	w := performGetRecommendations(handler, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"days"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// pagedRecommendationRows returns count tickers that all qualify, each with a 20% target raise
+func pagedRecommendationRows(count int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at"})
+	for i := 0; i < count; i++ {
+		rows.AddRow("T"+strconv.Itoa(1000+i), "Company", "target raised by", "Goldman Sachs", "Hold", "Buy", "$100.00", "$120.00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), time.Now())
+	}
+	return rows
+}
+
+// TestGetStockRecommendations_Paged validates paging beyond the 50 recommendation limit
+func TestGetStockRecommendations_Paged(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(pagedRecommendationRows(60))
+
+	w := performGetRecommendations(handler, "?page_number=2&page_length=25")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Recommendations, 25)
+	assert.Equal(t, 60, response.TotalRecommendations)
+	if assert.NotNil(t, response.Pagination) {
+		assert.Equal(t, 3, response.Pagination.TotalPages)
+		assert.True(t, response.Pagination.HasNext)
+		assert.True(t, response.Pagination.HasPrevious)
+	}
+
+	// The last page holds the remainder, past it is empty
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(pagedRecommendationRows(60))
+	w = performGetRecommendations(handler, "?page_number=3&page_length=25")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Recommendations, 10)
+	assert.False(t, response.Pagination.HasNext)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_LimitReportsTotal validates that the default limit mode still caps results and reports the total
+func TestGetStockRecommendations_LimitReportsTotal(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(pagedRecommendationRows(60))
+
+	w := performGetRecommendations(handler, "?limit=50")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Recommendations, 50)
+	assert.Equal(t, 60, response.TotalRecommendations)
+	assert.Nil(t, response.Pagination)
+	assert.NotContains(t, w.Body.String(), `"pagination"`)
+}
+
+// TestGetStockRecommendations_InvalidPaging validates page_number and page_length bounds
+func TestGetStockRecommendations_InvalidPaging(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"?page_number=0", "?page_number=abc", "?page_length=0", "?page_length=1001"} {
+		w := performGetRecommendations(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s should be rejected", query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// tickerRecommendationRows returns one qualifying row per ticker, each with a 20% target raise
+func tickerRecommendationRows(tickers ...string) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at"})
+	for _, ticker := range tickers {
+		rows.AddRow(ticker, "Company", "target raised by", "Goldman Sachs", "Hold", "Buy", "$100.00", "$120.00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), time.Now())
+	}
+	return rows
+}
+
+// TestGetStockRecommendations_Exclude validates that excluded tickers are dropped after scoring and limit still fills up
+func TestGetStockRecommendations_Exclude(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE ticker IS NOT NULL AND company IS NOT NULL ORDER BY time DESC")).
+		WithArgs().
+		WillReturnRows(tickerRecommendationRows("AAPL", "MSFT", "GOOG", "AMZN", "NVDA"))
+
+	w := performGetRecommendations(handler, "?limit=3&exclude=%20aapl,MSFT,aapl")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 5, response.TotalAnalyzed)
+	assert.Equal(t, 3, response.TotalRecommendations)
+	if assert.Len(t, response.Recommendations, 3) {
+		for _, rec := range response.Recommendations {
+			assert.NotContains(t, []string{"AAPL", "MSFT"}, rec.Ticker)
+		}
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_ExcludePaged validates that paging counts only the remaining stocks
+func TestGetStockRecommendations_ExcludePaged(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(tickerRecommendationRows("AAPL", "MSFT", "GOOG", "AMZN", "NVDA"))
+
+	w := performGetRecommendations(handler, "?exclude=NVDA&page_number=2&page_length=2")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Recommendations, 2)
+	assert.Equal(t, 4, response.TotalRecommendations)
+	if assert.NotNil(t, response.Pagination) {
+		assert.Equal(t, 2, response.Pagination.TotalPages)
+		assert.False(t, response.Pagination.HasNext)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_Only validates that the watchlist restricts the analysis query
+func TestGetStockRecommendations_Only(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE ticker IS NOT NULL AND company IS NOT NULL AND time >= NOW() - ($1 * INTERVAL '1 day') AND UPPER(ticker) = ANY($2) ORDER BY time DESC")).
+		WithArgs(30, pq.Array([]string{"AAPL", "MSFT"})).
+		WillReturnRows(tickerRecommendationRows("AAPL", "MSFT"))
+
+	w := performGetRecommendations(handler, "?days=30&only=aapl,%20msft")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.TotalRecommendations)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Without days the watchlist takes the first placeholder
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE ticker IS NOT NULL AND company IS NOT NULL AND UPPER(ticker) = ANY($1) ORDER BY time DESC")).
+		WithArgs(pq.Array([]string{"AAPL"})).
+		WillReturnRows(tickerRecommendationRows("AAPL"))
+
+	w = performGetRecommendations(handler, "?only=AAPL&exclude=MSFT")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_Explain validates the score breakdown in the response and in the reason when explain is set
+func TestGetStockRecommendations_Explain(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(tickerRecommendationRows("AAPL"))
+	w := performGetRecommendations(handler, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Recommendations, 1) {
+		rec := response.Recommendations[0]
+		assert.Equal(t, rec.Score, rec.ScoreBreakdown.Total)
+		assert.NotContains(t, rec.Reason, "score ", "The breakdown is only in the reason when explain is set")
+	}
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(tickerRecommendationRows("AAPL"))
+	w = performGetRecommendations(handler, "?explain=true")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Recommendations, 1) {
+		rec := response.Recommendations[0]
+		assert.True(t, strings.HasSuffix(rec.Reason, "; "+rec.ScoreBreakdown.explanation()), rec.Reason)
+	}
+
+	w = performGetRecommendations(handler, "?explain=maybe")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_InvalidTickerLists validates ticker format and list length of exclude and only
+func TestGetStockRecommendations_InvalidTickerLists(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	tooMany := make([]string, maxTickerListLength+1)
+	for i := range tooMany {
+		tooMany[i] = string(rune('A'+i/26%26)) + string(rune('A'+i%26)) + "X"
+	}
+
+	for _, query := range []string{"?exclude=AAPL,12", "?only=TOOLONG", "?exclude=" + strings.Join(tooMany, ","), "?only=" + strings.Join(tooMany, ",")} {
+		w := performGetRecommendations(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s should be rejected", query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_LatestByReportTime validates that the latest entry per ticker is picked by its scanned report time, not row order
+func TestGetStockRecommendations_LatestByReportTime(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	older := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 1, 15, 16, 45, 30, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at"}).
+		AddRow("AAPL", "Apple Inc.", "target lowered by", "Morgan Stanley", "Buy", "Sell", "$200.00", "$150.00", older, time.Now()).
+		AddRow("AAPL", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", "$150.00", "$180.00", newer, time.Now()).
+		AddRow("AAPL", "Apple Inc.", "reiterated by", "Barclays", "Hold", "Hold", "$170.00", "$170.00", nil, time.Now())
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(rows)
+
+	w := performGetRecommendations(handler, "?min_score=0")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.TotalAnalyzed, "A NULL report time should not drop the row")
+	if assert.Len(t, response.Recommendations, 1) {
+		assert.Equal(t, "Goldman Sachs", response.Recommendations[0].Brokerage)
+		assert.Equal(t, "Buy", response.Recommendations[0].CurrentRating)
+		assert.InDelta(t, 20.0, response.Recommendations[0].PriceChange, 0.001)
+		assert.InDelta(t, coverageConfidence(coverageHistory(3)), response.Recommendations[0].Confidence, 0.001, "Three brokerages cover the ticker")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRecommendations_InvalidDays validates days bounds
+func TestGetStockRecommendations_InvalidDays(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"?days=0", "?days=-7", "?days=3651", "?days=week"} {
+		w := performGetRecommendations(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), "Invalid days parameter", query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "No query should run for invalid days")
+}
+
+// MINIMUM SCORE THRESHOLD TESTS
+
+// scoreSpreadRows returns three tickers scoring 3.9, 6.1 and 7.55 with default weights
+func scoreSpreadRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at"}).
+		AddRow("LOW", "Low Corp", "target lowered by", "Goldman Sachs", "Buy", "Sell", "$100.00", "$80.00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), time.Now()).
+		AddRow("MID", "Mid Corp", "target raised by", "Goldman Sachs", "Hold", "Hold", "$100.00", "$115.00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), time.Now()).
+		AddRow("TOP", "Top Corp", "upgraded by", "Goldman Sachs", "Sell", "Strong Buy", "$100.00", "$150.00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), time.Now())
+}
+
+// TestGetStockRecommendations_MinScoreZero validates that min_score=0 returns every stock, best first
+func TestGetStockRecommendations_MinScoreZero(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(scoreSpreadRows())
+
+	w := performGetRecommendations(handler, "?min_score=0&limit=2")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.Recommendations, 2, "Limit should still apply with min_score=0")
+	assert.Equal(t, "TOP", response.Recommendations[0].Ticker)
+	assert.Equal(t, "MID", response.Recommendations[1].Ticker)
+
+	// Without a limit cut the low scorer is included too
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(scoreSpreadRows())
+	w = performGetRecommendations(handler, "?min_score=0")
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.Recommendations, 3, "Stocks below the default threshold should be returned")
+}
+
+// TestGetStockRecommendations_MinScoreHigh validates tightening the threshold to strong buys
+// With a pure target-price weighting TOP (+50%) scores 8.0 and MID (+15%) scores 7.0
+func TestGetStockRecommendations_MinScoreHigh(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(scoreSpreadRows())
+
+	w := performGetRecommendations(handler, "?min_score=8&target_weight=1")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response RecommendationsResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.Recommendations, 1)
+	assert.Equal(t, "TOP", response.Recommendations[0].Ticker)
+	assert.GreaterOrEqual(t, response.Recommendations[0].Score, 8.0)
+}
+
+// TestGetStockRecommendations_MinScoreOutOfRange validates min_score bounds
+func TestGetStockRecommendations_MinScoreOutOfRange(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"?min_score=11", "?min_score=-1", "?min_score=high"} {
+		w := performGetRecommendations(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), "Invalid min_score parameter", query)
+	}
+}
+
+// SORTING TESTS
+
+// TestGetStockRatings_Sorting validates that each allowed sort produces the expected ORDER BY
+func TestGetStockRatings_Sorting(t *testing.T) {
+	tests := []struct {
+		sortBy    string
+		sortOrder string
+		orderBy   string
+	}{
+		{"", "", "ORDER BY created_at DESC, id DESC"},
+		{"created_at", "asc", "ORDER BY created_at ASC, id ASC"},
+		{"time", "desc", "ORDER BY time DESC, id DESC"},
+		{"ticker", "asc", "ORDER BY ticker ASC, id ASC"},
+		{"company", "DESC", "ORDER BY company DESC, id DESC"},
+	}
+
+	for _, test := range tests {
+		handler, mock, db := setupTestHandler()
+
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(test.orderBy)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/stocks/list", handler.GetStockRatings)
+
+		reqBody := models.PaginationRequest{PageNumber: 1, PageLength: 20, SortBy: test.sortBy, SortOrder: test.sortOrder}
+		jsonBody, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/stocks/list", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "sort_by=%q sort_order=%q", test.sortBy, test.sortOrder)
+		assert.NoError(t, mock.ExpectationsWereMet(), "sort_by=%q sort_order=%q", test.sortBy, test.sortOrder)
+		db.Close()
+	}
+}
+
+// TestGetStockRatings_PagePastEnd validates that a page beyond the last one is empty with coherent metadata
+func TestGetStockRatings_PagePastEnd(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	// 95 rows are 5 pages of 20, no data query should run for page 9999
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(95))
+
+	w := serve("POST", "/stocks/list", "/stocks/list", handler.GetStockRatings, bytes.NewBufferString(`{"page_number": 9999, "page_length": 20}`))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data       []models.StockRatings `json:"data"`
+		Pagination models.PaginationMeta `json:"pagination"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotNil(t, response.Data, "data should be an empty array, not null")
+	assert.Empty(t, response.Data)
+	assert.Equal(t, 9999, response.Pagination.PageNumber)
+	assert.Equal(t, 5, response.Pagination.TotalPages)
+	assert.Equal(t, 95, response.Pagination.TotalRecords)
+	assert.False(t, response.Pagination.HasNext)
+	assert.True(t, response.Pagination.HasPrevious)
+	assert.True(t, response.Pagination.OutOfRange)
+	assert.Nil(t, response.Pagination.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRatings_InvalidSort validates that unknown sort values are rejected
+// Security: Prevents SQL injection through the ORDER BY clause
+func TestGetStockRatings_InvalidSort(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/list", handler.GetStockRatings)
+
+	tests := []struct {
+		req     models.PaginationRequest
+		message string
+	}{
+		{models.PaginationRequest{PageNumber: 1, PageLength: 20, SortBy: "id; DROP TABLE stock_ratings"}, "Invalid sort_by"},
+		{models.PaginationRequest{PageNumber: 1, PageLength: 20, SortBy: "brokerage"}, "Invalid sort_by"},
+		{models.PaginationRequest{PageNumber: 1, PageLength: 20, SortBy: "ticker", SortOrder: "sideways"}, "Invalid sort_order"},
+	}
+
+	for _, test := range tests {
+		jsonBody, _ := json.Marshal(test.req)
+		req := httptest.NewRequest("POST", "/stocks/list", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), test.message)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "No query should run for invalid sorts")
+}
+
+// CURSOR PAGINATION TESTS
+
+// stockRatingColumns lists the columns returned by stock rating list queries
+var stockRatingColumns = []string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at"}
+
+// TestGetStockRatings_Cursor validates the keyset pagination query and next_cursor
+// Purpose: Deep pages seek past the cursor instead of using OFFSET
+func TestGetStockRatings_Cursor(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	cursorTime := time.Date(2025, 1, 15, 10, 35, 0, 0, time.UTC)
+	lastTime := cursorTime.Add(-time.Minute)
+
+	// page_length 2 -> 3 rows requested, 3 returned means another page follows
+	rows := sqlmock.NewRows(stockRatingColumns).
+		AddRow(99, "AAPL", "$150.00", "$180.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", cursorTime, cursorTime).
+		AddRow(98, "MSFT", "$300.00", "$350.00", "Microsoft", "upgraded by", "Morgan Stanley", "Hold", "Buy", lastTime, lastTime).
+		AddRow(97, "NVDA", "$400.00", "$500.00", "Nvidia", "upgraded by", "Morgan Stanley", "Hold", "Buy", lastTime, lastTime)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE (created_at, id) < ($1, $2) ORDER BY created_at DESC, id DESC LIMIT $3")).
+		WithArgs(cursorTime, 100, 3).
+		WillReturnRows(rows)
+
+	reqBody := models.PaginationRequest{PageLength: 2, AfterID: 100, AfterCreatedAt: &cursorTime}
+	jsonBody, _ := json.Marshal(reqBody)
+	w := serve("POST", "/stocks/list", "/stocks/list", handler.GetStockRatings, bytes.NewBuffer(jsonBody))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.PaginatedResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.Data, 2, "The extra look-ahead row should not be returned")
+	assert.True(t, response.Pagination.HasNext)
+	assert.True(t, response.Pagination.HasPrevious, "Rows before the cursor were served on an earlier page")
+	if assert.NotNil(t, response.Pagination.NextCursor) {
+		assert.Equal(t, 98, response.Pagination.NextCursor.AfterID, "next_cursor should point to the last returned row")
+		assert.True(t, lastTime.Equal(response.Pagination.NextCursor.AfterCreatedAt))
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRatings_CursorLastPage validates that the final page has no next_cursor
+func TestGetStockRatings_CursorLastPage(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	cursorTime := time.Date(2025, 1, 15, 10, 35, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(stockRatingColumns).
+		AddRow(1, "AAPL", "$150.00", "$180.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", cursorTime, cursorTime)
+	mock.ExpectQuery("WHERE \\(created_at, id\\) <").WillReturnRows(rows)
+
+	reqBody := models.PaginationRequest{PageLength: 20, AfterID: 2, AfterCreatedAt: &cursorTime}
+	jsonBody, _ := json.Marshal(reqBody)
+	w := serve("POST", "/stocks/list", "/stocks/list", handler.GetStockRatings, bytes.NewBuffer(jsonBody))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response models.PaginatedResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.Data, 1)
+	assert.False(t, response.Pagination.HasNext)
+	assert.Nil(t, response.Pagination.NextCursor)
+}
+
+// TestGetStockRatings_CursorRowError validates that an error while reading the cursor page is not served as a short page
+func TestGetStockRatings_CursorRowError(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	cursorTime := time.Date(2025, 1, 15, 10, 35, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(stockRatingColumns).
+		AddRow(1, "AAPL", "$150.00", "$180.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", cursorTime, cursorTime).
+		RowError(0, errors.New("connection reset"))
+	mock.ExpectQuery("WHERE \\(created_at, id\\) <").WillReturnRows(rows)
+
+	reqBody := models.PaginationRequest{PageLength: 20, AfterID: 2, AfterCreatedAt: &cursorTime}
+	jsonBody, _ := json.Marshal(reqBody)
+	w := serve("POST", "/stocks/list", "/stocks/list", handler.GetStockRatings, bytes.NewBuffer(jsonBody))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to read stock ratings")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockRatings_IncompleteCursor validates that both cursor fields are required
+func TestGetStockRatings_IncompleteCursor(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	reqBody := models.PaginationRequest{PageLength: 20, AfterID: 100}
+	jsonBody, _ := json.Marshal(reqBody)
+	w := serve("POST", "/stocks/list", "/stocks/list", handler.GetStockRatings, bytes.NewBuffer(jsonBody))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "after_id and after_created_at")
+}
+
+// RANDOM SAMPLE TESTS
+
+func performGetRandomStocks(handler *StockHandler, query string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/random", "/stocks/random"+query, handler.GetRandomStocks, nil)
+}
+
+// randomStockRows returns the same single rating for the list and random sample queries
+func randomStockRows(reportTime time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at"}).
+		AddRow(7, "AAPL", "$150.00", "$180.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", reportTime, reportTime)
+}
+
+// TestGetRandomStocks_Success validates that count limits the sample and rows look like the list's data array
+func TestGetRandomStocks_Success(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	reportTime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_ratings ORDER BY RANDOM() LIMIT $1")).
+		WithArgs(25).
+		WillReturnRows(randomStockRows(reportTime))
+
+	w := performGetRandomStocks(handler, "?count=25")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data  []json.RawMessage `json:"data"`
+		Count int               `json:"count"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The same row served by POST /stocks/list serializes identically
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("FROM stock_ratings ORDER BY").WillReturnRows(randomStockRows(reportTime))
+	router := gin.New()
+	router.POST("/stocks/list", handler.GetStockRatings)
+	req := httptest.NewRequest("POST", "/stocks/list", strings.NewReader(`{"page_number": 1, "page_length": 20}`))
+	req.Header.Set("Content-Type", "application/json")
+	listW := httptest.NewRecorder()
+	router.ServeHTTP(listW, req)
+
+	var list struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(listW.Body.Bytes(), &list))
+	if assert.Len(t, response.Data, 1) && assert.Len(t, list.Data, 1) {
+		assert.JSONEq(t, string(list.Data[0]), string(response.Data[0]))
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetRandomStocks_DefaultCount validates the default sample size and an empty table
+func TestGetRandomStocks_DefaultCount(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("ORDER BY RANDOM() LIMIT $1")).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at"}))
+
+	w := performGetRandomStocks(handler, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": [], "count": 0}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetRandomStocks_InvalidCount validates count bounds before querying
+func TestGetRandomStocks_InvalidCount(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"?count=0", "?count=101", "?count=-1", "?count=abc"} {
+		w := performGetRandomStocks(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s should be rejected", query)
+		assert.Contains(t, w.Body.String(), "Must be between 1 and 100")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetRandomStocks_DatabaseError validates the 500 on query failures
+func TestGetRandomStocks_DatabaseError(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("ORDER BY RANDOM()").WillReturnError(errors.New("connection refused"))
+
+	w := performGetRandomStocks(handler, "?count=5")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to query stock ratings")
+}
+
+// GET BY ID TESTS
+
+func performGetStockByID(handler *StockHandler, id string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/:id", "/stocks/"+id, handler.GetStockByID, nil)
+}
+
+// TestGetStockByID_Found validates fetching a single rating by its primary key
+func TestGetStockByID_Found(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	reportTime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at", "sector"}).
+		AddRow(42, "AAPL", "$150.00", "$180.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", reportTime, reportTime, "Technology")
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_ratings WHERE id = $1")).WithArgs(int64(42)).WillReturnRows(rows)
+
+	w := performGetStockByID(handler, "42")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stock models.StockRatings
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stock))
+	assert.Equal(t, 42, stock.ID)
+	assert.Equal(t, "AAPL", stock.Ticker)
+	assert.Equal(t, "Goldman Sachs", stock.Brokerage)
+	assert.Equal(t, "Technology", stock.Sector)
+	assert.True(t, reportTime.Equal(stock.Time))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockByID_NotFound validates the 404 for unknown IDs
+func TestGetStockByID_NotFound(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_ratings WHERE id = $1")).WithArgs(int64(999)).WillReturnError(sql.ErrNoRows)
+
+	w := performGetStockByID(handler, "999")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "No stock rating found with id 999")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockByID_InvalidID validates that non-integer and non-positive IDs are rejected before querying
+func TestGetStockByID_InvalidID(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, id := range []string{"abc", "1.5", "0", "-3", "99999999999999999999"} {
+		w := performGetStockByID(handler, id)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "id: %s", id)
+		assert.Contains(t, w.Body.String(), "id must be a positive integer", "id: %s", id)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "No query should run for invalid ids")
+}
+
+// TestGetStockByID_DatabaseError validates the 500 on query failures
+func TestGetStockByID_DatabaseError(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_ratings WHERE id = $1")).WillReturnError(errors.New("connection reset"))
+
+	w := performGetStockByID(handler, "7")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func performUpdateStockByID(handler *StockHandler, id, body string) *httptest.ResponseRecorder {
+	return serve("PUT", "/stocks/:id", "/stocks/"+id, handler.UpdateStockByID, bytes.NewBufferString(body))
+}
+
+// TestUpdateStockByID_PartialUpdate validates that only the given fields are updated and prices are re-normalized
+func TestUpdateStockByID_PartialUpdate(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	reportTime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at", "sector"}).
+		AddRow(42, "AAPL", "$150.00", "$1850.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Strong Buy", reportTime, reportTime, "Technology")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE stock_ratings SET target_to = $1, target_to_num = $2, rating_to = $3 WHERE id = $4")).
+		WithArgs("$1850.00", "1850.00", "Strong Buy", int64(42)).
+		WillReturnRows(rows)
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w := performUpdateStockByID(handler, "42", `{"target_to": "$1,850", "rating_to": "Strong Buy"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stock models.StockRatings
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stock))
+	assert.Equal(t, 42, stock.ID)
+	assert.Equal(t, "$1850.00", stock.TargetTo)
+	assert.Equal(t, "Strong Buy", stock.RatingTo)
+	assert.Equal(t, "$150.00", stock.TargetFrom)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateStockByID_NotFound validates the 404 when no row has the ID
+func TestUpdateStockByID_NotFound(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE stock_ratings SET company = $1")).
+		WithArgs("Apple Inc.", int64(999)).
+		WillReturnError(sql.ErrNoRows)
+
+	w := performUpdateStockByID(handler, "999", `{"company": "Apple Inc."}`)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "No stock rating found with id 999")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateStockByID_Duplicate validates the 409 when the corrected rating collides with the stock_ratings unique key
+func TestUpdateStockByID_Duplicate(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE stock_ratings SET rating_to = $1 WHERE id = $2")).
+		WithArgs("Buy", int64(42)).
+		WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+
+	w := performUpdateStockByID(handler, "42", `{"rating_to": "Buy"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "already exists")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateStockByID_InvalidatesCaches validates that a corrected rating drops the summary, metrics and recommendations caches
+func TestUpdateStockByID_InvalidatesCaches(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.summaryCache.Set(SummaryResponse{Summary: "Outdated"})
+	handler.metricsCache.Set(models.MetricsData{TotalRecords: 1})
+
+	reportTime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	mock.ExpectQuery("UPDATE stock_ratings SET company = \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ticker", "target_from", "target_to", "company", "action", "brokerage", "rating_from", "rating_to", "time", "created_at", "sector"}).
+			AddRow(42, "AAPL", "$150.00", "$180.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", reportTime, reportTime, "Technology"))
+	mock.ExpectExec("DELETE FROM recommendations_cache").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := performUpdateStockByID(handler, "42", `{"company": "Apple Inc."}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, _, summaryCached := handler.summaryCache.Get()
+	_, _, metricsCached := handler.metricsCache.Get()
+	assert.False(t, summaryCached)
+	assert.False(t, metricsCached)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateStockByID_InvalidInput validates that bad IDs, prices and empty bodies are rejected before querying
+func TestUpdateStockByID_InvalidInput(t *testing.T) {
+	tests := []struct {
+		id       string
+		body     string
+		expected string
+	}{
+		{"abc", `{"company": "Apple Inc."}`, "id must be a positive integer"},
+		{"42", `not json`, "Invalid JSON format"},
+		{"42", `{}`, "No fields to update"},
+		{"42", `{"target_from": "N/A"}`, "target_from must be a non-negative number"},
+		{"42", `{"ticker": "A1"}`, "Invalid ticker"},
+	}
+
+	for _, test := range tests {
+		handler, mock, db := setupTestHandler()
+
+		w := performUpdateStockByID(handler, test.id, test.body)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, test.body)
+		assert.Contains(t, w.Body.String(), test.expected)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	}
+}
+
+// TICKER HISTORY TESTS
+
+func performGetTickerHistory(handler *StockHandler, path string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/ticker/:ticker", "/stocks/ticker/"+path, handler.GetStockHistoryByTicker, nil)
+}
+
+// TestGetStockHistoryByTicker_Success validates the full history for a covered ticker
+// Purpose: Ticker is normalized, rows are paginated and brokerage coverage is reported
+func TestGetStockHistoryByTicker_Success(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\), COUNT\\(DISTINCT brokerage\\) FROM stock_ratings WHERE UPPER\\(ticker\\) = \\$1").
+		WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "brokerages"}).AddRow(3, 2))
+
+	rows := sqlmock.NewRows(stockRatingColumns).
+		AddRow(3, "AAPL", "$180.00", "$200.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Buy", "Buy", time.Now(), time.Now()).
+		AddRow(2, "AAPL", "$150.00", "$180.00", "Apple Inc.", "upgraded by", "Morgan Stanley", "Hold", "Buy", time.Now().Add(-time.Hour), time.Now())
+	mock.ExpectQuery("ORDER BY time DESC, id DESC").
+		WithArgs("AAPL", 2, 0).
+		WillReturnRows(rows)
+
+	w := performGetTickerHistory(handler, "aapl?page_length=2")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "AAPL", response["ticker"])
+	assert.Equal(t, float64(2), response["brokerage_count"])
+	assert.Len(t, response["data"], 2)
+
+	pagination := response["pagination"].(map[string]interface{})
+	assert.Equal(t, float64(3), pagination["total_records"])
+	assert.Equal(t, float64(2), pagination["total_pages"])
+	assert.Equal(t, true, pagination["has_next"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockHistoryByTicker_LowercaseStored validates that ratings imported with a lowercase ticker are part of the history
+func TestGetStockHistoryByTicker_LowercaseStored(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_ratings WHERE UPPER(ticker) = $1")).
+		WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "brokerages"}).AddRow(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE UPPER(ticker) = $1")).
+		WithArgs("AAPL", 20, 0).
+		WillReturnRows(sqlmock.NewRows(stockRatingColumns).
+			AddRow(1, "aapl", "$150.00", "$180.00", "Apple Inc.", "upgraded by", "Goldman Sachs", "Hold", "Buy", time.Now(), time.Now()))
+
+	w := performGetTickerHistory(handler, "AAPL")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"ticker":"aapl"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockHistoryByTicker_NotFound validates the 404 for tickers without ratings
+func TestGetStockHistoryByTicker_NotFound(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\), COUNT\\(DISTINCT brokerage\\)").
+		WithArgs("ZZZZ").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "brokerages"}).AddRow(0, 0))
+
+	w := performGetTickerHistory(handler, "ZZZZ")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "No ratings found for ticker ZZZZ")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// METRICS CACHE TESTS
+
+// expectMetricsQueries mocks the eight parallel aggregation queries of GetStockMetrics
+func expectMetricsQueries(mock sqlmock.Sqlmock) {
+	expectMetricsQueriesWith(mock, metricsRows{})
+}
+
+// metricsRows overrides the rows of some metric queries, nil fields keep the expectMetricsQueries defaults
+type metricsRows struct {
+	ratings   *sqlmock.Rows // rating_to, count
+	sentiment *sqlmock.Rows // bullish, bearish, neutral
+	freshness *sqlmock.Rows // oldest and newest time and created_at
+}
+
+// expectMetricsQueriesWith mocks the metric queries, returning rows where set
+func expectMetricsQueriesWith(mock sqlmock.Sqlmock, rows metricsRows) {
+	if rows.ratings == nil {
+		rows.ratings = sqlmock.NewRows([]string{"rating_to", "count"}).AddRow("Buy", 60).AddRow("Hold", 40)
+	}
+	if rows.sentiment == nil {
+		rows.sentiment = sqlmock.NewRows([]string{"bullish", "bearish", "neutral"}).AddRow(60, 10, 30)
+	}
+	if rows.freshness == nil {
+		rows.freshness = freshnessRows().AddRow(nil, nil, nil, nil)
+	}
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings$").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(100))
+	mock.ExpectQuery("targets_raised").
+		WillReturnRows(sqlmock.NewRows([]string{"raised", "lowered", "maintained"}).AddRow(50, 30, 20))
+	mock.ExpectQuery("SELECT rating_to, COUNT").WillReturnRows(rows.ratings)
+	mock.ExpectQuery("SELECT brokerage, COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"brokerage", "activity_count"}).AddRow("Goldman Sachs", 25))
+	mock.ExpectQuery("SELECT ticker, company, COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"ticker", "company", "rating_count"}).AddRow("AAPL", "Apple Inc.", 10))
+	mock.ExpectQuery(regexp.QuoteMeta("AVG((target_to_num - target_from_num) / NULLIF(target_from_num, 0) * 100) AS average_change_pct")).
+		WillReturnRows(sqlmock.NewRows([]string{"prices_compared", "price_increases", "price_decreases", "price_unchanged", "average_change_pct", "largest_raise_pct"}).
+			AddRow(80, 50, 20, 10, 4.256, 62.5))
+	mock.ExpectQuery("bullish_ratings").WillReturnRows(rows.sentiment)
+	mock.ExpectQuery("recent_count").
+		WillReturnRows(sqlmock.NewRows([]string{"recent_count"}).AddRow(5))
+	mock.ExpectQuery("newest_created_at").WillReturnRows(rows.freshness)
+}
+
+// freshnessRows returns the columns of the data_freshness query
+func freshnessRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"oldest_report_time", "newest_report_time", "oldest_created_at", "newest_created_at"})
+}
+
+func performGetMetrics(router *gin.Engine) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest("GET", "/stocks/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+// TestGetStockMetrics_Cached validates that repeated calls within the TTL reuse the cached metrics
+// Purpose: Dashboards polling the endpoint must not re-run the aggregation queries every time
+func TestGetStockMetrics_Cached(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	// Only one set of queries is expected, a second run would fail the mock
+	expectMetricsQueries(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	w, first := performGetMetrics(router)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, first["cached"])
+
+	w, second := performGetMetrics(router)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, second["cached"])
+	assert.GreaterOrEqual(t, second["cache_age_seconds"].(float64), 0.0)
+	assert.Equal(t, first["metrics"], second["metrics"], "Cached metrics should match the computed ones")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockMetrics_ConditionalGet validates that a matching If-None-Match gets 304 until the metrics change
+func TestGetStockMetrics_ConditionalGet(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	expectMetricsQueries(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	w, _ := performGetMetrics(router)
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^W/"[0-9a-f]{16}"$`, etag)
+
+	req := httptest.NewRequest("GET", "/stocks/metrics", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String(), "304 responses have no body")
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	// A recompute produces a new version, so the old ETag no longer matches
+	handler.metricsCache.Set(map[string]interface{}{"total_records": 101, "generated_at": time.Now().UTC()})
+	req = httptest.NewRequest("GET", "/stocks/metrics", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestEtagMatches validates If-None-Match parsing: lists, the wildcard and weak comparison
+func TestEtagMatches(t *testing.T) {
+	etag := `W/"0123456789abcdef"`
+	assert.True(t, etagMatches(`W/"0123456789abcdef"`, etag))
+	assert.True(t, etagMatches(`"0123456789abcdef"`, etag), "Weak comparison ignores the W/ prefix")
+	assert.True(t, etagMatches(`"other", W/"0123456789abcdef"`, etag))
+	assert.True(t, etagMatches(`*`, etag))
+	assert.False(t, etagMatches(``, etag))
+	assert.False(t, etagMatches(`W/"fedcba9876543210"`, etag))
+}
+
+// TestGetStockMetrics_CacheExpired validates that stale metrics are recomputed
+func TestGetStockMetrics_CacheExpired(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.metricsCache = newTTLCache(0)
+
+	expectMetricsQueries(mock)
+	expectMetricsQueries(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	_, first := performGetMetrics(router)
+	_, second := performGetMetrics(router)
+
+	assert.Equal(t, false, first["cached"])
+	assert.Equal(t, false, second["cached"], "Expired cache should be recomputed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expireMetricsCache seeds the handler cache with metrics computed an hour ago
+func expireMetricsCache(handler *StockHandler, value map[string]interface{}) {
+	handler.metricsCache = newTTLCache(time.Minute)
+	handler.metricsCache.Set(value)
+	handler.metricsCache.computedAt = time.Now().Add(-time.Hour)
+}
+
+// waitForMetricsRefresh blocks until the background refresh has stored fresh metrics
+func waitForMetricsRefresh(t *testing.T, handler *StockHandler) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, _, ok := handler.metricsCache.Get(); ok && !handler.metricsCache.Refreshing() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("background metrics refresh did not finish in time")
+}
+
+// TestGetStockMetrics_StaleWhileRevalidate validates that expired metrics are served while a background refresh runs
+func TestGetStockMetrics_StaleWhileRevalidate(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	expireMetricsCache(handler, map[string]interface{}{"total_records": 1})
+	expectMetricsQueries(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	w, stale := performGetMetrics(router)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, true, stale["refreshing"])
+	assert.Equal(t, true, stale["cached"])
+	assert.Equal(t, float64(1), stale["metrics"].(map[string]interface{})["total_records"], "Stale metrics should be served immediately")
+
+	waitForMetricsRefresh(t, handler)
+
+	w, fresh := performGetMetrics(router)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, fresh["refreshing"])
+	assert.Equal(t, float64(100), fresh["metrics"].(map[string]interface{})["total_records"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockMetrics_SingleBackgroundRefresh validates that concurrent requests on a stale cache start only one recompute
+// Purpose: A burst of dashboard polls right after expiry must not run the aggregation queries once per request
+func TestGetStockMetrics_SingleBackgroundRefresh(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	expireMetricsCache(handler, map[string]interface{}{"total_records": 1})
+	// Only one set of queries is expected, a second recompute would fail the mock
+	expectMetricsQueries(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	const requests = 20
+	var accepted, servedStale int32
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, response := performGetMetrics(router)
+			if w.Code == http.StatusAccepted {
+				atomic.AddInt32(&accepted, 1)
+			}
+			if response["refreshing"] == true {
+				atomic.AddInt32(&servedStale, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	waitForMetricsRefresh(t, handler)
+
+	assert.Equal(t, int32(1), accepted, "Exactly one request should trigger the background refresh")
+	assert.Greater(t, servedStale, int32(0))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockMetrics_TargetPriceStats validates the price comparison computed from the numeric target columns
+func TestGetStockMetrics_TargetPriceStats(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	expectMetricsQueries(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	w, response := performGetMetrics(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]interface{}{
+		"compared":               float64(80),
+		"increases":              float64(50),
+		"decreases":              float64(20),
+		"unchanged":              float64(10),
+		"average_change_percent": 4.26,
+		"largest_raise_percent":  62.5,
+	}, response["metrics"].(map[string]interface{})["target_price_stats"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockMetrics_RatingDistribution validates the percentages of the top ratings and the "other" bucket
+func TestGetStockMetrics_RatingDistribution(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	// 13 distinct ratings over 400 ratings, the last 3 go to "other"
+	ratings := sqlmock.NewRows([]string{"rating_to", "count"})
+	counts := []int{100, 80, 60, 40, 30, 20, 20, 10, 10, 10, 8, 8, 4}
+	for i, count := range counts {
+		ratings.AddRow(fmt.Sprintf("Rating %d", i+1), count)
+	}
+	expectMetricsQueriesWith(mock, metricsRows{ratings: ratings})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	w, _ := performGetMetrics(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Metrics models.MetricsData `json:"metrics"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	distribution := response.Metrics.RatingDistribution
+	assert.Equal(t, 400, distribution.Total)
+	assert.Len(t, distribution.Ratings, 10)
+	assert.Equal(t, models.RatingShare{Rating: "Rating 1", Count: 100, Percentage: 25}, distribution.Ratings[0])
+	assert.Equal(t, models.OtherRatings{Ratings: 3, Count: 20, Percentage: 5}, distribution.Other)
+
+	sum := distribution.Other.Percentage
+	for _, share := range distribution.Ratings {
+		sum += share.Percentage
+	}
+	assert.InDelta(t, 100, sum, 0.001)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockMetrics_Filtered validates that ticker, brokerage and time filters scope every metric query
+func TestGetStockMetrics_Filtered(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := regexp.QuoteMeta("UPPER(ticker) = UPPER($1) AND LOWER(brokerage) = LOWER($2) AND time >= $3")
+	expect := func(query string, columns []string, values ...driver.Value) {
+		mock.ExpectQuery(query+"(?s).*WHERE .*"+filter).
+			WithArgs("aapl", "Goldman Sachs", from).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(values...))
+	}
+	expect(`SELECT COUNT\(\*\) FROM stock_ratings\s`, []string{"count"}, 12)
+	expect("targets_raised", []string{"raised", "lowered", "maintained"}, 5, 4, 3)
+	expect("SELECT rating_to, COUNT", []string{"rating_to", "count"}, "Buy", 12)
+	expect("SELECT brokerage, COUNT", []string{"brokerage", "activity_count"}, "Goldman Sachs", 12)
+	expect("SELECT ticker, company, COUNT", []string{"ticker", "company", "rating_count"}, "AAPL", "Apple Inc.", 12)
+	expect("bullish_ratings", []string{"bullish", "bearish", "neutral"}, 12, 0, 0)
+	expect("average_change_pct", []string{"prices_compared", "price_increases", "price_decreases", "price_unchanged", "average_change_pct", "largest_raise_pct"}, 12, 5, 4, 3, 2.5, 10.0)
+	expect("recent_count", []string{"recent_count"}, 2)
+	expect("newest_created_at", []string{"oldest_report_time", "newest_report_time", "oldest_created_at", "newest_created_at"}, nil, nil, nil, nil)
+
+	w := serve("GET", "/stocks/metrics", "/stocks/metrics?ticker=aapl&brokerage=Goldman%20Sachs&time_from=2025-01-01T00:00:00Z", handler.GetStockMetrics, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, false, response["cached"])
+	assert.Equal(t, float64(12), response["metrics"].(map[string]interface{})["total_records"])
+	_, _, cached := handler.metricsCache.Get()
+	assert.False(t, cached, "scoped metrics must not replace the whole-table cache")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockMetrics_DataFreshness validates the freshness fields computed from the MIN/MAX query
+func TestGetStockMetrics_DataFreshness(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	now := time.Date(2025, 1, 15, 16, 30, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+
+	oldestTime := time.Date(2024, 6, 3, 13, 30, 0, 0, time.UTC)
+	newestTime := time.Date(2025, 1, 15, 13, 30, 0, 0, time.UTC)
+	oldestCreated := time.Date(2025, 1, 10, 8, 0, 0, 0, time.UTC)
+	newestCreated := time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC)
+	expectMetricsQueriesWith(mock, metricsRows{
+		freshness: freshnessRows().AddRow(oldestTime, newestTime, oldestCreated, newestCreated),
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	w, _ := performGetMetrics(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Metrics models.MetricsData `json:"metrics"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	hours := 2.5
+	assert.Equal(t, models.DataFreshness{
+		OldestReportTime:     &oldestTime,
+		NewestReportTime:     &newestTime,
+		OldestCreatedAt:      &oldestCreated,
+		NewestCreatedAt:      &newestCreated,
+		HoursSinceLastIngest: &hours,
+	}, response.Metrics.DataFreshness)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockMetrics_InvalidFilter validates that malformed or contradictory filters are rejected
+func TestGetStockMetrics_InvalidFilter(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	for _, query := range []string{
+		"?time_from=yesterday",
+		"?target_to_min=cheap",
+		"?time_from=2025-02-01T00:00:00Z&time_to=2025-01-01T00:00:00Z",
+	} {
+		req := httptest.NewRequest("GET", "/stocks/metrics"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockMetrics_NoRatedStocks validates that zero sentiment counts give 0 percentages instead of NaN and a 500
+func TestGetStockMetrics_NoRatedStocks(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	expectMetricsQueriesWith(mock, metricsRows{
+		ratings:   sqlmock.NewRows([]string{"rating_to", "count"}),
+		sentiment: sqlmock.NewRows([]string{"bullish", "bearish", "neutral"}).AddRow(0, 0, 0),
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	w, response := performGetMetrics(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, json.Valid(w.Body.Bytes()))
+	metrics := response["metrics"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"bullish_count":      float64(0),
+		"bearish_count":      float64(0),
+		"neutral_count":      float64(0),
+		"bullish_percentage": float64(0),
+		"bearish_percentage": float64(0),
+		"neutral_percentage": float64(0),
+	}, metrics["market_sentiment"])
+	assert.Equal(t, float64(0), metrics["rating_distribution"].(map[string]interface{})["total"])
+	assert.Nil(t, metrics["data_freshness"].(map[string]interface{})["hours_since_last_ingest"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBuildRatingDistribution_Empty validates that no ratings give zero percentages instead of NaN
+func TestBuildRatingDistribution_Empty(t *testing.T) {
+	distribution := buildRatingDistribution(nil, topRatingsInDistribution)
+
+	assert.Equal(t, models.RatingDistribution{Ratings: []models.RatingShare{}}, distribution)
+}
+
+// TestRefreshStockMetrics validates that the refresh endpoint recomputes and caches metrics synchronously
+func TestRefreshStockMetrics(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	expireMetricsCache(handler, map[string]interface{}{"total_records": 1})
+	expectMetricsQueries(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/metrics/refresh", handler.RefreshStockMetrics)
+	router.GET("/stocks/metrics", handler.GetStockMetrics)
+
+	req := httptest.NewRequest("POST", "/stocks/metrics/refresh", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, false, response["cached"])
+	assert.Equal(t, float64(100), response["metrics"].(map[string]interface{})["total_records"])
+
+	// The GET should now be served from the refreshed cache without new queries
+	w, cached := performGetMetrics(router)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, cached["cached"])
+	assert.Equal(t, float64(100), cached["metrics"].(map[string]interface{})["total_records"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// FILTER OPTIONS TESTS
+
+// TestGetFilterOptions_Success validates that actions, ratings_from and ratings_to are returned as arrays
+func TestGetFilterOptions_Success(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT DISTINCT action FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"action"}).AddRow("downgraded by").AddRow("upgraded by"))
+	mock.ExpectQuery("SELECT DISTINCT rating_from FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"rating_from"}).AddRow("Buy").AddRow("Hold"))
+	mock.ExpectQuery("SELECT DISTINCT rating_to FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"rating_to"}).AddRow("Sell"))
+	mock.ExpectQuery("SELECT DISTINCT brokerage FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"brokerage"}))
+
+	w := serve("GET", "/stocks/filter-options", "/stocks/filter-options", handler.GetFilterOptions, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []interface{}{"downgraded by", "upgraded by"}, response["actions"])
+	assert.Equal(t, []interface{}{"Buy", "Hold"}, response["ratings_from"])
+	assert.Equal(t, []interface{}{"Sell"}, response["ratings_to"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetFilterOptions_IncludesBrokerages validates that distinct brokerages are returned with the other options
+func TestGetFilterOptions_IncludesBrokerages(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT DISTINCT action FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"action"}).AddRow("upgraded by"))
+	mock.ExpectQuery("SELECT DISTINCT rating_from FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"rating_from"}).AddRow("Hold"))
+	mock.ExpectQuery("SELECT DISTINCT rating_to FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"rating_to"}).AddRow("Buy"))
+	mock.ExpectQuery("SELECT DISTINCT brokerage FROM stock_ratings .* ORDER BY brokerage ASC").
+		WillReturnRows(sqlmock.NewRows([]string{"brokerage"}).AddRow("Goldman Sachs").AddRow("Morgan Stanley"))
+
+	w := serve("GET", "/stocks/filter-options", "/stocks/filter-options", handler.GetFilterOptions, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response FilterOptionsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"upgraded by"}, response.Actions)
+	assert.Equal(t, []string{"Goldman Sachs", "Morgan Stanley"}, response.Brokerages)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetFilterOptions_ClosesEachResultSet validates that every DISTINCT result set is closed,
+// and that a failing query only empties its own option list
+func TestGetFilterOptions_ClosesEachResultSet(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT DISTINCT action FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"action"}).AddRow("upgraded by")).
+		RowsWillBeClosed()
+	mock.ExpectQuery("SELECT DISTINCT rating_from FROM stock_ratings").
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery("SELECT DISTINCT rating_to FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"rating_to"}).AddRow("Buy")).
+		RowsWillBeClosed()
+	mock.ExpectQuery("SELECT DISTINCT brokerage FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"brokerage"}).AddRow("Goldman Sachs")).
+		RowsWillBeClosed()
+
+	w := serve("GET", "/stocks/filter-options", "/stocks/filter-options", handler.GetFilterOptions, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response FilterOptionsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"upgraded by"}, response.Actions)
+	assert.Empty(t, response.RatingsFrom)
+	assert.Equal(t, []string{"Buy"}, response.RatingsTo)
+	assert.Equal(t, []string{"Goldman Sachs"}, response.Brokerages)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// CONSENSUS TESTS
+
+var consensusColumns = []string{"ticker", "company", "coverage", "brokerages", "bullish", "neutral", "bearish", "avg_target", "min_target", "max_target"}
+
+func performConsensus(handler *StockHandler, query string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/consensus", "/stocks/consensus"+query, handler.GetStockConsensus, nil)
+}
+
+// TestGetStockConsensus_AggregatesPerTicker validates rating buckets and target statistics per ticker
+func TestGetStockConsensus_AggregatesPerTicker(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	rows := sqlmock.NewRows(consensusColumns).
+		AddRow("AAPL", "Apple Inc.", 4, 3, 2, 1, 1, 182.333, 160.0, 210.0).
+		AddRow("MSFT", "Microsoft", 2, 2, 0, 2, 0, nil, nil, nil)
+	mock.ExpectQuery("(?s)SELECT ticker, MAX\\(company\\), COUNT\\(\\*\\) AS coverage.*GROUP BY ticker\\s+ORDER BY coverage DESC, ticker ASC\\s+LIMIT \\$1").
+		WithArgs(5).
+		WillReturnRows(rows)
+
+	w := performConsensus(handler, "?limit=5")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ConsensusResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if !assert.Len(t, response.Consensus, 2) {
+		return
+	}
+
+	apple := response.Consensus[0]
+	assert.Equal(t, "AAPL", apple.Ticker)
+	assert.Equal(t, 4, apple.Coverage)
+	assert.Equal(t, 3, apple.Brokerages)
+	assert.Equal(t, []int{2, 1, 1}, []int{apple.Bullish, apple.Neutral, apple.Bearish})
+	assert.Equal(t, 182.33, *apple.AvgTargetPrice)
+	assert.Equal(t, 160.0, *apple.MinTargetPrice)
+	assert.Equal(t, 210.0, *apple.MaxTargetPrice)
+	assert.Equal(t, 50.0, *apple.TargetSpread)
+
+	microsoft := response.Consensus[1]
+	assert.Equal(t, 2, microsoft.Neutral)
+	assert.Nil(t, microsoft.AvgTargetPrice, "Tickers without numeric targets should return null prices")
+	assert.Nil(t, microsoft.TargetSpread)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockConsensus_InvalidLimit validates the limit bounds
+func TestGetStockConsensus_InvalidLimit(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"?limit=0", "?limit=51", "?limit=abc"} {
+		w := performConsensus(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "limit %s should be rejected", query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TRENDING TESTS
+
+var trendingColumns = []string{"ticker", "company", "actions", "upgrades", "downgrades", "latest_rating", "latest_time"}
+
+func performTrending(handler *StockHandler, query string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/trending", "/stocks/trending"+query, handler.GetTrendingStocks, nil)
+}
+
+// TestGetTrendingStocks_RanksByActivity validates that tickers come back most active first with their upgrade balance
+func TestGetTrendingStocks_RanksByActivity(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	latest := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(trendingColumns).
+		AddRow("NVDA", "NVIDIA Corporation", 9, 4, 1, "Buy", latest).
+		AddRow("AAPL", "Apple Inc.", 5, 0, 2, "Hold", latest).
+		AddRow("MSFT", "Microsoft", 2, 1, 0, nil, nil)
+	mock.ExpectQuery("(?s)COUNT\\(\\*\\) AS actions.*created_at >= NOW\\(\\) - \\(\\$1 \\* INTERVAL '1 day'\\).*ORDER BY actions DESC, ticker ASC\\s+LIMIT \\$2").
+		WithArgs(14, 3).
+		WillReturnRows(rows)
+
+	w := performTrending(handler, "?days=14&limit=3")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response TrendingResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 14, response.Days)
+	if !assert.Len(t, response.Trending, 3) {
+		return
+	}
+
+	assert.Equal(t, []string{"NVDA", "AAPL", "MSFT"}, []string{response.Trending[0].Ticker, response.Trending[1].Ticker, response.Trending[2].Ticker})
+	assert.Equal(t, 9, response.Trending[0].Actions)
+	assert.Equal(t, 3, response.Trending[0].NetUpgrades)
+	assert.Equal(t, "Buy", response.Trending[0].LatestRating)
+	assert.Equal(t, -2, response.Trending[1].NetUpgrades)
+	assert.Nil(t, response.Trending[2].LatestActionTime)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetTrendingStocks_DefaultWindow validates the 7 day, 10 ticker defaults
+func TestGetTrendingStocks_DefaultWindow(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("FROM stock_ratings").WithArgs(7, 10).WillReturnRows(sqlmock.NewRows(trendingColumns))
+
+	w := performTrending(handler, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response TrendingResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotNil(t, response.Trending, "No activity should return an empty list, not null")
+	assert.Empty(t, response.Trending)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetTrendingStocks_InvalidParams validates the days and limit bounds
+func TestGetTrendingStocks_InvalidParams(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"?days=0", "?days=366", "?days=abc", "?limit=0", "?limit=51", "?limit=abc"} {
+		w := performTrending(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s should be rejected", query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// COMPARE TESTS
+
+var compareColumns = []string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time"}
+
+func performCompare(handler *StockHandler, query string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/compare", "/stocks/compare"+query, handler.CompareStocks, nil)
+}
+
+// TestCompareStocks_TwoTickers validates the side-by-side view in request order
+func TestCompareStocks_TwoTickers(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.now = func() time.Time { return time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC) }
+
+	older := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(compareColumns).
+		AddRow("AAPL", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", "$150.00", "$180.00", newer).
+		AddRow("AAPL", "Apple Inc.", "reiterated by", "Barclays", "Hold", "Hold", "$150.00", "$150.00", older).
+		AddRow("msft", "Microsoft", "target lowered by", "Morgan Stanley", "Buy", "Hold", "$400.00", "$360.00", newer)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE UPPER(ticker) = ANY($1)")).
+		WithArgs("{\"MSFT\",\"AAPL\"}").
+		WillReturnRows(rows)
+
+	w := performCompare(handler, "?tickers=msft,%20AAPL,MSFT")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response CompareResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if !assert.Len(t, response.Stocks, 2) {
+		return
+	}
+
+	microsoft, apple := response.Stocks[0], response.Stocks[1]
+	assert.Equal(t, "MSFT", microsoft.Ticker)
+	assert.Equal(t, "Hold", microsoft.LatestRating)
+	assert.InDelta(t, -10.0, microsoft.PriceChange, 0.001)
+	assert.Equal(t, 1, microsoft.Coverage)
+
+	assert.Equal(t, "AAPL", apple.Ticker)
+	assert.Equal(t, "Buy", apple.LatestRating)
+	assert.Equal(t, "$180.00", apple.LatestTarget)
+	assert.Equal(t, "Goldman Sachs", apple.Brokerage)
+	assert.InDelta(t, 20.0, apple.PriceChange, 0.001)
+	assert.Equal(t, 2, apple.Coverage)
+	assert.Greater(t, apple.Score, microsoft.Score)
+	assert.Equal(t, getRecommendationLevel(apple.Score), apple.Recommendation)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCompareStocks_UnknownTicker validates that tickers without ratings are reported by name
+func TestCompareStocks_UnknownTicker(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	rows := sqlmock.NewRows(compareColumns).
+		AddRow("AAPL", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", "$150.00", "$180.00", time.Now())
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE UPPER(ticker) = ANY($1)")).WillReturnRows(rows)
+
+	w := performCompare(handler, "?tickers=AAPL,ZZZZ")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []interface{}{"ZZZZ"}, response["unknown_tickers"])
+	assert.Contains(t, response["error"], "ZZZZ")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCompareStocks_InvalidTickers validates the ticker count and format checks
+func TestCompareStocks_InvalidTickers(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	for _, query := range []string{"", "?tickers=AAPL", "?tickers=AAPL,aapl", "?tickers=AA,BB,CC,DD,EE,FF", "?tickers=AAPL,M1"} {
+		w := performCompare(handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%q should be rejected", query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// BROKERAGE STATS TESTS
+
+var brokerageStatsColumns = []string{"brokerage", "total", "upgrades", "downgrades", "tickers", "avg_change", "last_action"}
+
+func performBrokerageStats(handler *StockHandler, name string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/brokerage/:name", "/stocks/brokerage/"+name, handler.GetBrokerageStats, nil)
+}
+
+// TestGetBrokerageStats_MixedActions validates the statistics for a firm with upgrades and downgrades
+func TestGetBrokerageStats_MixedActions(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	lastAction := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE LOWER(brokerage) = LOWER($1)")).
+		WithArgs("goldman sachs").
+		WillReturnRows(sqlmock.NewRows(brokerageStatsColumns).
+			AddRow("Goldman Sachs", 5, 2, 1, 4, 6.666666, lastAction))
+
+	w := performBrokerageStats(handler, "goldman%20sachs")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stats BrokerageStats
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, "Goldman Sachs", stats.Brokerage, "Name should be reported as stored")
+	assert.Equal(t, 5, stats.TotalActions)
+	assert.Equal(t, 2, stats.Upgrades)
+	assert.Equal(t, 1, stats.Downgrades)
+	assert.Equal(t, 4, stats.TickersCovered)
+	if assert.NotNil(t, stats.AvgTargetChangePercent) {
+		assert.Equal(t, 6.67, *stats.AvgTargetChangePercent)
+	}
+	if assert.NotNil(t, stats.MostRecentActionTime) {
+		assert.True(t, lastAction.Equal(*stats.MostRecentActionTime))
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetBrokerageStats_UnknownBrokerage validates the 404 when a firm has no ratings
+func TestGetBrokerageStats_UnknownBrokerage(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE LOWER(brokerage) = LOWER($1)")).
+		WithArgs("Nobody Capital").
+		WillReturnRows(sqlmock.NewRows(brokerageStatsColumns).
+			AddRow(nil, 0, nil, nil, 0, nil, nil))
+
+	w := performBrokerageStats(handler, "Nobody%20Capital")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "No ratings found for brokerage Nobody Capital")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// dailyStatsColumns are the columns returned by the GetDailyStats query
+var dailyStatsColumns = []string{"bucket", "count", "upgrades", "downgrades", "avg"}
+
+// performDailyStats calls GET /stocks/stats/daily with the given query string
+func performDailyStats(handler *StockHandler, query string) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/stats/daily", "/stocks/stats/daily"+query, handler.GetDailyStats, nil)
+}
+
+// TestGetDailyStats_FillsGaps validates the grouping query and that quiet days are returned with zeros
+func TestGetDailyStats_FillsGaps(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT DATE(date_trunc($3, time)) AS bucket, COUNT(*),") + ".*" +
+		regexp.QuoteMeta("WHERE time >= $1 AND time < $2 GROUP BY bucket ORDER BY bucket")).
+		WithArgs(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC), "day").
+		WillReturnRows(sqlmock.NewRows(dailyStatsColumns).
+			AddRow(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), 5, 2, 1, 6.666666).
+			AddRow(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), 3, 0, 2, nil))
+
+	w := performDailyStats(handler, "?from=2025-01-13&to=2025-01-16")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response DailyStatsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-01-13", response.From)
+	assert.Equal(t, "2025-01-16", response.To)
+	assert.Equal(t, "day", response.Granularity)
+	assert.Equal(t, []DailyStat{
+		{Date: "2025-01-13", TotalActions: 5, Upgrades: 2, Downgrades: 1, AvgTargetChange: 6.67},
+		{Date: "2025-01-14"},
+		{Date: "2025-01-15", TotalActions: 3, Downgrades: 2},
+		{Date: "2025-01-16"},
+	}, response.Data)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetDailyStats_WeekAndMonth validates that buckets start on Mondays and on the first of the month
+func TestGetDailyStats_WeekAndMonth(t *testing.T) {
+	tests := []struct {
+		query       string
+		granularity string
+		row         time.Time
+		expected    []string
+	}{
+		// 2025-01-08 is a Wednesday, its week starts on Monday 2025-01-06
+		{"?from=2025-01-08&to=2025-01-21&granularity=week", "week", time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), []string{"2025-01-06", "2025-01-13", "2025-01-20"}},
+		{"?from=2024-12-15&to=2025-02-10&granularity=MONTH", "month", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), []string{"2024-12-01", "2025-01-01", "2025-02-01"}},
+	}
+
+	for _, test := range tests {
+		handler, mock, db := setupTestHandler()
+
+		mock.ExpectQuery(regexp.QuoteMeta("GROUP BY bucket")).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), test.granularity).
+			WillReturnRows(sqlmock.NewRows(dailyStatsColumns).AddRow(test.row, 4, 1, 1, 2.5))
+
+		w := performDailyStats(handler, test.query)
+
+		assert.Equal(t, http.StatusOK, w.Code, test.query)
+		var response DailyStatsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		var dates []string
+		for _, stat := range response.Data {
+			dates = append(dates, stat.Date)
+			if stat.Date == test.row.Format("2006-01-02") {
+				assert.Equal(t, 4, stat.TotalActions, test.query)
+			} else {
+				assert.Zero(t, stat.TotalActions, test.query)
+			}
+		}
+		assert.Equal(t, test.expected, dates, test.query)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	}
+}
+
+// TestGetDailyStats_DefaultRange validates the default last 30 days ending today
+func TestGetDailyStats_DefaultRange(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	handler.now = func() time.Time { return time.Date(2025, 1, 31, 18, 45, 0, 0, time.UTC) }
+
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY bucket")).
+		WithArgs(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), "day").
+		WillReturnRows(sqlmock.NewRows(dailyStatsColumns))
+
+	w := performDailyStats(handler, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response DailyStatsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-01-02", response.From)
+	assert.Equal(t, "2025-01-31", response.To)
+	if assert.Len(t, response.Data, 30) {
+		assert.Equal(t, "2025-01-02", response.Data[0].Date)
+		assert.Equal(t, "2025-01-31", response.Data[29].Date)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetDailyStats_InvalidParams validates that bad parameters are rejected before querying
+func TestGetDailyStats_InvalidParams(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"?granularity=year", "granularity must be one of: day, week, month"},
+		{"?from=01/13/2025", "from must be a date in YYYY-MM-DD format"},
+		{"?to=yesterday", "to must be a date in YYYY-MM-DD format"},
+		{"?from=2025-02-01&to=2025-01-01", "from must be before or equal to to"},
+		{"?from=2000-01-01&to=2025-01-01", "Date range too large (max 3660 days)"},
+	}
+
+	for _, test := range tests {
+		handler, mock, db := setupTestHandler()
+
+		w := performDailyStats(handler, test.query)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, test.query)
+		assert.Contains(t, w.Body.String(), test.expected)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	}
+}
+
+// SECTOR TESTS
+
+// TestStoreStock_PersistsSector validates that the sector from the external API is stored with the rating
+func TestStoreStock_PersistsSector(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	var stock models.StockRatings
+	json.Unmarshal([]byte(`{"ticker":"AAPL","company":"Apple Inc.","target_from":"$150.00","target_to":"$180.00","action":"target raised by","brokerage":"Goldman Sachs","rating_from":"Hold","rating_to":"Buy","time":"2025-01-15T10:30:00Z","sector":"Technology"}`), &stock)
+
+	mock.ExpectExec(regexp.QuoteMeta("NULLIF($11, '')")).
+		WithArgs("AAPL", "$150.00", "$180.00", "Apple Inc.", "target raised by", "Goldman Sachs", "Hold", "Buy", stock.Time, sqlmock.AnyArg(), "Technology", "150.00", "180.00").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	inserted, err := handler.storeStock(stock)
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSearchStockRatings_SectorFilter validates that the sector filter combines with other filters
+func TestSearchStockRatings_SectorFilter(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	where := "WHERE LOWER(action) = LOWER($1) AND LOWER(sector) = LOWER($2)"
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM stock_ratings " + where)).
+		WithArgs("upgraded by", "Technology").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta(where)).
+		WithArgs("upgraded by", "Technology", 20, 0).
+		WillReturnRows(sqlmock.NewRows(stockRatingColumns))
+
+	body := `{"page_number": 1, "page_length": 20, "action": "upgraded by", "sector": "Technology"}`
+	w := serve("POST", "/stocks/search", "/stocks/search", handler.SearchStockRatings, bytes.NewBufferString(body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSearchStockRatings_PriceRangeUsesNumericColumns validates that price filters compare the numeric target columns
+// Purpose: Searches must not re-parse price strings, and a single "N/A" target (NULL number) must not turn them into a 500
+func TestSearchStockRatings_PriceRangeUsesNumericColumns(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	where := "WHERE target_from_num >= $1 AND target_to_num <= $2"
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM stock_ratings " + where)).
+		WithArgs(100.0, 500.0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta(where)).
+		WithArgs(100.0, 500.0, 20, 0).
+		WillReturnRows(sqlmock.NewRows(stockRatingColumns))
+
+	body := `{"page_number": 1, "page_length": 20, "target_from_min": 100, "target_to_max": 500}`
+	w := serve("POST", "/stocks/search", "/stocks/search", handler.SearchStockRatings, bytes.NewBufferString(body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func performGetSectors(handler *StockHandler) *httptest.ResponseRecorder {
+	return serve("GET", "/stocks/sectors", "/stocks/sectors", handler.GetStockSectors, nil)
+}
+
+// TestGetStockSectors validates that distinct non-null sectors are returned sorted
+func TestGetStockSectors(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT sector FROM stock_ratings WHERE sector IS NOT NULL AND sector != '' ORDER BY sector ASC")).
+		WillReturnRows(sqlmock.NewRows([]string{"sector"}).AddRow("Healthcare").AddRow("Technology"))
+
+	w := performGetSectors(handler)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"sectors": ["Healthcare", "Technology"]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockSectors_Empty validates that a database without sector data returns an empty list, not null
+func TestGetStockSectors_Empty(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT DISTINCT sector").WillReturnRows(sqlmock.NewRows([]string{"sector"}))
+
+	w := performGetSectors(handler)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"sectors": []}`, w.Body.String())
+}
+
+// TestGetStockSectors_DatabaseError validates the 500 response when the query fails
+func TestGetStockSectors_DatabaseError(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT DISTINCT sector").WillReturnError(errors.New("column \"sector\" does not exist"))
+
+	w := performGetSectors(handler)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to query stock sectors")
+}
+
+// CSV EXPORT TESTS
+
+// TestExportStockRatings validates that filtered ratings are streamed as a CSV attachment
+func TestExportStockRatings(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	ratingTime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(stockRatingColumns).
+		AddRow(1, "AAPL", "$150.00", "$180.00", "Apple, Inc.", "upgraded by", "Goldman Sachs", "Hold", "Buy", ratingTime, ratingTime).
+		AddRow(2, "MSFT", "$300.00", "$350.00", "Microsoft", "upgraded by", "Morgan Stanley", "Hold", "Buy", ratingTime, ratingTime)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE LOWER(action) = LOWER($1)")).
+		WithArgs("upgraded by").
+		WillReturnRows(rows)
+
+	body := `{"action": "upgraded by"}`
+	w := serve("POST", "/stocks/export", "/stocks/export", handler.ExportStockRatings, bytes.NewBufferString(body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment; filename=")
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "id,ticker,target_from,target_to,company,action,brokerage,rating_from,rating_to,time,created_at", lines[0])
+	assert.Equal(t, `1,AAPL,$150.00,$180.00,"Apple, Inc.",upgraded by,Goldman Sachs,Hold,Buy,2025-01-15T10:30:00Z,2025-01-15T10:30:00Z`, lines[1])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestExportStockRatings_AbortLogged validates that a failure after the headers were sent is logged with its row count
+func TestExportStockRatings_AbortLogged(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	logs := captureLogs(handler, slog.LevelInfo)
+
+	ratingTime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(stockRatingColumns).
+		AddRow(1, "AAPL", "$150.00", "$180.00", "Apple Inc.", "upgraded by", "Goldman Sachs", "Hold", "Buy", ratingTime, ratingTime).
+		RowError(0, errors.New("connection reset"))
+	mock.ExpectQuery("FROM stock_ratings").WillReturnRows(rows)
+
+	w := serve("POST", "/stocks/export", "/stocks/export", handler.ExportStockRatings, bytes.NewBufferString(`{}`))
+
+	assert.Equal(t, http.StatusOK, w.Code, "the headers were already sent")
+	records := logs()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "csv export aborted", records[0]["msg"])
+		assert.Equal(t, float64(0), records[0]["rows_written"])
+		assert.Equal(t, "connection reset", records[0]["error"])
+	}
+}
+
+// TestExportStockRatings_InvalidJSON validates that malformed filters are rejected before querying
+func TestExportStockRatings_InvalidJSON(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	w := serve("POST", "/stocks/export", "/stocks/export", handler.ExportStockRatings, bytes.NewBufferString("{invalid"))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// performCount posts body to /stocks/count
+func performCount(handler *StockHandler, body string) *httptest.ResponseRecorder {
+	return serve("POST", "/stocks/count", "/stocks/count", handler.GetStockCount, bytes.NewBufferString(body))
+}
+
+// TestGetStockCount_Unfiltered validates that an empty filter counts every rating with a single query
+func TestGetStockCount_Unfiltered(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(`^SELECT COUNT\(\*\) FROM stock_ratings\s*$`).
+		WithArgs().
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1250))
+
+	w := performCount(handler, `{}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count": 1250}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockCount_Filtered validates that the search filters apply and pagination fields are ignored
+func TestGetStockCount_Filtered(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM stock_ratings WHERE LOWER(action) = LOWER($1) AND LOWER(brokerage) LIKE LOWER($2) AND target_to_num >= $3")).
+		WithArgs("upgraded by", "%goldman%", 100.0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+	w := performCount(handler, `{"action": "upgraded by", "brokerage_contains": "goldman", "target_to_min": 100, "page_number": 3, "page_length": 5}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count": 7}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockCount_Errors validates bad filters and query failures
+func TestGetStockCount_Errors(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	w := performCount(handler, `{"time_from": "2025-02-01T00:00:00Z", "time_to": "2025-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "time_from must be before or equal to time_to")
+
+	mock.ExpectQuery("SELECT COUNT").WillReturnError(errors.New("connection refused"))
+	w = performCount(handler, `{}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to count stock ratings")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// AI RATE LIMIT TESTS
+
+// TestGetStockSummary_RateLimited validates that requests beyond AI_RATE_LIMIT_PER_MINUTE get 429
+// Purpose: Bursts must not exhaust the OpenAI quota
+func TestGetStockSummary_RateLimited(t *testing.T) {
+	t.Setenv("AI_RATE_LIMIT_PER_MINUTE", "3")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	// Only the allowed requests reach the database, empty data avoids calling OpenAI
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("SELECT ticker, company, action").
+			WillReturnRows(sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at"}))
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/summary", handler.GetStockSummary)
+	router.POST("/stocks/chat", handler.GetStockChat)
+
+	var codes []int
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/summary", nil))
+		codes = append(codes, w.Code)
+		if w.Code == http.StatusTooManyRequests {
+			retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+			assert.NoError(t, err)
+			assert.Greater(t, retryAfter, 0)
+		}
+	}
+	assert.Equal(t, []int{200, 200, 200, 429, 429}, codes)
+
+	// The limiter is shared, so chat is rejected as well
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/stocks/chat", bytes.NewBufferString(`{"message": "hi"}`)))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestNewAIRateLimiter_Disabled validates that a limit of 0 never rejects requests
+func TestNewAIRateLimiter_Disabled(t *testing.T) {
+	limiter := newAIRateLimiter(0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, limiter.Allow())
+	}
+}
+
+// OPENAI MODEL TESTS
+
+// newMockOpenAI serves canned chat completions and records the model of every request
+func newMockOpenAI(models *[]string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		*models = append(*models, body.Model)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"content": "Mock answer"}}], "usage": {"total_tokens": 42}}`))
+	}))
+}
+
+// TestGenerateAISummary_ConfiguredModel validates that OPENAI_MODEL is sent to OpenAI
+func TestGenerateAISummary_ConfiguredModel(t *testing.T) {
+	t.Setenv("OPENAI_MODEL", "gpt-4o-mini")
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var models []string
+	server := newMockOpenAI(&models)
+	defer server.Close()
+	handler.openAI = NewHTTPOpenAIClient(server.URL, "test-key")
+
+	summary, tokens, err := handler.generateAISummary(context.Background(), []StockRecommendation{{Ticker: "AAPL", Company: "Apple Inc."}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Mock answer", summary)
+	assert.Equal(t, 42, tokens)
+	assert.Equal(t, []string{"gpt-4o-mini"}, models)
+}
+
+// TestNewStockHandler_DefaultModel validates the fallback model name
+func TestNewStockHandler_DefaultModel(t *testing.T) {
+	t.Setenv("OPENAI_MODEL", "")
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	assert.Equal(t, defaultOpenAIModel, handler.openAIModel)
+}
+
+// performChatWithCachedContext posts a chat message whose topic matches the cached memory,
+// so no SQL generation call is made and only the answer hits OpenAI
+func performChatWithCachedContext(handler *StockHandler, model string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(ChatRequest{
+		Message:            "What about AAPL?",
+		ConversationMemory: &ConversationMemory{KeyTopics: []string{"AAPL"}, LastContext: "AAPL upgraded to Buy"},
+		Model:              model,
+	})
+	return serve("POST", "/stocks/chat", "/stocks/chat", handler.GetStockChat, bytes.NewBuffer(body))
+}
+
+// TestGetStockChat_ModelOverride validates the per-request model field and its allowlist
+func TestGetStockChat_ModelOverride(t *testing.T) {
+	t.Setenv("OPENAI_MODEL", "")
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	var models []string
+	server := newMockOpenAI(&models)
+	defer server.Close()
+	handler.openAI = NewHTTPOpenAIClient(server.URL, "test-key")
+
+	w := performChatWithCachedContext(handler, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = performChatWithCachedContext(handler, "gpt-4.1-mini")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = performChatWithCachedContext(handler, "my-expensive-model")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid model")
+
+	assert.Equal(t, []string{defaultOpenAIModel, "gpt-4.1-mini"}, models, "Rejected models must not reach OpenAI")
+}
+
+// OPENAI CLIENT TESTS
+
+// fakeOpenAIClient returns a canned completion and records the requests it receives
+type fakeOpenAIClient struct {
+	content   string
+	responses []string // Returned by ChatCompletion in order before falling back to content
+	tokens    int
+	err       error
+	requests  []ChatCompletionRequest
+}
+
+func (f *fakeOpenAIClient) ChatCompletion(ctx context.Context, req ChatCompletionRequest) (string, int, error) {
+	f.requests = append(f.requests, req)
+	if len(f.responses) > 0 {
+		content := f.responses[0]
+		f.responses = f.responses[1:]
+		return content, f.tokens, f.err
+	}
+	return f.content, f.tokens, f.err
+}
+
+func (f *fakeOpenAIClient) ChatCompletionStream(ctx context.Context, req ChatCompletionRequest, onDelta func(string) error) (string, int, error) {
+	f.requests = append(f.requests, req)
+	if f.err != nil {
+		return "", 0, f.err
+	}
+	if err := onDelta(f.content); err != nil {
+		return "", 0, err
+	}
+	return f.content, f.tokens, nil
+}
+
+// summaryRows returns mock rows for the summary query with one strongly recommended stock
+func summaryRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"ticker", "company", "action", "brokerage", "rating_from", "rating_to", "target_from", "target_to", "time", "created_at", "sector"}).
+		AddRow("TOP", "Top Corp", "upgraded by", "Goldman Sachs", "Sell", "Strong Buy", "$100.00", "$150.00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), time.Now(), "Technology")
+}
+
+// performGetSummary performs GET /stocks/summary and decodes the response
+func performGetSummary(t *testing.T, handler *StockHandler) SummaryResponse {
+	w := serve("GET", "/stocks/summary", "/stocks/summary", handler.GetStockSummary, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response SummaryResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+// TestGetStockSummary_Cached validates that repeated summary requests reuse the AI summary until invalidated
+// Purpose: Dashboard reloads must not call OpenAI again while the data hasn't changed
+func TestGetStockSummary_Cached(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{content: "Markets look bullish", tokens: 120}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	mock.ExpectQuery("SELECT ticker, company, action").WillReturnRows(summaryRows())
+	first := performGetSummary(t, handler)
+	assert.Equal(t, "Markets look bullish", first.Summary)
+	assert.False(t, first.FromCache)
+	assert.Empty(t, first.CachedAt)
+
+	second := performGetSummary(t, handler)
+	assert.Equal(t, "Markets look bullish", second.Summary)
+	assert.True(t, second.FromCache)
+	assert.NotEmpty(t, second.CachedAt)
+	assert.Len(t, fake.requests, 1, "The cached summary should not call OpenAI again")
+
+	// New data was stored, the next request generates a fresh summary
+	handler.summaryCache.Invalidate()
+	mock.ExpectQuery("SELECT ticker, company, action").WillReturnRows(summaryRows())
+	third := performGetSummary(t, handler)
+	assert.False(t, third.FromCache)
+	assert.Len(t, fake.requests, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestFetchStocksBulkParallel_InvalidatesSummaryCache validates that a bulk ingest drops the cached AI summary
+func TestFetchStocksBulkParallel_InvalidatesSummaryCache(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	server := newBulkMockAPI()
+	defer server.Close()
+	handler.SetBaseURL(server.URL)
+
+	handler.summaryCache.Set(SummaryResponse{Summary: "Outdated"})
+	expectMultiRowInsert(mock, bulkMockStocks...)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stock_ratings").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	_, err := handler.fetchStocksBulkParallel(context.Background(), 1, 1, handler.bulkSettings)
+
+	assert.NoError(t, err)
+	_, _, ok := handler.summaryCache.Get()
+	assert.False(t, ok, "The cached summary should be dropped after storing new data")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestAIEndpoints_MissingAPIKey validates that chat endpoints answer 503 when OPENAI_API_KEY is not set
+// Purpose: A missing key is a configuration problem, not a vague internal error
+// The summary endpoint falls back to a templated summary instead, see TestGetStockSummary_FallbackOnAIError
+func TestAIEndpoints_MissingAPIKey(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/chat", handler.GetStockChat)
+	router.POST("/stocks/chat/stream", handler.GetStockChatStream)
+
+	requests := []*http.Request{
+		httptest.NewRequest("POST", "/stocks/chat", bytes.NewBufferString(`{"message": "How is AAPL rated?"}`)),
+		httptest.NewRequest("POST", "/stocks/chat/stream", bytes.NewBufferString(`{"message": "How is AAPL rated?"}`)),
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, req.URL.Path)
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "AI features unavailable: OPENAI_API_KEY not configured", response["error"], req.URL.Path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockSummary_FallbackOnAIError validates the templated summary when the AI call fails
+// Purpose: The dashboard still gets a useful summary while OpenAI is down or not configured
+func TestGetStockSummary_FallbackOnAIError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{err: errors.New("OpenAI API error: service unavailable")}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	mock.ExpectQuery("SELECT ticker, company, action").WillReturnRows(summaryRows())
+
+	response := performGetSummary(t, handler)
+
+	assert.Equal(t, "fallback", response.Source)
+	assert.Equal(t, 0, response.TokensUsed)
+	assert.Contains(t, response.Summary, "Top picks: TOP (")
+	assert.Contains(t, response.Summary, "rated Strong Buy")
+	assert.Contains(t, response.Summary, "Average target change: +50.0%")
+	assert.Len(t, fake.requests, 1)
+	_, _, cached := handler.summaryCache.Get()
+	assert.False(t, cached, "Fallback summaries should not be cached")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockSummary_AIDisabled validates that ai=false skips OpenAI entirely
+func TestGetStockSummary_AIDisabled(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{content: "Markets look bullish", tokens: 120}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	mock.ExpectQuery("SELECT ticker, company, action").WillReturnRows(summaryRows())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stocks/summary", handler.GetStockSummary)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/summary?ai=false", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response SummaryResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "fallback", response.Source)
+	assert.Contains(t, response.Summary, "Top picks:")
+	assert.Empty(t, fake.requests)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/summary?ai=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestFallbackSummaryResponse validates the templated summary text
+func TestFallbackSummaryResponse(t *testing.T) {
+	recommendations := []StockRecommendation{
+		{Ticker: "NVDA", Recommendation: "Strong Buy", Score: 9.0, TargetPrice: "$150.00", PriceChange: 30},
+		{Ticker: "AAPL", Recommendation: "Buy", Score: 7.5, TargetPrice: "$200.00", PriceChange: 10},
+		{Ticker: "MSFT", Recommendation: "Strong Buy", Score: 8.6, TargetPrice: "$450.00", PriceChange: 0},
+		{Ticker: "PFE", Recommendation: "Moderate Buy", Score: 6.0, TargetPrice: "$30.00", PriceChange: -8},
+	}
+
+	response := fallbackSummaryResponse(recommendations)
+
+	assert.Equal(t, "Top picks: NVDA (Strong Buy, score 9.0, target $150.00), AAPL (Buy, score 7.5, target $200.00), MSFT (Strong Buy, score 8.6, target $450.00). "+
+		"2 of 4 recommended stocks are rated Strong Buy. Average target change: +8.0%.", response.Summary)
+	assert.Equal(t, "fallback", response.Source)
+
+	empty := fallbackSummaryResponse(nil)
+	assert.Contains(t, empty.Summary, "No stock recommendations available")
+}
+
+// TestCheckAIConfigured validates that only the default client without a key is reported as unavailable
+func TestCheckAIConfigured(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	t.Setenv("OPENAI_API_KEY", "")
+	assert.ErrorIs(t, NewStockHandler(db).checkAIConfigured(), errAIUnavailable)
+	assert.NoError(t, NewStockHandler(db, WithOpenAIClient(&fakeOpenAIClient{})).checkAIConfigured(), "Injected clients bring their own credentials")
+
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	assert.NoError(t, NewStockHandler(db).checkAIConfigured())
+}
+
+// TestBuildSummaryPrompt_Aggregates validates the computed aggregate lines of the summary prompt
+// Purpose: The model should summarize real counts and shares instead of guessing them
+func TestBuildSummaryPrompt_Aggregates(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	recommendations := []StockRecommendation{
+		{Ticker: "NVDA", CurrentRating: "Strong Buy", Recommendation: "Strong Buy", Score: 9.0, PriceChange: 30, Sector: "Technology"},
+		{Ticker: "AAPL", CurrentRating: "Buy", Recommendation: "Buy", Score: 7.5, PriceChange: 10, Sector: "Technology"},
+		{Ticker: "PFE", CurrentRating: "Underperform", Recommendation: "Moderate Buy", Score: 6.0, PriceChange: -4, Sector: "Healthcare"},
+		{Ticker: "XYZ", CurrentRating: "Hold", Recommendation: "Moderate Buy", Score: 6.5, PriceChange: 0},
+	}
+
+	prompt := handler.buildSummaryPrompt(recommendations)
+
+	assert.Contains(t, prompt, "AGGREGATES (4 recommendations")
+	assert.Contains(t, prompt, "Bullish ratings: 2 | Bearish ratings: 1 | Strong Buys: 1 | Average score: 7.25 | Average price change: 9.0%")
+	assert.Contains(t, prompt, "Recommendations per sector: Technology 2, Healthcare 1, Unknown 1")
+}
+
+// TestBuildSummaryPrompt_NoSectors validates that the sector line is left out when no sector is known
+func TestBuildSummaryPrompt_NoSectors(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	prompt := handler.buildSummaryPrompt([]StockRecommendation{{Ticker: "AAPL", CurrentRating: "Buy", Score: 8.0, PriceChange: 12.5}})
+
+	assert.Contains(t, prompt, "Bullish ratings: 1 | Bearish ratings: 0 | Strong Buys: 0 | Average score: 8.00 | Average price change: 12.5%")
+	assert.NotContains(t, prompt, "Recommendations per sector")
+}
+
+// TestGenerateAISummary_FakeClient validates the summary path through an injected client
+func TestGenerateAISummary_FakeClient(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{content: "Markets look bullish", tokens: 120}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	summary, tokens, err := handler.generateAISummary(context.Background(), []StockRecommendation{{Ticker: "AAPL", Company: "Apple Inc."}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Markets look bullish", summary)
+	assert.Equal(t, 120, tokens)
+
+	assert.Len(t, fake.requests, 1)
+	assert.Equal(t, 200, fake.requests[0].MaxTokens)
+	assert.Equal(t, "system", fake.requests[0].Messages[0].Role)
+	assert.Contains(t, fake.requests[0].Messages[1].Content, "AAPL")
+}
+
+// TestGetStockChat_OpenAIError validates that client errors surface as a 500
+func TestGetStockChat_OpenAIError(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{err: errors.New("OpenAI API error: quota exceeded")}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	w := performChatWithCachedContext(handler, "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "quota exceeded")
+	assert.Len(t, fake.requests, 1)
+}
+
+// TestGenerateSQLFromQuestion_EmptyChoices validates the error when OpenAI returns no choices
+func TestGenerateSQLFromQuestion_EmptyChoices(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	handler := NewStockHandler(db, WithOpenAIClient(&fakeOpenAIClient{err: errNoChoices}))
+
+	_, err := handler.generateSQLFromQuestion(context.Background(), "top stocks")
+	assert.EqualError(t, err, "no SQL generated")
+}
+
+// TestGenerateSQLFromQuestion_StripsBackticks validates the SQL cleanup of the completion
+func TestGenerateSQLFromQuestion_StripsBackticks(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{content: "  `SELECT ticker FROM stock_ratings LIMIT 5`  "}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	sqlQuery, err := handler.generateSQLFromQuestion(context.Background(), "top stocks")
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT ticker FROM stock_ratings LIMIT 5", sqlQuery)
+	assert.Equal(t, 0.1, fake.requests[0].Temperature)
+}
+
+// TestHTTPOpenAIClient_Errors validates how the HTTP client maps OpenAI error payloads
+func TestHTTPOpenAIClient_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"API error", `{"error": {"message": "Invalid API key"}}`, "OpenAI API error: Invalid API key"},
+		{"Empty choices", `{"choices": []}`, errNoChoices.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewHTTPOpenAIClient(server.URL, "test-key")
+			_, _, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{Model: defaultOpenAIModel})
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+// OPENAI RETRY TESTS
+
+// newFlakyOpenAI fails the first failures requests with status, then answers normally
+func newFlakyOpenAI(failures int, status int, attempts *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(atomic.AddInt32(attempts, 1)) <= failures {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error": {"message": "try again"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"content": "Recovered"}}], "usage": {"total_tokens": 7}}`))
+	}))
+}
+
+// newTestOpenAIClient builds the HTTP client without backoff delays
+func newTestOpenAIClient(url string) *httpOpenAIClient {
+	client := NewHTTPOpenAIClient(url, "test-key").(*httpOpenAIClient)
+	client.backoff = func(int) time.Duration { return 0 }
+	return client
+}
+
+// TestHTTPOpenAIClient_RetriesTransientErrors validates that 5xx answers are retried until success
+func TestHTTPOpenAIClient_RetriesTransientErrors(t *testing.T) {
+	var attempts int32
+	server := newFlakyOpenAI(2, http.StatusServiceUnavailable, &attempts)
+	defer server.Close()
+
+	content, tokens, err := newTestOpenAIClient(server.URL).ChatCompletion(context.Background(), ChatCompletionRequest{Model: defaultOpenAIModel})
+	assert.NoError(t, err)
+	assert.Equal(t, "Recovered", content)
+	assert.Equal(t, 7, tokens)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+// TestHTTPOpenAIClient_RateLimited validates that a persistent 429 gives up after the max attempts
+func TestHTTPOpenAIClient_RateLimited(t *testing.T) {
+	var attempts int32
+	server := newFlakyOpenAI(100, http.StatusTooManyRequests, &attempts)
+	defer server.Close()
+
+	_, _, err := newTestOpenAIClient(server.URL).ChatCompletion(context.Background(), ChatCompletionRequest{Model: defaultOpenAIModel})
+	assert.ErrorIs(t, err, errOpenAIRateLimited)
+	assert.Equal(t, int32(openAIMaxAttempts), atomic.LoadInt32(&attempts))
+
+	// The chat endpoint reports the rate limit as a 429 instead of a generic 500
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	handler := NewStockHandler(db, WithOpenAIClient(newTestOpenAIClient(server.URL)))
+
+	w := performChatWithCachedContext(handler, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "rate limit")
+}
+
+// TestHTTPOpenAIClient_NoRetryOnClientError validates that 4xx answers other than 429 are not retried
+func TestHTTPOpenAIClient_NoRetryOnClientError(t *testing.T) {
+	var attempts int32
+	server := newFlakyOpenAI(100, http.StatusUnauthorized, &attempts)
+	defer server.Close()
+
+	_, _, err := newTestOpenAIClient(server.URL).ChatCompletion(context.Background(), ChatCompletionRequest{Model: defaultOpenAIModel})
+	assert.EqualError(t, err, "OpenAI API error: try again")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+// TestHTTPOpenAIClient_AttemptTimeout validates that a hanging attempt is abandoned and retried
+func TestHTTPOpenAIClient_AttemptTimeout(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			// Hang past the attempt timeout, the body must be read for disconnects to be noticed
+			io.ReadAll(r.Body)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"content": "Recovered"}}]}`))
+	}))
+	defer server.Close()
+
+	client := newTestOpenAIClient(server.URL)
+	client.attemptTimeout = 50 * time.Millisecond
+
+	content, _, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{Model: defaultOpenAIModel})
+	assert.NoError(t, err)
+	assert.Equal(t, "Recovered", content)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+// RAG SQL TIMEOUT TESTS
+
+// TestExecuteSafeSQL_ReadOnlyWithStatementTimeout validates the transaction wrapping AI-generated SQL
+func TestExecuteSafeSQL_ReadOnlyWithStatementTimeout(t *testing.T) {
+	t.Setenv("RAG_SQL_TIMEOUT_MS", "")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout = 5000").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT ticker FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"ticker"}).AddRow("AAPL").AddRow("MSFT"))
+	mock.ExpectRollback()
+
+	results, err := handler.executeSafeSQL(context.Background(), "SELECT ticker FROM stock_ratings LIMIT 2")
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "AAPL", results[0]["ticker"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestExecuteSafeSQL_Timeout validates that a slow query is cancelled once RAG_SQL_TIMEOUT_MS elapses
+func TestExecuteSafeSQL_Timeout(t *testing.T) {
+	t.Setenv("RAG_SQL_TIMEOUT_MS", "50")
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout = 50").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT ticker FROM stock_ratings").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"ticker"}).AddRow("AAPL"))
+	mock.ExpectRollback()
+
+	start := time.Now()
+	_, err := handler.executeSafeSQL(context.Background(), "SELECT ticker FROM stock_ratings")
+	assert.ErrorIs(t, err, errRAGQueryTimeout)
+	assert.Contains(t, err.Error(), "query timed out")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "Timeout should fire before the query finishes")
+}
+
+// TestGetStockChat_RAGQueryTimeout validates that a timed out generated query is reported as a 504
+func TestGetStockChat_RAGQueryTimeout(t *testing.T) {
+	t.Setenv("RAG_SQL_TIMEOUT_MS", "50")
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	handler := NewStockHandler(db, WithOpenAIClient(&fakeOpenAIClient{content: "SELECT * FROM stock_ratings"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT ticker, company, .* FROM stock_ratings LIMIT 50").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"ticker"}))
+	mock.ExpectRollback()
+
+	w := serve("POST", "/stocks/chat", "/stocks/chat", handler.GetStockChat, bytes.NewBufferString(`{"message": "Show me everything"}`))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "timed out")
+}
+
+// CHAT SESSION TESTS
+
+// expectRAGQuery mocks one read-only execution of AI-generated SQL
+func expectRAGQuery(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT ticker FROM stock_ratings").
+		WillReturnRows(sqlmock.NewRows([]string{"ticker"}).AddRow("AAPL"))
+	mock.ExpectRollback()
+}
+
+func performChat(router *gin.Engine, request ChatRequest) (*httptest.ResponseRecorder, ChatResponse) {
+	body, _ := json.Marshal(request)
+	req := httptest.NewRequest("POST", "/stocks/chat", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response ChatResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+// TestGetStockChat_SessionMemory validates that requests sharing a session_id accumulate topics server-side
+// Purpose: Memory must not depend on (or trust) what the client sends back
+func TestGetStockChat_SessionMemory(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	handler := NewStockHandler(db, WithOpenAIClient(&fakeOpenAIClient{content: "SELECT ticker FROM stock_ratings"}))
+
+	// Both messages are about different tickers, so each one runs a fresh RAG query
+	expectRAGQuery(mock)
+	expectRAGQuery(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/chat", handler.GetStockChat)
+
+	w, first := performChat(router, ChatRequest{Message: "AAPL", SessionID: "session-1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "session-1", first.SessionID)
+
+	// A fabricated client memory is ignored in favour of the stored one
+	w, _ = performChat(router, ChatRequest{
+		Message:            "MSFT",
+		SessionID:          "session-1",
+		ConversationMemory: &ConversationMemory{Summary: "fabricated", KeyTopics: []string{"EVIL"}},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	memory, ok := handler.chatSessions.Get("session-1")
+	assert.True(t, ok)
+	assert.ElementsMatch(t, []string{"AAPL", "MSFT"}, memory.KeyTopics)
+	assert.NotContains(t, memory.Summary, "fabricated")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestRetrieveRelevantData_CorrectsFailedSQL validates that a failing query is sent back to the model once and the fix runs
+func TestRetrieveRelevantData_CorrectsFailedSQL(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{responses: []string{
+		"SELECT ticker, DATEADD(day, -7, time) FROM stock_ratings LIMIT 5",
+		"SELECT ticker FROM stock_ratings WHERE time > NOW() - INTERVAL '7 days' LIMIT 5",
+	}}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("DATEADD").WillReturnError(errors.New(`pq: function dateadd(unknown, integer, timestamp without time zone) does not exist`))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INTERVAL '7 days'").WillReturnRows(sqlmock.NewRows([]string{"ticker"}).AddRow("AAPL"))
+	mock.ExpectRollback()
+
+	rag, err := handler.retrieveRelevantData(context.Background(), "What changed this week?")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rag.RowCount)
+	assert.Contains(t, rag.SQL, "INTERVAL '7 days'")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The correction request replays the failed query and its error
+	if assert.Len(t, fake.requests, 2) {
+		messages := fake.requests[1].Messages
+		assert.Equal(t, "assistant", messages[len(messages)-2].Role)
+		assert.Contains(t, messages[len(messages)-2].Content, "DATEADD")
+		assert.Contains(t, messages[len(messages)-1].Content, "function dateadd")
+	}
+}
+
+// TestRetrieveRelevantData_CorrectionFailsOnce validates that only one correction is attempted
+func TestRetrieveRelevantData_CorrectionFailsOnce(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{content: "SELECT 1"}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	_, err := handler.retrieveRelevantData(context.Background(), "Anything")
+	assert.ErrorContains(t, err, "FROM stock_ratings")
+	assert.Len(t, fake.requests, 2, "Original generation plus one correction")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockChat_SessionIDTooLong validates the session_id length limit
+func TestGetStockChat_SessionIDTooLong(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	handler := NewStockHandler(db, WithOpenAIClient(&fakeOpenAIClient{}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/chat", handler.GetStockChat)
+
+	w, _ := performChat(router, ChatRequest{Message: "AAPL", SessionID: strings.Repeat("x", maxSessionIDLength+1)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestGetStockChat_Debug validates that generated_sql and row_count are only returned with ?debug=true
+func TestGetStockChat_Debug(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	handler := NewStockHandler(db, WithOpenAIClient(&fakeOpenAIClient{content: "SELECT ticker FROM stock_ratings"}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/chat", handler.GetStockChat)
+
+	postChat := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"message": "AAPL"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	expectRAGQuery(mock)
+	w := postChat("/stocks/chat?debug=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ChatResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.NotNil(t, response.GeneratedSQL) && assert.NotNil(t, response.RowCount) {
+		assert.Equal(t, "SELECT ticker FROM stock_ratings", *response.GeneratedSQL)
+		assert.Equal(t, 1, *response.RowCount)
+	}
+
+	// Off by default
+	expectRAGQuery(mock)
+	w = postChat("/stocks/chat")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "generated_sql")
+	assert.NotContains(t, w.Body.String(), "row_count")
+
+	w = postChat("/stocks/chat?debug=maybe")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "debug must be true or false")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockChat_MessageTooLong validates the CHAT_MAX_MESSAGE_LENGTH limit, counted in characters
+func TestGetStockChat_MessageTooLong(t *testing.T) {
+	t.Setenv("CHAT_MAX_MESSAGE_LENGTH", "20")
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/chat", handler.GetStockChat)
+
+	w, _ := performChat(router, ChatRequest{Message: strings.Repeat("a", 21)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "message must be at most 20 characters")
+	assert.Empty(t, fake.requests, "OpenAI must not be called")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Multi-byte characters count once
+	fake.content = "SELECT ticker FROM stock_ratings"
+	expectRAGQuery(mock)
+	w, _ = performChat(router, ChatRequest{Message: strings.Repeat("é", 20)})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetStockChat_RecentMessagesCapped validates that only the last maxRecentMessages reach the prompt
+func TestGetStockChat_RecentMessagesCapped(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{content: "SELECT ticker FROM stock_ratings"}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+	expectRAGQuery(mock)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stocks/chat", handler.GetStockChat)
+
+	var recent []RecentMessage
+	for i := 0; i < 15; i++ {
+		recent = append(recent, RecentMessage{Role: "user", Content: fmt.Sprintf("turn-%02d", i)})
+	}
+	w, _ := performChat(router, ChatRequest{Message: "AAPL", RecentMessages: recent})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	prompt := fake.requests[len(fake.requests)-1].Messages[0].Content
+	for i := 0; i < 15; i++ {
+		if i < 15-maxRecentMessages {
+			assert.NotContains(t, prompt, fmt.Sprintf("turn-%02d", i))
+		} else {
+			assert.Contains(t, prompt, fmt.Sprintf("turn-%02d", i))
+		}
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSessionStore_Expiry validates that sessions disappear after their TTL
+func TestSessionStore_Expiry(t *testing.T) {
+	store := newSessionStore(20 * time.Millisecond)
+	store.Set("a", &ConversationMemory{Summary: "hello"})
+
+	memory, ok := store.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "hello", memory.Summary)
+
+	time.Sleep(30 * time.Millisecond)
+	_, ok = store.Get("a")
+	assert.False(t, ok, "Expired session should be gone")
+}
+
+// CHAT STREAMING TESTS
+
+// newStreamingOpenAI emits the given deltas as OpenAI SSE chunks, followed by a usage chunk and [DONE]
+func newStreamingOpenAI(deltas []string, streamRequested *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		*streamRequested = body.Stream
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range deltas {
+			chunk, _ := json.Marshal(map[string]interface{}{
+				"choices": []map[string]interface{}{{"delta": map[string]string{"content": delta}}},
+			})
+			w.Write([]byte("data: " + string(chunk) + "\n\n"))
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(`data: {"choices": [], "usage": {"total_tokens": 12}}` + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+}
+
+func performChatStream(handler *StockHandler, request ChatRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(request)
+	return serve("POST", "/stocks/chat/stream", "/stocks/chat/stream", handler.GetStockChatStream, bytes.NewBuffer(body))
+}
+
+// TestGetStockChatStream_ForwardsChunks validates that OpenAI deltas are relayed in order, then a done event
+func TestGetStockChatStream_ForwardsChunks(t *testing.T) {
+	var streamRequested bool
+	server := newStreamingOpenAI([]string{"Hel", "lo ", "world"}, &streamRequested)
+	defer server.Close()
+
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	handler := NewStockHandler(db, WithOpenAIClient(newTestOpenAIClient(server.URL)))
+
+	// Cached context for the same topic skips the SQL generation call
+	w := performChatStream(handler, ChatRequest{
+		Message:            "What about AAPL?",
+		ConversationMemory: &ConversationMemory{KeyTopics: []string{"AAPL"}, LastContext: "AAPL upgraded to Buy"},
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, streamRequested, "OpenAI request should ask for a stream")
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+
+	body := w.Body.String()
+	first := strings.Index(body, `data:{"content":"Hel"}`)
+	second := strings.Index(body, `data:{"content":"lo "}`)
+	third := strings.Index(body, `data:{"content":"world"}`)
+	done := strings.Index(body, "event:done")
+	assert.True(t, first >= 0 && first < second && second < third && third < done, "Deltas should be forwarded in order before done: %s", body)
+	assert.Contains(t, body[done:], `"tokens_used":12`)
+	assert.Contains(t, body[done:], `"updated_memory"`)
+}
+
+// TestGetStockChatStream_ErrorBeforeStart validates that failures before the first delta use a JSON error
+func TestGetStockChatStream_ErrorBeforeStart(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	handler := NewStockHandler(db, WithOpenAIClient(&fakeOpenAIClient{err: errOpenAIRateLimited}))
+
+	w := performChatStream(handler, ChatRequest{
+		Message:            "What about AAPL?",
+		ConversationMemory: &ConversationMemory{KeyTopics: []string{"AAPL"}, LastContext: "AAPL upgraded to Buy"},
+	})
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAICostPer1KTokens prices 1,000 OpenAI tokens in USD when AI_COST_PER_1K_TOKENS is not set.
+const defaultAICostPer1KTokens = 0.0004
+
+// AI features whose OpenAI token usage is tracked separately
+const (
+	aiUsageSummary       = "summary"
+	aiUsageChat          = "chat"
+	aiUsageSQLGeneration = "sql_generation"
+)
+
+// AIEndpointUsage is the OpenAI usage of one AI feature
+type AIEndpointUsage struct {
+	Calls  int `json:"calls" example:"12"`
+	Tokens int `json:"tokens" example:"2940"`
+}
+
+// AIUsageResponse reports the OpenAI tokens used since the server started
+type AIUsageResponse struct {
+	TotalCalls       int                        `json:"total_calls" example:"30"`
+	TotalTokens      int                        `json:"total_tokens" example:"7350"`
+	CostPer1KTokens  float64                    `json:"cost_per_1k_tokens" example:"0.0004"`
+	EstimatedCostUSD float64                    `json:"estimated_cost_usd" example:"0.00294"`
+	Endpoints        map[string]AIEndpointUsage `json:"endpoints"`
+	Since            string                     `json:"since" example:"2024-01-15T10:30:00Z"`
+}
+
+// aiUsageTracker sums OpenAI calls and tokens per AI feature in memory.
+// Counts reset when the server restarts. It is safe for concurrent use.
+type aiUsageTracker struct {
+	mu         sync.Mutex
+	since      time.Time
+	byEndpoint map[string]AIEndpointUsage
+}
+
+// newAIUsageTracker creates a tracker with no usage recorded.
+func newAIUsageTracker() *aiUsageTracker {
+	return &aiUsageTracker{
+		since:      time.Now(),
+		byEndpoint: make(map[string]AIEndpointUsage),
+	}
+}
+
+// Record adds one OpenAI call that used tokens to endpoint.
+func (u *aiUsageTracker) Record(endpoint string, tokens int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	usage := u.byEndpoint[endpoint]
+	usage.Calls++
+	usage.Tokens += tokens
+	u.byEndpoint[endpoint] = usage
+	recordOpenAIMetrics(endpoint, tokens)
+}
+
+// Report totals the recorded usage and estimates its cost at costPer1K USD per 1,000 tokens.
+func (u *aiUsageTracker) Report(costPer1K float64) AIUsageResponse {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	report := AIUsageResponse{
+		CostPer1KTokens: costPer1K,
+		Endpoints:       make(map[string]AIEndpointUsage, len(u.byEndpoint)),
+		Since:           u.since.Format(time.RFC3339),
+	}
+	for endpoint, usage := range u.byEndpoint {
+		report.Endpoints[endpoint] = usage
+		report.TotalCalls += usage.Calls
+		report.TotalTokens += usage.Tokens
+	}
+	// Rounded to micro-dollars so float noise doesn't show up in the response
+	report.EstimatedCostUSD = math.Round(float64(report.TotalTokens)/1000*costPer1K*1e6) / 1e6
+	return report
+}
+
+// GetAIUsage reports the OpenAI token usage of the AI features
+// @Summary Get AI token usage
+// @Description Returns the OpenAI calls and tokens used by the summary, chat (including streaming) and SQL generation features since the server started, with an estimated cost at AI_COST_PER_1K_TOKENS USD per 1,000 tokens (default 0.0004).
+// @Tags ai-analysis
+// @Produce json
+// @Success 200 {object} AIUsageResponse "Token usage totals per feature"
+// @Router /ai/usage [get]
+func (h *StockHandler) GetAIUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, h.aiUsage.Report(h.aiCostPer1K))
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// performGetAIUsage performs GET /ai/usage and decodes the response
+func performGetAIUsage(t *testing.T, handler *StockHandler) AIUsageResponse {
+	w := serve("GET", "/ai/usage", "/ai/usage", handler.GetAIUsage, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response AIUsageResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+// TestGetAIUsage_SumsTokensPerFeature validates that AI calls are summed per feature and priced
+// Purpose: Spend can be tracked without digging through OpenAI's dashboard
+func TestGetAIUsage_SumsTokensPerFeature(t *testing.T) {
+	t.Setenv("AI_COST_PER_1K_TOKENS", "0.5")
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	fake := &fakeOpenAIClient{content: "SELECT ticker FROM stock_ratings LIMIT 5", tokens: 100}
+	handler := NewStockHandler(db, WithOpenAIClient(fake))
+
+	ctx := context.Background()
+	handler.generateAISummary(ctx, []StockRecommendation{{Ticker: "AAPL"}})
+	handler.generateChatResponse(ctx, handler.openAIModel, "hi", "", "")
+	fake.tokens = 300
+	handler.generateChatResponse(ctx, handler.openAIModel, "and now?", "", "")
+	handler.generateSQLFromQuestion(ctx, "Top tickers?")
+
+	usage := performGetAIUsage(t, handler)
+
+	assert.Equal(t, 4, usage.TotalCalls)
+	assert.Equal(t, 800, usage.TotalTokens)
+	assert.Equal(t, AIEndpointUsage{Calls: 1, Tokens: 100}, usage.Endpoints[aiUsageSummary])
+	assert.Equal(t, AIEndpointUsage{Calls: 2, Tokens: 400}, usage.Endpoints[aiUsageChat])
+	assert.Equal(t, AIEndpointUsage{Calls: 1, Tokens: 300}, usage.Endpoints[aiUsageSQLGeneration])
+	assert.Equal(t, 0.5, usage.CostPer1KTokens)
+	assert.InDelta(t, 0.4, usage.EstimatedCostUSD, 1e-9)
+	assert.NotEmpty(t, usage.Since)
+}
+
+// TestGetAIUsage_Empty validates the report before any AI call
+func TestGetAIUsage_Empty(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	usage := performGetAIUsage(t, handler)
+
+	assert.Equal(t, 0, usage.TotalTokens)
+	assert.Equal(t, 0.0, usage.EstimatedCostUSD)
+	assert.Equal(t, defaultAICostPer1KTokens, usage.CostPer1KTokens)
+	assert.NotNil(t, usage.Endpoints)
+	assert.Empty(t, usage.Endpoints)
+}
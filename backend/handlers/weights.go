@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scoringWeightsStore holds the default scoring weights used when a request gives no custom weights.
+// It starts with getDefaultWeights and admins may replace them at runtime; changes last until the
+// server restarts. It is safe for concurrent use.
+type scoringWeightsStore struct {
+	mu      sync.RWMutex
+	weights ScoringWeights
+}
+
+// newScoringWeightsStore creates a store holding the built-in default weights.
+func newScoringWeightsStore() *scoringWeightsStore {
+	return &scoringWeightsStore{weights: getDefaultWeights()}
+}
+
+// Get returns the current default weights.
+func (s *scoringWeightsStore) Get() ScoringWeights {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.weights
+}
+
+// Set replaces the default weights, callers validate them first.
+func (s *scoringWeightsStore) Set(weights ScoringWeights) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weights = weights
+}
+
+// GetScoringWeights returns the default scoring weights
+// @Summary Get the default scoring weights
+// @Description Returns the scoring weights recommendations, the summary and comparisons use when no custom weights are given. They start at the built-in defaults (0.4, 0.3, 0.2, 0.1) and can be changed with PUT /admin/scoring-weights.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ScoringWeights "Current default scoring weights"
+// @Router /admin/scoring-weights [get]
+func (h *StockHandler) GetScoringWeights(c *gin.Context) {
+	c.JSON(http.StatusOK, h.scoringWeights.Get())
+}
+
+// UpdateScoringWeights replaces the default scoring weights
+// @Summary Set the default scoring weights
+// @Description Replaces the default scoring weights for every later recommendation, summary and comparison request until the server restarts. Each weight must be between 0 and 1, omitted weights count as 0, and all must sum to 1. The cached AI summary and recommendations are cleared. Requires the X-API-Key header when API_KEY is set.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string false "API key, required when the server has API_KEY set"
+// @Param request body ScoringWeights true "New default weights"
+// @Success 200 {object} ScoringWeights "Weights stored"
+// @Failure 400 {object} models.ErrorResponse "Bad request - invalid JSON, a weight outside 0-1 or weights not summing to 1"
+// @Failure 401 {object} models.ErrorResponse "Missing or invalid API key"
+// @Router /admin/scoring-weights [put]
+func (h *StockHandler) UpdateScoringWeights(c *gin.Context) {
+	var weights ScoringWeights
+	if !bindJSON(c, &weights) {
+		return
+	}
+
+	for _, weight := range []struct {
+		name  string
+		value float64
+	}{
+		{"target_weight", weights.TargetPriceWeight},
+		{"rating_weight", weights.RatingWeight},
+		{"action_weight", weights.ActionWeight},
+		{"timing_weight", weights.TimingWeight},
+	} {
+		if weight.value < 0 || weight.value > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s. Must be a number between 0 and 1", weight.name)})
+			return
+		}
+	}
+	if err := weights.validateWeights(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.scoringWeights.Set(weights)
+	h.summaryCache.Invalidate() // The cached summary was ranked with the old weights
+	h.clearRecommendationsCache(c.Request.Context())
+	h.requestLogger(c).Info("scoring weights updated",
+		"target_weight", weights.TargetPriceWeight, "rating_weight", weights.RatingWeight,
+		"action_weight", weights.ActionWeight, "timing_weight", weights.TimingWeight)
+	c.JSON(http.StatusOK, weights)
+}
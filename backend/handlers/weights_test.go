@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// weightsRouter serves the scoring weights endpoints like main, with the PUT behind the API key
+func weightsRouter(handler *StockHandler, apiKey string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/scoring-weights", handler.GetScoringWeights)
+	router.PUT("/admin/scoring-weights", APIKeyAuth(apiKey), handler.UpdateScoringWeights)
+	router.GET("/stocks/recommendations", handler.GetStockRecommendations)
+	return router
+}
+
+// performPutWeights sends body to PUT /admin/scoring-weights with the given API key
+func performPutWeights(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", "/admin/scoring-weights", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// getWeights performs GET /admin/scoring-weights and decodes the response
+func getWeights(t *testing.T, router *gin.Engine) ScoringWeights {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/scoring-weights", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var weights ScoringWeights
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &weights))
+	return weights
+}
+
+// TestGetScoringWeights_Defaults validates that a new handler reports the built-in default weights
+func TestGetScoringWeights_Defaults(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+
+	assert.Equal(t, getDefaultWeights(), getWeights(t, weightsRouter(handler, "")))
+}
+
+// TestUpdateScoringWeights_Valid validates that stored weights are returned and used by later recommendations
+func TestUpdateScoringWeights_Valid(t *testing.T) {
+	handler, mock, db := setupTestHandler()
+	defer db.Close()
+	router := weightsRouter(handler, "s3cret")
+	handler.summaryCache.Set(SummaryResponse{Summary: "ranked with the old weights"})
+
+	w := performPutWeights(router, "s3cret", `{"target_weight": 0.1, "rating_weight": 0.2, "action_weight": 0.3, "timing_weight": 0.4}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	want := ScoringWeights{TargetPriceWeight: 0.1, RatingWeight: 0.2, ActionWeight: 0.3, TimingWeight: 0.4}
+	assert.Equal(t, want, getWeights(t, router))
+	_, _, cached := handler.summaryCache.Get()
+	assert.False(t, cached, "The cached summary should be cleared")
+
+	// Requests without custom weights now rank with the stored ones, custom query weights still win
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(recommendationRows())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/stocks/recommendations", nil))
+	var response RecommendationsResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, want, response.Weights)
+
+	mock.ExpectQuery("SELECT ticker, company").WillReturnRows(recommendationRows())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/stocks/recommendations?target_weight=1", nil))
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, ScoringWeights{TargetPriceWeight: 1}, response.Weights)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateScoringWeights_Invalid validates that rejected weights leave the current ones in place
+func TestUpdateScoringWeights_Invalid(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+	router := weightsRouter(handler, "s3cret")
+
+	cases := []struct {
+		body    string
+		message string
+	}{
+		{`{"target_weight": 0.5, "rating_weight": 0.5, "action_weight": 0.5}`, "weights must sum to 100%, got 150.0%"},
+		{`{"target_weight": 0.4}`, "weights must sum to 100%"},
+		{`{"target_weight": 1.5, "rating_weight": -0.5}`, "Invalid target_weight. Must be a number between 0 and 1"},
+		{`{"target_weight": "high"}`, "Invalid JSON format in request body"},
+		{`{"target_weight": 0.4,`, "Invalid JSON format in request body"},
+	}
+	for _, tc := range cases {
+		w := performPutWeights(router, "s3cret", tc.body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.body)
+		assert.Contains(t, w.Body.String(), tc.message, tc.body)
+	}
+	assert.Equal(t, getDefaultWeights(), getWeights(t, router))
+}
+
+// TestUpdateScoringWeights_RequiresAPIKey validates that the PUT is protected while the GET stays open
+func TestUpdateScoringWeights_RequiresAPIKey(t *testing.T) {
+	handler, _, db := setupTestHandler()
+	defer db.Close()
+	router := weightsRouter(handler, "s3cret")
+
+	w := performPutWeights(router, "", `{"target_weight": 1}`)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, getDefaultWeights(), getWeights(t, router))
+}
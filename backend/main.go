@@ -6,12 +6,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"smart-stock-recommender/config"
 	"smart-stock-recommender/database"
 	_ "smart-stock-recommender/docs"
 	"smart-stock-recommender/handlers"
+	"strings"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -26,19 +34,40 @@ func main() {
 		log.Println("No .env file found")
 	}
 
+	// Fail fast on missing configuration instead of a confusing connection error later
+	missing, warnings := config.Validate(os.Getenv)
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+	if len(missing) > 0 {
+		log.Fatalf("Missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	// Structured logs go to stdout as JSON, LOG_LEVEL=debug turns on per-page and per-batch records
+	logLevel, ok := config.ParseLogLevel(os.Getenv("LOG_LEVEL"))
+	if !ok {
+		log.Printf("Warning: unknown LOG_LEVEL %q, using info", os.Getenv("LOG_LEVEL"))
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+
+	// RATING_MAP adds broker-specific rating terms to the bullish/neutral/bearish classification
+	if err := handlers.LoadRatingMapFromEnv(); err != nil {
+		log.Fatalf("Invalid rating map: %v", err)
+	}
+
 	// Connect to database
 	db, err := database.Connect()
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer db.Close()
 
 	// Create tables
 	createTables(db)
 
 	// Initialize handlers
-	stockHandler := handlers.NewStockHandler(db)
+	stockHandler := handlers.NewStockHandler(db, handlers.WithLogger(logger))
 	securityHandler := handlers.NewSecurityHandler()
+	healthHandler := handlers.NewHealthHandler(db)
 
 	// Setup router
 	// gin.SetMode(gin.ReleaseMode)
@@ -46,11 +75,18 @@ func main() {
 	r := gin.New()
 	r.Use(gin.Logger(), gin.Recovery())
 
+	// Request counts and latencies per route for GET /metrics
+	r.Use(handlers.PrometheusMiddleware())
+
+	// X-Request-ID on every response and request_id in error bodies and logs, to match a reported error to its log line
+	r.Use(handlers.RequestID(logger))
+
 	// Enable CORS
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, X-API-Key, If-None-Match, Idempotency-Key, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "ETag, X-Request-ID")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -58,28 +94,67 @@ func main() {
 		c.Next()
 	})
 
+	// Cap request bodies so an oversized upload can't exhaust memory, MAX_REQUEST_BYTES defaults to 1MB
+	r.Use(handlers.BodySizeLimit(handlers.MaxRequestBytesFromEnv()))
+
+	// Health probes stay outside /api so infrastructure can reach them directly
+	r.GET("/healthz", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
+
+	// Prometheus scrape endpoint, also outside /api
+	r.GET("/metrics", handlers.MetricsHandler())
+
+	// Live feed of newly inserted ratings for the dashboard
+	r.GET("/ws", stockHandler.StreamRatings)
+
 	// Swagger documentation route
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// API Routes from the Go Server
+	// Mutating endpoints require X-API-Key when API_KEY is set
+	requireAPIKey := handlers.APIKeyAuth(os.Getenv("API_KEY"))
+
 	api := r.Group("/api")
 	{
 		// Stock-related endpoints
-		api.POST("/stocks", stockHandler.GetStocksByPage)
-		api.POST("/stocks/bulk", stockHandler.GetStocksBulk)
+		api.POST("/stocks", requireAPIKey, stockHandler.GetStocksByPage)
+		api.POST("/stocks/bulk", requireAPIKey, stockHandler.GetStocksBulk)
+		api.POST("/stocks/import", requireAPIKey, stockHandler.ImportStocks)
+		api.GET("/stocks/bulk/:job_id/status", stockHandler.GetBulkJobStatus)
+		api.GET("/stocks/random", stockHandler.GetRandomStocks)
+		api.DELETE("/stocks/:ticker", requireAPIKey, stockHandler.DeleteStockByTicker)
+		api.GET("/stocks/:id", stockHandler.GetStockByID)
+		api.PUT("/stocks/:id", requireAPIKey, stockHandler.UpdateStockByID)
 		api.POST("/stocks/list", stockHandler.GetStockRatings)
 		api.POST("/stocks/search", stockHandler.SearchStockRatings)
+		api.POST("/stocks/export", stockHandler.ExportStockRatings)
+		api.POST("/stocks/count", stockHandler.GetStockCount)
 		api.GET("/stocks/actions", stockHandler.GetStockActions)
+		api.GET("/stocks/ticker/:ticker", stockHandler.GetStockHistoryByTicker)
 		api.GET("/stocks/filter-options", stockHandler.GetFilterOptions)
+		api.GET("/stocks/sectors", stockHandler.GetStockSectors)
 		api.GET("/stocks/recommendations", stockHandler.GetStockRecommendations)
+		api.POST("/stocks/recommendations/refresh", requireAPIKey, stockHandler.RefreshRecommendationsCache)
 		api.GET("/stocks/summary", stockHandler.GetStockSummary)
 		api.POST("/stocks/chat", stockHandler.GetStockChat)
+		api.POST("/stocks/chat/stream", stockHandler.GetStockChatStream)
+		api.GET("/stocks/consensus", stockHandler.GetStockConsensus)
+		api.GET("/stocks/trending", stockHandler.GetTrendingStocks)
+		api.GET("/stocks/compare", stockHandler.CompareStocks)
+		api.GET("/stocks/brokerage/:name", stockHandler.GetBrokerageStats)
+		api.GET("/stocks/stats/daily", stockHandler.GetDailyStats)
 		api.GET("/stocks/metrics", stockHandler.GetStockMetrics)
+		api.POST("/stocks/metrics/refresh", requireAPIKey, stockHandler.RefreshStockMetrics)
+		api.GET("/ai/usage", stockHandler.GetAIUsage)
+		api.GET("/admin/scoring-weights", stockHandler.GetScoringWeights)
+		api.PUT("/admin/scoring-weights", requireAPIKey, stockHandler.UpdateScoringWeights)
+		api.GET("/debug/external-status", stockHandler.GetExternalStatus)
 
 		// Security demonstration endpoints
 		security := api.Group("/security")
 		{
 			security.POST("/bulk-timing-attack", securityHandler.BulkTimingAttack)
+			security.POST("/secure-login", securityHandler.SecureLogin)
 		}
 	}
 
@@ -89,9 +164,44 @@ func main() {
 		port = "8081"
 	}
 
-	// Start server
-	log.Printf("Server starting on port %s", port)
-	r.Run(":" + port)
+	// Start server in the background so the main goroutine can wait for shutdown signals
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
+	go func() {
+		log.Printf("Server starting on port %s", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server failed:", err)
+		}
+	}()
+
+	// Optional periodic ingest, AUTO_REFRESH_INTERVAL_MINUTES enables it
+	autoRefreshCtx, stopAutoRefresh := context.WithCancel(context.Background())
+	autoRefreshDone := stockHandler.StartAutoRefresh(autoRefreshCtx)
+
+	// Wait for Ctrl+C or SIGTERM (sent on deploys)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	sig := <-quit
+	log.Printf("Received %s, draining in-flight requests (timeout %s)", sig, shutdownTimeout)
+
+	// A running auto refresh stops fetching and stores what it already has
+	stopAutoRefresh()
+
+	// Let active requests such as bulk inserts finish before closing the database
+	drained, err := shutdownServer(context.Background(), server, shutdownTimeout)
+	if err != nil {
+		log.Printf("Forced shutdown after %.1fs: %v", drained.Seconds(), err)
+	} else {
+		log.Printf("Server drained in %.1fs", drained.Seconds())
+	}
+
+	<-autoRefreshDone
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+	log.Println("Server stopped")
 }
 
 // createTables creates the necessary tables in the database if they do not exist.
@@ -110,6 +220,9 @@ func createTables(db *sql.DB) {
 		rating_to VARCHAR(50),
 		time TIMESTAMP,
 		created_at TIMESTAMP DEFAULT NOW(),
+		sector VARCHAR(100),
+		target_from_num NUMERIC,
+		target_to_num NUMERIC,
 		UNIQUE(ticker, brokerage, action, rating_from, rating_to, time)
 	)`
 
@@ -117,4 +230,67 @@ func createTables(db *sql.DB) {
 	if _, err := db.Exec(query); err != nil {
 		log.Fatal("Failed to create table:", err)
 	}
+
+	// Tables created before the sector column existed get it added, existing rows keep a NULL sector
+	if _, err := db.Exec(`ALTER TABLE stock_ratings ADD COLUMN IF NOT EXISTS sector VARCHAR(100)`); err != nil {
+		log.Fatal("Failed to add sector column:", err)
+	}
+
+	// Numeric copies of the target prices so price filters don't re-parse "$1,250.00" strings on every row
+	if _, err := db.Exec(`ALTER TABLE stock_ratings ADD COLUMN IF NOT EXISTS target_from_num NUMERIC, ADD COLUMN IF NOT EXISTS target_to_num NUMERIC`); err != nil {
+		log.Fatal("Failed to add numeric target columns:", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_stock_ratings_target_to_num ON stock_ratings (target_to_num)`); err != nil {
+		log.Fatal("Failed to create target_to_num index:", err)
+	}
+
+	// Ticker lookups compare UPPER(ticker) since imported tickers keep the case they were sent in
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_stock_ratings_upper_ticker ON stock_ratings (UPPER(ticker))`); err != nil {
+		log.Fatal("Failed to create ticker index:", err)
+	}
+
+	// Backfill rows stored before the numeric columns existed, targets that aren't numbers stay NULL
+	backfill := `
+	UPDATE stock_ratings SET
+		target_from_num = CASE WHEN target_from ~ '^\$?[0-9][0-9,]*(\.[0-9]+)?$' THEN CAST(REPLACE(REPLACE(target_from, '$', ''), ',', '') AS NUMERIC) END,
+		target_to_num = CASE WHEN target_to ~ '^\$?[0-9][0-9,]*(\.[0-9]+)?$' THEN CAST(REPLACE(REPLACE(target_to, '$', ''), ',', '') AS NUMERIC) END
+	WHERE target_from_num IS NULL AND target_to_num IS NULL`
+	if _, err := db.Exec(backfill); err != nil {
+		log.Fatal("Failed to backfill numeric target columns:", err)
+	}
+
+	// Precomputed default-weight recommendations, one row per ticker, rebuilt after bulk ingests.
+	// details holds the full recommendation so cached responses match live ones.
+	cache := `
+	CREATE TABLE IF NOT EXISTS recommendations_cache (
+		ticker VARCHAR(10) PRIMARY KEY,
+		score DOUBLE PRECISION NOT NULL,
+		recommendation VARCHAR(50) NOT NULL,
+		reason TEXT NOT NULL,
+		target VARCHAR(20) NOT NULL,
+		details JSONB NOT NULL,
+		ratings_analyzed INTEGER NOT NULL,
+		generated_at TIMESTAMPTZ NOT NULL
+	)`
+	if _, err := db.Exec(cache); err != nil {
+		log.Fatal("Failed to create recommendations cache table:", err)
+	}
+
+	createSearchIndexes(db)
+}
+
+// createSearchIndexes adds trigram indexes so the search_term ILIKE '%term%' filter doesn't scan the whole table.
+// Search still works without them (just slower), so a database where pg_trgm can't be enabled only logs a warning.
+func createSearchIndexes(db *sql.DB) {
+	if _, err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`); err != nil {
+		log.Printf("Skipping trigram search indexes, pg_trgm unavailable: %v", err)
+		return
+	}
+
+	for _, column := range []string{"ticker", "company", "brokerage"} {
+		query := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_stock_ratings_%s_trgm ON stock_ratings USING GIN (%s gin_trgm_ops)`, column, column)
+		if _, err := db.Exec(query); err != nil {
+			log.Printf("Failed to create trigram index on %s: %v", column, err)
+		}
+	}
 }
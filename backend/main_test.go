@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCreateSearchIndexes validates that trigram indexes are created for the searchable text columns
+func TestCreateSearchIndexes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE EXTENSION IF NOT EXISTS pg_trgm")).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, column := range []string{"ticker", "company", "brokerage"} {
+		mock.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS idx_stock_ratings_" + column + "_trgm ON stock_ratings USING GIN (" + column + " gin_trgm_ops)")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	createSearchIndexes(db)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCreateSearchIndexes_ExtensionUnavailable validates that startup continues without indexes when pg_trgm can't be enabled
+func TestCreateSearchIndexes_ExtensionUnavailable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE EXTENSION IF NOT EXISTS pg_trgm")).WillReturnError(errors.New("permission denied"))
+
+	createSearchIndexes(db)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -15,10 +15,40 @@ type StockResponse struct {
 
 // BulkResponse represents bulk operation response
 type BulkResponse struct {
-	Message      string         `json:"message" example:"Successfully fetched and stored stock data"`
-	PagesFetched string         `json:"pages_fetched" example:"1-1000"`
-	Stocks       []StockRatings `json:"stocks"`
-	TotalStocks  int            `json:"total_stocks" example:"7860"`
+	Message           string         `json:"message" example:"Successfully fetched and stored stock data"`
+	PagesFetched      string         `json:"pages_fetched" example:"1-1000"`
+	Stocks            []StockRatings `json:"stocks"`
+	TotalStocks       int            `json:"total_stocks" example:"7860"`
+	Truncated         bool           `json:"stocks_truncated" example:"true"`
+	Cancelled         bool           `json:"cancelled" example:"false"`
+	TimedOut          bool           `json:"timed_out" example:"false"`
+	PagesProcessed    int            `json:"pages_processed" example:"1000"`
+	DuplicatesDropped int            `json:"duplicates_dropped" example:"120"`
+	TotalInserted     int            `json:"total_inserted" example:"7500"`
+	TotalDuplicates   int            `json:"total_duplicates" example:"240"`
+	Inserted          int            `json:"inserted" example:"7500"`
+	DuplicatesSkipped int            `json:"duplicates_skipped" example:"360"`
+	Cleared           bool           `json:"cleared" example:"false"`
+}
+
+// BulkJobResponse represents a bulk fetch started as a background job
+type BulkJobResponse struct {
+	JobID     string `json:"job_id" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	Status    string `json:"status" example:"running"`
+	StatusURL string `json:"status_url" example:"/api/stocks/bulk/9f86d081884c7d659a2feaa0c55ad015/status"`
+}
+
+// BulkJobStatusResponse represents the progress of a background bulk fetch
+type BulkJobStatusResponse struct {
+	JobID          string `json:"job_id" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	Status         string `json:"status" example:"running" enums:"running,done,error"`
+	PagesProcessed int    `json:"pages_processed" example:"420"`
+	PagesTotal     int    `json:"pages_total" example:"1000"`
+	PagesWithData  int    `json:"pages_with_data" example:"400"`
+	// Error is set when status is error
+	Error string `json:"error,omitempty" example:"failed to fetch page 12: unexpected status 500"`
+	// Result is set when status is done
+	Result *BulkResponse `json:"result,omitempty"`
 }
 
 // PaginationMeta represents pagination metadata
@@ -29,6 +59,10 @@ type PaginationMeta struct {
 	TotalPages   int  `json:"total_pages" example:"126"`
 	HasNext      bool `json:"has_next" example:"true"`
 	HasPrevious  bool `json:"has_previous" example:"false"`
+	// OutOfRange is set when page_number is past the last page, data is then empty
+	OutOfRange bool `json:"out_of_range,omitempty" example:"false"`
+	// NextCursor is set when more rows follow in the default created_at DESC order
+	NextCursor *Cursor `json:"next_cursor,omitempty"`
 }
 
 // PaginatedResponse represents paginated stock ratings response
@@ -44,6 +78,46 @@ type TargetChanges struct {
 	Maintained int `json:"maintained" example:"520"`
 }
 
+// TargetPriceStats compares the numeric previous and new target prices of every rating where both are numbers
+type TargetPriceStats struct {
+	Compared             int     `json:"compared" example:"2410"` // Ratings with numeric target_from and target_to
+	Increases            int     `json:"increases" example:"1310"`
+	Decreases            int     `json:"decreases" example:"750"`
+	Unchanged            int     `json:"unchanged" example:"350"`
+	AverageChangePercent float64 `json:"average_change_percent" example:"4.25"` // 0 when nothing was compared
+	LargestRaisePercent  float64 `json:"largest_raise_percent" example:"85.5"`  // Biggest single increase, 0 when there is none
+}
+
+// RatingShare is how often one rating appears among all stored ratings
+type RatingShare struct {
+	Rating     string  `json:"rating" example:"Buy"`
+	Count      int     `json:"count" example:"820"`
+	Percentage float64 `json:"percentage" example:"32.54"` // Share of RatingDistribution.Total, rounded to 2 decimals
+}
+
+// OtherRatings aggregates the ratings beyond the top ones listed in RatingDistribution
+type OtherRatings struct {
+	Ratings    int     `json:"ratings" example:"14"` // Distinct ratings in the bucket
+	Count      int     `json:"count" example:"95"`
+	Percentage float64 `json:"percentage" example:"3.77"`
+}
+
+// RatingDistribution lists the most common ratings, most frequent first, with their share of the total
+type RatingDistribution struct {
+	Total   int           `json:"total" example:"2520"` // Ratings with a non-empty rating_to
+	Ratings []RatingShare `json:"ratings"`
+	Other   OtherRatings  `json:"other"`
+}
+
+// DataFreshness tells how current the stored ratings are, every field is null when none are stored
+type DataFreshness struct {
+	OldestReportTime     *time.Time `json:"oldest_report_time" example:"2024-06-03T13:30:00Z"`
+	NewestReportTime     *time.Time `json:"newest_report_time" example:"2025-01-15T13:30:00Z"`
+	OldestCreatedAt      *time.Time `json:"oldest_created_at" example:"2025-01-10T08:00:00Z"`
+	NewestCreatedAt      *time.Time `json:"newest_created_at" example:"2025-01-15T14:00:00Z"` // Last ingest
+	HoursSinceLastIngest *float64   `json:"hours_since_last_ingest" example:"2.5"`
+}
+
 // MarketSentiment represents market sentiment analysis
 type MarketSentiment struct {
 	BullishCount      int     `json:"bullish_count" example:"1400"`
@@ -71,27 +145,34 @@ type ActiveStock struct {
 type MetricsData struct {
 	TotalRecords        int                          `json:"total_records" example:"2520"`
 	TargetChanges       TargetChanges                `json:"target_changes"`
+	TargetPriceStats    TargetPriceStats             `json:"target_price_stats"`
 	MarketSentiment     MarketSentiment              `json:"market_sentiment"`
-	RatingDistribution  map[string]int               `json:"rating_distribution"`
+	RatingDistribution  RatingDistribution           `json:"rating_distribution"`
 	TopBrokerages       []BrokerageActivity          `json:"top_brokerages"`
 	MostActiveStocks    []ActiveStock                `json:"most_active_stocks"`
 	RecentActivity      int                          `json:"recent_activity" example:"125"`
+	DataFreshness       DataFreshness                `json:"data_freshness"`
 	GeneratedAt         time.Time                    `json:"generated_at" example:"2025-01-15T10:30:00Z"`
 	Description         string                       `json:"description" example:"Comprehensive stock market analytics based on analyst ratings and target price changes"`
 }
 
 // MetricsResponse represents metrics endpoint response
 type MetricsResponse struct {
-	Success bool        `json:"success" example:"true"`
-	Metrics MetricsData `json:"metrics"`
+	Success         bool        `json:"success" example:"true"`
+	Metrics         MetricsData `json:"metrics"`
+	Cached          bool        `json:"cached" example:"true"`
+	CacheAgeSeconds float64     `json:"cache_age_seconds" example:"12.4"`
+	Refreshing      bool        `json:"refreshing" example:"false"`
 }
 
 // ErrorResponse represents error response
 type ErrorResponse struct {
-	Error string `json:"error" example:"Invalid JSON format in request body"`
+	Error     string `json:"error" example:"Invalid JSON format in request body"`
+	RequestID string `json:"request_id" example:"3f2a9c1e7b4d4e6f8a0b1c2d3e4f5a6b"` // Also sent as the X-Request-ID header
 }
 
 // GenericErrorResponse represents generic server error response
 type GenericErrorResponse struct {
-	Error string `json:"error" example:"Internal server error occurred"`
+	Error     string `json:"error" example:"Internal server error occurred"`
+	RequestID string `json:"request_id" example:"3f2a9c1e7b4d4e6f8a0b1c2d3e4f5a6b"` // Quote it when reporting the error
 }
\ No newline at end of file
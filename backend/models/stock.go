@@ -20,6 +20,8 @@ type StockRatings struct {
 	RatingTo   string    `json:"rating_to" db:"rating_to" example:"Strong Buy"`
 	Time       time.Time `json:"time" db:"time" example:"2025-01-15T10:30:00Z"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at" example:"2025-01-15T10:35:00Z"`
+	// Sector is optional, it is only stored when the external API provides it
+	Sector string `json:"sector,omitempty" db:"sector" example:"Technology"`
 }
 
 // ApiResponse represents the response from the external stock API.
@@ -36,11 +38,30 @@ type PageRequest struct {
 type BulkPageRequest struct {
 	StartPage int `json:"start_page" binding:"required" example:"1"`
 	EndPage   int `json:"end_page" binding:"required" example:"100"`
+	// ConfirmClear must be true to delete every stored rating before fetching; otherwise new ratings are added on top
+	ConfirmClear bool `json:"confirm_clear,omitempty" example:"false"`
+	// Optional tuning for this request, capped at BULK_BATCH_SIZE and BULK_MAX_CONCURRENT
+	BatchSize     int `json:"batch_size,omitempty" example:"500"`
+	MaxConcurrent int `json:"max_concurrent,omitempty" example:"10"`
+	// Optional time limit in seconds for this request, capped at BULK_DEADLINE_SECONDS when that is set
+	DeadlineSeconds int `json:"deadline_seconds,omitempty" example:"300"`
 }
 
 type PaginationRequest struct {
-	PageNumber int `json:"page_number" binding:"required" example:"1"`
-	PageLength int `json:"page_length" binding:"required" example:"20"`
+	// PageNumber is required unless a cursor (after_id, after_created_at) is given
+	PageNumber int    `json:"page_number" binding:"required_without_all=AfterID AfterCreatedAt" example:"1"`
+	PageLength int    `json:"page_length" binding:"required" example:"20"`
+	SortBy     string `json:"sort_by,omitempty" example:"created_at" enums:"created_at,time,ticker,company"`
+	SortOrder  string `json:"sort_order,omitempty" example:"desc" enums:"asc,desc"`
+	// Keyset pagination: when set, rows older than this cursor are returned instead of using page_number
+	AfterID        int        `json:"after_id,omitempty" example:"2500"`
+	AfterCreatedAt *time.Time `json:"after_created_at,omitempty" example:"2025-01-15T10:35:00Z"`
+}
+
+// Cursor identifies the last row of a page for keyset pagination.
+type Cursor struct {
+	AfterID        int       `json:"after_id" example:"2500"`
+	AfterCreatedAt time.Time `json:"after_created_at" example:"2025-01-15T10:35:00Z"`
 }
 
 type SearchRequest struct {
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout bounds how long in-flight requests (e.g. bulk inserts) may take to drain on shutdown
+const shutdownTimeout = 30 * time.Second
+
+// shutdownServer stops the server from accepting new connections and waits for active requests
+// to finish, giving up after timeout. It returns how long the drain took.
+func shutdownServer(ctx context.Context, server *http.Server, timeout time.Duration) (time.Duration, error) {
+	shutdownCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := server.Shutdown(shutdownCtx)
+	return time.Since(start), err
+}
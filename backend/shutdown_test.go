@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startSlowServer serves a handler that takes delay to answer and signals once a request is in flight
+func startSlowServer(t *testing.T, delay time.Duration) (*http.Server, string, chan struct{}) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	started := make(chan struct{}, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+	})}
+	go server.Serve(listener)
+
+	return server, "http://" + listener.Addr().String(), started
+}
+
+// TestShutdownServer_DrainsInFlightRequests validates that active requests complete before shutdown returns
+func TestShutdownServer_DrainsInFlightRequests(t *testing.T) {
+	server, url, started := startSlowServer(t, 200*time.Millisecond)
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	drained, err := shutdownServer(context.Background(), server, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, <-status, "In-flight request should finish successfully")
+	assert.Greater(t, drained, 100*time.Millisecond, "Shutdown should wait for the request")
+
+	_, err = http.Get(url)
+	assert.Error(t, err, "New connections should be refused after shutdown")
+}
+
+// TestShutdownServer_Timeout validates that shutdown gives up once the timeout expires
+func TestShutdownServer_Timeout(t *testing.T) {
+	server, url, started := startSlowServer(t, time.Second)
+	defer server.Close()
+
+	go http.Get(url)
+	<-started
+
+	drained, err := shutdownServer(context.Background(), server, 50*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, drained, time.Second)
+}